@@ -2,15 +2,16 @@ package ipk
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
-	"errors"
+	"fmt"
 	"math"
-	"time"
 )
 
-const anlErrorNoConnection = `Нет соединения с ФАС-3`
-const anlErrorWrongParam = `Неверный параметр функции`
-const anlErrorNoDevice = `FreqDevice == nil`
+var anlErrorNoConnection = newError(ErrNotConnected, `Нет соединения с ФАС-3`, `No connection to FAS-3`)
+var anlErrorWrongParam = newError(ErrInvalidParam, `Неверный параметр функции`, `Invalid function parameter`)
+var anlErrorNoDevice = newError(ErrNotInitialized, `AnalogDevice == nil`, `AnalogDevice == nil`)
+
 const analogCount = 14
 
 // 14 ЦАПов
@@ -31,6 +32,12 @@ const (
 	DAC14
 )
 
+// AnalogDevice это тип для работы с ФАС-3
+type AnalogDevice struct {
+	usbConnection
+	idProductVariant uint16
+}
+
 type analogDeviceData struct {
 	analog [analogCount]uint16
 	freq   [freqCount]uint16
@@ -100,35 +107,27 @@ func (dev *AnalogDevice) GetProductID() uint16 {
 // Параметр ch - номер канала. Значение от ipk.DAC1 до ipk.DAC14.
 // Параметр val - значение для вывода на ЦАП. Значение следует получить
 // с помощью одной из функций: MilliAmperToDAC, AtToDAC, KiloPascalToDAC
-func (dev *AnalogDevice) setDAC(ch uint8, val uint16) (err error) {
+func (dev *AnalogDevice) setDAC(ctx context.Context, ch uint8, val uint16) (err error) {
 	if nil == dev {
-		err = errors.New("setDAC():" + anlErrorNoDevice)
+		err = fmt.Errorf("setDAC():%w", anlErrorNoDevice)
 		return
 	}
 	if ch >= analogCount {
-		err = errors.New("setDAC():" + anlErrorWrongParam)
+		err = fmt.Errorf("setDAC():%w", anlErrorWrongParam)
 		return
 	}
 
-	// делаем несколько попыток, так как обращение по USB иногда приводит к ошибке device not functioning
-
-	ok := false
-	timeout := false
-	t := time.Now()
-	for !ok && !timeout {
+	err = dev.retry(ctx, func(ctx context.Context) (err error) {
+		dev.mutexRMW.Lock()
+		defer dev.mutexRMW.Unlock()
 		var as analogDeviceData
-		err = dev.getDataUSB(&as)
+		err = dev.getDataUSB(ctx, &as)
 		if nil == err {
 			as.analog[ch] = val
-			err = dev.setDataUSB(&as)
+			err = dev.setDataUSB(ctx, &as)
 		}
-		ok = (nil == err)
-		timeout = time.Since(t) >= maxDelayUSB
-	}
-
-	if ok && timeout {
-		err = errors.New("setDAC():" + errUsbTimeout)
-	}
+		return
+	})
 
 	return
 }
@@ -136,20 +135,23 @@ func (dev *AnalogDevice) setDAC(ch uint8, val uint16) (err error) {
 // getOutputDAC позволяет узнать, какое значение установлено в данный момент
 // на одном из каналов ЦАП ФАС-3.
 // Параметр ch - номер канала. Значение от ipk.DAC1 до ipk.DAC14.
-func (dev *AnalogDevice) getOutputDAC(ch uint8) (val uint16, err error) {
+func (dev *AnalogDevice) getOutputDAC(ctx context.Context, ch uint8) (val uint16, err error) {
 	if nil == dev {
-		err = errors.New("getOutputDAC():" + anlErrorNoDevice)
+		err = fmt.Errorf("getOutputDAC():%w", anlErrorNoDevice)
 		return
 	}
 	if ch >= analogCount {
-		err = errors.New("getOutputDAC():" + anlErrorWrongParam)
+		err = fmt.Errorf("getOutputDAC():%w", anlErrorWrongParam)
 		return
 	}
-	var as analogDeviceData
-	err = dev.getDataUSB(&as)
-	if nil == err {
-		val = as.analog[ch]
-	}
+	err = dev.retry(ctx, func(ctx context.Context) (err error) {
+		var as analogDeviceData
+		err = dev.getDataUSB(ctx, &as)
+		if nil == err {
+			val = as.analog[ch]
+		}
+		return
+	})
 	return
 }
 
@@ -181,6 +183,20 @@ func ValueToMa(val, maxVal float64, minMilliAmper uint16, maxMilliAmper uint16)
 	return
 }
 
+// MaToValue переводит мА из заданного диапазона в величину (обратное ValueToMa)
+// milliAmper - значение в мА.
+// maxVal - максимальное значение величины.
+// minMilliAmper, maxMilliAmper - диапазон мА (0-5, 4-20).
+func MaToValue(milliAmper, maxVal float64, minMilliAmper uint16, maxMilliAmper uint16) (val float64) {
+	if (0 >= maxVal) || (minMilliAmper >= maxMilliAmper) {
+		return
+	}
+
+	diap := float64(maxMilliAmper) - float64(minMilliAmper) // диапазон изменения миллиампер
+	val = (milliAmper - float64(minMilliAmper)) * maxVal / diap
+	return
+}
+
 // AtToDAC переводит давление ат в значение для ЦАП.
 // Под "ат" подразумевается килограмм-сила на квадратный сантиметр (кгс/см²) также называемая технической атмосферой.
 // at - требуемое значение давления.
@@ -216,23 +232,23 @@ func KiloPascalToDAC(kiloPascal, maxKiloPascal float64, maxDAC uint16, minMilliA
 
 ///////////////////////////////////////////////////////////////
 
-func (dev *AnalogDevice) getDataUSB(data *analogDeviceData) (err error) {
+func (dev *AnalogDevice) getDataUSB(ctx context.Context, data *analogDeviceData) (err error) {
 	if nil == data || nil == dev {
-		err = errors.New("AnalogDevice.getDataUSB():" + anlErrorWrongParam)
+		err = fmt.Errorf("AnalogDevice.getDataUSB():%w", anlErrorWrongParam)
 		return
 	}
 
 	if !dev.opened() {
-		err = errors.New("AnalogDevice.getDataUSB():" + anlErrorNoConnection)
+		err = fmt.Errorf("AnalogDevice.getDataUSB():%w", anlErrorNoConnection)
 		return
 	}
 
 	asbytes := make([]byte, data.Size())
 
-	err = dev.deviceIoControl(VendorRequestInput, 0xB0, asbytes, len(asbytes))
+	err = dev.deviceIoControl(ctx, VendorRequestInput, 0xB0, asbytes, len(asbytes))
 
 	if nil != err {
-		err = errors.New("AnalogDevice.getDataUSB():" + err.Error())
+		err = fmt.Errorf("AnalogDevice.getDataUSB():%w", err)
 		return
 	}
 
@@ -241,40 +257,193 @@ func (dev *AnalogDevice) getDataUSB(data *analogDeviceData) (err error) {
 	return
 }
 
-func (dev *AnalogDevice) setDataUSB(data *analogDeviceData) (err error) {
+func (dev *AnalogDevice) setDataUSB(ctx context.Context, data *analogDeviceData) (err error) {
 	if nil == data || nil == dev {
-		err = errors.New("AnalogDevice.setDataUSB():" + anlErrorWrongParam)
+		err = fmt.Errorf("AnalogDevice.setDataUSB():%w", anlErrorWrongParam)
 		return
 	}
 
 	if !dev.opened() {
-		err = errors.New("AnalogDevice.setDataUSB():" + anlErrorNoConnection)
+		err = fmt.Errorf("AnalogDevice.setDataUSB():%w", anlErrorNoConnection)
 		return
 	}
 
 	asbytes := data.toBytes()
 
-	err = dev.deviceIoControl(VendorRequestOutput, 0xB0, asbytes, len(asbytes))
+	err = dev.deviceIoControl(ctx, VendorRequestOutput, 0xB0, asbytes, len(asbytes))
 
 	if nil != err {
-		err = errors.New("AnalogDevice.setDataUSB():" + err.Error())
+		err = fmt.Errorf("AnalogDevice.setDataUSB():%w", err)
 	}
 	return
 }
 
+// Потокобезопасный обмен данными с микроконтроллером.
+func (dev *AnalogDevice) deviceIoControl(ctx context.Context, direction, request byte, bytes []byte, length int) (err error) {
+	if nil == dev {
+		err = fmt.Errorf("deviceIoControl():%w", anlErrorNoDevice)
+		return
+	}
+	err = dev.transfer(ctx, direction, request, bytes, length)
+	// команды обновления прошивки для восстановления не запоминаются
+	if nil == err && VendorRequestOutput == direction && 0xB4 != request {
+		dev.remember(int(request), request, bytes[:length])
+	}
+	return
+}
+
+// opened показывает открыто ли соединение с ФАС-3
+func (dev *AnalogDevice) opened() bool {
+	if nil == dev {
+		return false
+	}
+	return dev.connected()
+}
+
+/////////////////////ИНТЕРФЕЙСНЫЕ ФУНКЦИИ/////////////////////
+
 // Open соединиться с ФАС-3
 func (dev *AnalogDevice) Open() (ok bool) {
 	if dev == nil {
 		return
 	}
-	dev.handle, ok = USBOpen(IDProductANL12bit)
-	if ok {
-		dev.idProductVariant = IDProductANL12bit
-	} else {
-		dev.handle, ok = USBOpen(IDProductANL16bit)
+	for _, idProduct := range []uint16{IDProductANL12bit, IDProductANL16bit} {
+		product := idProduct
+		reopen := func() (Transport, bool) { return OpenUSBTransport(product) }
+		var t Transport
+		t, ok = reopen()
 		if ok {
-			dev.idProductVariant = IDProductANL16bit
+			dev.attach(t, reopen)
+			dev.idProductVariant = product
+			dev.detectCapabilities(context.Background(), product, dev.GetVersionCtx)
+			return
 		}
 	}
 	return
 }
+
+// OpenBy соединиться с конкретной платой ФАС-3 из списка Enumerate.
+func (dev *AnalogDevice) OpenBy(info DeviceInfo) (ok bool) {
+	if dev == nil {
+		return
+	}
+	switch info.ProductID {
+	case IDProductANL12bit, IDProductANL16bit:
+	default:
+		return
+	}
+	reopen := func() (Transport, bool) { return OpenUSBTransportBy(info) }
+	var t Transport
+	t, ok = reopen()
+	if ok {
+		dev.attach(t, reopen)
+		dev.idProductVariant = info.ProductID
+		dev.detectCapabilities(context.Background(), info.ProductID, dev.GetVersionCtx)
+	}
+	return
+}
+
+// OpenTransport соединиться с ФАС-3 через заданный транспорт.
+// idProduct - вариант ФАС-3 (IDProductANL12bit или IDProductANL16bit).
+// Обмена с платой при этом нет, поэтому возможности платы определяются только по варианту;
+// чтобы уточнить их по версии прошивки, вызовите DetectCapabilities.
+func (dev *AnalogDevice) OpenTransport(t Transport, idProduct uint16) (ok bool) {
+	if dev == nil || t == nil {
+		return
+	}
+	switch idProduct {
+	case IDProductANL12bit, IDProductANL16bit:
+	default:
+		return
+	}
+	dev.attach(t, nil)
+	dev.idProductVariant = idProduct
+	dev.detectCapabilities(context.Background(), idProduct, nil)
+	ok = true
+	return
+}
+
+// Close закрыть соединение с ФАС-3
+func (dev *AnalogDevice) Close() {
+	if dev == nil {
+		return
+	}
+	dev.close()
+}
+
+// Active показывает активно ли соединение с ФАС-3
+func (dev *AnalogDevice) Active() (ok bool) {
+	if dev == nil {
+		return
+	}
+	return dev.present()
+}
+
+// SetRetryPolicy задаёт политику повторных попыток обмена с ФАС-3
+func (dev *AnalogDevice) SetRetryPolicy(p RetryPolicy) {
+	if dev == nil {
+		return
+	}
+	dev.setRetryPolicy(p)
+}
+
+// GetRetryPolicy возвращает политику повторных попыток обмена с ФАС-3
+func (dev *AnalogDevice) GetRetryPolicy() (p RetryPolicy) {
+	if dev == nil {
+		return DefaultRetryPolicy()
+	}
+	return dev.retryPolicy()
+}
+
+// GetRetryStats возвращает счётчики операций, повторных попыток и ошибок обмена с ФАС-3
+func (dev *AnalogDevice) GetRetryStats() (st RetryStats) {
+	if dev == nil {
+		return
+	}
+	return dev.stats.get()
+}
+
+// ResetRetryStats обнуляет счётчики обмена с ФАС-3
+func (dev *AnalogDevice) ResetRetryStats() {
+	if dev == nil {
+		return
+	}
+	dev.stats.reset()
+}
+
+// Capabilities возвращает возможности ФАС-3, определённые при открытии платы
+// или последним вызовом DetectCapabilities
+func (dev *AnalogDevice) Capabilities() (caps Capabilities) {
+	if dev == nil {
+		return
+	}
+	return dev.capabilities()
+}
+
+// DetectCapabilities запрашивает у ФАС-3 версию прошивки и по ней уточняет возможности платы
+func (dev *AnalogDevice) DetectCapabilities() (caps Capabilities, err error) {
+	return dev.DetectCapabilitiesCtx(context.Background())
+}
+
+// DetectCapabilitiesCtx то же, что DetectCapabilities, но с возможностью отмены и ограничения времени через ctx.
+func (dev *AnalogDevice) DetectCapabilitiesCtx(ctx context.Context) (caps Capabilities, err error) {
+	if dev == nil {
+		err = fmt.Errorf("AnalogDevice.DetectCapabilities():%w", anlErrorNoDevice)
+		return
+	}
+	caps, err = dev.detectCapabilities(ctx, dev.GetProductID(), dev.GetVersionCtx)
+	if nil != err {
+		err = fmt.Errorf("AnalogDevice.DetectCapabilities():%w", err)
+	}
+	return
+}
+
+// SetCapabilities заменяет возможности ФАС-3, определённые библиотекой
+// (например, для платы с нестандартной прошивкой)
+func (dev *AnalogDevice) SetCapabilities(caps Capabilities) {
+	if dev == nil {
+		return
+	}
+	caps.ProductID = dev.GetProductID()
+	dev.setCapabilities(caps)
+}
@@ -0,0 +1,194 @@
+package ipk
+
+import (
+	"context"
+	"fmt"
+)
+
+// AnalogBatch набор значений для одновременной установки на выходах ФАС-3.
+// Значения ЦАП и частотных выходов накапливаются в AnalogBatch, а затем
+// AnalogDevice.Apply записывает их в плату за одно чтение и одну запись по USB,
+// так что все выходы меняются одновременно (например, давление в тормозной
+// магистрали и в тормозном цилиндре).
+// Первая ошибка в параметрах запоминается и возвращается из Apply.
+type AnalogBatch struct {
+	dacMask  uint16 // каналы ЦАП, которые надо изменить
+	dac      [analogCount]uint16
+	freqMask uint8 // частотные выходы, которые надо изменить
+	freq     [freqCount]uint16
+	pressure []pressureValue // значения давления, которые станут текущими после записи
+	err      error
+}
+
+type pressureValue struct {
+	pres  *PressureOutput
+	value float64
+}
+
+// SetDAC задаёт значение val на канале ЦАП ch (от ipk.DAC1 до ipk.DAC14).
+// Значение следует получить с помощью одной из функций: MilliAmperToDAC, AtToDAC, KiloPascalToDAC
+func (b *AnalogBatch) SetDAC(ch uint8, val uint16) *AnalogBatch {
+	if nil == b {
+		return b
+	}
+	if ch >= analogCount {
+		b.fail(fmt.Errorf("AnalogBatch.SetDAC():%w", anlErrorWrongParam))
+		return b
+	}
+	b.dac[ch] = val
+	b.dacMask |= 1 << ch
+	return b
+}
+
+// SetMilliAmper задаёт значение в мА на канале ЦАП dac.
+// Если значение выходит за установленный максимум, Apply вернёт ошибку.
+func (b *AnalogBatch) SetMilliAmper(dac *DAC, val float64) *AnalogBatch {
+	if nil == b {
+		return b
+	}
+	if nil == dac || val > float64(dac.maxMilliAmper) {
+		b.fail(fmt.Errorf("AnalogBatch.SetMilliAmper():%w", anlErrorWrongParam))
+		return b
+	}
+	return b.SetDAC(dac.numChannel, MilliAmperToDAC(val, dac.maxDAC, dac.maxMilliAmper))
+}
+
+// SetPressure задаёт значение давления на выходе датчика давления pres.
+// Если значение выходит за установленный максимум, Apply вернёт ошибку.
+// pres.GetVal вернёт новое значение после успешного Apply.
+func (b *AnalogBatch) SetPressure(pres *PressureOutput, val float64) *AnalogBatch {
+	if nil == b {
+		return b
+	}
+	if nil == pres || val > pres.maxValue {
+		b.fail(fmt.Errorf("AnalogBatch.SetPressure():%w", anlErrorWrongParam))
+		return b
+	}
+	switch pres.outputType {
+	case DACAtmosphere, DACKiloPascal:
+	default:
+		b.fail(fmt.Errorf("AnalogBatch.SetPressure():%w", anlErrorInternal))
+		return b
+	}
+	maVal := ValueToMa(val, pres.maxValue, pres.minMilliAmperConv, pres.maxMilliAmperConv)
+	b.SetMilliAmper(pres.dac, maVal)
+	b.pressure = append(b.pressure, pressureValue{pres: pres, value: val})
+	return b
+}
+
+// SetFreq задаёт одно из заранее заданных значений частоты (см. константы ipk.AnlFreq)
+// на выходе ВЫХ.ЧС-БУС ch (от ipk.FREQ1 до ipk.FREQ4).
+func (b *AnalogBatch) SetFreq(ch uint8, predefinedVal uint16) *AnalogBatch {
+	if nil == b {
+		return b
+	}
+	if ch >= freqCount {
+		b.fail(fmt.Errorf("AnalogBatch.SetFreq():%w", anlErrorWrongParam))
+		return b
+	}
+	b.freq[ch] = predefinedVal
+	b.freqMask |= 1 << ch
+	return b
+}
+
+// Err возвращает первую ошибку в параметрах, накопленных в b
+func (b *AnalogBatch) Err() error {
+	if nil == b {
+		return nil
+	}
+	return b.err
+}
+
+func (b *AnalogBatch) fail(err error) {
+	if nil == b.err {
+		b.err = err
+	}
+}
+
+// apply переносит накопленные значения в данные ФАС-3
+func (b *AnalogBatch) apply(as *analogDeviceData) {
+	for ch := range as.analog {
+		if 0 != b.dacMask&(1<<ch) {
+			as.analog[ch] = b.dac[ch]
+		}
+	}
+	for ch := range as.freq {
+		if 0 != b.freqMask&(1<<ch) {
+			as.freq[ch] = b.freq[ch]
+		}
+	}
+}
+
+// Apply одновременно устанавливает на выходах ФАС-3 все значения, накопленные в b.
+// Данные платы читаются один раз и записываются один раз.
+func (dev *AnalogDevice) Apply(b *AnalogBatch) (err error) {
+	return dev.ApplyCtx(context.Background(), b)
+}
+
+// ApplyCtx то же, что Apply, но с возможностью отмены и ограничения времени через ctx.
+func (dev *AnalogDevice) ApplyCtx(ctx context.Context, b *AnalogBatch) (err error) {
+	if nil == dev {
+		err = fmt.Errorf("Apply():%w", anlErrorNoDevice)
+		return
+	}
+	if nil == b {
+		err = fmt.Errorf("Apply():%w", anlErrorWrongParam)
+		return
+	}
+	if nil != b.err {
+		err = b.err
+		return
+	}
+
+	err = dev.retry(ctx, func(ctx context.Context) (err error) {
+		dev.mutexRMW.Lock()
+		defer dev.mutexRMW.Unlock()
+		var as analogDeviceData
+		err = dev.getDataUSB(ctx, &as)
+		if nil == err {
+			b.apply(&as)
+			err = dev.setDataUSB(ctx, &as)
+		}
+		return
+	})
+
+	if nil == err {
+		for _, p := range b.pressure {
+			p.pres.value = p.value
+		}
+	}
+	return
+}
+
+// SetOutputs одновременно устанавливает значения в мА на нескольких каналах ЦАП ФАС-3.
+// Ключ values - номер канала (от ipk.DAC1 до ipk.DAC14), значение - ток в мА.
+func (dev *AnalogDevice) SetOutputs(values map[uint8]float64) (err error) {
+	return dev.SetOutputsCtx(context.Background(), values)
+}
+
+// SetOutputsCtx то же, что SetOutputs, но с возможностью отмены и ограничения времени через ctx.
+func (dev *AnalogDevice) SetOutputsCtx(ctx context.Context, values map[uint8]float64) (err error) {
+	if nil == dev {
+		err = fmt.Errorf("SetOutputs():%w", anlErrorNoDevice)
+		return
+	}
+	var b AnalogBatch
+	caps := dev.Capabilities()
+	for ch, ma := range values {
+		if ch >= analogCount {
+			err = fmt.Errorf("SetOutputs():%w", anlErrorWrongParam)
+			return
+		}
+		maxDAC, maxMilliAmper, ok := caps.dacChannel(ch)
+		if !ok { // диапазоны ЦАП неизвестны, пока плата не открыта
+			err = fmt.Errorf("SetOutputs():%w", anlErrorNoConnection)
+			return
+		}
+		if ma > float64(maxMilliAmper) {
+			err = fmt.Errorf("SetOutputs():%w", anlErrorWrongParam)
+			return
+		}
+		b.SetDAC(ch, MilliAmperToDAC(ma, maxDAC, maxMilliAmper))
+	}
+	return dev.ApplyCtx(ctx, &b)
+}
@@ -0,0 +1,146 @@
+package ipk
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// writeFailTransport завершает ошибкой запись данных в ФАС-3 (0xB0), чтение выполняется
+type writeFailTransport struct {
+	*AnalogSimulator
+}
+
+func (wt *writeFailTransport) ControlOut(request byte, data []byte, timeout time.Duration) (int, error) {
+	if 0xB0 == request {
+		return 0, wrapError(ErrBadResponse, errors.New("сбой записи"))
+	}
+	return wt.AnalogSimulator.ControlOut(request, data, timeout)
+}
+
+func TestAnalogBatchSingleTransfer(t *testing.T) {
+	sim := NewAnalogSimulator(IDProductANL16bit)
+	var buf bytes.Buffer
+	capture := NewCapture(&buf)
+	dev := new(AnalogDevice)
+	if !dev.OpenTransport(capture.Transport(sim, IDProductANL16bit), IDProductANL16bit) {
+		t.Fatal("OpenTransport() = false")
+	}
+	var dac, presDAC DAC
+	if err := dac.Init(dev, DAC2); nil != err {
+		t.Fatal(err)
+	}
+	if err := presDAC.Init(dev, DAC9); nil != err {
+		t.Fatal(err)
+	}
+	var pres PressureOutput
+	if err := pres.Init(&presDAC, DACAtmosphere, 10); nil != err {
+		t.Fatal(err)
+	}
+	buf.Reset()
+
+	var b AnalogBatch
+	b.SetDAC(DAC1, 0x1234).
+		SetMilliAmper(&dac, 10).
+		SetPressure(&pres, 5).
+		SetFreq(FREQ2, AnlFreq1kHz).
+		SetDAC(DAC14, 0xFFFF)
+	if err := dev.Apply(&b); nil != err {
+		t.Fatal(err)
+	}
+
+	var writes []Exchange
+	dec := json.NewDecoder(&buf)
+	for {
+		var e Exchange
+		if err := dec.Decode(&e); io.EOF == err {
+			break
+		} else if nil != err {
+			t.Fatal(err)
+		}
+		if 0xB0 == e.Request && VendorRequestOutput == e.Direction {
+			writes = append(writes, e)
+		}
+	}
+	if 1 != len(writes) {
+		t.Fatalf("записей 0xB0: %d, want 1", len(writes))
+	}
+	var as analogDeviceData
+	if !as.setFromBytes(writes[0].Data) {
+		t.Fatalf("данные записи %X", writes[0].Data)
+	}
+	want := map[uint8]uint16{
+		DAC1:  0x1234,
+		DAC2:  MilliAmperToDAC(10, dac.maxDAC, dac.maxMilliAmper),
+		DAC9:  MilliAmperToDAC(ValueToMa(5, 10, pres.minMilliAmperConv, pres.maxMilliAmperConv), presDAC.maxDAC, presDAC.maxMilliAmper),
+		DAC14: 0xFFFF,
+	}
+	for ch := uint8(0); ch < analogCount; ch++ {
+		if want[ch] != as.analog[ch] {
+			t.Errorf("канал ЦАП %d: %04X, want %04X", ch+1, as.analog[ch], want[ch])
+		}
+		if want[ch] != sim.DAC(ch) {
+			t.Errorf("sim.DAC(%d) = %04X, want %04X", ch, sim.DAC(ch), want[ch])
+		}
+	}
+	if AnlFreq1kHz != as.freq[FREQ2] || AnlFreq1kHz != sim.Freq(FREQ2) {
+		t.Errorf("частотный выход 2: %d, sim %d, want %d", as.freq[FREQ2], sim.Freq(FREQ2), AnlFreq1kHz)
+	}
+	if 5 != pres.GetVal() {
+		t.Errorf("GetVal() = %v, want 5", pres.GetVal())
+	}
+}
+
+func TestAnalogBatchFailed(t *testing.T) {
+	sim := NewAnalogSimulator(IDProductANL16bit)
+	dev := new(AnalogDevice)
+	if !dev.OpenTransport(&writeFailTransport{sim}, IDProductANL16bit) {
+		t.Fatal("OpenTransport() = false")
+	}
+	dev.SetRetryPolicy(RetryPolicy{MaxAttempts: 2})
+	var dac DAC
+	if err := dac.Init(dev, DAC3); nil != err {
+		t.Fatal(err)
+	}
+	var pres PressureOutput
+	if err := pres.Init(&dac, DACKiloPascal, 1000); nil != err {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		batch func(b *AnalogBatch)
+		want  error
+	}{
+		{"ошибка записи", func(b *AnalogBatch) {
+			b.SetPressure(&pres, 500).SetDAC(DAC1, 100)
+		}, ErrBadResponse},
+		{"неверный параметр", func(b *AnalogBatch) {
+			b.SetPressure(&pres, 500).SetPressure(&pres, 2000)
+		}, ErrInvalidParam},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b AnalogBatch
+			tt.batch(&b)
+			if err := dev.Apply(&b); !errors.Is(err, tt.want) {
+				t.Fatalf("Apply() = %v, want %v", err, tt.want)
+			}
+			// кэш давления и выходы платы не изменились
+			if 0 != pres.GetVal() {
+				t.Errorf("GetVal() = %v, want 0", pres.GetVal())
+			}
+			for ch := uint8(0); ch < analogCount; ch++ {
+				if 0 != sim.DAC(ch) {
+					t.Errorf("sim.DAC(%d) = %04X, want 0", ch, sim.DAC(ch))
+				}
+			}
+			if ma, err := dac.GetMilliAmper(); nil != err || 0 != ma {
+				t.Errorf("GetMilliAmper() = %v, %v, want 0", ma, err)
+			}
+		})
+	}
+}
@@ -1,32 +1,47 @@
 package ipk
 
-import "errors"
+import (
+	"context"
+	"fmt"
+)
 
 //UintGetBinaryInput позволяет получить данные с двоичных входов ФАС-3 в виде одного числа.
 func (dev *AnalogDevice) UintGetBinaryInput() (val uint16, err error) {
+	return dev.UintGetBinaryInputCtx(context.Background())
+}
+
+//UintGetBinaryInputCtx то же, что UintGetBinaryInput, но с возможностью отмены и ограничения времени через ctx.
+func (dev *AnalogDevice) UintGetBinaryInputCtx(ctx context.Context) (val uint16, err error) {
 	if nil == dev {
-		err = errors.New("UintGetBinaryInput():" + anlErrorWrongParam)
+		err = fmt.Errorf("UintGetBinaryInput():%w", anlErrorWrongParam)
 		return
 	}
-	var as analogDeviceData
-	err = dev.getDataUSB(&as)
-	if nil == err {
-		val = as.binary[0]
+	err = dev.retry(ctx, func(ctx context.Context) (err error) {
+		var as analogDeviceData
+		err = dev.getDataUSB(ctx, &as)
+		if nil == err {
+			val = as.binary[0]
+		}
 		return
-	}
+	})
 	return
 }
 
 //GetBinaryInputVal позволяет получить значение отдельного двоичного входа ФАС-3.
 //num - номер двоичного входа, от 0 до 15.
 func (dev *AnalogDevice) GetBinaryInputVal(num uint16) (val bool, err error) {
+	return dev.GetBinaryInputValCtx(context.Background(), num)
+}
+
+//GetBinaryInputValCtx то же, что GetBinaryInputVal, но с возможностью отмены и ограничения времени через ctx.
+func (dev *AnalogDevice) GetBinaryInputValCtx(ctx context.Context, num uint16) (val bool, err error) {
 	if nil == dev || num >= 16 {
-		err = errors.New("GetBinaryInputVal():" + anlErrorWrongParam)
+		err = fmt.Errorf("GetBinaryInputVal():%w", anlErrorWrongParam)
 		return
 	}
 	val = false
 	var uintval uint16
-	uintval, err = dev.UintGetBinaryInput()
+	uintval, err = dev.UintGetBinaryInputCtx(ctx)
 	if nil == err {
 		if 0 != (uintval & (1 << num)) {
 			val = true
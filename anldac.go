@@ -1,9 +1,11 @@
 package ipk
 
-import "errors"
+import (
+	"context"
+	"fmt"
+)
 
-const anlErrorIncorrectMaxVal = `Максимальное значение для канала слишком большое`
-const anlErrorInternal = `Внутренняя ошибка`
+var anlErrorInternal = ErrInternal
 
 // DAC представляет один отдельный канал ЦАП на ФАС-3. Позволяет задавать значение в мА.
 type DAC struct {
@@ -36,7 +38,7 @@ type PressureOutput struct {
 //numChannel - номер канала ЦАП (от ipk.DAC1 до ipk.DAC14).
 func (dac *DAC) Init(device *AnalogDevice, numChannel uint8) (err error) {
 	if nil == dac || nil == device || numChannel >= analogCount {
-		err = errors.New("DAC.Init():" + anlErrorWrongParam)
+		err = fmt.Errorf("DAC.Init():%w", anlErrorWrongParam)
 		return
 	}
 
@@ -44,22 +46,35 @@ func (dac *DAC) Init(device *AnalogDevice, numChannel uint8) (err error) {
 
 	dac.numChannel = numChannel
 
-	switch device.GetProductID() {
+	var ok bool
+	caps := device.Capabilities()
+	dac.maxDAC, dac.maxMilliAmper, ok = caps.dacChannel(numChannel)
+	if !ok {
+		err = fmt.Errorf("DAC.Init():%w", anlErrorNoConnection)
+		return
+	}
+
+	return
+}
+
+//dacRange возвращает максимальное значение ЦАП и соответствующее ему значение мА
+//для канала ch варианта ФАС-3 idProduct (по умолчанию, см. Capabilities).
+func dacRange(idProduct uint16, ch uint8) (maxDAC, maxMilliAmper uint16, ok bool) {
+	switch idProduct {
 	case IDProductANL12bit:
-		if dac.numChannel < 7 {
-			dac.maxMilliAmper = 10
+		if ch < 7 {
+			maxMilliAmper = 10
 		} else {
-			dac.maxMilliAmper = 20
+			maxMilliAmper = 20
 		}
-		dac.maxDAC = 4095
+		maxDAC = 4095
 	case IDProductANL16bit:
-		dac.maxMilliAmper = 20
-		dac.maxDAC = 0xFFFF
+		maxMilliAmper = 20
+		maxDAC = 0xFFFF
 	default:
-		err = errors.New("DAC.Init():" + anlErrorNoConnection)
 		return
 	}
-
+	ok = true
 	return
 }
 
@@ -69,7 +84,7 @@ func (dac *DAC) Init(device *AnalogDevice, numChannel uint8) (err error) {
 //maxValue - максимальное значение в выбранных единицах.
 func (pres *PressureOutput) Init(dac *DAC, outputType uint8, maxValue float64) (err error) {
 	if nil == pres || nil == dac {
-		err = errors.New("PressureOutput.Init():" + anlErrorWrongParam)
+		err = fmt.Errorf("PressureOutput.Init():%w", anlErrorWrongParam)
 		return
 	}
 	pres.dac = dac
@@ -85,7 +100,7 @@ func (pres *PressureOutput) Init(dac *DAC, outputType uint8, maxValue float64) (
 		pres.outputType = outputType
 		pres.maxValue = maxValue
 	default:
-		err = errors.New("PressureOutput.Init():" + anlErrorWrongParam)
+		err = fmt.Errorf("PressureOutput.Init():%w", anlErrorWrongParam)
 		return
 	}
 
@@ -95,31 +110,69 @@ func (pres *PressureOutput) Init(dac *DAC, outputType uint8, maxValue float64) (
 //SetMilliAmper устанавливает значение на выход канала ЦАП.
 //Если значение выходит за установленный максимум, вернёт ошибку.
 func (dac *DAC) SetMilliAmper(val float64) (err error) {
+	return dac.SetMilliAmperCtx(context.Background(), val)
+}
+
+//SetMilliAmperCtx то же, что SetMilliAmper, но с возможностью отмены и ограничения времени через ctx.
+func (dac *DAC) SetMilliAmperCtx(ctx context.Context, val float64) (err error) {
 	if nil == dac || val > float64(dac.maxMilliAmper) {
-		err = errors.New("DAC.Set():" + anlErrorWrongParam)
+		err = fmt.Errorf("DAC.Set():%w", anlErrorWrongParam)
 		return
 	}
 
 	dacval := MilliAmperToDAC(val, dac.maxDAC, dac.maxMilliAmper)
-	err = dac.device.setDAC(dac.numChannel, dacval)
+	err = dac.device.setDAC(ctx, dac.numChannel, dacval)
+
+	return
+}
+
+//GetMilliAmper возвращает значение, установленное на выходе канала ЦАП, в мА.
+func (dac *DAC) GetMilliAmper() (val float64, err error) {
+	return dac.GetMilliAmperCtx(context.Background())
+}
+
+//GetMilliAmperCtx то же, что GetMilliAmper, но с возможностью отмены и ограничения времени через ctx.
+func (dac *DAC) GetMilliAmperCtx(ctx context.Context) (val float64, err error) {
+	if nil == dac || 0 == dac.maxDAC {
+		err = fmt.Errorf("DAC.Get():%w", anlErrorWrongParam)
+		return
+	}
+
+	dacval, err := dac.device.getOutputDAC(ctx, dac.numChannel)
+	if nil == err {
+		val = float64(dacval) * float64(dac.maxMilliAmper) / float64(dac.maxDAC)
+	}
 
 	return
 }
 
+//GetMaxMilliAmper возвращает максимально возможное значение в мА на канале ЦАП.
+func (dac *DAC) GetMaxMilliAmper() float64 {
+	if nil == dac {
+		return 0
+	}
+	return float64(dac.maxMilliAmper)
+}
+
 //Set устанавливает значение давления на выход канала ЦАП.
 //Если значение выходит за установленный максимум, вернёт ошибку.
 func (pres *PressureOutput) Set(val float64) (err error) {
+	return pres.SetCtx(context.Background(), val)
+}
+
+//SetCtx то же, что Set, но с возможностью отмены и ограничения времени через ctx.
+func (pres *PressureOutput) SetCtx(ctx context.Context, val float64) (err error) {
 	if nil == pres || val > pres.maxValue {
-		err = errors.New("PressureOutput.Set():" + anlErrorWrongParam)
+		err = fmt.Errorf("PressureOutput.Set():%w", anlErrorWrongParam)
 		return
 	}
 
 	switch pres.outputType {
 	case DACAtmosphere, DACKiloPascal:
 		maVal := ValueToMa(val, pres.maxValue, pres.minMilliAmperConv, pres.maxMilliAmperConv)
-		err = pres.dac.SetMilliAmper(maVal)
+		err = pres.dac.SetMilliAmperCtx(ctx, maVal)
 	default:
-		err = errors.New("PressureOutput.Set():" + anlErrorInternal)
+		err = fmt.Errorf("PressureOutput.Set():%w", anlErrorInternal)
 	}
 
 	pres.value = val
@@ -134,3 +187,32 @@ func (pres *PressureOutput) GetVal() float64 {
 	}
 	return pres.value
 }
+
+//Get возвращает давление, соответствующее значению на выходе канала ЦАП.
+//В отличие от GetVal, значение читается из ФАС-3.
+func (pres *PressureOutput) Get() (val float64, err error) {
+	return pres.GetCtx(context.Background())
+}
+
+//GetCtx то же, что Get, но с возможностью отмены и ограничения времени через ctx.
+func (pres *PressureOutput) GetCtx(ctx context.Context) (val float64, err error) {
+	if nil == pres || nil == pres.dac {
+		err = fmt.Errorf("PressureOutput.Get():%w", anlErrorWrongParam)
+		return
+	}
+
+	ma, err := pres.dac.GetMilliAmperCtx(ctx)
+	if nil == err {
+		val = pres.FromMilliAmper(ma)
+	}
+
+	return
+}
+
+//FromMilliAmper переводит значение канала ЦАП в мА в давление.
+func (pres *PressureOutput) FromMilliAmper(ma float64) float64 {
+	if nil == pres {
+		return 0
+	}
+	return MaToValue(ma, pres.maxValue, pres.minMilliAmperConv, pres.maxMilliAmperConv)
+}
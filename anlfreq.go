@@ -1,6 +1,10 @@
 package ipk
 
-import "errors"
+import (
+	"context"
+	"fmt"
+	"strings"
+)
 
 const freqCount = 4
 
@@ -21,20 +25,66 @@ const (
 	AnlFreq4kHz  = 4000
 )
 
+// anlFreqNames названия заранее заданных значений частоты
+var anlFreqNames = []struct {
+	name string
+	val  uint16
+}{
+	{"200Hz", AnlFreq200Hz},
+	{"500Hz", AnlFreq500Hz},
+	{"1kHz", AnlFreq1kHz},
+	{"2kHz", AnlFreq2kHz},
+	{"4kHz", AnlFreq4kHz},
+}
+
+//ParseAnlFreq возвращает константу ipk.AnlFreq по её названию: 200Hz, 500Hz, 1kHz, 2kHz или 4kHz.
+//Регистр букв не учитывается.
+func ParseAnlFreq(name string) (val uint16, err error) {
+	for _, f := range anlFreqNames {
+		if strings.EqualFold(f.name, strings.TrimSpace(name)) {
+			val = f.val
+			return
+		}
+	}
+	err = fmt.Errorf("ParseAnlFreq(%q):%w", name, anlErrorWrongParam)
+	return
+}
+
+//AnlFreqName возвращает название значения частоты (см. ParseAnlFreq),
+//"" если значение не является одной из констант ipk.AnlFreq.
+func AnlFreqName(val uint16) string {
+	for _, f := range anlFreqNames {
+		if f.val == val {
+			return f.name
+		}
+	}
+	return ""
+}
+
 //SetFreq выводит на выход ВЫХ.ЧС-БУС одно из заранее заданных значений частоты.
 // Параметр ch - номер канала. Значение от ipk.FREQ1 до ipk.FREQ4
 // Параметр predefinedVal - (см. константы ipk.AnlFreq).
 func (dev *AnalogDevice) SetFreq(ch uint8, predefinedVal uint16) (err error) {
+	return dev.SetFreqCtx(context.Background(), ch, predefinedVal)
+}
+
+//SetFreqCtx то же, что SetFreq, но с возможностью отмены и ограничения времени через ctx.
+func (dev *AnalogDevice) SetFreqCtx(ctx context.Context, ch uint8, predefinedVal uint16) (err error) {
 	if nil == dev || ch >= freqCount {
-		err = errors.New("SetFreq():" + anlErrorWrongParam)
+		err = fmt.Errorf("SetFreq():%w", anlErrorWrongParam)
 		return
 	}
-	var as analogDeviceData
-	err = dev.getDataUSB(&as)
-	if nil == err {
-		as.freq[ch] = predefinedVal
-		err = dev.setDataUSB(&as)
-	}
+	err = dev.retry(ctx, func(ctx context.Context) (err error) {
+		dev.mutexRMW.Lock()
+		defer dev.mutexRMW.Unlock()
+		var as analogDeviceData
+		err = dev.getDataUSB(ctx, &as)
+		if nil == err {
+			as.freq[ch] = predefinedVal
+			err = dev.setDataUSB(ctx, &as)
+		}
+		return
+	})
 	return
 }
 
@@ -42,14 +92,22 @@ func (dev *AnalogDevice) SetFreq(ch uint8, predefinedVal uint16) (err error) {
 //на одном из выходов ВЫХ.ЧС-БУС. Значение следует сравнивать с константами ipk.AnlFreq.
 //Параметр ch - номер канала. Значение от ipk.FREQ1 до ipk.FREQ4
 func (dev *AnalogDevice) GetOutputFreq(ch uint8) (val uint16, err error) {
+	return dev.GetOutputFreqCtx(context.Background(), ch)
+}
+
+//GetOutputFreqCtx то же, что GetOutputFreq, но с возможностью отмены и ограничения времени через ctx.
+func (dev *AnalogDevice) GetOutputFreqCtx(ctx context.Context, ch uint8) (val uint16, err error) {
 	if nil == dev || ch >= freqCount {
-		err = errors.New("GetOutputFreq():" + anlErrorWrongParam)
+		err = fmt.Errorf("GetOutputFreq():%w", anlErrorWrongParam)
 		return
 	}
-	var as analogDeviceData
-	err = dev.getDataUSB(&as)
-	if nil == err {
-		val = as.freq[ch]
-	}
+	err = dev.retry(ctx, func(ctx context.Context) (err error) {
+		var as analogDeviceData
+		err = dev.getDataUSB(ctx, &as)
+		if nil == err {
+			val = as.freq[ch]
+		}
+		return
+	})
 	return
 }
@@ -0,0 +1,205 @@
+package ipk
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+var simErrorUnknownRequest = errUnknownRequest // как STALL настоящей платы
+var simErrorShortData = newError(ErrInvalidParam, `Недостаточно данных в запросе`, `Not enough data in request`)
+var simErrorDisconnected = newError(ErrNotConnected, `Устройство отключено`, `Device is disconnected`)
+
+// AnalogSimulator - программная модель ФАС-3, реализующая Transport.
+// Отвечает на запрос 0xB0 так же, как микроконтроллер платы: хранит 14 значений ЦАП,
+// 4 значения частоты и слово двоичных входов в формате big endian.
+// Двоичные входы задаются только со стороны симулятора (SetBinaryInput),
+// запись в них со стороны приложения игнорируется.
+// Также поддерживается канал версии/обновления прошивки (0xB4).
+type AnalogSimulator struct {
+	mutex     sync.Mutex
+	idProduct uint16
+	data      analogDeviceData
+
+	firmware simFirmware
+
+	disconnected bool
+}
+
+// NewAnalogSimulator создаёт симулятор ФАС-3 с версией прошивки 1.0.0.
+// idProduct - вариант платы (IDProductANL12bit или IDProductANL16bit).
+func NewAnalogSimulator(idProduct uint16) *AnalogSimulator {
+	return &AnalogSimulator{idProduct: idProduct, firmware: simFirmware{major: 1}}
+}
+
+// ProductID возвращает вариант платы, который эмулирует симулятор.
+func (sim *AnalogSimulator) ProductID() uint16 {
+	if nil == sim {
+		return 0
+	}
+	return sim.idProduct
+}
+
+// ControlIn реализует чтение данных из ФАС-3.
+func (sim *AnalogSimulator) ControlIn(request byte, data []byte, timeout time.Duration) (n int, err error) {
+	if nil == sim {
+		err = fmt.Errorf("AnalogSimulator.ControlIn():%w", anlErrorNoDevice)
+		return
+	}
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	if sim.disconnected {
+		err = fmt.Errorf("AnalogSimulator.ControlIn():%w", simErrorDisconnected)
+		return
+	}
+
+	switch request {
+	case 0xB0:
+		n = copy(data, sim.data.toBytes())
+	case 0xB4:
+		if sim.firmware.legacy() { // старая ревизия платы не знает запроса версии
+			err = fmt.Errorf("AnalogSimulator.ControlIn():%w", simErrorUnknownRequest)
+			return
+		}
+		n = sim.firmware.version(data)
+	default:
+		err = fmt.Errorf("AnalogSimulator.ControlIn():%w", simErrorUnknownRequest)
+	}
+	return
+}
+
+// ControlOut реализует запись данных в ФАС-3.
+func (sim *AnalogSimulator) ControlOut(request byte, data []byte, timeout time.Duration) (n int, err error) {
+	if nil == sim {
+		err = fmt.Errorf("AnalogSimulator.ControlOut():%w", anlErrorNoDevice)
+		return
+	}
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	if sim.disconnected {
+		err = fmt.Errorf("AnalogSimulator.ControlOut():%w", simErrorDisconnected)
+		return
+	}
+
+	switch request {
+	case 0xB0:
+		var as analogDeviceData
+		if !as.setFromBytes(data) {
+			err = fmt.Errorf("AnalogSimulator.ControlOut():%w", simErrorShortData)
+			return
+		}
+		as.binary = sim.data.binary // двоичные входы приложение изменить не может
+		sim.data = as
+		n = as.Size()
+	case 0xB4:
+		if sim.firmware.legacy() {
+			err = fmt.Errorf("AnalogSimulator.ControlOut():%w", simErrorUnknownRequest)
+			return
+		}
+		err = sim.firmware.update(data)
+		n = len(data)
+	default:
+		err = fmt.Errorf("AnalogSimulator.ControlOut():%w", simErrorUnknownRequest)
+	}
+	return
+}
+
+// SetConnected имитирует отключение (false) и подключение (true) кабеля USB.
+// Пока плата отключена, обмен данными завершается ошибкой, а Present возвращает false.
+func (sim *AnalogSimulator) SetConnected(connected bool) {
+	if nil == sim {
+		return
+	}
+	sim.mutex.Lock()
+	sim.disconnected = !connected
+	sim.mutex.Unlock()
+}
+
+// Present показывает, подключена ли плата (см. SetConnected).
+func (sim *AnalogSimulator) Present() (ok bool) {
+	if nil == sim {
+		return
+	}
+	sim.mutex.Lock()
+	ok = !sim.disconnected
+	sim.mutex.Unlock()
+	return
+}
+
+// Close ничего не делает: состояние симулятора сохраняется, как у включенной платы.
+func (sim *AnalogSimulator) Close() error {
+	return nil
+}
+
+// SetBinaryInput задаёт состояние всех 16 двоичных входов ФАС-3.
+// Младший бит val соответствует первому двоичному входу.
+func (sim *AnalogSimulator) SetBinaryInput(val uint16) {
+	if nil == sim {
+		return
+	}
+	sim.mutex.Lock()
+	sim.data.binary[0] = val
+	sim.mutex.Unlock()
+}
+
+// SetBinaryInputVal задаёт состояние одного двоичного входа ФАС-3.
+// num - номер двоичного входа, от 0 до 15.
+func (sim *AnalogSimulator) SetBinaryInputVal(num uint16, val bool) {
+	if nil == sim || num >= 16 {
+		return
+	}
+	sim.mutex.Lock()
+	if val {
+		sim.data.binary[0] |= 1 << num
+	} else {
+		sim.data.binary[0] &^= 1 << num
+	}
+	sim.mutex.Unlock()
+}
+
+// DAC возвращает значение, записанное приложением в канал ЦАП ch (от ipk.DAC1 до ipk.DAC14).
+func (sim *AnalogSimulator) DAC(ch uint8) (val uint16) {
+	if nil == sim || ch >= analogCount {
+		return
+	}
+	sim.mutex.Lock()
+	val = sim.data.analog[ch]
+	sim.mutex.Unlock()
+	return
+}
+
+// Freq возвращает значение, записанное приложением в частотный выход ch (от ipk.FREQ1 до ipk.FREQ4).
+func (sim *AnalogSimulator) Freq(ch uint8) (val uint16) {
+	if nil == sim || ch >= freqCount {
+		return
+	}
+	sim.mutex.Lock()
+	val = sim.data.freq[ch]
+	sim.mutex.Unlock()
+	return
+}
+
+// SetVersion задаёт версию прошивки. Версия 0.0.0 соответствует старой ревизии платы,
+// которая не знает запроса версии и команд обновления (0xB4).
+// После записи прошивки и перезагрузки с другого банка памяти симулятор сообщает
+// версию с номером патча на 1 больше, после возврата на прежний банк - прежнюю.
+func (sim *AnalogSimulator) SetVersion(major, minor, patch uint32) {
+	if nil == sim {
+		return
+	}
+	sim.mutex.Lock()
+	sim.firmware.major, sim.firmware.minor, sim.firmware.patch = major, minor, patch
+	sim.mutex.Unlock()
+}
+
+// Flash возвращает копию слов прошивки, записанных последним обновлением, и номер текущего банка памяти.
+func (sim *AnalogSimulator) Flash() (words map[uint32]uint32, bank int) {
+	if nil == sim {
+		return
+	}
+	sim.mutex.Lock()
+	words = sim.firmware.words()
+	bank = sim.firmware.bank
+	sim.mutex.Unlock()
+	return
+}
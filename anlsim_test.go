@@ -0,0 +1,176 @@
+package ipk
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func openAnalogSimulator(t *testing.T, idProduct uint16) (*AnalogDevice, *AnalogSimulator) {
+	t.Helper()
+	sim := NewAnalogSimulator(idProduct)
+	dev := new(AnalogDevice)
+	if !dev.OpenTransport(sim, idProduct) {
+		t.Fatalf("OpenTransport(%04X) = false", idProduct)
+	}
+	return dev, sim
+}
+
+func TestAnalogSimulatorDAC(t *testing.T) {
+	tests := []struct {
+		name      string
+		idProduct uint16
+		ch        uint8
+		ma        float64
+		want      uint16 // значение ЦАП в симуляторе
+	}{
+		{"12 бит, канал до 10 мА", IDProductANL12bit, DAC1, 5, 2048},
+		{"12 бит, максимум 10 мА", IDProductANL12bit, DAC7, 10, 4095},
+		{"12 бит, канал до 20 мА", IDProductANL12bit, DAC8, 20, 4095},
+		{"12 бит, ноль", IDProductANL12bit, DAC14, 0, 0},
+		{"16 бит", IDProductANL16bit, DAC3, 10, 32768},
+		{"16 бит, максимум", IDProductANL16bit, DAC14, 20, 0xFFFF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dev, sim := openAnalogSimulator(t, tt.idProduct)
+			var dac DAC
+			if err := dac.Init(dev, tt.ch); nil != err {
+				t.Fatal(err)
+			}
+			if err := dac.SetMilliAmper(tt.ma); nil != err {
+				t.Fatal(err)
+			}
+			if got := sim.DAC(tt.ch); tt.want != got {
+				t.Errorf("sim.DAC(%d) = %d, want %d", tt.ch, got, tt.want)
+			}
+			ma, err := dac.GetMilliAmper()
+			if nil != err {
+				t.Fatal(err)
+			}
+			if math.Abs(ma-tt.ma) > 0.01 {
+				t.Errorf("GetMilliAmper() = %v, want %v", ma, tt.ma)
+			}
+			// остальные каналы не изменились
+			for ch := uint8(0); ch < analogCount; ch++ {
+				if ch != tt.ch && 0 != sim.DAC(ch) {
+					t.Errorf("sim.DAC(%d) = %d, want 0", ch, sim.DAC(ch))
+				}
+			}
+		})
+	}
+}
+
+func TestAnalogSimulatorFreq(t *testing.T) {
+	tests := []struct {
+		ch  uint8
+		val uint16
+	}{
+		{FREQ1, AnlFreq200Hz},
+		{FREQ2, AnlFreq500Hz},
+		{FREQ3, AnlFreq1kHz},
+		{FREQ4, AnlFreq4kHz},
+	}
+	dev, sim := openAnalogSimulator(t, IDProductANL16bit)
+	for _, tt := range tests {
+		if err := dev.SetFreq(tt.ch, tt.val); nil != err {
+			t.Fatalf("SetFreq(%d) = %v", tt.ch, err)
+		}
+	}
+	for _, tt := range tests {
+		if got := sim.Freq(tt.ch); tt.val != got {
+			t.Errorf("sim.Freq(%d) = %d, want %d", tt.ch, got, tt.val)
+		}
+		got, err := dev.GetOutputFreq(tt.ch)
+		if nil != err || tt.val != got {
+			t.Errorf("GetOutputFreq(%d) = %d, %v, want %d", tt.ch, got, err, tt.val)
+		}
+	}
+}
+
+func TestAnalogSimulatorBinaryInput(t *testing.T) {
+	tests := []struct {
+		name  string
+		input uint16
+		num   uint16
+		want  bool
+	}{
+		{"все сброшены", 0, 0, false},
+		{"вход 0", 0x0001, 0, true},
+		{"вход 15", 0x8000, 15, true},
+		{"другой вход", 0x8000, 14, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dev, sim := openAnalogSimulator(t, IDProductANL12bit)
+			sim.SetBinaryInput(tt.input)
+			// запись 0xB0 со стороны приложения не меняет двоичные входы
+			if err := dev.SetFreq(FREQ1, AnlFreq2kHz); nil != err {
+				t.Fatal(err)
+			}
+			val, err := dev.UintGetBinaryInput()
+			if nil != err || tt.input != val {
+				t.Errorf("UintGetBinaryInput() = %04X, %v, want %04X", val, err, tt.input)
+			}
+			got, err := dev.GetBinaryInputVal(tt.num)
+			if nil != err || tt.want != got {
+				t.Errorf("GetBinaryInputVal(%d) = %v, %v, want %v", tt.num, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalogSimulatorErrors(t *testing.T) {
+	dev, sim := openAnalogSimulator(t, IDProductANL12bit)
+	tests := []struct {
+		name string
+		call func() error
+		want error
+	}{
+		{"неверный канал частоты", func() error { return dev.SetFreq(freqCount, AnlFreq1kHz) }, ErrInvalidParam},
+		{"неверный вход", func() error { _, err := dev.GetBinaryInputVal(16); return err }, ErrInvalidParam},
+		{"отключение", func() error {
+			sim.SetConnected(false)
+			defer sim.SetConnected(true)
+			_, err := dev.UintGetBinaryInput()
+			return err
+		}, ErrNotConnected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+	if _, err := dev.UintGetBinaryInput(); nil != err {
+		t.Errorf("после подключения: %v", err)
+	}
+}
+
+func TestAnalogSimulatorVersion(t *testing.T) {
+	tests := []struct {
+		name                string
+		major, minor, patch uint32
+		want                string
+		update              bool
+	}{
+		{"по умолчанию", 1, 0, 0, "1.0.0", true},
+		{"новая прошивка", 1, 2, 3, "1.2.3", true},
+		{"старая ревизия", 0, 0, 0, "0.0.0", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dev, sim := openAnalogSimulator(t, IDProductANL16bit)
+			sim.SetVersion(tt.major, tt.minor, tt.patch)
+			version, err := dev.GetVersionString()
+			if nil != err || tt.want != version {
+				t.Errorf("GetVersionString() = %q, %v, want %q", version, err, tt.want)
+			}
+			caps, err := dev.DetectCapabilities()
+			if nil != err || tt.update != caps.FirmwareUpdate || tt.want != caps.Version {
+				t.Errorf("DetectCapabilities() = %+v, %v", caps, err)
+			}
+		})
+	}
+}
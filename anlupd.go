@@ -0,0 +1,91 @@
+package ipk
+
+import "context"
+
+var _ firmwareUpdater = (*AnalogDevice)(nil)
+
+// Обновление и версия прошивки ФАС-3: общая часть для всех плат ФПС-3 - в frequpd.go.
+
+// connection возвращает соединение с ФАС-3, nil если платы нет
+func (dev *AnalogDevice) connection() *usbConnection {
+	if nil == dev {
+		return nil
+	}
+	return &dev.usbConnection
+}
+
+// PrepareUpdate подготавливает запись обновления прошивки ФАС-3
+func (dev *AnalogDevice) PrepareUpdate() (err error) {
+	return dev.PrepareUpdateCtx(context.Background())
+}
+
+// PrepareUpdateCtx то же, что PrepareUpdate, но с возможностью отмены и ограничения времени через ctx.
+func (dev *AnalogDevice) PrepareUpdateCtx(ctx context.Context) (err error) {
+	return updateError("AnalogDevice.PrepareUpdate()", dev.connection().prepareUpdate(ctx))
+}
+
+// WriteUpdate записывает слово по адресу (пишет прошивку ФАС-3 в память)
+func (dev *AnalogDevice) WriteUpdate(uFlashAddress uint32, uWord uint32) (err error) {
+	return dev.WriteUpdateCtx(context.Background(), uFlashAddress, uWord)
+}
+
+// WriteUpdateCtx то же, что WriteUpdate, но с возможностью отмены и ограничения времени через ctx.
+func (dev *AnalogDevice) WriteUpdateCtx(ctx context.Context, uFlashAddress uint32, uWord uint32) (err error) {
+	return updateError("AnalogDevice.WriteUpdate()", dev.connection().writeUpdate(ctx, uFlashAddress, uWord))
+}
+
+// FinishUpdate завершает запись обновления прошивки ФАС-3
+func (dev *AnalogDevice) FinishUpdate() (err error) {
+	return dev.FinishUpdateCtx(context.Background())
+}
+
+// FinishUpdateCtx то же, что FinishUpdate, но с возможностью отмены и ограничения времени через ctx.
+func (dev *AnalogDevice) FinishUpdateCtx(ctx context.Context) (err error) {
+	return updateError("AnalogDevice.FinishUpdate()", dev.connection().finishUpdate(ctx))
+}
+
+// RestartToAnotherBank отправляет команду ФАС-3 перезагрузиться с другого банка памяти
+func (dev *AnalogDevice) RestartToAnotherBank() (err error) {
+	return dev.RestartToAnotherBankCtx(context.Background())
+}
+
+// RestartToAnotherBankCtx то же, что RestartToAnotherBank, но с возможностью отмены и ограничения времени через ctx.
+func (dev *AnalogDevice) RestartToAnotherBankCtx(ctx context.Context) (err error) {
+	return updateError("AnalogDevice.RestartToAnotherBank()", dev.connection().restartToAnotherBank(ctx))
+}
+
+// GetVersionString возвращает версию прошивки ФАС-3 (например, 1.0.0) в виде строки
+// Старая версия платы - 0.0.0
+func (dev *AnalogDevice) GetVersionString() (version string, err error) {
+	return dev.GetVersionStringCtx(context.Background())
+}
+
+// GetVersionStringCtx то же, что GetVersionString, но с возможностью отмены и ограничения времени через ctx.
+func (dev *AnalogDevice) GetVersionStringCtx(ctx context.Context) (version string, err error) {
+	version, err = dev.connection().getVersionString(ctx)
+	return version, updateError("AnalogDevice.GetVersion()", err)
+}
+
+// GetVersion возвращает версию прошивки ФАС-3 (например, 1.0.0)
+// Старая версия платы - 0.0.0: она не знает запроса 0xB4 и отвечает на него STALL
+// или не передаёт сигнатуру версии. Другие ошибки обмена возвращаются как ошибки.
+func (dev *AnalogDevice) GetVersion() (major, minor, patch uint32, err error) {
+	return dev.GetVersionCtx(context.Background())
+}
+
+// GetVersionCtx то же, что GetVersion, но с возможностью отмены и ограничения времени через ctx.
+func (dev *AnalogDevice) GetVersionCtx(ctx context.Context) (major, minor, patch uint32, err error) {
+	major, minor, patch, err = dev.connection().getVersion(ctx)
+	return major, minor, patch, updateError("AnalogDevice.GetVersion()", err)
+}
+
+// FlashFirmware записывает образ прошивки img в другой банк памяти ФАС-3 (см. FreqDevice.FlashFirmware)
+func (dev *AnalogDevice) FlashFirmware(img *FirmwareImage, opts FlashOptions) (version string, err error) {
+	return dev.FlashFirmwareCtx(context.Background(), img, opts)
+}
+
+// FlashFirmwareCtx то же, что FlashFirmware, но с возможностью отмены и ограничения времени через ctx.
+func (dev *AnalogDevice) FlashFirmwareCtx(ctx context.Context, img *FirmwareImage, opts FlashOptions) (version string, err error) {
+	version, err = flashFirmware(ctx, dev, img, opts)
+	return version, updateError("AnalogDevice.FlashFirmware()", err)
+}
@@ -0,0 +1,287 @@
+package ipk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var wchErrorNotStarted = newError(ErrNotInitialized, `наблюдение за двоичными входами не запущено`, `binary input watcher is not started`)
+
+// DefaultWatchInterval период опроса двоичных входов ФАС-3 по умолчанию
+const DefaultWatchInterval = 10 * time.Millisecond
+
+// размер буфера канала подписчика InputWatcher
+const watchBufferSize = 64
+
+// InputEdge изменение состояния двоичного входа ФАС-3
+type InputEdge struct {
+	Input  uint16    // номер двоичного входа, от 0 до 15
+	Rising bool      // true - вход включился (0 -> 1), false - выключился (1 -> 0)
+	Time   time.Time // момент, когда изменение было впервые замечено
+}
+
+// InputWatcher опрашивает двоичные входы ФАС-3 в отдельной горутине и сообщает
+// об их изменениях (фронтах) через OnEdge и каналы Subscribe.
+// Изменение засчитывается, если новое состояние входа держится не меньше Debounce.
+type InputWatcher struct {
+	Interval time.Duration        // период опроса
+	Debounce time.Duration        // время подавления дребезга, 0 - без подавления
+	OnEdge   func(edge InputEdge) // вызывается при каждом изменении входа
+	OnError  func(err error)      // вызывается при ошибке опроса
+	dev      *AnalogDevice
+	now      func() time.Time
+
+	mutex       sync.Mutex
+	valid       bool   // состояние входов получено
+	state       uint16 // состояние входов после подавления дребезга
+	raw         uint16 // состояние входов при последнем опросе
+	since       [16]time.Time
+	err         error
+	subscribers []chan InputEdge
+	stop        context.CancelFunc
+	done        chan struct{}
+}
+
+// NewInputWatcher создаёт наблюдение за двоичными входами ФАС-3 dev
+func NewInputWatcher(dev *AnalogDevice) *InputWatcher {
+	return &InputWatcher{Interval: DefaultWatchInterval, dev: dev, now: time.Now}
+}
+
+// Start запускает опрос двоичных входов в отдельной горутине
+func (w *InputWatcher) Start() {
+	if nil == w {
+		return
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if nil != w.stop {
+		return
+	}
+	interval := w.Interval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w.stop = cancel
+	w.done = make(chan struct{})
+	w.valid = false
+	go w.run(ctx, interval, w.done)
+}
+
+// Stop останавливает опрос
+func (w *InputWatcher) Stop() {
+	if nil == w {
+		return
+	}
+	w.mutex.Lock()
+	stop, done := w.stop, w.done
+	w.stop, w.done = nil, nil
+	w.mutex.Unlock()
+	if nil != stop {
+		stop()
+		<-done
+	}
+}
+
+func (w *InputWatcher) run(ctx context.Context, interval time.Duration, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		w.Poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll однократно опрашивает двоичные входы. Вызывается периодически после Start,
+// но может вызываться и вручную.
+func (w *InputWatcher) Poll(ctx context.Context) (err error) {
+	if nil == w {
+		return
+	}
+	var val uint16
+	val, err = w.dev.UintGetBinaryInputCtx(ctx)
+	if nil != ctx.Err() {
+		return
+	}
+	now := w.now()
+
+	var edges []InputEdge
+	w.mutex.Lock()
+	w.err = err
+	if nil == err {
+		edges = w.update(val, now)
+	}
+	subscribers := append([]chan InputEdge(nil), w.subscribers...)
+	w.mutex.Unlock()
+
+	if nil != err && nil != w.OnError {
+		w.OnError(err)
+	}
+	for _, edge := range edges {
+		if nil != w.OnEdge {
+			w.OnEdge(edge)
+		}
+		for _, ch := range subscribers {
+			select {
+			case ch <- edge:
+			default: // подписчик не успевает читать события
+			}
+		}
+	}
+	return
+}
+
+// update обрабатывает новое состояние входов val. Вызывается под mutex.
+func (w *InputWatcher) update(val uint16, now time.Time) (edges []InputEdge) {
+	if !w.valid {
+		w.valid = true
+		w.state, w.raw = val, val
+		for i := range w.since {
+			w.since[i] = now
+		}
+		return
+	}
+	for i := range w.since {
+		bit := uint16(1) << i
+		if val&bit != w.raw&bit {
+			w.since[i] = now
+		}
+		if val&bit != w.state&bit && now.Sub(w.since[i]) >= w.Debounce {
+			w.state ^= bit
+			edges = append(edges, InputEdge{Input: uint16(i), Rising: 0 != val&bit, Time: w.since[i]})
+		}
+	}
+	w.raw = val
+	return
+}
+
+// State возвращает состояние всех 16 двоичных входов после подавления дребезга.
+// ok - false, если входы ещё не были опрошены.
+func (w *InputWatcher) State() (val uint16, ok bool) {
+	if nil == w {
+		return
+	}
+	w.mutex.Lock()
+	val, ok = w.state, w.valid
+	w.mutex.Unlock()
+	return
+}
+
+// Err возвращает ошибку последнего опроса
+func (w *InputWatcher) Err() error {
+	if nil == w {
+		return nil
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.err
+}
+
+// Subscribe возвращает канал, в который отправляются изменения двоичных входов.
+// Если подписчик не успевает читать события, лишние события отбрасываются.
+func (w *InputWatcher) Subscribe() <-chan InputEdge {
+	if nil == w {
+		return nil
+	}
+	ch := make(chan InputEdge, watchBufferSize)
+	w.mutex.Lock()
+	w.subscribers = append(w.subscribers, ch)
+	w.mutex.Unlock()
+	return ch
+}
+
+// Unsubscribe прекращает отправку событий в канал ch, полученный от Subscribe
+func (w *InputWatcher) Unsubscribe(ch <-chan InputEdge) {
+	if nil == w {
+		return
+	}
+	w.mutex.Lock()
+	for i, c := range w.subscribers {
+		if c == ch {
+			w.subscribers = append(w.subscribers[:i], w.subscribers[i+1:]...)
+			break
+		}
+	}
+	w.mutex.Unlock()
+}
+
+// WaitEdge ждёт следующего изменения двоичного входа num (от 0 до 15):
+// включения, если rising - true, или выключения.
+// Время ожидания ограничивается ctx, например:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+//	defer cancel()
+//	edge, err := w.WaitEdge(ctx, 5, true)
+func (w *InputWatcher) WaitEdge(ctx context.Context, num uint16, rising bool) (edge InputEdge, err error) {
+	return w.wait(ctx, num, rising, false)
+}
+
+// WaitLevel ждёт, пока двоичный вход num (от 0 до 15) не окажется в состоянии high.
+// Если вход уже в этом состоянии, возвращается сразу (edge.Time - время проверки).
+func (w *InputWatcher) WaitLevel(ctx context.Context, num uint16, high bool) (edge InputEdge, err error) {
+	return w.wait(ctx, num, high, true)
+}
+
+func (w *InputWatcher) wait(ctx context.Context, num uint16, rising, level bool) (edge InputEdge, err error) {
+	if nil == w || num >= 16 {
+		err = fmt.Errorf("InputWatcher.Wait():%w", anlErrorWrongParam)
+		return
+	}
+	w.mutex.Lock()
+	started := nil != w.stop
+	w.mutex.Unlock()
+	if !started {
+		err = fmt.Errorf("InputWatcher.Wait():%w", wchErrorNotStarted)
+		return
+	}
+
+	ch := w.Subscribe()
+	defer w.Unsubscribe(ch)
+
+	// в ожидании состояния оно проверяется и периодически: события могли быть
+	// отброшены, а первое состояние входов событий не создаёт
+	var tick <-chan time.Time
+	if level {
+		if w.reached(num, rising) {
+			edge = InputEdge{Input: num, Rising: rising, Time: w.now()}
+			return
+		}
+		ticker := time.NewTicker(DefaultWatchInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case edge = <-ch:
+			if edge.Input == num && edge.Rising == rising {
+				return
+			}
+		case <-tick:
+			if w.reached(num, rising) {
+				edge = InputEdge{Input: num, Rising: rising, Time: w.now()}
+				return
+			}
+		case <-ctx.Done():
+			err = ctx.Err()
+			if errors.Is(err, context.DeadlineExceeded) {
+				err = wrapError(ErrTimeout, err)
+			}
+			err = fmt.Errorf("InputWatcher.Wait():%w", err)
+			return
+		}
+	}
+}
+
+// reached показывает, что двоичный вход num находится в состоянии high
+func (w *InputWatcher) reached(num uint16, high bool) bool {
+	state, ok := w.State()
+	return ok && (0 != state&(1<<num)) == high
+}
@@ -0,0 +1,108 @@
+package ipk
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestInputWatcherDebounce(t *testing.T) {
+	const ms = time.Millisecond
+	type step struct {
+		at    time.Duration // время опроса от начала
+		input uint16        // состояние входов
+	}
+	type edge struct {
+		input  uint16
+		rising bool
+		at     time.Duration // время, когда изменение было впервые замечено
+	}
+	tests := []struct {
+		name      string
+		debounce  time.Duration
+		steps     []step
+		want      []edge
+		wantState uint16
+	}{
+		{"первый опрос без событий", 20 * ms, []step{{0, 0x0003}}, nil, 0x0003},
+		{"без подавления дребезга", 0, []step{{0, 0}, {10 * ms, 0x0001}, {20 * ms, 0}},
+			[]edge{{0, true, 10 * ms}, {0, false, 20 * ms}}, 0},
+		{"дребезг подавлен", 20 * ms, []step{{0, 0}, {10 * ms, 0x0001}, {15 * ms, 0}, {40 * ms, 0}}, nil, 0},
+		{"изменение держится меньше Debounce", 20 * ms, []step{{0, 0}, {10 * ms, 0x0001}, {20 * ms, 0x0001}}, nil, 0},
+		{"изменение держится Debounce", 20 * ms, []step{{0, 0}, {10 * ms, 0x0001}, {20 * ms, 0x0001}, {30 * ms, 0x0001}},
+			[]edge{{0, true, 10 * ms}}, 0x0001},
+		{"выключение", 20 * ms, []step{{0, 0x8000}, {10 * ms, 0}, {40 * ms, 0}},
+			[]edge{{15, false, 10 * ms}}, 0},
+		{"дребезг отсчитывается заново", 20 * ms, []step{{0, 0}, {10 * ms, 0x0004}, {15 * ms, 0}, {20 * ms, 0x0004}, {35 * ms, 0x0004}, {40 * ms, 0x0004}},
+			[]edge{{2, true, 20 * ms}}, 0x0004},
+		{"несколько входов", 0, []step{{0, 0x0001}, {10 * ms, 0x0102}},
+			[]edge{{0, false, 10 * ms}, {1, true, 10 * ms}, {8, true, 10 * ms}}, 0x0102},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dev, sim := openAnalogSimulator(t, IDProductANL12bit)
+			clock := newFakeClock()
+			start := clock.now()
+			w := NewInputWatcher(dev)
+			w.Debounce = tt.debounce
+			w.now = clock.now
+			var got []edge
+			w.OnEdge = func(e InputEdge) {
+				got = append(got, edge{e.Input, e.Rising, e.Time.Sub(start)})
+			}
+			ch := w.Subscribe()
+			for _, s := range tt.steps {
+				clock.t = start.Add(s.at)
+				sim.SetBinaryInput(s.input)
+				if err := w.Poll(context.Background()); nil != err {
+					t.Fatal(err)
+				}
+			}
+			if !reflect.DeepEqual(tt.want, got) {
+				t.Errorf("OnEdge: %v, want %v", got, tt.want)
+			}
+			if len(ch) != len(tt.want) {
+				t.Errorf("Subscribe: %d событий, want %d", len(ch), len(tt.want))
+			}
+			if state, ok := w.State(); !ok || tt.wantState != state {
+				t.Errorf("State() = %04X, %v, want %04X", state, ok, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestInputWatcherWait(t *testing.T) {
+	dev, sim := openAnalogSimulator(t, IDProductANL12bit)
+	w := NewInputWatcher(dev)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if _, err := w.WaitEdge(ctx, 0, true); !errors.Is(err, ErrNotInitialized) {
+		t.Errorf("WaitEdge() до Start: %v, want %v", err, ErrNotInitialized)
+	}
+	if _, err := w.WaitEdge(ctx, 16, true); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("WaitEdge(16): %v, want %v", err, ErrInvalidParam)
+	}
+
+	sim.SetBinaryInput(0x0020)
+	w.Start()
+	defer w.Stop()
+	if edge, err := w.WaitLevel(ctx, 5, true); nil != err || !edge.Rising || 5 != edge.Input {
+		t.Errorf("WaitLevel(5) = %+v, %v", edge, err)
+	}
+	go func() {
+		time.Sleep(5 * DefaultWatchInterval)
+		sim.SetBinaryInput(0)
+	}()
+	if edge, err := w.WaitEdge(ctx, 5, false); nil != err || edge.Rising || 5 != edge.Input {
+		t.Errorf("WaitEdge(5) = %+v, %v", edge, err)
+	}
+
+	short, cancelShort := context.WithTimeout(context.Background(), 3*DefaultWatchInterval)
+	defer cancelShort()
+	if _, err := w.WaitEdge(short, 7, true); !errors.Is(err, ErrTimeout) {
+		t.Errorf("WaitEdge() без изменения: %v, want %v", err, ErrTimeout)
+	}
+}
@@ -1,14 +1,14 @@
 package ipk
 
 import (
+	"context"
 	"encoding/binary"
-	"errors"
-	"time"
+	"fmt"
 )
 
-const binErrorNoConnection = `Нет соединения с ФДС-3`
-const binErrorWrongParam = `Неверный параметр функции`
-const binErrorNoDevice = `BinaryDevice == nil`
+var binErrorNoConnection = newError(ErrNotConnected, `Нет соединения с ФДС-3`, `No connection to FDS-3`)
+var binErrorWrongParam = newError(ErrInvalidParam, `Неверный параметр функции`, `Invalid function parameter`)
+var binErrorNoDevice = newError(ErrNotInitialized, `BinaryDevice == nil`, `BinaryDevice == nil`)
 
 // Варианты кодирования сигнала ИФ
 const (
@@ -23,6 +23,14 @@ const (
 	IFMax         = 8
 )
 
+// номер выхода 50 В, вместо которого выводится сигнал ИФ
+const binIF50V = 28
+
+// BinaryDevice это тип для работы с ФДС-3
+type BinaryDevice struct {
+	usbConnection
+}
+
 type binaryData struct {
 	data [8]byte
 }
@@ -47,30 +55,30 @@ func (data *binaryData) SetUint64(val uint64) {
 //num это номер выхода,
 //num может принимать значение от 0 до 7
 func (dev *BinaryDevice) Set10V(num uint, val bool) (err error) {
+	return dev.Set10VCtx(context.Background(), num, val)
+}
+
+//Set10VCtx то же, что Set10V, но с возможностью отмены и ограничения времени через ctx.
+func (dev *BinaryDevice) Set10VCtx(ctx context.Context, num uint, val bool) (err error) {
 	if nil == dev {
-		err = errors.New("Set10V():" + binErrorNoDevice)
+		err = fmt.Errorf("Set10V():%w", binErrorNoDevice)
 		return
 	}
-	ok := false
-	timeout := false
-	t := time.Now()
-	for !ok && !timeout {
+	err = dev.retry(ctx, func(ctx context.Context) (err error) {
+		dev.mutexRMW.Lock()
+		defer dev.mutexRMW.Unlock()
 		var bindata binaryData
-		bindata, err = dev.getDataUSB()
+		bindata, err = dev.getDataUSB(ctx)
 		if nil == err {
 			if val { //сброс бита когда true, потому что так было в SRS_BIN2_Set (SrsBin2.cpp, srs2.dll)
 				bindata.data[0] &^= 1 << num
 			} else {
 				bindata.data[0] |= 1 << num
 			}
-			err = dev.setDataUSB(bindata)
+			err = dev.setDataUSB(ctx, bindata)
 		}
-		ok = (nil == err)
-		timeout = time.Since(t) >= maxDelayUSB
-	}
-	if ok && timeout {
-		err = errors.New("Set10V():" + errUsbTimeout)
-	}
+		return
+	})
 	return
 }
 
@@ -78,27 +86,27 @@ func (dev *BinaryDevice) Set10V(num uint, val bool) (err error) {
 //сразу на все 10 В выходные сигналы ФДС-3.
 //Младший бит val соответствует первому 10 В выходу ФДС-3
 func (dev *BinaryDevice) UintSet10V(val uint8) (err error) {
+	return dev.UintSet10VCtx(context.Background(), val)
+}
+
+//UintSet10VCtx то же, что UintSet10V, но с возможностью отмены и ограничения времени через ctx.
+func (dev *BinaryDevice) UintSet10VCtx(ctx context.Context, val uint8) (err error) {
 	if nil == dev {
-		err = errors.New("UintSet10V():" + binErrorNoDevice)
+		err = fmt.Errorf("UintSet10V():%w", binErrorNoDevice)
 		return
 	}
-	ok := false
-	timeout := false
-	t := time.Now()
-	for !ok && !timeout {
+	err = dev.retry(ctx, func(ctx context.Context) (err error) {
+		dev.mutexRMW.Lock()
+		defer dev.mutexRMW.Unlock()
 		var bindata binaryData
-		bindata, err = dev.getDataUSB()
+		bindata, err = dev.getDataUSB(ctx)
 		if nil == err {
 			//инверсия потому что так было в SRS_BIN2_Set (SrsBin2.cpp, srs2.dll)
 			bindata.data[0] = ^val
-			err = dev.setDataUSB(bindata)
+			err = dev.setDataUSB(ctx, bindata)
 		}
-		ok = (nil == err)
-		timeout = time.Since(t) >= maxDelayUSB
-	}
-	if ok && timeout {
-		err = errors.New("UintSet10V():" + errUsbTimeout)
-	}
+		return
+	})
 	return
 }
 
@@ -106,27 +114,25 @@ func (dev *BinaryDevice) UintSet10V(val uint8) (err error) {
 //на выходах 10 В в виде одного числа.
 //Младший бит val соответствует первому 10 В выходу ФДС-3
 func (dev *BinaryDevice) UintGetOutput10V() (val uint8, err error) {
+	return dev.UintGetOutput10VCtx(context.Background())
+}
+
+//UintGetOutput10VCtx то же, что UintGetOutput10V, но с возможностью отмены и ограничения времени через ctx.
+func (dev *BinaryDevice) UintGetOutput10VCtx(ctx context.Context) (val uint8, err error) {
 	if nil == dev {
-		err = errors.New("UintGetOutput10V():" + binErrorNoDevice)
+		err = fmt.Errorf("UintGetOutput10V():%w", binErrorNoDevice)
 		return
 	}
-	ok := false
-	timeout := false
-	t := time.Now()
-	for !ok && !timeout {
+	err = dev.retry(ctx, func(ctx context.Context) (err error) {
 		var bindata binaryData
-		bindata, err = dev.getDataUSB()
+		bindata, err = dev.getDataUSB(ctx)
 		if nil == err {
 			val = bindata.data[0]
 			//инверсия потому что так было в SRS_BIN2_Set (SrsBin2.cpp, srs2.dll)
 			val = ^val
 		}
-		ok = (nil == err)
-		timeout = time.Since(t) >= maxDelayUSB
-	}
-	if ok && timeout {
-		err = errors.New("UintGetOutput10V():" + errUsbTimeout)
-	}
+		return
+	})
 
 	return
 }
@@ -138,29 +144,30 @@ func (dev *BinaryDevice) UintGetOutput10V() (val uint8, err error) {
 //который отвечает за сигнал ИФ. Но через 10 мс его состояние
 //снова поменяется микроконтроллером на то, которое предусмотрено.
 //Так что теоретически вызов этой функции может затронуть кодирование ИФ(?).
+//Чтобы не затрагивать сигнал ИФ, используйте UpdateOutputs.
 func (dev *BinaryDevice) UintSet50V(val uint64) (err error) {
+	return dev.UintSet50VCtx(context.Background(), val)
+}
+
+//UintSet50VCtx то же, что UintSet50V, но с возможностью отмены и ограничения времени через ctx.
+func (dev *BinaryDevice) UintSet50VCtx(ctx context.Context, val uint64) (err error) {
 	if nil == dev {
-		err = errors.New("UintSet50V():" + binErrorNoDevice)
+		err = fmt.Errorf("UintSet50V():%w", binErrorNoDevice)
 		return
 	}
-	ok := false
-	timeout := false
-	t := time.Now()
-	for !ok && !timeout {
+	err = dev.retry(ctx, func(ctx context.Context) (err error) {
+		dev.mutexRMW.Lock()
+		defer dev.mutexRMW.Unlock()
 		var bindata binaryData
-		bindata, err = dev.getDataUSB()
+		bindata, err = dev.getDataUSB(ctx)
 		if nil == err {
 			save10v := uint64(bindata.data[0])     // сохраняем 10 В сигналы (не хотим их менять)
 			setval := ^val                         //инверсия потому что так было в SRS_BIN2_Set (SrsBin2.cpp, srs2.dll)
 			bindata.SetUint64(setval<<8 | save10v) // новые значения 50 В + старые значения 10 В
-			err = dev.setDataUSB(bindata)
+			err = dev.setDataUSB(ctx, bindata)
 		}
-		ok = (nil == err)
-		timeout = time.Since(t) >= maxDelayUSB
-	}
-	if ok && timeout {
-		err = errors.New("UintSet50V():" + errUsbTimeout)
-	}
+		return
+	})
 
 	return
 }
@@ -168,26 +175,24 @@ func (dev *BinaryDevice) UintSet50V(val uint64) (err error) {
 //UintGetOutput50V возвращает все значения, установленные в данный момент
 //на выходах 50 В в виде одного числа.
 func (dev *BinaryDevice) UintGetOutput50V() (val uint64, err error) {
+	return dev.UintGetOutput50VCtx(context.Background())
+}
+
+//UintGetOutput50VCtx то же, что UintGetOutput50V, но с возможностью отмены и ограничения времени через ctx.
+func (dev *BinaryDevice) UintGetOutput50VCtx(ctx context.Context) (val uint64, err error) {
 	if nil == dev {
-		err = errors.New("UintGetOutput50V():" + binErrorNoDevice)
+		err = fmt.Errorf("UintGetOutput50V():%w", binErrorNoDevice)
 		return
 	}
-	ok := false
-	timeout := false
-	t := time.Now()
-	for !ok && !timeout {
+	err = dev.retry(ctx, func(ctx context.Context) (err error) {
 		var bindata binaryData
-		bindata, err = dev.getDataUSB()
+		bindata, err = dev.getDataUSB(ctx)
 		if err == nil {
 			val = bindata.Uint64() >> 8
 			val = ^val //инверсия потому что так было в SRS_BIN2_Set (SrsBin2.cpp, srs2.dll)
 		}
-		ok = (nil == err)
-		timeout = time.Since(t) >= maxDelayUSB
-	}
-	if ok && timeout {
-		err = errors.New("UintGetOutput50V():" + errUsbTimeout)
-	}
+		return
+	})
 	return
 }
 
@@ -197,20 +202,24 @@ func (dev *BinaryDevice) UintGetOutput50V() (val uint64, err error) {
 //num может принимать значение от 0 до 35
 //(кроме 28, вместо этого выхода - сигнал ИФ, который обрабатывается отдельно).
 func (dev *BinaryDevice) Set50V(num uint, val bool) (err error) {
+	return dev.Set50VCtx(context.Background(), num, val)
+}
+
+//Set50VCtx то же, что Set50V, но с возможностью отмены и ограничения времени через ctx.
+func (dev *BinaryDevice) Set50VCtx(ctx context.Context, num uint, val bool) (err error) {
 	if nil == dev {
-		err = errors.New("Set50V():" + binErrorNoDevice)
+		err = fmt.Errorf("Set50V():%w", binErrorNoDevice)
 		return
 	}
-	if num == 28 { // не позволяем менять сигнал ИФ
+	if num == binIF50V { // не позволяем менять сигнал ИФ
 		return
 	}
 
-	ok := false
-	timeout := false
-	t := time.Now()
-	for !ok && !timeout {
+	err = dev.retry(ctx, func(ctx context.Context) (err error) {
+		dev.mutexRMW.Lock()
+		defer dev.mutexRMW.Unlock()
 		var bindata binaryData
-		bindata, err = dev.getDataUSB()
+		bindata, err = dev.getDataUSB(ctx)
 		if nil == err {
 			inum := num + 8 // нумерация 50 В сигналов начианется с 8 бита
 			ibs := bindata.Uint64()
@@ -220,37 +229,39 @@ func (dev *BinaryDevice) Set50V(num uint, val bool) (err error) {
 				ibs |= uint64(1) << inum
 			}
 			bindata.SetUint64(ibs)
-			err = dev.setDataUSB(bindata)
+			err = dev.setDataUSB(ctx, bindata)
 		}
-		ok = (nil == err)
-		timeout = time.Since(t) >= maxDelayUSB
-	}
-
-	if ok && timeout {
-		err = errors.New("Set50V():" + errUsbTimeout)
-	}
+		return
+	})
 
 	return
 }
 
 //GetOutputIF возвращает установленный в данный момент сигнал ИФ
 func (dev *BinaryDevice) GetOutputIF() (state uint8, err error) {
+	return dev.GetOutputIFCtx(context.Background())
+}
+
+//GetOutputIFCtx то же, что GetOutputIF, но с возможностью отмены и ограничения времени через ctx.
+func (dev *BinaryDevice) GetOutputIFCtx(ctx context.Context) (state uint8, err error) {
 	if nil == dev {
-		err = errors.New("GetOutputIF():" + binErrorNoDevice)
+		err = fmt.Errorf("GetOutputIF():%w", binErrorNoDevice)
 		return
 	}
 
 	if !dev.opened() {
-		err = errors.New("GetOutputIF():" + binErrorNoConnection)
+		err = fmt.Errorf("GetOutputIF():%w", binErrorNoConnection)
 		return
 	}
 
 	getstate := make([]byte, 1)
 
-	err = dev.deviceIoControl(VendorRequestInput, 0xB1, getstate, 1)
+	err = dev.retry(ctx, func(ctx context.Context) error {
+		return dev.deviceIoControl(ctx, VendorRequestInput, 0xB1, getstate, 1)
+	})
 
 	if nil != err {
-		err = errors.New("GetOutputIF():" + err.Error())
+		err = fmt.Errorf("GetOutputIF():%w", err)
 		return
 	}
 
@@ -261,24 +272,31 @@ func (dev *BinaryDevice) GetOutputIF() (state uint8, err error) {
 
 //SetIF устанавливает сигнал ИФ
 func (dev *BinaryDevice) SetIF(state uint8) (err error) {
+	return dev.SetIFCtx(context.Background(), state)
+}
+
+//SetIFCtx то же, что SetIF, но с возможностью отмены и ограничения времени через ctx.
+func (dev *BinaryDevice) SetIFCtx(ctx context.Context, state uint8) (err error) {
 	if nil == dev {
-		err = errors.New("SetIF():" + binErrorNoDevice)
+		err = fmt.Errorf("SetIF():%w", binErrorNoDevice)
 		return
 	}
 	if state >= IFMax {
-		err = errors.New("SetIF():" + binErrorWrongParam)
+		err = fmt.Errorf("SetIF():%w", binErrorWrongParam)
 		return
 	}
 
 	if !dev.opened() {
-		err = errors.New(binErrorNoConnection)
+		err = fmt.Errorf("SetIF():%w", binErrorNoConnection)
 		return
 	}
 
-	err = dev.deviceIoControl(VendorRequestOutput, 0xB1, []byte{state}, 1)
+	err = dev.retry(ctx, func(ctx context.Context) error {
+		return dev.deviceIoControl(ctx, VendorRequestOutput, 0xB1, []byte{state}, 1)
+	})
 
 	if nil != err {
-		err = errors.New("SetIF():" + err.Error())
+		err = fmt.Errorf("SetIF():%w", err)
 	}
 
 	return
@@ -286,21 +304,28 @@ func (dev *BinaryDevice) SetIF(state uint8) (err error) {
 
 //GetOutputTURT возвращает состояние сигнала TURT
 func (dev *BinaryDevice) GetOutputTURT() (val bool, err error) {
+	return dev.GetOutputTURTCtx(context.Background())
+}
+
+//GetOutputTURTCtx то же, что GetOutputTURT, но с возможностью отмены и ограничения времени через ctx.
+func (dev *BinaryDevice) GetOutputTURTCtx(ctx context.Context) (val bool, err error) {
 	if nil == dev {
-		err = errors.New("GetOutputTURT():" + binErrorNoDevice)
+		err = fmt.Errorf("GetOutputTURT():%w", binErrorNoDevice)
 		return
 	}
 	if !dev.opened() {
-		err = errors.New("GetOutputTURT():" + binErrorNoConnection)
+		err = fmt.Errorf("GetOutputTURT():%w", binErrorNoConnection)
 		return
 	}
 
 	state := make([]byte, 1)
 
-	err = dev.deviceIoControl(VendorRequestInput, 0xB2, state, 1)
+	err = dev.retry(ctx, func(ctx context.Context) error {
+		return dev.deviceIoControl(ctx, VendorRequestInput, 0xB2, state, 1)
+	})
 
 	if nil != err {
-		err = errors.New("GetOutputTURT():" + err.Error())
+		err = fmt.Errorf("GetOutputTURT():%w", err)
 		return
 	}
 
@@ -311,12 +336,17 @@ func (dev *BinaryDevice) GetOutputTURT() (val bool, err error) {
 
 //SetTURT устанавливает сигнал ИФ
 func (dev *BinaryDevice) SetTURT(val bool) (err error) {
+	return dev.SetTURTCtx(context.Background(), val)
+}
+
+//SetTURTCtx то же, что SetTURT, но с возможностью отмены и ограничения времени через ctx.
+func (dev *BinaryDevice) SetTURTCtx(ctx context.Context, val bool) (err error) {
 	if nil == dev {
-		err = errors.New("SetTURT():" + binErrorNoDevice)
+		err = fmt.Errorf("SetTURT():%w", binErrorNoDevice)
 		return
 	}
 	if !dev.opened() {
-		err = errors.New("SetTURT():" + binErrorNoConnection)
+		err = fmt.Errorf("SetTURT():%w", binErrorNoConnection)
 		return
 	}
 
@@ -326,10 +356,12 @@ func (dev *BinaryDevice) SetTURT(val bool) (err error) {
 		state = 1
 	}
 
-	err = dev.deviceIoControl(VendorRequestOutput, 0xB2, []byte{state}, 1)
+	err = dev.retry(ctx, func(ctx context.Context) error {
+		return dev.deviceIoControl(ctx, VendorRequestOutput, 0xB2, []byte{state}, 1)
+	})
 
 	if nil != err {
-		err = errors.New("SetTURT():" + err.Error())
+		err = fmt.Errorf("SetTURT():%w", err)
 	}
 
 	return
@@ -337,24 +369,24 @@ func (dev *BinaryDevice) SetTURT(val bool) (err error) {
 
 //////////////////////////////////////////////////////////////
 
-func (dev *BinaryDevice) getDataUSB() (bindata binaryData, err error) {
+func (dev *BinaryDevice) getDataUSB(ctx context.Context) (bindata binaryData, err error) {
 	if nil == dev {
-		err = errors.New("BinaryDevice.getDataUSB():" + binErrorNoDevice)
+		err = fmt.Errorf("BinaryDevice.getDataUSB():%w", binErrorNoDevice)
 		return
 	}
 
 	if !dev.opened() {
-		err = errors.New("BinaryDevice.getDataUSB():" + binErrorNoConnection)
+		err = fmt.Errorf("BinaryDevice.getDataUSB():%w", binErrorNoConnection)
 		return
 	}
 
 	binaryDataSize := len(bindata.data)
 	data := make([]byte, binaryDataSize)
 
-	err = dev.deviceIoControl(VendorRequestInput, 0xB0, data, len(data))
+	err = dev.deviceIoControl(ctx, VendorRequestInput, 0xB0, data, len(data))
 
 	if nil != err {
-		err = errors.New("BinaryDevice.getDataUSB():" + err.Error())
+		err = fmt.Errorf("BinaryDevice.getDataUSB():%w", err)
 		return
 	}
 
@@ -365,32 +397,180 @@ func (dev *BinaryDevice) getDataUSB() (bindata binaryData, err error) {
 	return
 }
 
-func (dev *BinaryDevice) setDataUSB(bindata binaryData) (err error) {
+func (dev *BinaryDevice) setDataUSB(ctx context.Context, bindata binaryData) (err error) {
 	if nil == dev {
-		err = errors.New("BinaryDevice.setDataUSB():" + binErrorNoDevice)
+		err = fmt.Errorf("BinaryDevice.setDataUSB():%w", binErrorNoDevice)
 		return
 	}
 
 	if !dev.opened() {
-		err = errors.New("BinaryDevice.setDataUSB():" + binErrorNoConnection)
+		err = fmt.Errorf("BinaryDevice.setDataUSB():%w", binErrorNoConnection)
 		return
 	}
 
-	err = dev.deviceIoControl(VendorRequestOutput, 0xB0, bindata.data[:], len(bindata.data))
+	err = dev.deviceIoControl(ctx, VendorRequestOutput, 0xB0, bindata.data[:], len(bindata.data))
 
 	if nil != err {
-		err = errors.New("BinaryDevice.setDataUSB():" + err.Error())
+		err = fmt.Errorf("BinaryDevice.setDataUSB():%w", err)
 	}
 	return
 }
 
+// Потокобезопасный обмен данными с микроконтроллером.
+func (dev *BinaryDevice) deviceIoControl(ctx context.Context, direction, request byte, bytes []byte, length int) (err error) {
+	if nil == dev {
+		err = fmt.Errorf("deviceIoControl():%w", binErrorNoDevice)
+		return
+	}
+	err = dev.transfer(ctx, direction, request, bytes, length)
+	// команды обновления прошивки для восстановления не запоминаются
+	if nil == err && VendorRequestOutput == direction && 0xB4 != request {
+		dev.remember(int(request), request, bytes[:length])
+	}
+	return
+}
+
+// opened показывает открыто ли соединение с ФДС-3
+func (dev *BinaryDevice) opened() bool {
+	if nil == dev {
+		return false
+	}
+	return dev.connected()
+}
+
 //Open соединиться с ФДС-3
 func (dev *BinaryDevice) Open() (ok bool) {
 	if dev == nil {
 		return
 	}
-	dev.handle, ok = USBOpen(IDProductBIN)
+	reopen := func() (Transport, bool) { return OpenUSBTransport(IDProductBIN) }
+	var t Transport
+	t, ok = reopen()
+	if ok {
+		dev.attach(t, reopen)
+		dev.detectCapabilities(context.Background(), IDProductBIN, dev.GetVersionCtx)
+	}
 	return
 }
 
+// GetProductID возвращает идентификатор продукта ФДС-3
+func (dev *BinaryDevice) GetProductID() uint16 {
+	if nil != dev {
+		return IDProductBIN
+	}
+	return 0
+}
+
+// OpenBy соединиться с конкретной платой ФДС-3 из списка Enumerate.
+func (dev *BinaryDevice) OpenBy(info DeviceInfo) (ok bool) {
+	if dev == nil || IDProductBIN != info.ProductID {
+		return
+	}
+	reopen := func() (Transport, bool) { return OpenUSBTransportBy(info) }
+	var t Transport
+	t, ok = reopen()
+	if ok {
+		dev.attach(t, reopen)
+		dev.detectCapabilities(context.Background(), IDProductBIN, dev.GetVersionCtx)
+	}
+	return
+}
+
+// OpenTransport соединиться с ФДС-3 через заданный транспорт.
+// Возможности платы определяются без обмена с ней (см. DetectCapabilities).
+func (dev *BinaryDevice) OpenTransport(t Transport) (ok bool) {
+	if dev == nil || t == nil {
+		return
+	}
+	dev.attach(t, nil)
+	dev.detectCapabilities(context.Background(), IDProductBIN, nil)
+	ok = true
+	return
+}
+
+// Close закрыть соединение с ФДС-3
+func (dev *BinaryDevice) Close() {
+	if dev == nil {
+		return
+	}
+	dev.close()
+}
+
+// Active показывает активно ли соединение с ФДС-3
+func (dev *BinaryDevice) Active() (ok bool) {
+	if dev == nil {
+		return
+	}
+	return dev.present()
+}
+
+// SetRetryPolicy задаёт политику повторных попыток обмена с ФДС-3
+func (dev *BinaryDevice) SetRetryPolicy(p RetryPolicy) {
+	if dev == nil {
+		return
+	}
+	dev.setRetryPolicy(p)
+}
+
+// GetRetryPolicy возвращает политику повторных попыток обмена с ФДС-3
+func (dev *BinaryDevice) GetRetryPolicy() (p RetryPolicy) {
+	if dev == nil {
+		return DefaultRetryPolicy()
+	}
+	return dev.retryPolicy()
+}
+
+// GetRetryStats возвращает счётчики операций, повторных попыток и ошибок обмена с ФДС-3
+func (dev *BinaryDevice) GetRetryStats() (st RetryStats) {
+	if dev == nil {
+		return
+	}
+	return dev.stats.get()
+}
+
+// ResetRetryStats обнуляет счётчики обмена с ФДС-3
+func (dev *BinaryDevice) ResetRetryStats() {
+	if dev == nil {
+		return
+	}
+	dev.stats.reset()
+}
+
+// Capabilities возвращает возможности ФДС-3, определённые при открытии платы
+// или последним вызовом DetectCapabilities
+func (dev *BinaryDevice) Capabilities() (caps Capabilities) {
+	if dev == nil {
+		return
+	}
+	return dev.capabilities()
+}
+
+// DetectCapabilities запрашивает у ФДС-3 версию прошивки и по ней уточняет возможности платы
+func (dev *BinaryDevice) DetectCapabilities() (caps Capabilities, err error) {
+	return dev.DetectCapabilitiesCtx(context.Background())
+}
+
+// DetectCapabilitiesCtx то же, что DetectCapabilities, но с возможностью отмены и ограничения времени через ctx.
+func (dev *BinaryDevice) DetectCapabilitiesCtx(ctx context.Context) (caps Capabilities, err error) {
+	if dev == nil {
+		err = fmt.Errorf("BinaryDevice.DetectCapabilities():%w", binErrorNoDevice)
+		return
+	}
+	caps, err = dev.detectCapabilities(ctx, dev.GetProductID(), dev.GetVersionCtx)
+	if nil != err {
+		err = fmt.Errorf("BinaryDevice.DetectCapabilities():%w", err)
+	}
+	return
+}
+
+// SetCapabilities заменяет возможности ФДС-3, определённые библиотекой
+// (например, для платы с нестандартной прошивкой)
+func (dev *BinaryDevice) SetCapabilities(caps Capabilities) {
+	if dev == nil {
+		return
+	}
+	caps.ProductID = dev.GetProductID()
+	dev.setCapabilities(caps)
+}
+
 //TODO: контролировать время обращения по USB для функций TURT и IF?
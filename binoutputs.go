@@ -0,0 +1,99 @@
+package ipk
+
+import (
+	"context"
+	"fmt"
+)
+
+// маска 36 выходов 50 В без 28-го выхода (сигнал ИФ)
+const binMask50V = (uint64(1)<<36 - 1) &^ (uint64(1) << binIF50V)
+
+// BinaryOutputs набор выходов ФДС-3, которые надо одновременно включить или выключить
+// (см. BinaryDevice.UpdateOutputs). Младший бит маски соответствует первому выходу.
+// Если выход указан и во включаемых, и в выключаемых, он будет включен.
+type BinaryOutputs struct {
+	Set10V   uint8  // 10 В выходы, которые надо включить
+	Clear10V uint8  // 10 В выходы, которые надо выключить
+	Set50V   uint64 // 50 В выходы, которые надо включить (от 0 до 35)
+	Clear50V uint64 // 50 В выходы, которые надо выключить (от 0 до 35)
+}
+
+// On10V включает 10 В выход num (от 0 до 7)
+func (out *BinaryOutputs) On10V(num uint) *BinaryOutputs {
+	if nil != out && num < 8 {
+		out.Set10V |= 1 << num
+		out.Clear10V &^= 1 << num
+	}
+	return out
+}
+
+// Off10V выключает 10 В выход num (от 0 до 7)
+func (out *BinaryOutputs) Off10V(num uint) *BinaryOutputs {
+	if nil != out && num < 8 {
+		out.Clear10V |= 1 << num
+		out.Set10V &^= 1 << num
+	}
+	return out
+}
+
+// On50V включает 50 В выход num (от 0 до 35)
+func (out *BinaryOutputs) On50V(num uint) *BinaryOutputs {
+	if nil != out && num < 36 {
+		out.Set50V |= uint64(1) << num
+		out.Clear50V &^= uint64(1) << num
+	}
+	return out
+}
+
+// Off50V выключает 50 В выход num (от 0 до 35)
+func (out *BinaryOutputs) Off50V(num uint) *BinaryOutputs {
+	if nil != out && num < 36 {
+		out.Clear50V |= uint64(1) << num
+		out.Set50V &^= uint64(1) << num
+	}
+	return out
+}
+
+// apply изменяет выходной образ ФДС-3. Выход включен, когда бит сброшен
+// (так было в SRS_BIN2_Set (SrsBin2.cpp, srs2.dll)). 28-й выход 50 В (ИФ) не меняется.
+func (out *BinaryOutputs) apply(bindata *binaryData) {
+	bindata.data[0] |= out.Clear10V
+	bindata.data[0] &^= out.Set10V
+
+	ibs := bindata.Uint64()
+	ibs |= (out.Clear50V & binMask50V) << 8 // нумерация 50 В сигналов начинается с 8 бита
+	ibs &^= (out.Set50V & binMask50V) << 8
+	bindata.SetUint64(ibs)
+}
+
+// UpdateOutputs за одно чтение и одну запись по USB включает и выключает
+// выходы 10 В и 50 В ФДС-3, указанные в out. Остальные выходы, а также сигнал ИФ
+// (28-й выход 50 В) не меняются. Одновременные вызовы из разных горутин
+// выполняются по очереди, поэтому изменения не теряются.
+func (dev *BinaryDevice) UpdateOutputs(out BinaryOutputs) (err error) {
+	return dev.UpdateOutputsCtx(context.Background(), out)
+}
+
+// UpdateOutputsCtx то же, что UpdateOutputs, но с возможностью отмены и ограничения времени через ctx.
+func (dev *BinaryDevice) UpdateOutputsCtx(ctx context.Context, out BinaryOutputs) (err error) {
+	if nil == dev {
+		err = fmt.Errorf("UpdateOutputs():%w", binErrorNoDevice)
+		return
+	}
+	if out.Set50V|out.Clear50V >= uint64(1)<<36 {
+		err = fmt.Errorf("UpdateOutputs():%w", binErrorWrongParam)
+		return
+	}
+	err = dev.retry(ctx, func(ctx context.Context) (err error) {
+		dev.mutexRMW.Lock()
+		defer dev.mutexRMW.Unlock()
+		var bindata binaryData
+		bindata, err = dev.getDataUSB(ctx)
+		if nil == err {
+			out.apply(&bindata)
+			err = dev.setDataUSB(ctx, bindata)
+		}
+		return
+	})
+	return
+}
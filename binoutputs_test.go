@@ -0,0 +1,117 @@
+package ipk
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUpdateOutputsConcurrent(t *testing.T) {
+	dev, sim, _ := openBinarySimulator(t)
+	// часть выходов включена заранее: горутины их выключают
+	if err := dev.UintSet10V(0xF0); nil != err {
+		t.Fatal(err)
+	}
+	if err := dev.UintSet50V(0xF0_0000_0000); nil != err {
+		t.Fatal(err)
+	}
+
+	// каждая горутина меняет свой выход: изменения не должны теряться
+	var wg sync.WaitGroup
+	errs := make(chan error, 8+36)
+	for num := uint(0); num < 8; num++ {
+		wg.Add(1)
+		go func(num uint) {
+			defer wg.Done()
+			var out BinaryOutputs
+			if num < 4 {
+				out.On10V(num)
+			} else {
+				out.Off10V(num)
+			}
+			errs <- dev.UpdateOutputs(out)
+		}(num)
+	}
+	for num := uint(0); num < 36; num++ {
+		if binIF50V == num {
+			continue
+		}
+		wg.Add(1)
+		go func(num uint) {
+			defer wg.Done()
+			var out BinaryOutputs
+			if num < 32 {
+				out.On50V(num)
+			} else {
+				out.Off50V(num)
+			}
+			errs <- dev.UpdateOutputs(out)
+		}(num)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if nil != err {
+			t.Error(err)
+		}
+	}
+
+	if got := sim.Output10V(); 0x0F != got {
+		t.Errorf("sim.Output10V() = %02X, want 0F", got)
+	}
+	if want := uint64(0xFFFF_FFFF) &^ (1 << binIF50V); want != sim.Output50V()&^(1<<binIF50V) {
+		t.Errorf("sim.Output50V() = %X, want %X", sim.Output50V(), want)
+	}
+}
+
+func TestUpdateOutputsKeepIF(t *testing.T) {
+	const ms = time.Millisecond
+	tests := []struct {
+		name    string
+		code    uint8
+		elapsed time.Duration // время после установки кода ИФ до обновления
+		out     BinaryOutputs
+	}{
+		{"ИФ включен, включение выходов", IFEnable, 0, BinaryOutputs{Set50V: 0xF_FFFF_FFFF}},
+		{"ИФ включен, выключение выходов", IFEnable, 0, BinaryOutputs{Clear50V: 0xF_FFFF_FFFF}},
+		{"ИФ выключен, включение выходов", IFDisable, 0, BinaryOutputs{Set50V: 0xF_FFFF_FFFF}},
+		{"З 1,6: импульс", IFGreen16, 20 * ms, BinaryOutputs{Clear50V: 1 << binIF50V, Set50V: 1}},
+		{"З 1,6: интервал", IFGreen16, 400 * ms, BinaryOutputs{Set50V: 1 << binIF50V, Clear50V: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dev, sim, clock := openBinarySimulator(t)
+			if err := dev.SetIF(tt.code); nil != err {
+				t.Fatal(err)
+			}
+			if err := dev.SetTURT(true); nil != err {
+				t.Fatal(err)
+			}
+			clock.add(tt.elapsed)
+			active := sim.IFActive()
+			if err := dev.UpdateOutputs(tt.out); nil != err {
+				t.Fatal(err)
+			}
+			// бит 28 записан в том состоянии, в котором был прочитан
+			if got := sim.IFActive(); active != got {
+				t.Errorf("IFActive() сразу после записи = %v, want %v", got, active)
+			}
+			// до обновления все выходы выключены
+			want50V := tt.out.Set50V &^ (1 << binIF50V)
+			if got := sim.Output50V() &^ (1 << binIF50V); want50V != got {
+				t.Errorf("sim.Output50V() = %X, want %X", got, want50V)
+			}
+			if code, err := dev.GetOutputIF(); nil != err || tt.code != code {
+				t.Errorf("GetOutputIF() = %d, %v, want %d", code, err, tt.code)
+			}
+			if !sim.TURT() {
+				t.Error("sim.TURT() = false после UpdateOutputs")
+			}
+			// микроконтроллер продолжает выводить код ИФ
+			clock.add(binIFOverrideDelay)
+			if want := simIFActive(tt.code, tt.elapsed+binIFOverrideDelay); want != sim.IFActive() {
+				t.Errorf("IFActive() = %v, want %v", sim.IFActive(), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,318 @@
+package ipk
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// номер бита сигнала ИФ в выходном образе ФДС-3 (28-й выход 50 В)
+const binIFBit = binIF50V + 8
+
+var simErrorIFUnsupported = newError(ErrInvalidParam, `Симулятор ФДС-3 не поддерживает коды ИФ КПТШ-7 (цикл 1,9 с)`, `FDS-3 simulator does not support KPTSh-7 IF codes (1.9 s cycle)`)
+
+// через это время после записи микроконтроллер восстанавливает состояние выхода ИФ
+const binIFOverrideDelay = 10 * time.Millisecond
+
+// simIFCodes - кодовые последовательности сигнала ИФ: длительности чередующихся
+// импульсов и интервалов, начиная с импульса, для кодов трансмиттера КПТШ-5
+// (IFRedYellow16, IFYellow16, IFGreen16). Цикл кодов З и Ж - 1,6 с, кода КЖ - 0,8 с.
+// Значения - номинальные длительности кодов АЛСН; с прошивкой ФДС-3 они не сверялись:
+// симулятор воспроизводит структуру кода, а не точные времена платы.
+// Коды КПТШ-7 (IFRedYellow19, IFYellow19, IFGreen19) симулятор не поддерживает
+// (см. simErrorIFUnsupported).
+var simIFCodes = map[uint8][]time.Duration{
+	IFRedYellow16: {230 * time.Millisecond, 570 * time.Millisecond},
+	IFYellow16:    {380 * time.Millisecond, 120 * time.Millisecond, 380 * time.Millisecond, 720 * time.Millisecond},
+	IFGreen16:     {350 * time.Millisecond, 120 * time.Millisecond, 220 * time.Millisecond, 120 * time.Millisecond, 220 * time.Millisecond, 570 * time.Millisecond},
+}
+
+// simIFActive вычисляет состояние выхода ИФ для кода code через время elapsed после его установки.
+func simIFActive(code uint8, elapsed time.Duration) bool {
+	switch code {
+	case IFDisable:
+		return false
+	case IFEnable:
+		return true
+	}
+	seq, ok := simIFCodes[code]
+	if !ok {
+		return false
+	}
+	var cycle time.Duration
+	for _, d := range seq {
+		cycle += d
+	}
+	pos := elapsed % cycle
+	for i, d := range seq {
+		if pos < d {
+			return 0 == i%2 // чётные элементы - импульсы
+		}
+		pos -= d
+	}
+	return false
+}
+
+// BinarySimulator - программная модель ФДС-3, реализующая Transport.
+// Хранит 8-байтный выходной образ (запрос 0xB0) с инверсной логикой,
+// как в SRS_BIN2_Set: сброшенный бит означает включенный выход.
+// Код ИФ задаётся запросом 0xB1, сигнал TURT - запросом 0xB2. Коды ИФ КПТШ-7
+// (IFRedYellow19, IFYellow19, IFGreen19) не поддерживаются: запись такого кода
+// завершается ошибкой simErrorIFUnsupported.
+// Выход ИФ (28-й выход 50 В) формирует микроконтроллер: записанное приложением
+// значение этого бита держится binIFOverrideDelay, затем заменяется на текущее состояние кода ИФ.
+// Также поддерживается канал версии/обновления прошивки (0xB4).
+type BinarySimulator struct {
+	mutex sync.Mutex
+	now   func() time.Time
+
+	image     uint64
+	written   time.Time // время последней записи выходного образа
+	ifCode    uint8
+	ifChanged time.Time // время установки кода ИФ
+	turt      bool
+
+	firmware simFirmware
+
+	disconnected bool
+}
+
+// NewBinarySimulator создаёт симулятор ФДС-3 с версией прошивки 1.0.0. Все выходы выключены.
+func NewBinarySimulator() *BinarySimulator {
+	sim := &BinarySimulator{now: time.Now, image: ^uint64(0), firmware: simFirmware{major: 1}}
+	sim.written = sim.now()
+	sim.ifChanged = sim.written
+	return sim
+}
+
+// SetClock заменяет источник времени симулятора (например, для детерминированных тестов).
+func (sim *BinarySimulator) SetClock(now func() time.Time) {
+	if nil == sim || nil == now {
+		return
+	}
+	sim.mutex.Lock()
+	sim.now = now
+	sim.written = now()
+	sim.ifChanged = sim.written
+	sim.mutex.Unlock()
+}
+
+// currentImage возвращает выходной образ с учётом выхода ИФ, формируемого микроконтроллером.
+// Вызывается с захваченным mutex.
+func (sim *BinarySimulator) currentImage() uint64 {
+	t := sim.now()
+	if t.Sub(sim.written) < binIFOverrideDelay {
+		return sim.image
+	}
+	image := sim.image | uint64(1)<<binIFBit
+	if simIFActive(sim.ifCode, t.Sub(sim.ifChanged)) {
+		image &^= uint64(1) << binIFBit
+	}
+	return image
+}
+
+// ControlIn реализует чтение данных из ФДС-3.
+func (sim *BinarySimulator) ControlIn(request byte, data []byte, timeout time.Duration) (n int, err error) {
+	if nil == sim {
+		err = fmt.Errorf("BinarySimulator.ControlIn():%w", binErrorNoDevice)
+		return
+	}
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	if sim.disconnected {
+		err = fmt.Errorf("BinarySimulator.ControlIn():%w", simErrorDisconnected)
+		return
+	}
+
+	switch request {
+	case 0xB0:
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], sim.currentImage())
+		n = copy(data, buf[:])
+	case 0xB1:
+		n = copy(data, []byte{sim.ifCode})
+	case 0xB2:
+		var state byte
+		if sim.turt {
+			state = 1
+		}
+		n = copy(data, []byte{state})
+	case 0xB4:
+		if sim.firmware.legacy() { // старая ревизия платы не знает запроса версии
+			err = fmt.Errorf("BinarySimulator.ControlIn():%w", simErrorUnknownRequest)
+			return
+		}
+		n = sim.firmware.version(data)
+	default:
+		err = fmt.Errorf("BinarySimulator.ControlIn():%w", simErrorUnknownRequest)
+	}
+	return
+}
+
+// ControlOut реализует запись данных в ФДС-3.
+func (sim *BinarySimulator) ControlOut(request byte, data []byte, timeout time.Duration) (n int, err error) {
+	if nil == sim {
+		err = fmt.Errorf("BinarySimulator.ControlOut():%w", binErrorNoDevice)
+		return
+	}
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	if sim.disconnected {
+		err = fmt.Errorf("BinarySimulator.ControlOut():%w", simErrorDisconnected)
+		return
+	}
+
+	switch request {
+	case 0xB0:
+		if len(data) < 8 {
+			err = fmt.Errorf("BinarySimulator.ControlOut():%w", simErrorShortData)
+			return
+		}
+		sim.image = binary.LittleEndian.Uint64(data)
+		sim.written = sim.now()
+		n = 8
+	case 0xB1:
+		if 0 == len(data) {
+			err = fmt.Errorf("BinarySimulator.ControlOut():%w", simErrorShortData)
+			return
+		}
+		switch code := data[0]; {
+		case code >= IFMax:
+			err = fmt.Errorf("BinarySimulator.ControlOut():%w", binErrorWrongParam)
+			return
+		case IFRedYellow19 == code, IFYellow19 == code, IFGreen19 == code:
+			err = fmt.Errorf("BinarySimulator.ControlOut():%w", simErrorIFUnsupported)
+			return
+		}
+		if data[0] != sim.ifCode {
+			sim.ifCode = data[0]
+			sim.ifChanged = sim.now()
+		}
+		n = 1
+	case 0xB2:
+		if 0 == len(data) {
+			err = fmt.Errorf("BinarySimulator.ControlOut():%w", simErrorShortData)
+			return
+		}
+		sim.turt = data[0] != 0
+		n = 1
+	case 0xB4:
+		if sim.firmware.legacy() {
+			err = fmt.Errorf("BinarySimulator.ControlOut():%w", simErrorUnknownRequest)
+			return
+		}
+		err = sim.firmware.update(data)
+		n = len(data)
+	default:
+		err = fmt.Errorf("BinarySimulator.ControlOut():%w", simErrorUnknownRequest)
+	}
+	return
+}
+
+// SetConnected имитирует отключение (false) и подключение (true) кабеля USB.
+// Пока плата отключена, обмен данными завершается ошибкой, а Present возвращает false.
+func (sim *BinarySimulator) SetConnected(connected bool) {
+	if nil == sim {
+		return
+	}
+	sim.mutex.Lock()
+	sim.disconnected = !connected
+	sim.mutex.Unlock()
+}
+
+// Present показывает, подключена ли плата (см. SetConnected).
+func (sim *BinarySimulator) Present() (ok bool) {
+	if nil == sim {
+		return
+	}
+	sim.mutex.Lock()
+	ok = !sim.disconnected
+	sim.mutex.Unlock()
+	return
+}
+
+// Close ничего не делает: состояние симулятора сохраняется, как у включенной платы.
+func (sim *BinarySimulator) Close() error {
+	return nil
+}
+
+// Image возвращает выходной образ в том виде, в котором его хранит микроконтроллер (с инверсией).
+func (sim *BinarySimulator) Image() (image uint64) {
+	if nil == sim {
+		return
+	}
+	sim.mutex.Lock()
+	image = sim.currentImage()
+	sim.mutex.Unlock()
+	return
+}
+
+// Output10V возвращает состояние 10 В выходов. Младший бит соответствует первому выходу.
+func (sim *BinarySimulator) Output10V() uint8 {
+	if nil == sim {
+		return 0
+	}
+	return ^uint8(sim.Image())
+}
+
+// Output50V возвращает состояние 36 выходов 50 В (включая 28-й выход ИФ).
+// Младший бит соответствует первому выходу.
+func (sim *BinarySimulator) Output50V() uint64 {
+	if nil == sim {
+		return 0
+	}
+	return ^(sim.Image() >> 8) & (1<<36 - 1)
+}
+
+// IFActive показывает, включен ли сейчас выход сигнала ИФ.
+func (sim *BinarySimulator) IFActive() bool {
+	return 0 != sim.Output50V()&(1<<binIF50V)
+}
+
+// IF возвращает установленный код ИФ.
+func (sim *BinarySimulator) IF() (code uint8) {
+	if nil == sim {
+		return
+	}
+	sim.mutex.Lock()
+	code = sim.ifCode
+	sim.mutex.Unlock()
+	return
+}
+
+// TURT возвращает состояние сигнала TURT.
+func (sim *BinarySimulator) TURT() (val bool) {
+	if nil == sim {
+		return
+	}
+	sim.mutex.Lock()
+	val = sim.turt
+	sim.mutex.Unlock()
+	return
+}
+
+// SetVersion задаёт версию прошивки. Версия 0.0.0 соответствует старой ревизии платы,
+// которая не знает запроса версии и команд обновления (0xB4).
+// После записи прошивки и перезагрузки с другого банка памяти симулятор сообщает
+// версию с номером патча на 1 больше, после возврата на прежний банк - прежнюю.
+func (sim *BinarySimulator) SetVersion(major, minor, patch uint32) {
+	if nil == sim {
+		return
+	}
+	sim.mutex.Lock()
+	sim.firmware.major, sim.firmware.minor, sim.firmware.patch = major, minor, patch
+	sim.mutex.Unlock()
+}
+
+// Flash возвращает копию слов прошивки, записанных последним обновлением, и номер текущего банка памяти.
+func (sim *BinarySimulator) Flash() (words map[uint32]uint32, bank int) {
+	if nil == sim {
+		return
+	}
+	sim.mutex.Lock()
+	words = sim.firmware.words()
+	bank = sim.firmware.bank
+	sim.mutex.Unlock()
+	return
+}
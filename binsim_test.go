@@ -0,0 +1,150 @@
+package ipk
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func openBinarySimulator(t *testing.T) (*BinaryDevice, *BinarySimulator, *fakeClock) {
+	t.Helper()
+	clock := newFakeClock()
+	sim := NewBinarySimulator()
+	sim.SetClock(clock.now)
+	dev := new(BinaryDevice)
+	if !dev.OpenTransport(sim) {
+		t.Fatal("OpenTransport() = false")
+	}
+	return dev, sim, clock
+}
+
+func TestBinarySimulatorIF(t *testing.T) {
+	const ms = time.Millisecond
+	tests := []struct {
+		name    string
+		code    uint8
+		elapsed time.Duration // время после установки кода
+		want    bool
+	}{
+		{"выключен", IFDisable, 500 * ms, false},
+		{"включен", IFEnable, 500 * ms, true},
+		{"КЖ 1,6: импульс", IFRedYellow16, 20 * ms, true},
+		{"КЖ 1,6: конец импульса", IFRedYellow16, 229 * ms, true},
+		{"КЖ 1,6: интервал", IFRedYellow16, 230 * ms, false},
+		{"КЖ 1,6: следующий цикл", IFRedYellow16, 810 * ms, true},
+		{"Ж 1,6: интервал между импульсами", IFYellow16, 400 * ms, false},
+		{"Ж 1,6: второй импульс", IFYellow16, 500 * ms, true},
+		{"Ж 1,6: интервал цикла", IFYellow16, 880 * ms, false},
+		{"Ж 1,6: следующий цикл", IFYellow16, 1600*ms + 20*ms, true},
+		{"З 1,6: третий импульс", IFGreen16, 900 * ms, true},
+		{"З 1,6: интервал цикла", IFGreen16, 1100 * ms, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dev, sim, clock := openBinarySimulator(t)
+			if err := dev.SetIF(tt.code); nil != err {
+				t.Fatal(err)
+			}
+			clock.add(tt.elapsed)
+			if got := sim.IFActive(); tt.want != got {
+				t.Errorf("IFActive() = %v, want %v", got, tt.want)
+			}
+			if code, err := dev.GetOutputIF(); nil != err || tt.code != code {
+				t.Errorf("GetOutputIF() = %d, %v, want %d", code, err, tt.code)
+			}
+		})
+	}
+}
+
+func TestBinarySimulatorIFUnsupported(t *testing.T) {
+	// длительности кодов КПТШ-7 не известны: симулятор отказывается их выводить
+	for _, code := range []uint8{IFRedYellow19, IFYellow19, IFGreen19} {
+		dev, sim, _ := openBinarySimulator(t)
+		if err := dev.SetIF(IFGreen16); nil != err {
+			t.Fatal(err)
+		}
+		if err := dev.SetIF(code); !errors.Is(err, simErrorIFUnsupported) || !errors.Is(err, ErrInvalidParam) {
+			t.Errorf("SetIF(%d) = %v, want %v", code, err, simErrorIFUnsupported)
+		}
+		if IFGreen16 != sim.IF() {
+			t.Errorf("SetIF(%d): код ИФ %d, want %d", code, sim.IF(), IFGreen16)
+		}
+	}
+}
+
+func TestBinarySimulatorIFOverride(t *testing.T) {
+	// записанное значение выхода ИФ держится binIFOverrideDelay, затем его заменяет код ИФ
+	tests := []struct {
+		name    string
+		code    uint8
+		out50V  uint64
+		elapsed time.Duration // время после записи
+		want    bool
+	}{
+		{"запись включает выход", IFDisable, 1 << binIF50V, binIFOverrideDelay / 2, true},
+		{"код выключает выход", IFDisable, 1 << binIF50V, binIFOverrideDelay, false},
+		{"запись выключает выход", IFEnable, 0, binIFOverrideDelay / 2, false},
+		{"код включает выход", IFEnable, 0, binIFOverrideDelay, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dev, sim, clock := openBinarySimulator(t)
+			if err := dev.SetIF(tt.code); nil != err {
+				t.Fatal(err)
+			}
+			clock.add(time.Second)
+			if err := dev.UintSet50V(tt.out50V); nil != err {
+				t.Fatal(err)
+			}
+			clock.add(tt.elapsed)
+			if got := sim.IFActive(); tt.want != got {
+				t.Errorf("IFActive() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBinarySimulatorOutputs(t *testing.T) {
+	tests := []struct {
+		name     string
+		set      func(dev *BinaryDevice) error
+		want10V  uint8
+		want50V  uint64
+		wantTURT bool
+	}{
+		{"все выключены", func(dev *BinaryDevice) error { return nil }, 0, 0, false},
+		{"выход 10 В", func(dev *BinaryDevice) error { return dev.Set10V(3, true) }, 0x08, 0, false},
+		{"все 10 В", func(dev *BinaryDevice) error { return dev.UintSet10V(0xA5) }, 0xA5, 0, false},
+		{"выход 50 В", func(dev *BinaryDevice) error { return dev.Set50V(35, true) }, 0, 1 << 35, false},
+		{"выход ИФ не меняется", func(dev *BinaryDevice) error { return dev.Set50V(binIF50V, true) }, 0, 0, false},
+		{"все 50 В", func(dev *BinaryDevice) error { return dev.UintSet50V(0x5) }, 0, 0x5, false},
+		{"TURT", func(dev *BinaryDevice) error { return dev.SetTURT(true) }, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dev, sim, clock := openBinarySimulator(t)
+			if err := tt.set(dev); nil != err {
+				t.Fatal(err)
+			}
+			clock.add(binIFOverrideDelay)
+			if got := sim.Output10V(); tt.want10V != got {
+				t.Errorf("sim.Output10V() = %02X, want %02X", got, tt.want10V)
+			}
+			if got := sim.Output50V(); tt.want50V != got {
+				t.Errorf("sim.Output50V() = %X, want %X", got, tt.want50V)
+			}
+			if got := sim.TURT(); tt.wantTURT != got {
+				t.Errorf("sim.TURT() = %v, want %v", got, tt.wantTURT)
+			}
+			if val, err := dev.UintGetOutput10V(); nil != err || tt.want10V != val {
+				t.Errorf("UintGetOutput10V() = %02X, %v, want %02X", val, err, tt.want10V)
+			}
+			if val, err := dev.UintGetOutput50V(); nil != err || tt.want50V != val&(1<<36-1) {
+				t.Errorf("UintGetOutput50V() = %X, %v, want %X", val, err, tt.want50V)
+			}
+			if val, err := dev.GetOutputTURT(); nil != err || tt.wantTURT != val {
+				t.Errorf("GetOutputTURT() = %v, %v, want %v", val, err, tt.wantTURT)
+			}
+		})
+	}
+}
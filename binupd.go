@@ -0,0 +1,91 @@
+package ipk
+
+import "context"
+
+var _ firmwareUpdater = (*BinaryDevice)(nil)
+
+// Обновление и версия прошивки ФДС-3: общая часть для всех плат ФПС-3 - в frequpd.go.
+
+// connection возвращает соединение с ФДС-3, nil если платы нет
+func (dev *BinaryDevice) connection() *usbConnection {
+	if nil == dev {
+		return nil
+	}
+	return &dev.usbConnection
+}
+
+// PrepareUpdate подготавливает запись обновления прошивки ФДС-3
+func (dev *BinaryDevice) PrepareUpdate() (err error) {
+	return dev.PrepareUpdateCtx(context.Background())
+}
+
+// PrepareUpdateCtx то же, что PrepareUpdate, но с возможностью отмены и ограничения времени через ctx.
+func (dev *BinaryDevice) PrepareUpdateCtx(ctx context.Context) (err error) {
+	return updateError("BinaryDevice.PrepareUpdate()", dev.connection().prepareUpdate(ctx))
+}
+
+// WriteUpdate записывает слово по адресу (пишет прошивку ФДС-3 в память)
+func (dev *BinaryDevice) WriteUpdate(uFlashAddress uint32, uWord uint32) (err error) {
+	return dev.WriteUpdateCtx(context.Background(), uFlashAddress, uWord)
+}
+
+// WriteUpdateCtx то же, что WriteUpdate, но с возможностью отмены и ограничения времени через ctx.
+func (dev *BinaryDevice) WriteUpdateCtx(ctx context.Context, uFlashAddress uint32, uWord uint32) (err error) {
+	return updateError("BinaryDevice.WriteUpdate()", dev.connection().writeUpdate(ctx, uFlashAddress, uWord))
+}
+
+// FinishUpdate завершает запись обновления прошивки ФДС-3
+func (dev *BinaryDevice) FinishUpdate() (err error) {
+	return dev.FinishUpdateCtx(context.Background())
+}
+
+// FinishUpdateCtx то же, что FinishUpdate, но с возможностью отмены и ограничения времени через ctx.
+func (dev *BinaryDevice) FinishUpdateCtx(ctx context.Context) (err error) {
+	return updateError("BinaryDevice.FinishUpdate()", dev.connection().finishUpdate(ctx))
+}
+
+// RestartToAnotherBank отправляет команду ФДС-3 перезагрузиться с другого банка памяти
+func (dev *BinaryDevice) RestartToAnotherBank() (err error) {
+	return dev.RestartToAnotherBankCtx(context.Background())
+}
+
+// RestartToAnotherBankCtx то же, что RestartToAnotherBank, но с возможностью отмены и ограничения времени через ctx.
+func (dev *BinaryDevice) RestartToAnotherBankCtx(ctx context.Context) (err error) {
+	return updateError("BinaryDevice.RestartToAnotherBank()", dev.connection().restartToAnotherBank(ctx))
+}
+
+// GetVersionString возвращает версию прошивки ФДС-3 (например, 1.0.0) в виде строки
+// Старая версия платы - 0.0.0
+func (dev *BinaryDevice) GetVersionString() (version string, err error) {
+	return dev.GetVersionStringCtx(context.Background())
+}
+
+// GetVersionStringCtx то же, что GetVersionString, но с возможностью отмены и ограничения времени через ctx.
+func (dev *BinaryDevice) GetVersionStringCtx(ctx context.Context) (version string, err error) {
+	version, err = dev.connection().getVersionString(ctx)
+	return version, updateError("BinaryDevice.GetVersion()", err)
+}
+
+// GetVersion возвращает версию прошивки ФДС-3 (например, 1.0.0)
+// Старая версия платы - 0.0.0: она не знает запроса 0xB4 и отвечает на него STALL
+// или не передаёт сигнатуру версии. Другие ошибки обмена возвращаются как ошибки.
+func (dev *BinaryDevice) GetVersion() (major, minor, patch uint32, err error) {
+	return dev.GetVersionCtx(context.Background())
+}
+
+// GetVersionCtx то же, что GetVersion, но с возможностью отмены и ограничения времени через ctx.
+func (dev *BinaryDevice) GetVersionCtx(ctx context.Context) (major, minor, patch uint32, err error) {
+	major, minor, patch, err = dev.connection().getVersion(ctx)
+	return major, minor, patch, updateError("BinaryDevice.GetVersion()", err)
+}
+
+// FlashFirmware записывает образ прошивки img в другой банк памяти ФДС-3 (см. FreqDevice.FlashFirmware)
+func (dev *BinaryDevice) FlashFirmware(img *FirmwareImage, opts FlashOptions) (version string, err error) {
+	return dev.FlashFirmwareCtx(context.Background(), img, opts)
+}
+
+// FlashFirmwareCtx то же, что FlashFirmware, но с возможностью отмены и ограничения времени через ctx.
+func (dev *BinaryDevice) FlashFirmwareCtx(ctx context.Context, img *FirmwareImage, opts FlashOptions) (version string, err error) {
+	version, err = flashFirmware(ctx, dev, img, opts)
+	return version, updateError("BinaryDevice.FlashFirmware()", err)
+}
@@ -0,0 +1,156 @@
+package ipk
+
+import (
+	"context"
+	"fmt"
+)
+
+// DACRange диапазон канала ЦАП ФАС-3
+type DACRange struct {
+	MaxDAC        uint16 // максимальное значение кода ЦАП
+	MaxMilliAmper uint16 // ток, соответствующий MaxDAC, мА
+}
+
+// Capabilities возможности платы ФПС-3, которые зависят от варианта и ревизии платы
+// и версии прошивки. Определяются при открытии платы: по идентификатору продукта,
+// а для плат, открытых через Open и OpenBy, ещё и по версии прошивки (см. DetectCapabilities).
+type Capabilities struct {
+	ProductID uint16
+	Detected  bool   // версия прошивки запрошена у платы; иначе - значения по умолчанию для варианта платы
+	Version   string // версия прошивки (Detected), 0.0.0 - старая ревизия платы
+
+	VersionQuery   bool // плата сообщает версию прошивки (ревизия на основе STM32)
+	FirmwareUpdate bool // плата поддерживает обновление прошивки (FlashFirmware)
+
+	DACBits int        // ФАС-3: разрядность ЦАП (12 или 16)
+	DAC     []DACRange // ФАС-3: диапазоны каналов ЦАП, от DAC1 до DAC14
+
+	// ФЧС-3: направление движения передаётся в плату инвертированным (MotionOnward - 0,
+	// MotionBackwards - 1). Так решили в мае 2022 года; прошивки STM32 до версии 1.0.0
+	// получают направление как есть (см. motionInverted).
+	MotionInverted bool
+	ADC            bool // ФЧС-3: есть режим АЦП (плата отвечает на запрос 0xB2)
+}
+
+// motionInvertedSince первая версия прошивки ФЧС-3 на основе STM32, которая получает
+// направление движения инвертированным
+var motionInvertedSince = [3]uint32{1, 0, 0}
+
+// motionInverted показывает, получает ли ФЧС-3 с прошивкой major.minor.patch
+// направление движения инвертированным. Старая ревизия платы (0.0.0) обновлена в мае 2022 года.
+func motionInverted(major, minor, patch uint32) bool {
+	if 0 == major && 0 == minor && 0 == patch {
+		return true
+	}
+	v := [3]uint32{major, minor, patch}
+	for i := range v {
+		if v[i] != motionInvertedSince[i] {
+			return v[i] > motionInvertedSince[i]
+		}
+	}
+	return true
+}
+
+// productCapabilities возвращает возможности варианта платы idProduct до запроса версии прошивки.
+// Версию считаем доступной для запроса: старые ревизии плат отвечают на него версией 0.0.0.
+func productCapabilities(idProduct uint16) (caps Capabilities) {
+	caps.ProductID = idProduct
+	caps.VersionQuery = true
+	switch idProduct {
+	case IDProductANL12bit, IDProductANL16bit:
+		caps.DACBits = 16
+		if IDProductANL12bit == idProduct {
+			caps.DACBits = 12
+		}
+		caps.DAC = make([]DACRange, analogCount)
+		for ch := range caps.DAC {
+			caps.DAC[ch].MaxDAC, caps.DAC[ch].MaxMilliAmper, _ = dacRange(idProduct, uint8(ch))
+		}
+	case IDProductFRQ:
+		caps.MotionInverted = true
+		caps.ADC = true
+	}
+	return
+}
+
+// setVersion уточняет возможности по версии прошивки
+func (caps *Capabilities) setVersion(major, minor, patch uint32) {
+	caps.Detected = true
+	caps.Version = fmt.Sprintf("%d.%d.%d", major, minor, patch)
+	// обновление прошивки и запрос версии появились в ревизии платы на основе STM32
+	legacy := 0 == major && 0 == minor && 0 == patch
+	caps.VersionQuery = !legacy
+	caps.FirmwareUpdate = !legacy
+	if IDProductFRQ == caps.ProductID {
+		caps.MotionInverted = motionInverted(major, minor, patch)
+	}
+}
+
+// dacChannel возвращает диапазон канала ЦАП ch
+func (caps *Capabilities) dacChannel(ch uint8) (maxDAC, maxMilliAmper uint16, ok bool) {
+	if int(ch) >= len(caps.DAC) || 0 == caps.DAC[ch].MaxDAC {
+		return
+	}
+	return caps.DAC[ch].MaxDAC, caps.DAC[ch].MaxMilliAmper, true
+}
+
+// motionToBoard возвращает значение направления движения direction для передачи в ФЧС-3
+func (caps *Capabilities) motionToBoard(direction uint8) (motion uint8, ok bool) {
+	switch direction {
+	case MotionOnward, MotionBackwards:
+	default:
+		return
+	}
+	motion = direction
+	if caps.MotionInverted {
+		motion ^= 1
+	}
+	return motion, true
+}
+
+// motionFromBoard возвращает направление движения по значению motion, которое вернула ФЧС-3
+func (caps *Capabilities) motionFromBoard(motion uint8) uint8 {
+	if motion > 1 {
+		return MotionUnknown
+	}
+	if caps.MotionInverted {
+		motion ^= 1
+	}
+	return motion
+}
+
+// capabilities возвращает возможности платы; до открытия - пустые
+func (c *usbConnection) capabilities() (caps Capabilities) {
+	if p := c.caps.Load(); nil != p {
+		caps = *p
+		caps.DAC = append([]DACRange(nil), p.DAC...)
+	}
+	return
+}
+
+func (c *usbConnection) setCapabilities(caps Capabilities) {
+	caps.DAC = append([]DACRange(nil), caps.DAC...)
+	c.caps.Store(&caps)
+}
+
+// versionGetter запрос версии прошивки платы
+type versionGetter func(ctx context.Context) (major, minor, patch uint32, err error)
+
+// detectCapabilities определяет возможности платы idProduct. Если getVersion не nil,
+// то версия прошивки запрашивается у платы. При ошибке запроса остаются определённые ранее
+// возможности, а если их ещё нет - значения по умолчанию для варианта платы.
+func (c *usbConnection) detectCapabilities(ctx context.Context, idProduct uint16, getVersion versionGetter) (caps Capabilities, err error) {
+	caps = productCapabilities(idProduct)
+	if nil != getVersion {
+		var major, minor, patch uint32
+		if major, minor, patch, err = getVersion(ctx); nil != err {
+			if prev := c.caps.Load(); nil != prev && idProduct == prev.ProductID {
+				return c.capabilities(), err
+			}
+		} else {
+			caps.setVersion(major, minor, patch)
+		}
+	}
+	c.setCapabilities(caps)
+	return
+}
@@ -0,0 +1,215 @@
+package ipk
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// Exchange один обмен с платой ФПС-3 (запрос производителя по нулевой конечной точке),
+// записанный Capture. В файле записи каждый обмен занимает одну строку JSON,
+// данные записываются в шестнадцатеричном виде.
+type Exchange struct {
+	Product   uint16        `json:"product"`              // плата: IDProductANL12bit, IDProductBIN и т.д.
+	Direction byte          `json:"direction"`            // VendorRequestInput или VendorRequestOutput
+	Request   byte          `json:"request"`              // код запроса, например 0xB0
+	Data      []byte        `json:"data"`                 // отправленные (Output) или полученные (Input) байты
+	N         int           `json:"n"`                    // результат ControlIn/ControlOut
+	Err       string        `json:"error,omitempty"`      // ошибка обмена
+	ErrKind   string        `json:"error_kind,omitempty"` // общий вид ошибки (см. ErrorKindName)
+	At        time.Duration `json:"at"`                   // время от начала записи
+	Duration  time.Duration `json:"duration"`             // длительность обмена
+}
+
+// MarshalJSON записывает Data в шестнадцатеричном виде
+func (e Exchange) MarshalJSON() ([]byte, error) {
+	type plain Exchange
+	return json.Marshal(struct {
+		plain
+		Data string `json:"data"`
+	}{plain(e), hex.EncodeToString(e.Data)})
+}
+
+// UnmarshalJSON читает Data в шестнадцатеричном виде
+func (e *Exchange) UnmarshalJSON(b []byte) (err error) {
+	type plain Exchange
+	var v struct {
+		plain
+		Data string `json:"data"`
+	}
+	if err = json.Unmarshal(b, &v); nil != err {
+		return
+	}
+	*e = Exchange(v.plain)
+	e.Data, err = hex.DecodeString(v.Data)
+	return
+}
+
+// errorFromExchange восстанавливает ошибку записанного обмена так,
+// чтобы errors.Is работал с ней так же, как с исходной
+func errorFromExchange(e *Exchange) error {
+	if "" == e.Err && "" == e.ErrKind {
+		return nil
+	}
+	err := errors.New(e.Err)
+	if kind := ErrorKind(e.ErrKind); nil != kind {
+		return wrapError(kind, err)
+	}
+	return err
+}
+
+// Capture записывает все обмены с платами ФПС-3 (направление, код запроса, данные,
+// результат и время) для последующего воспроизведения через Replay.
+// Одна запись может содержать обмен со всеми тремя платами стойки.
+type Capture struct {
+	mutex sync.Mutex
+	enc   *json.Encoder
+	start time.Time
+	now   func() time.Time
+	err   error
+	ipk   *IPK
+}
+
+// NewCapture создаёт запись обмена в w (JSON lines, одна запись Exchange на строку)
+func NewCapture(w io.Writer) *Capture {
+	return &Capture{enc: json.NewEncoder(w), start: time.Now(), now: time.Now}
+}
+
+// Transport оборачивает транспорт t платы product: обмен выполняется через t
+// и записывается в журнал.
+func (c *Capture) Transport(t Transport, product uint16) Transport {
+	if nil == c || nil == t {
+		return t
+	}
+	return &CaptureTransport{capture: c, t: t, product: product}
+}
+
+// Attach начинает запись обмена со всеми платами ipk, открытыми к моменту вызова
+// (например, после OpenAll). Запись продолжается после переподключения плат.
+func (c *Capture) Attach(ipk *IPK) {
+	if nil == c || nil == ipk {
+		return
+	}
+	c.Detach()
+	c.mutex.Lock()
+	c.ipk = ipk
+	c.mutex.Unlock()
+
+	if nil != ipk.AnalogDev {
+		product := ipk.AnalogDev.GetProductID()
+		ipk.AnalogDev.intercept(func(t Transport) Transport { return c.Transport(t, product) })
+	}
+	if nil != ipk.BinDev {
+		ipk.BinDev.intercept(func(t Transport) Transport { return c.Transport(t, IDProductBIN) })
+	}
+	if nil != ipk.FreqDev {
+		ipk.FreqDev.intercept(func(t Transport) Transport { return c.Transport(t, IDProductFRQ) })
+	}
+}
+
+// Detach прекращает запись обмена с платами, подключенными через Attach
+func (c *Capture) Detach() {
+	if nil == c {
+		return
+	}
+	c.mutex.Lock()
+	ipk := c.ipk
+	c.ipk = nil
+	c.mutex.Unlock()
+	if nil == ipk {
+		return
+	}
+	if nil != ipk.AnalogDev {
+		ipk.AnalogDev.intercept(nil)
+	}
+	if nil != ipk.BinDev {
+		ipk.BinDev.intercept(nil)
+	}
+	if nil != ipk.FreqDev {
+		ipk.FreqDev.intercept(nil)
+	}
+}
+
+// Err возвращает первую ошибку записи в журнал
+func (c *Capture) Err() error {
+	if nil == c {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.err
+}
+
+// write добавляет обмен в журнал
+func (c *Capture) write(e *Exchange) {
+	c.mutex.Lock()
+	if err := c.enc.Encode(e); nil != err && nil == c.err {
+		c.err = err
+	}
+	c.mutex.Unlock()
+}
+
+// CaptureTransport транспорт, записывающий обмен с платой (см. Capture.Transport)
+type CaptureTransport struct {
+	capture *Capture
+	t       Transport
+	product uint16
+}
+
+// ControlIn читает данные из устройства и записывает обмен
+func (ct *CaptureTransport) ControlIn(request byte, data []byte, timeout time.Duration) (n int, err error) {
+	return ct.control(VendorRequestInput, request, data, timeout)
+}
+
+// ControlOut отправляет данные в устройство и записывает обмен
+func (ct *CaptureTransport) ControlOut(request byte, data []byte, timeout time.Duration) (n int, err error) {
+	return ct.control(VendorRequestOutput, request, data, timeout)
+}
+
+func (ct *CaptureTransport) control(direction, request byte, data []byte, timeout time.Duration) (n int, err error) {
+	start := ct.capture.now()
+	if VendorRequestInput == direction {
+		n, err = ct.t.ControlIn(request, data, timeout)
+	} else {
+		n, err = ct.t.ControlOut(request, data, timeout)
+	}
+	stop := ct.capture.now()
+
+	e := Exchange{
+		Product:   ct.product,
+		Direction: direction,
+		Request:   request,
+		N:         n,
+		At:        start.Sub(ct.capture.start),
+		Duration:  stop.Sub(start),
+	}
+	switch {
+	case VendorRequestOutput == direction:
+		e.Data = append([]byte(nil), data...)
+	case n > 0 && n <= len(data):
+		e.Data = append([]byte(nil), data[:n]...)
+	}
+	if nil != err {
+		e.Err = err.Error()
+		e.ErrKind = ErrorKindName(err)
+	}
+	ct.capture.write(&e)
+	return
+}
+
+// Close закрывает исходный транспорт
+func (ct *CaptureTransport) Close() error {
+	return ct.t.Close()
+}
+
+// Present показывает, подключено ли устройство исходного транспорта
+func (ct *CaptureTransport) Present() bool {
+	return transportPresent(ct.t)
+}
+
+func (ct *CaptureTransport) unwrap() Transport {
+	return ct.t
+}
@@ -0,0 +1,150 @@
+package ipk
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// captureSessions сеансы работы с платами: результаты сеанса на симуляторах
+// и при воспроизведении его записи должны совпадать
+var captureSessions = []struct {
+	name    string
+	setup   func(sims Simulators)
+	session func(ipk *IPK) []interface{}
+}{
+	{"ФАС-3", func(sims Simulators) {
+		sims.Analog.SetBinaryInput(0x1234)
+	}, func(ipk *IPK) []interface{} {
+		var dac DAC
+		errInit := dac.Init(ipk.AnalogDev, DAC2)
+		errSet := dac.SetMilliAmper(7.5)
+		ma, errGet := dac.GetMilliAmper()
+		errFreq := ipk.AnalogDev.SetFreq(FREQ3, AnlFreq1kHz)
+		freq, errOutFreq := ipk.AnalogDev.GetOutputFreq(FREQ3)
+		inputs, errInputs := ipk.AnalogDev.UintGetBinaryInput()
+		version, errVersion := ipk.AnalogDev.GetVersionString()
+		return []interface{}{errInit, errSet, ma, errGet, errFreq, freq, errOutFreq, inputs, errInputs, version, errVersion}
+	}},
+	{"ФДС-3", nil, func(ipk *IPK) []interface{} {
+		err10V := ipk.BinDev.Set10V(2, true)
+		err50V := ipk.BinDev.Set50V(7, true)
+		errIF := ipk.BinDev.SetIF(IFGreen16)
+		code, errCode := ipk.BinDev.GetOutputIF()
+		out10V, errOut10V := ipk.BinDev.UintGetOutput10V()
+		errTURT := ipk.BinDev.SetTURT(true)
+		turt, errOutTURT := ipk.BinDev.GetOutputTURT()
+		return []interface{}{err10V, err50V, errIF, code, errCode, out10V, errOut10V, errTURT, turt, errOutTURT}
+	}},
+	{"ФЧС-3", nil, func(ipk *IPK) []interface{} {
+		errHz := ipk.FreqDev.SetHz(1200, 800)
+		errDelta := ipk.FreqDev.SetDeltaHz(-10, 0)
+		errUpdate := ipk.FreqDev.UpdateFreqDataUSB()
+		hz1, hz2, errOutHz := ipk.FreqDev.GetOutputHz()
+		errADC := ipk.FreqDev.EnableADC(true)
+		errUpdateADC := ipk.FreqDev.UpdateADC()
+		dat1, errDat1 := ipk.FreqDev.GetDat1ADC()
+		version, errVersion := ipk.FreqDev.GetVersionString()
+		return []interface{}{errHz, errDelta, errUpdate, hz1, hz2, errOutHz, errADC, errUpdateADC, dat1, errDat1, version, errVersion}
+	}},
+	{"старая ревизия ФДС-3", func(sims Simulators) {
+		sims.Binary.SetVersion(0, 0, 0)
+	}, func(ipk *IPK) []interface{} {
+		version, err := ipk.BinDev.GetVersionString()
+		return []interface{}{version, nil == err}
+	}},
+	{"ошибка обмена", func(sims Simulators) {
+		sims.Freq.SetConnected(false)
+	}, func(ipk *IPK) []interface{} {
+		ipk.FreqDev.SetRetryPolicy(RetryPolicy{MaxAttempts: 1})
+		err := ipk.FreqDev.UpdateFreqDataUSB()
+		return []interface{}{errors.Is(err, ErrNotConnected)}
+	}},
+}
+
+func TestCaptureReplay(t *testing.T) {
+	for _, tt := range captureSessions {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			ipk := new(IPK)
+			sims := ipk.OpenSimulators(IDProductANL16bit)
+			if nil != tt.setup {
+				tt.setup(sims)
+			}
+			capture := NewCapture(&buf)
+			capture.Attach(ipk)
+			want := tt.session(ipk)
+			capture.Detach()
+			if err := capture.Err(); nil != err {
+				t.Fatal(err)
+			}
+
+			rp, err := LoadReplay(&buf)
+			if nil != err {
+				t.Fatal(err)
+			}
+			replayed := new(IPK)
+			if !rp.Open(replayed) {
+				t.Fatal("Replay.Open() = false")
+			}
+			if nil != tt.setup && nil != replayed.FreqDev {
+				replayed.FreqDev.SetRetryPolicy(RetryPolicy{MaxAttempts: 1})
+			}
+			got := tt.session(replayed)
+			if !reflect.DeepEqual(want, got) {
+				t.Errorf("воспроизведение: %v\nзапись: %v", got, want)
+			}
+			if err := rp.Err(); nil != err {
+				t.Errorf("Replay.Err() = %v", err)
+			}
+			if n := rp.Remaining(); 0 != n {
+				t.Errorf("Replay.Remaining() = %d", n)
+			}
+		})
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	var buf bytes.Buffer
+	ipk := new(IPK)
+	ipk.OpenSimulators(IDProductANL12bit)
+	capture := NewCapture(&buf)
+	capture.Attach(ipk)
+	if err := ipk.BinDev.SetIF(IFYellow16); nil != err {
+		t.Fatal(err)
+	}
+	capture.Detach()
+
+	tests := []struct {
+		name string
+		call func(ipk *IPK) error
+		want error
+	}{
+		{"другие данные", func(ipk *IPK) error { return ipk.BinDev.SetIF(IFRedYellow16) }, replayErrorMismatch},
+		{"другой запрос", func(ipk *IPK) error { _, err := ipk.BinDev.GetOutputTURT(); return err }, replayErrorMismatch},
+		{"запись закончилась", func(ipk *IPK) error {
+			if err := ipk.BinDev.SetIF(IFYellow16); nil != err {
+				return err
+			}
+			return ipk.BinDev.SetIF(IFYellow16)
+		}, replayErrorEnd},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rp, err := LoadReplay(bytes.NewReader(buf.Bytes()))
+			if nil != err {
+				t.Fatal(err)
+			}
+			replayed := new(IPK)
+			rp.Open(replayed)
+			replayed.BinDev.SetRetryPolicy(RetryPolicy{MaxAttempts: 1})
+			if err := tt.call(replayed); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+			if err := rp.Err(); !errors.Is(err, tt.want) {
+				t.Errorf("Replay.Err() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,797 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amdf/ipk"
+)
+
+// pollInterval период опроса в командах с -watch
+const pollInterval = 500 * time.Millisecond
+
+func (a *app) analog() (*ipk.AnalogDevice, error) {
+	if nil == a.ipk.AnalogDev {
+		return nil, fmt.Errorf("ФАС-3 %w", errNoBoard)
+	}
+	return a.ipk.AnalogDev, nil
+}
+
+func (a *app) binary() (*ipk.BinaryDevice, error) {
+	if nil == a.ipk.BinDev {
+		return nil, fmt.Errorf("ФДС-3 %w", errNoBoard)
+	}
+	return a.ipk.BinDev, nil
+}
+
+func (a *app) freq() (*ipk.FreqDevice, error) {
+	if nil == a.ipk.FreqDev {
+		return nil, fmt.Errorf("ФЧС-3 %w", errNoBoard)
+	}
+	return a.ipk.FreqDev, nil
+}
+
+// parseFlags разбирает флаги команды; ошибка разбора считается ошибкой использования
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); nil != err {
+		return errUsage
+	}
+	return nil
+}
+
+// channel разбирает номер канала (с 1) и возвращает его с нуля
+func channel(s string, count int) (ch uint8, err error) {
+	n, err := strconv.Atoi(s)
+	if nil != err || n < 1 || n > count {
+		return 0, fmt.Errorf("канал %q (от 1 до %d):%w", s, count, ipk.ErrInvalidParam)
+	}
+	return uint8(n - 1), nil
+}
+
+// number разбирает число с плавающей точкой (допускается запятая)
+func number(s string) (v float64, err error) {
+	v, err = strconv.ParseFloat(strings.Replace(s, ",", ".", 1), 64)
+	if nil != err {
+		err = fmt.Errorf("число %q:%w", s, ipk.ErrInvalidParam)
+	}
+	return
+}
+
+///////////////////////////////////////////////////////////////
+
+// BoardInfo плата в выводе list
+type BoardInfo struct {
+	ProductID uint16 `json:"product_id"` // 0, если плата не найдена
+	Name      string `json:"name"`
+	Bits      int    `json:"bits,omitempty"` // разрядность ЦАП ФАС-3
+	Present   bool   `json:"present"`
+	Version   string `json:"version,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+func (a *app) boardInfo(bs ipk.BoardStatus, name string) (info BoardInfo) {
+	info = BoardInfo{ProductID: bs.ProductID, Name: name, Present: bs.Present, Version: bs.Version}
+	if 0 != bs.ProductID {
+		info.Name = bs.Name
+	}
+	switch bs.ProductID {
+	case ipk.IDProductANL12bit:
+		info.Bits = 12
+	case ipk.IDProductANL16bit:
+		info.Bits = 16
+	}
+	if nil != bs.Err {
+		info.Error = ipk.Localize(bs.Err, a.lang)
+	}
+	return
+}
+
+func (a *app) list(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return errUsage
+	}
+	st := a.ipk.Status()
+	boards := []BoardInfo{
+		a.boardInfo(st.Analog, "ФАС-3"),
+		a.boardInfo(st.Binary, ipk.ProductName(ipk.IDProductBIN)),
+		a.boardInfo(st.Freq, ipk.ProductName(ipk.IDProductFRQ)),
+	}
+	a.print(boards, func(w io.Writer) {
+		for _, b := range boards {
+			state := "не найдена"
+			switch {
+			case b.Present:
+				state = "подключена"
+			case 0 != b.ProductID:
+				state = "отключена"
+			}
+			fmt.Fprintf(w, "%-16s %s", b.Name, state)
+			if "" != b.Version {
+				fmt.Fprintf(w, ", прошивка %s", b.Version)
+			}
+			if "" != b.Error {
+				fmt.Fprintf(w, ", %s", b.Error)
+			}
+			fmt.Fprintln(w)
+		}
+	})
+	return nil
+}
+
+///////////////////////////////////////////////////////////////
+
+// DACState значение канала ЦАП ФАС-3
+type DACState struct {
+	Channel       int     `json:"channel"` // от 1 до 14
+	MilliAmper    float64 `json:"milliamper"`
+	MaxMilliAmper float64 `json:"max_milliamper"`
+}
+
+func (a *app) dacState(ctx context.Context, dev *ipk.AnalogDevice, ch uint8) (st DACState, err error) {
+	var dac ipk.DAC
+	if err = dac.Init(dev, ch); nil != err {
+		return
+	}
+	st = DACState{Channel: int(ch) + 1, MaxMilliAmper: dac.GetMaxMilliAmper()}
+	st.MilliAmper, err = dac.GetMilliAmperCtx(ctx)
+	return
+}
+
+func (a *app) printDAC(states []DACState) {
+	a.print(states, func(w io.Writer) {
+		for _, st := range states {
+			fmt.Fprintf(w, "ЦАП %-2d %7.3f мА (макс. %g мА)\n", st.Channel, st.MilliAmper, st.MaxMilliAmper)
+		}
+	})
+}
+
+func (a *app) dacGet(ctx context.Context, args []string) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+	dev, err := a.analog()
+	if nil != err {
+		return err
+	}
+	first, last := uint8(ipk.DAC1), uint8(ipk.DAC14)
+	if 1 == len(args) {
+		if first, err = channel(args[0], ipk.DAC14+1); nil != err {
+			return err
+		}
+		last = first
+	}
+	var states []DACState
+	for ch := first; ch <= last; ch++ {
+		st, err := a.dacState(ctx, dev, ch)
+		if nil != err {
+			return err
+		}
+		states = append(states, st)
+	}
+	a.printDAC(states)
+	return nil
+}
+
+// dacUnits единицы значения в dac set
+var dacUnits = []struct {
+	suffix   string
+	pressure bool
+	unit     uint8
+}{
+	{"kpa", true, ipk.DACKiloPascal},
+	{"at", true, ipk.DACAtmosphere},
+	{"ma", false, 0},
+}
+
+func (a *app) dacSet(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("dac set", flag.ContinueOnError)
+	max := fs.Float64("max", 0, "максимум шкалы датчика давления")
+	if err := parseFlags(fs, args); nil != err {
+		return err
+	}
+	if 2 != fs.NArg() {
+		return errUsage
+	}
+	dev, err := a.analog()
+	if nil != err {
+		return err
+	}
+	ch, err := channel(fs.Arg(0), ipk.DAC14+1)
+	if nil != err {
+		return err
+	}
+
+	value := strings.ToLower(fs.Arg(1))
+	pressure, unit := false, uint8(0)
+	for _, u := range dacUnits {
+		if strings.HasSuffix(value, u.suffix) {
+			value = strings.TrimSuffix(value, u.suffix)
+			pressure, unit = u.pressure, u.unit
+			break
+		}
+	}
+	val, err := number(value)
+	if nil != err {
+		return err
+	}
+
+	var dac ipk.DAC
+	if err = dac.Init(dev, ch); nil != err {
+		return err
+	}
+	if pressure {
+		if *max <= 0 {
+			return fmt.Errorf("для давления нужен -max:%w", ipk.ErrInvalidParam)
+		}
+		var pres ipk.PressureOutput
+		if err = pres.Init(&dac, unit, *max); nil == err {
+			err = pres.SetCtx(ctx, val)
+		}
+	} else {
+		err = dac.SetMilliAmperCtx(ctx, val)
+	}
+	if nil != err {
+		return err
+	}
+	st, err := a.dacState(ctx, dev, ch)
+	if nil != err {
+		return err
+	}
+	a.printDAC([]DACState{st})
+	return nil
+}
+
+///////////////////////////////////////////////////////////////
+
+// FreqState частотный выход ФАС-3
+type FreqState struct {
+	Channel int    `json:"channel"` // от 1 до 4
+	Code    uint16 `json:"code"`    // значение ipk.AnlFreq
+	Freq    string `json:"freq"`    // 200Hz, 500Hz, 1kHz, 2kHz, 4kHz или "" для других значений
+}
+
+func (a *app) printFreq(states []FreqState) {
+	a.print(states, func(w io.Writer) {
+		for _, st := range states {
+			name := st.Freq
+			if "" == name {
+				name = fmt.Sprintf("код %d", st.Code)
+			}
+			fmt.Fprintf(w, "Частотный выход %d: %s\n", st.Channel, name)
+		}
+	})
+}
+
+func (a *app) freqState(ctx context.Context, dev *ipk.AnalogDevice, ch uint8) (st FreqState, err error) {
+	st.Channel = int(ch) + 1
+	st.Code, err = dev.GetOutputFreqCtx(ctx, ch)
+	st.Freq = ipk.AnlFreqName(st.Code)
+	return
+}
+
+func (a *app) freqGet(ctx context.Context, args []string) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+	dev, err := a.analog()
+	if nil != err {
+		return err
+	}
+	first, last := uint8(ipk.FREQ1), uint8(ipk.FREQ4)
+	if 1 == len(args) {
+		if first, err = channel(args[0], ipk.FREQ4+1); nil != err {
+			return err
+		}
+		last = first
+	}
+	var states []FreqState
+	for ch := first; ch <= last; ch++ {
+		st, err := a.freqState(ctx, dev, ch)
+		if nil != err {
+			return err
+		}
+		states = append(states, st)
+	}
+	a.printFreq(states)
+	return nil
+}
+
+func (a *app) freqSet(ctx context.Context, args []string) error {
+	if 2 != len(args) {
+		return errUsage
+	}
+	dev, err := a.analog()
+	if nil != err {
+		return err
+	}
+	ch, err := channel(args[0], ipk.FREQ4+1)
+	if nil != err {
+		return err
+	}
+	code, err := ipk.ParseAnlFreq(args[1])
+	if nil != err {
+		return err
+	}
+	if err = dev.SetFreqCtx(ctx, ch, code); nil != err {
+		return err
+	}
+	st, err := a.freqState(ctx, dev, ch)
+	if nil != err {
+		return err
+	}
+	a.printFreq([]FreqState{st})
+	return nil
+}
+
+///////////////////////////////////////////////////////////////
+
+// InputsState двоичные входы ФАС-3
+type InputsState struct {
+	Value  uint16   `json:"value"`  // младший бит - вход 0
+	Inputs [16]bool `json:"inputs"` // входы от 0 до 15
+}
+
+// InputEdge изменение двоичного входа ФАС-3
+type InputEdge struct {
+	Input  uint16    `json:"input"`
+	Rising bool      `json:"rising"`
+	Time   time.Time `json:"time"`
+}
+
+// bits выводит биты val от младшего к старшему
+func bits(val uint64, count int) string {
+	var b strings.Builder
+	for i := 0; i < count; i++ {
+		if 0 != i && 0 == i%8 {
+			b.WriteByte(' ')
+		}
+		if 0 != val&(1<<i) {
+			b.WriteByte('1')
+		} else {
+			b.WriteByte('0')
+		}
+	}
+	return b.String()
+}
+
+func (a *app) printInputs(val uint16) {
+	st := InputsState{Value: val}
+	for i := range st.Inputs {
+		st.Inputs[i] = 0 != val&(1<<i)
+	}
+	a.print(st, func(w io.Writer) {
+		fmt.Fprintf(w, "Входы 0-15: %s\n", bits(uint64(val), 16))
+	})
+}
+
+func (a *app) inputsGet(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return errUsage
+	}
+	dev, err := a.analog()
+	if nil != err {
+		return err
+	}
+	val, err := dev.UintGetBinaryInputCtx(ctx)
+	if nil != err {
+		return err
+	}
+	a.printInputs(val)
+	return nil
+}
+
+func (a *app) inputsWatch(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return errUsage
+	}
+	dev, err := a.analog()
+	if nil != err {
+		return err
+	}
+	w := ipk.NewInputWatcher(dev)
+	if err = w.Poll(ctx); nil != err {
+		return err
+	}
+	val, _ := w.State()
+	a.printInputs(val)
+
+	w.OnEdge = func(edge ipk.InputEdge) {
+		e := InputEdge{Input: edge.Input, Rising: edge.Rising, Time: edge.Time}
+		a.print(e, func(w io.Writer) {
+			fmt.Fprintf(w, "%s вход %d %s\n", e.Time.Format("15:04:05.000"), e.Input, onOffName(e.Rising))
+		})
+	}
+	errs := make(chan error, 1)
+	w.OnError = func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}
+	w.Start()
+	defer w.Stop()
+	select {
+	case <-ctx.Done():
+		return nil
+	case err = <-errs:
+		return err
+	}
+}
+
+///////////////////////////////////////////////////////////////
+
+// OutputsState выходы ФДС-3
+type OutputsState struct {
+	Out10V [8]bool  `json:"10v"` // выходы 10 В от 0 до 7
+	Out50V [36]bool `json:"50v"` // выходы 50 В от 0 до 35
+	IF     uint8    `json:"if"`
+	TURT   bool     `json:"turt"`
+}
+
+func (a *app) printOutputs(ctx context.Context, dev *ipk.BinaryDevice) error {
+	v10, err := dev.UintGetOutput10VCtx(ctx)
+	if nil != err {
+		return err
+	}
+	v50, err := dev.UintGetOutput50VCtx(ctx)
+	if nil != err {
+		return err
+	}
+	var st OutputsState
+	for i := range st.Out10V {
+		st.Out10V[i] = 0 != v10&(1<<i)
+	}
+	for i := range st.Out50V {
+		st.Out50V[i] = 0 != v50&(1<<i)
+	}
+	if st.IF, err = dev.GetOutputIFCtx(ctx); nil != err {
+		return err
+	}
+	if st.TURT, err = dev.GetOutputTURTCtx(ctx); nil != err {
+		return err
+	}
+	a.print(st, func(w io.Writer) {
+		fmt.Fprintf(w, "10 В (0-7):  %s\n", bits(uint64(v10), len(st.Out10V)))
+		fmt.Fprintf(w, "50 В (0-35): %s\n", bits(v50, len(st.Out50V)))
+		fmt.Fprintf(w, "ИФ: %d, TURT: %s\n", st.IF, onOffName(st.TURT))
+	})
+	return nil
+}
+
+func (a *app) binGet(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return errUsage
+	}
+	dev, err := a.binary()
+	if nil != err {
+		return err
+	}
+	return a.printOutputs(ctx, dev)
+}
+
+// binSet разбирает аргументы <выход> on|off и включает выход функцией set
+func (a *app) binSet(ctx context.Context, args []string, count int, set func(dev *ipk.BinaryDevice, num uint, on bool) error) error {
+	if 2 != len(args) {
+		return errUsage
+	}
+	dev, err := a.binary()
+	if nil != err {
+		return err
+	}
+	num, err := strconv.Atoi(args[0])
+	if nil != err || num < 0 || num >= count {
+		return fmt.Errorf("выход %q (от 0 до %d):%w", args[0], count-1, ipk.ErrInvalidParam)
+	}
+	on, err := onOff(args[1])
+	if nil != err {
+		return err
+	}
+	if err = set(dev, uint(num), on); nil != err {
+		return err
+	}
+	return a.printOutputs(ctx, dev)
+}
+
+func (a *app) binSet10V(ctx context.Context, args []string) error {
+	return a.binSet(ctx, args, 8, func(dev *ipk.BinaryDevice, num uint, on bool) error {
+		return dev.Set10VCtx(ctx, num, on)
+	})
+}
+
+func (a *app) binSet50V(ctx context.Context, args []string) error {
+	return a.binSet(ctx, args, 36, func(dev *ipk.BinaryDevice, num uint, on bool) error {
+		return dev.Set50VCtx(ctx, num, on)
+	})
+}
+
+func (a *app) binIF(ctx context.Context, args []string) error {
+	if 1 != len(args) {
+		return errUsage
+	}
+	dev, err := a.binary()
+	if nil != err {
+		return err
+	}
+	state, err := strconv.ParseUint(args[0], 10, 8)
+	if nil != err {
+		return fmt.Errorf("код ИФ %q:%w", args[0], ipk.ErrInvalidParam)
+	}
+	if err = dev.SetIFCtx(ctx, uint8(state)); nil != err {
+		return err
+	}
+	return a.printOutputs(ctx, dev)
+}
+
+func (a *app) binTURT(ctx context.Context, args []string) error {
+	if 1 != len(args) {
+		return errUsage
+	}
+	dev, err := a.binary()
+	if nil != err {
+		return err
+	}
+	on, err := onOff(args[0])
+	if nil != err {
+		return err
+	}
+	if err = dev.SetTURTCtx(ctx, on); nil != err {
+		return err
+	}
+	return a.printOutputs(ctx, dev)
+}
+
+///////////////////////////////////////////////////////////////
+
+// SpeedState состояние генераторов ФЧС-3
+type SpeedState struct {
+	Speed1        float64 `json:"speed1"`        // км/ч
+	Speed2        float64 `json:"speed2"`        // км/ч
+	Acceleration1 float64 `json:"acceleration1"` // 0,01 м/с²
+	Acceleration2 float64 `json:"acceleration2"` // 0,01 м/с²
+	Motion        string  `json:"motion"`        // onward, backwards или unknown
+	Way1          uint32  `json:"way1"`          // пройденный путь, м
+	Way2          uint32  `json:"way2"`          // пройденный путь, м
+	LimitWay      uint32  `json:"limit_way"`     // предельный путь, м
+}
+
+// WayState пройденный путь
+type WayState struct {
+	Time time.Time `json:"time"`
+	Way1 uint32    `json:"way1"` // м
+	Way2 uint32    `json:"way2"` // м
+}
+
+// motionName возвращает название направления движения (см. ipk.FreqSnapshot.Direction)
+func motionName(motion uint8) string {
+	switch motion {
+	case ipk.MotionOnward:
+		return "onward"
+	case ipk.MotionBackwards:
+		return "backwards"
+	}
+	return "unknown"
+}
+
+func (a *app) printSpeed(ctx context.Context, dev *ipk.FreqDevice) (err error) {
+	if err = dev.UpdateFreqDataUSBCtx(ctx); nil != err {
+		return
+	}
+	var st SpeedState
+	if st.Speed1, st.Speed2, err = a.speed.GetOutputSpeed(); nil != err {
+		return
+	}
+	if st.Acceleration1, st.Acceleration2, err = a.speed.GetOutputAcceleration(); nil != err {
+		return
+	}
+	if st.Way1, st.Way2, err = a.speed.GetWay(); nil != err {
+		return
+	}
+	if st.LimitWay, err = a.speed.GetLimitWay(); nil != err {
+		return
+	}
+	snap := dev.Snapshot()
+	st.Motion = motionName(snap.Direction())
+	a.print(st, func(w io.Writer) {
+		fmt.Fprintf(w, "Скорость:  %.2f / %.2f км/ч\n", st.Speed1, st.Speed2)
+		fmt.Fprintf(w, "Ускорение: %.2f / %.2f (0,01 м/с²)\n", st.Acceleration1, st.Acceleration2)
+		fmt.Fprintf(w, "Движение:  %s\n", st.Motion)
+		fmt.Fprintf(w, "Путь:      %d / %d м (предел %d м)\n", st.Way1, st.Way2, st.LimitWay)
+	})
+	return
+}
+
+// speedCommand выполняет команду ФЧС-3 и выводит состояние генераторов
+func (a *app) speedCommand(ctx context.Context, command func() error) error {
+	dev, err := a.freq()
+	if nil != err {
+		return err
+	}
+	if nil != command {
+		if err = command(); nil != err {
+			return err
+		}
+	}
+	return a.printSpeed(ctx, dev)
+}
+
+// pair разбирает одно (для обоих генераторов) или два значения
+func pair(args []string) (v1, v2 float64, err error) {
+	if len(args) < 1 || len(args) > 2 {
+		err = errUsage
+		return
+	}
+	if v1, err = number(args[0]); nil != err {
+		return
+	}
+	v2 = v1
+	if 2 == len(args) {
+		v2, err = number(args[1])
+	}
+	return
+}
+
+func (a *app) speedGet(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return errUsage
+	}
+	return a.speedCommand(ctx, nil)
+}
+
+func (a *app) speedSet(ctx context.Context, args []string) error {
+	kmh1, kmh2, err := pair(args)
+	if nil != err {
+		return err
+	}
+	return a.speedCommand(ctx, func() error { return a.speed.SetSpeedCtx(ctx, kmh1, kmh2) })
+}
+
+func (a *app) speedAccel(ctx context.Context, args []string) error {
+	accel1, accel2, err := pair(args)
+	if nil != err {
+		return err
+	}
+	return a.speedCommand(ctx, func() error { return a.speed.SetAccelerationCtx(ctx, accel1, accel2) })
+}
+
+func (a *app) speedMotion(ctx context.Context, args []string) error {
+	if 1 != len(args) {
+		return errUsage
+	}
+	var motion uint8
+	switch strings.ToLower(args[0]) {
+	case "onward":
+		motion = ipk.MotionOnward
+	case "backwards":
+		motion = ipk.MotionBackwards
+	default:
+		return fmt.Errorf("направление %q (onward или backwards):%w", args[0], ipk.ErrInvalidParam)
+	}
+	return a.speedCommand(ctx, func() error { return a.speed.SetMotionCtx(ctx, motion) })
+}
+
+func (a *app) speedLimit(ctx context.Context, args []string) error {
+	if 1 != len(args) {
+		return errUsage
+	}
+	meters, err := strconv.ParseUint(args[0], 10, 32)
+	if nil != err {
+		return fmt.Errorf("путь %q:%w", args[0], ipk.ErrInvalidParam)
+	}
+	return a.speedCommand(ctx, func() error { return a.speed.SetLimitWayCtx(ctx, uint32(meters)) })
+}
+
+func (a *app) speedWay(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("speed way", flag.ContinueOnError)
+	repeat := fs.Bool("watch", false, "выводить путь до Ctrl+C")
+	if err := parseFlags(fs, args); nil != err {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errUsage
+	}
+	dev, err := a.freq()
+	if nil != err {
+		return err
+	}
+	poll := func() (err error) {
+		if err = dev.UpdateFreqDataUSBCtx(ctx); nil != err {
+			return
+		}
+		st := WayState{Time: time.Now()}
+		if st.Way1, st.Way2, err = a.speed.GetWay(); nil != err {
+			return
+		}
+		a.print(st, func(w io.Writer) {
+			fmt.Fprintf(w, "%s путь %d / %d м\n", st.Time.Format("15:04:05.000"), st.Way1, st.Way2)
+		})
+		return
+	}
+	if !*repeat {
+		return poll()
+	}
+	return watch(ctx, pollInterval, poll)
+}
+
+///////////////////////////////////////////////////////////////
+
+// ADCState данные АЦП ФЧС-3 в мА
+type ADCState struct {
+	Time    time.Time `json:"time"`
+	Enabled bool      `json:"enabled"`
+	Dat1    float64   `json:"dat1"`
+	Dat2    float64   `json:"dat2"`
+	Ref     float64   `json:"ref"`
+}
+
+func (a *app) adcEnable(ctx context.Context, args []string) error {
+	if 1 != len(args) {
+		return errUsage
+	}
+	dev, err := a.freq()
+	if nil != err {
+		return err
+	}
+	on, err := onOff(args[0])
+	if nil != err {
+		return err
+	}
+	if err = dev.EnableADCCtx(ctx, on); nil != err {
+		return err
+	}
+	return a.readADC(ctx, dev)
+}
+
+func (a *app) readADC(ctx context.Context, dev *ipk.FreqDevice) (err error) {
+	if err = dev.UpdateFreqDataUSBCtx(ctx); nil != err {
+		return
+	}
+	st := ADCState{Time: time.Now(), Enabled: dev.Snapshot().ADCModeEnabled}
+	if st.Enabled {
+		if err = dev.UpdateADCCtx(ctx); nil != err {
+			return
+		}
+		if st.Dat1, err = dev.GetDat1MilliAmper(); nil != err {
+			return
+		}
+		if st.Dat2, err = dev.GetDat2MilliAmper(); nil != err {
+			return
+		}
+		if st.Ref, err = dev.GetRefValMilliAmper(); nil != err {
+			return
+		}
+	}
+	a.print(st, func(w io.Writer) {
+		if !st.Enabled {
+			fmt.Fprintln(w, "Режим АЦП выключен")
+			return
+		}
+		fmt.Fprintf(w, "%s ДАТ 1: %.3f мА, ДАТ 2: %.3f мА, эталон: %.3f мА\n",
+			st.Time.Format("15:04:05.000"), st.Dat1, st.Dat2, st.Ref)
+	})
+	return
+}
+
+func (a *app) adcRead(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("adc read", flag.ContinueOnError)
+	repeat := fs.Bool("watch", false, "выводить данные до Ctrl+C")
+	if err := parseFlags(fs, args); nil != err {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errUsage
+	}
+	dev, err := a.freq()
+	if nil != err {
+		return err
+	}
+	if !*repeat {
+		return a.readADC(ctx, dev)
+	}
+	return watch(ctx, pollInterval, func() error { return a.readADC(ctx, dev) })
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/amdf/ipk"
+)
+
+// FirmwareInfo образ прошивки в выводе fw info
+type FirmwareInfo struct {
+	File     string            `json:"file"`
+	Size     int               `json:"size"`  // байт
+	Words    int               `json:"words"` // 32-битных слов для записи
+	Entry    uint32            `json:"entry,omitempty"`
+	Segments []FirmwareSegment `json:"segments"`
+}
+
+// FirmwareSegment участок образа прошивки
+type FirmwareSegment struct {
+	Addr uint32 `json:"addr"`
+	Size int    `json:"size"`
+}
+
+// FlashResult результат fw flash
+type FlashResult struct {
+	Board   string `json:"board"`
+	File    string `json:"file"`
+	Words   int    `json:"words"`
+	Old     string `json:"old_version"`
+	Version string `json:"version"`
+}
+
+// flasher плата, прошивку которой можно обновить
+type flasher interface {
+	FlashFirmwareCtx(ctx context.Context, img *ipk.FirmwareImage, opts ipk.FlashOptions) (version string, err error)
+}
+
+// board возвращает плату для обновления прошивки по имени anl, bin или frq
+func (a *app) board(name string) (dev flasher, title string, err error) {
+	switch name {
+	case "anl":
+		dev, err = a.analog()
+		title = "ФАС-3"
+	case "bin":
+		dev, err = a.binary()
+		title = "ФДС-3"
+	case "frq":
+		dev, err = a.freq()
+		title = "ФЧС-3"
+	default:
+		err = fmt.Errorf("плата %q (anl, bin или frq):%w", name, ipk.ErrInvalidParam)
+	}
+	return
+}
+
+// address разбирает адрес Flash (допускается 0x...)
+func address(s string) (addr uint32, err error) {
+	v, err := strconv.ParseUint(s, 0, 32)
+	if nil != err {
+		return 0, fmt.Errorf("адрес %q:%w", s, ipk.ErrInvalidParam)
+	}
+	return uint32(v), nil
+}
+
+// loadFirmware разбирает общие флаги команд fw и читает образ прошивки
+func loadFirmware(fs *flag.FlagSet, args []string) (path string, img *ipk.FirmwareImage, err error) {
+	base := fs.String("base", fmt.Sprintf("0x%08X", ipk.FirmwareMinAddr), "начальный адрес двоичного файла (.bin)")
+	if err = parseFlags(fs, args); nil != err {
+		return
+	}
+	if 1 != fs.NArg() {
+		err = errUsage
+		return
+	}
+	addr, err := address(*base)
+	if nil != err {
+		return
+	}
+	path = fs.Arg(0)
+	img, err = ipk.LoadFirmware(path, addr)
+	return
+}
+
+func (a *app) fwInfo(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("fw info", flag.ContinueOnError)
+	path, img, err := loadFirmware(fs, args)
+	if nil != err {
+		return err
+	}
+	info := FirmwareInfo{File: path, Size: img.Size(), Words: len(img.Words()), Entry: img.Entry}
+	for _, seg := range img.Segments {
+		info.Segments = append(info.Segments, FirmwareSegment{Addr: seg.Addr, Size: len(seg.Data)})
+	}
+	valid := img.Validate(ipk.FirmwareMinAddr, ipk.FirmwareMaxAddr)
+	a.print(info, func(w io.Writer) {
+		fmt.Fprintf(w, "%s: %d байт, %d слов\n", info.File, info.Size, info.Words)
+		for _, seg := range info.Segments {
+			fmt.Fprintf(w, "  %08X-%08X  %d байт\n", seg.Addr, seg.Addr+uint32(seg.Size)-1, seg.Size)
+		}
+		if 0 != info.Entry {
+			fmt.Fprintf(w, "точка входа %08X\n", info.Entry)
+		}
+	})
+	return valid
+}
+
+func (a *app) fwFlash(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("fw flash", flag.ContinueOnError)
+	var opts ipk.FlashOptions
+	fs.IntVar(&opts.Resume, "resume", 0, "продолжить прерванное обновление с указанного слова")
+	fs.StringVar(&opts.ExpectVersion, "expect", "", "ожидаемая версия прошивки после обновления (по умолчанию - любая, кроме прежней)")
+	fs.BoolVar(&opts.NoRestart, "no-restart", false, "не перезагружать плату после записи")
+	name := fs.String("board", "frq", "плата: anl (ФАС-3), bin (ФДС-3) или frq (ФЧС-3)")
+	path, img, err := loadFirmware(fs, args)
+	if nil != err {
+		return err
+	}
+	dev, title, err := a.board(*name)
+	if nil != err {
+		return err
+	}
+
+	res := FlashResult{Board: *name, File: path, Words: len(img.Words())}
+	percent := -1
+	opts.Progress = func(ev ipk.FlashProgress) {
+		if ipk.FlashPrepare == ev.Stage {
+			res.Old = ev.Version
+		}
+		if a.json {
+			return
+		}
+		switch ev.Stage {
+		case ipk.FlashPrepare:
+			fmt.Fprintf(os.Stderr, "%s, прошивка %s: запись %s (%d слов)\n", title, ev.Version, path, ev.Total)
+		case ipk.FlashWrite:
+			if p := 100 * ev.Written / ev.Total; p != percent {
+				percent = p
+				fmt.Fprintf(os.Stderr, "\rзаписано %d из %d слов (%d%%)", ev.Written, ev.Total, p)
+			}
+		case ipk.FlashFinish:
+			fmt.Fprintln(os.Stderr)
+		case ipk.FlashRestart:
+			fmt.Fprintln(os.Stderr, "перезагрузка с другого банка памяти")
+		}
+	}
+
+	res.Version, err = dev.FlashFirmwareCtx(ctx, img, opts)
+	var fe *ipk.FlashError
+	if errors.As(err, &fe) && ipk.FlashWrite == fe.Stage && !a.json {
+		fmt.Fprintf(os.Stderr, "\nработающая прошивка не изменена; продолжить: ipkctl fw flash -board %s -resume %d %s\n", *name, fe.Written, path)
+	}
+	if nil != err {
+		return err
+	}
+	a.print(res, func(w io.Writer) {
+		if opts.NoRestart {
+			fmt.Fprintf(w, "Прошивка записана, будет запущена после перезагрузки %s\n", title)
+			return
+		}
+		fmt.Fprintf(w, "Прошивка обновлена: %s -> %s\n", res.Old, res.Version)
+	})
+	return nil
+}
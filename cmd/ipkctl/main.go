@@ -0,0 +1,292 @@
+// Команда ipkctl - управление платами ФПС-3 из командной строки.
+//
+// Использование:
+//
+//	ipkctl [флаги] команда [аргументы]
+//
+// Команды (номера ЦАП и частотных выходов - с 1, двоичных входов и выходов - с 0):
+//
+//	list                                  найденные платы, вариант ФАС-3 и версии прошивок
+//	dac get [канал]                       значения ЦАП ФАС-3
+//	dac set [-max N] <канал> <значение>   значение в мА (5, 5mA) или давление (300kPa, 2.5at)
+//	                                      при максимуме шкалы датчика N
+//	freq get [канал]                      частотные выходы ФАС-3
+//	freq set <канал> <частота>            200Hz, 500Hz, 1kHz, 2kHz или 4kHz
+//	inputs get                            двоичные входы ФАС-3
+//	inputs watch                          изменения двоичных входов ФАС-3 (до Ctrl+C)
+//	bin get                               выходы ФДС-3
+//	bin set10v <выход> on|off             выход 10 В (от 0 до 7)
+//	bin set50v <выход> on|off             выход 50 В (от 0 до 35)
+//	bin if <код>                          код ИФ (от 0 до 7)
+//	bin turt on|off                       TURT
+//	speed get                             скорость, ускорение, направление и путь ФЧС-3
+//	speed set <км/ч> [км/ч]               скорость генераторов (одна для обоих или по отдельности)
+//	speed accel <0,01 м/с²> [0,01 м/с²]   ускорение генераторов
+//	speed motion onward|backwards         направление движения
+//	speed limit <м>                       предельный путь
+//	speed way [-watch]                    пройденный путь
+//	adc enable on|off                     режим АЦП ФЧС-3
+//	adc read [-watch]                     данные АЦП ФЧС-3 в мА
+//	fw info [-base адрес] <файл>          участки и размер образа прошивки (Intel HEX или .bin)
+//	fw flash [флаги] <файл>               обновление прошивки платы -board anl|bin|frq (по умолчанию
+//	                                      ФЧС-3): запись в другой банк памяти, перезагрузка и проверка
+//	                                      версии (-expect); -resume N продолжает прерванную запись,
+//	                                      -base - адрес .bin
+//
+// Флаги:
+//
+//	-json            вывод в JSON
+//	-sim             работать с симулятором плат (для обучения)
+//	-anl12           симулировать ФАС-3 с 12-битными ЦАП
+//	-remote адрес    работать с платами сервера ipkd -grpc адрес
+//	-lang ru|en      язык сообщений об ошибках
+//	-teeth, -diameter параметры датчика скорости и бандажа
+//
+// Пример:
+//
+//	ipkctl -sim dac set -max 1000 3 300kPa
+//	ipkctl -json speed get
+//	ipkctl fw flash -expect 1.2.0 frq3.hex
+//
+// С флагом -sim каждый запуск работает с новым симулятором. Чтобы состояние плат
+// сохранялось между командами, запустите ipkd -sim -grpc :9090 и используйте
+// ipkctl -remote localhost:9090.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/amdf/ipk"
+	"github.com/amdf/ipk/ipkrpc"
+)
+
+var errUsage = errors.New("usage")
+var errNoBoard = fmt.Errorf("плата не открыта:%w", ipk.ErrNotConnected)
+
+// app параметры запуска и открытые платы
+type app struct {
+	ipk   ipk.IPK
+	speed ipk.Speed
+	json  bool
+	lang  string
+	out   io.Writer
+}
+
+// simADC данные АЦП ФЧС-3 в режиме -sim (около 2 мА на ДАТ 1 и ДАТ 2)
+var simADC = ipk.DataADC{Dat1: 400 * 16, Dat2: 100 * 16, ReferenceVal: 800 * 16, DivisorVal: 16}
+
+// command команда или группа команд (sub)
+type command struct {
+	name  string
+	usage string
+	run   func(a *app, ctx context.Context, args []string) error
+	sub   []command
+}
+
+var commands = []command{
+	{name: "list", usage: "list", run: (*app).list},
+	{name: "dac", sub: []command{
+		{name: "get", usage: "dac get [канал]", run: (*app).dacGet},
+		{name: "set", usage: "dac set [-max N] <канал> <значение>[mA|kPa|at]", run: (*app).dacSet},
+	}},
+	{name: "freq", sub: []command{
+		{name: "get", usage: "freq get [канал]", run: (*app).freqGet},
+		{name: "set", usage: "freq set <канал> 200Hz|500Hz|1kHz|2kHz|4kHz", run: (*app).freqSet},
+	}},
+	{name: "inputs", sub: []command{
+		{name: "get", usage: "inputs get", run: (*app).inputsGet},
+		{name: "watch", usage: "inputs watch", run: (*app).inputsWatch},
+	}},
+	{name: "bin", sub: []command{
+		{name: "get", usage: "bin get", run: (*app).binGet},
+		{name: "set10v", usage: "bin set10v <выход 0-7> on|off", run: (*app).binSet10V},
+		{name: "set50v", usage: "bin set50v <выход 0-35> on|off", run: (*app).binSet50V},
+		{name: "if", usage: "bin if <код 0-7>", run: (*app).binIF},
+		{name: "turt", usage: "bin turt on|off", run: (*app).binTURT},
+	}},
+	{name: "speed", sub: []command{
+		{name: "get", usage: "speed get", run: (*app).speedGet},
+		{name: "set", usage: "speed set <км/ч> [км/ч]", run: (*app).speedSet},
+		{name: "accel", usage: "speed accel <0,01 м/с²> [0,01 м/с²]", run: (*app).speedAccel},
+		{name: "motion", usage: "speed motion onward|backwards", run: (*app).speedMotion},
+		{name: "limit", usage: "speed limit <м>", run: (*app).speedLimit},
+		{name: "way", usage: "speed way [-watch]", run: (*app).speedWay},
+	}},
+	{name: "adc", sub: []command{
+		{name: "enable", usage: "adc enable on|off", run: (*app).adcEnable},
+		{name: "read", usage: "adc read [-watch]", run: (*app).adcRead},
+	}},
+	{name: "fw", sub: []command{
+		{name: "info", usage: "fw info [-base адрес] <файл .hex|.bin>", run: (*app).fwInfo},
+		{name: "flash", usage: "fw flash [-board anl|bin|frq] [-base адрес] [-resume N] [-expect версия] [-no-restart] <файл .hex|.bin>", run: (*app).fwFlash},
+	}},
+}
+
+func usage() {
+	w := flag.CommandLine.Output()
+	fmt.Fprintln(w, "Использование: ipkctl [флаги] команда [аргументы]")
+	fmt.Fprintln(w, "\nКоманды:")
+	for _, c := range commands {
+		if nil == c.sub {
+			fmt.Fprintln(w, "  "+c.usage)
+		}
+		for _, sub := range c.sub {
+			fmt.Fprintln(w, "  "+sub.usage)
+		}
+	}
+	fmt.Fprintln(w, "\nФлаги:")
+	flag.PrintDefaults()
+}
+
+// find ищет команду по аргументам командной строки, возвращает оставшиеся аргументы
+func find(args []string) (c *command, rest []string) {
+	list := commands
+	for len(args) > 0 {
+		var found *command
+		for i := range list {
+			if list[i].name == args[0] {
+				found = &list[i]
+				break
+			}
+		}
+		if nil == found {
+			return
+		}
+		c, args = found, args[1:]
+		if nil == c.sub {
+			return c, args
+		}
+		list = c.sub
+	}
+	return nil, nil
+}
+
+func main() {
+	var a app
+	flag.BoolVar(&a.json, "json", false, "вывод в JSON")
+	sim := flag.Bool("sim", false, "работать с симулятором плат вместо оборудования")
+	anl12 := flag.Bool("anl12", false, "симулировать ФАС-3 с 12-битными ЦАП")
+	remote := flag.String("remote", "", "адрес сервиса gRPC ipkd (например, bench1:9090)")
+	flag.StringVar(&a.lang, "lang", ipk.LangRU, "язык сообщений об ошибках: ru или en")
+	teeth := flag.Uint("teeth", 42, "количество зубьев датчика скорости")
+	diameter := flag.Uint("diameter", 1350, "диаметр бандажа, мм")
+	flag.Usage = usage
+	flag.Parse()
+
+	c, args := find(flag.Args())
+	if nil == c {
+		usage()
+		os.Exit(2)
+	}
+	a.out = os.Stdout
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	switch {
+	case *sim:
+		product := uint16(ipk.IDProductANL16bit)
+		if *anl12 {
+			product = ipk.IDProductANL12bit
+		}
+		sims := a.ipk.OpenSimulators(product)
+		sims.Freq.SetADC(simADC)
+	case "" != *remote:
+		client, err := ipkrpc.Dial(*remote)
+		if nil == err {
+			defer client.Close()
+			_, err = client.Open(ctx, &a.ipk)
+		}
+		if nil != err {
+			a.fail(err)
+		}
+	default:
+		a.ipk.OpenAll()
+	}
+	defer a.ipk.CloseAll()
+
+	if nil != a.ipk.FreqDev {
+		if err := a.speed.Init(a.ipk.FreqDev, uint32(*teeth), uint32(*diameter)); nil != err {
+			a.fail(err)
+		}
+	}
+
+	err := c.run(&a, ctx, args)
+	if errors.Is(err, errUsage) {
+		fmt.Fprintln(os.Stderr, "Использование: ipkctl "+c.usage)
+		os.Exit(2)
+	}
+	if nil != err {
+		a.fail(err)
+	}
+}
+
+// fail выводит ошибку и завершает программу
+func (a *app) fail(err error) {
+	// на русском выводится полное сообщение с уточнением (номер канала и т.п.)
+	msg := err.Error()
+	if ipk.LangRU != a.lang {
+		msg = ipk.Localize(err, a.lang)
+	}
+	if a.json {
+		json.NewEncoder(os.Stderr).Encode(struct {
+			Error string `json:"error"`
+		}{msg})
+	} else {
+		fmt.Fprintln(os.Stderr, "ipkctl: "+msg)
+	}
+	a.ipk.CloseAll()
+	os.Exit(1)
+}
+
+// print выводит результат v в JSON или в виде текста, который пишет text
+func (a *app) print(v interface{}, text func(w io.Writer)) {
+	if a.json {
+		json.NewEncoder(a.out).Encode(v)
+		return
+	}
+	text(a.out)
+}
+
+// watch вызывает poll с периодом interval, пока ctx не будет отменён (Ctrl+C)
+func watch(ctx context.Context, interval time.Duration, poll func() error) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := poll(); nil != err {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// onOff разбирает on/off (также 1/0, true/false)
+func onOff(s string) (on bool, err error) {
+	switch strings.ToLower(s) {
+	case "on", "1", "true":
+		return true, nil
+	case "off", "0", "false":
+		return false, nil
+	}
+	return false, fmt.Errorf("%q (ожидается on или off):%w", s, ipk.ErrInvalidParam)
+}
+
+func onOffName(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
@@ -0,0 +1,123 @@
+// Команда ipkd - сервер HTTP/JSON для удалённого управления платами ФПС-3.
+//
+// Запуск с оборудованием:
+//
+//	ipkd -addr :8080
+//
+// Запуск с симулятором плат (для обучения и проверки клиентов):
+//
+//	ipkd -sim
+//
+// С флагом -grpc (например, -grpc :9090) дополнительно запускается сервис gRPC
+// (см. пакет ipkrpc) с потоковой передачей скорости, пути, АЦП и двоичных входов.
+//
+// Основные запросы (номера ЦАП и частотных выходов - с 1, двоичных входов и выходов - с 0):
+//
+//	GET  /api/status                    наличие плат и версии прошивок
+//	GET  /api/analog/dac[/N]            значения ЦАП ФАС-3 в мА
+//	PUT  /api/analog/dac/N              {"milliamper": 5} или {"pressure": 300, "unit": "kPa", "max": 1000}
+//	GET  /api/analog/freq[/N]           частотные выходы ФАС-3
+//	PUT  /api/analog/freq/N             {"freq": "1kHz"}
+//	GET  /api/analog/inputs             двоичные входы ФАС-3
+//	GET  /api/binary/outputs            выходы 10 В и 50 В, код ИФ и TURT ФДС-3
+//	PUT  /api/binary/10v/N              {"on": true}
+//	PUT  /api/binary/50v/N              {"on": true}
+//	GET|PUT /api/binary/if              {"state": 3}
+//	GET|PUT /api/binary/turt            {"on": true}
+//	GET  /api/freq                      скорость, ускорение, направление и путь ФЧС-3
+//	PUT  /api/freq/speed                {"speed1": 60, "speed2": 60}
+//	PUT  /api/freq/acceleration         {"acceleration1": 50, "acceleration2": 50}
+//	PUT  /api/freq/motion               {"motion": "onward"}
+//	PUT  /api/freq/limit                {"meters": 100}
+//	GET|PUT /api/freq/adc               {"enabled": true}
+//	GET  /api/freq/version              версия прошивки ФЧС-3
+//	GET  /api/schema[/имя]              JSON Schema запросов и ответов
+//
+// Ошибки возвращаются в виде {"error": "..."}; язык сообщений - ?lang=en или Accept-Language.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/amdf/ipk"
+	"github.com/amdf/ipk/ipkrpc"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "адрес сервера")
+	grpcAddr := flag.String("grpc", "", "адрес сервиса gRPC (не запускается, если не задан)")
+	sim := flag.Bool("sim", false, "работать с симулятором плат вместо оборудования")
+	anl12 := flag.Bool("anl12", false, "симулировать ФАС-3 с 12-битными ЦАП")
+	teeth := flag.Uint("teeth", 42, "количество зубьев датчика скорости")
+	diameter := flag.Uint("diameter", 1350, "диаметр бандажа, мм")
+	flag.Parse()
+
+	var dev ipk.IPK
+	if *sim {
+		product := uint16(ipk.IDProductANL16bit)
+		if *anl12 {
+			product = ipk.IDProductANL12bit
+		}
+		dev.OpenSimulators(product)
+	} else if !dev.OpenAll() {
+		log.Fatal("платы ФПС-3 не найдены")
+	}
+	defer dev.CloseAll()
+
+	supervisor := ipk.NewSupervisor(&dev)
+	supervisor.Start()
+	defer supervisor.Stop()
+
+	server, err := NewServer(&dev, uint32(*teeth), uint32(*diameter))
+	if nil != err {
+		log.Fatal(err)
+	}
+
+	var grpcServer *grpc.Server
+	if "" != *grpcAddr {
+		service, err := ipkrpc.NewServer(&dev, uint32(*teeth), uint32(*diameter))
+		if nil != err {
+			log.Fatal(err)
+		}
+		defer service.Close()
+		listener, err := net.Listen("tcp", *grpcAddr)
+		if nil != err {
+			log.Fatal(err)
+		}
+		grpcServer = grpc.NewServer()
+		service.Register(grpcServer)
+		go func() {
+			log.Printf("ipkd: gRPC %s", *grpcAddr)
+			if err := grpcServer.Serve(listener); nil != err {
+				log.Print(err)
+			}
+		}()
+	}
+
+	httpServer := &http.Server{Addr: *addr, Handler: server}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdown)
+		if nil != grpcServer {
+			grpcServer.GracefulStop()
+		}
+	}()
+
+	log.Printf("ipkd: %s", *addr)
+	if err = httpServer.ListenAndServe(); nil != err && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// schemas JSON Schema тел запросов и ответов (GET /api/schema/<имя>)
+var schemas = map[string]string{
+	"status": `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "StatusResponse",
+  "type": "object",
+  "properties": {
+    "analog": {"$ref": "#/definitions/board"},
+    "binary": {"$ref": "#/definitions/board"},
+    "freq": {"$ref": "#/definitions/board"}
+  },
+  "definitions": {
+    "board": {
+      "type": "object",
+      "properties": {
+        "product_id": {"type": "integer"},
+        "name": {"type": "string"},
+        "present": {"type": "boolean"},
+        "version": {"type": "string"},
+        "error": {"type": "string"}
+      },
+      "required": ["product_id", "name", "present"]
+    }
+  }
+}`,
+	"dac": `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "DACState",
+  "type": "object",
+  "properties": {
+    "channel": {"type": "integer", "minimum": 1, "maximum": 14},
+    "milliamper": {"type": "number"},
+    "max_milliamper": {"type": "number"}
+  },
+  "required": ["channel", "milliamper", "max_milliamper"]
+}`,
+	"dac-request": `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "DACRequest",
+  "type": "object",
+  "oneOf": [
+    {"properties": {"milliamper": {"type": "number", "minimum": 0}}, "required": ["milliamper"], "additionalProperties": false},
+    {
+      "properties": {
+        "pressure": {"type": "number", "minimum": 0},
+        "unit": {"enum": ["kPa", "at"]},
+        "max": {"type": "number", "exclusiveMinimum": 0}
+      },
+      "required": ["pressure", "unit", "max"],
+      "additionalProperties": false
+    }
+  ]
+}`,
+	"analog-freq": `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "AnlFreqState",
+  "type": "object",
+  "properties": {
+    "channel": {"type": "integer", "minimum": 1, "maximum": 4},
+    "code": {"type": "integer"},
+    "freq": {"enum": ["200Hz", "500Hz", "1kHz", "2kHz", "4kHz", ""]}
+  },
+  "required": ["channel", "code", "freq"]
+}`,
+	"analog-freq-request": `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "AnlFreqRequest",
+  "type": "object",
+  "oneOf": [
+    {"properties": {"freq": {"enum": ["200Hz", "500Hz", "1kHz", "2kHz", "4kHz"]}}, "required": ["freq"], "additionalProperties": false},
+    {"properties": {"code": {"type": "integer", "minimum": 0, "maximum": 65535}}, "required": ["code"], "additionalProperties": false}
+  ]
+}`,
+	"inputs": `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "InputsState",
+  "type": "object",
+  "properties": {
+    "value": {"type": "integer", "minimum": 0, "maximum": 65535},
+    "inputs": {"type": "array", "items": {"type": "boolean"}, "minItems": 16, "maxItems": 16}
+  },
+  "required": ["value", "inputs"]
+}`,
+	"outputs": `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "OutputsState",
+  "type": "object",
+  "properties": {
+    "10v": {"type": "array", "items": {"type": "boolean"}, "minItems": 8, "maxItems": 8},
+    "50v": {"type": "array", "items": {"type": "boolean"}, "minItems": 36, "maxItems": 36},
+    "if": {"type": "integer", "minimum": 0, "maximum": 7},
+    "turt": {"type": "boolean"}
+  },
+  "required": ["10v", "50v", "if", "turt"]
+}`,
+	"output-request": `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "OutputRequest",
+  "type": "object",
+  "properties": {"on": {"type": "boolean"}},
+  "required": ["on"],
+  "additionalProperties": false
+}`,
+	"if-request": `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "IFRequest",
+  "type": "object",
+  "properties": {"state": {"type": "integer", "minimum": 0, "maximum": 7}},
+  "required": ["state"],
+  "additionalProperties": false
+}`,
+	"freq": `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "FreqState",
+  "type": "object",
+  "properties": {
+    "speed1": {"type": "number", "description": "km/h"},
+    "speed2": {"type": "number", "description": "km/h"},
+    "acceleration1": {"type": "number", "description": "0.01 m/s²"},
+    "acceleration2": {"type": "number", "description": "0.01 m/s²"},
+    "motion": {"enum": ["onward", "backwards", "unknown"]},
+    "way1": {"type": "integer", "description": "m"},
+    "way2": {"type": "integer", "description": "m"},
+    "limit_way": {"type": "integer", "description": "m"},
+    "hz1": {"type": "number"},
+    "hz2": {"type": "number"},
+    "adc_mode": {"type": "boolean"}
+  }
+}`,
+	"speed-request": `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "SpeedRequest",
+  "type": "object",
+  "properties": {
+    "speed1": {"type": "number", "minimum": 0, "description": "km/h"},
+    "speed2": {"type": "number", "minimum": 0, "description": "km/h"}
+  },
+  "additionalProperties": false
+}`,
+	"acceleration-request": `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "AccelerationRequest",
+  "type": "object",
+  "properties": {
+    "acceleration1": {"type": "number", "description": "0.01 m/s²"},
+    "acceleration2": {"type": "number", "description": "0.01 m/s²"}
+  },
+  "additionalProperties": false
+}`,
+	"motion-request": `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "MotionRequest",
+  "type": "object",
+  "properties": {"motion": {"enum": ["onward", "backwards"]}},
+  "required": ["motion"],
+  "additionalProperties": false
+}`,
+	"limit-request": `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "LimitRequest",
+  "type": "object",
+  "properties": {"meters": {"type": "integer", "minimum": 0}},
+  "required": ["meters"],
+  "additionalProperties": false
+}`,
+	"adc": `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "ADCState",
+  "type": "object",
+  "properties": {
+    "enabled": {"type": "boolean"},
+    "dat1": {"type": "number", "description": "mA"},
+    "dat2": {"type": "number", "description": "mA"},
+    "ref": {"type": "number", "description": "mA"}
+  },
+  "required": ["enabled"]
+}`,
+	"adc-request": `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "ADCRequest",
+  "type": "object",
+  "properties": {"enabled": {"type": "boolean"}},
+  "required": ["enabled"],
+  "additionalProperties": false
+}`,
+	"version": `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "VersionResponse",
+  "type": "object",
+  "properties": {"version": {"type": "string", "pattern": "^[0-9]+\\.[0-9]+\\.[0-9]+$"}},
+  "required": ["version"]
+}`,
+	"error": `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "ErrorResponse",
+  "type": "object",
+  "properties": {"error": {"type": "string"}},
+  "required": ["error"]
+}`,
+}
+
+// handleSchema отдаёт схему по имени; без имени - список имён схем
+func (s *Server) handleSchema(w http.ResponseWriter, r *http.Request) {
+	if !methods(w, r, http.MethodGet) {
+		return
+	}
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/schema"), "/")
+	if "" == name {
+		names := make([]string, 0, len(schemas))
+		for n := range schemas {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		reply(w, r, names, nil)
+		return
+	}
+	schema, ok := schemas[name]
+	if !ok {
+		reply(w, r, nil, errNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Write([]byte(schema))
+}
@@ -0,0 +1,763 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/amdf/ipk"
+)
+
+var errNotFound = errors.New("not found")
+var errNoBoard = errors.New("board is not opened")
+
+// Server HTTP/JSON интерфейс к платам ФПС-3.
+// Обращения к платам выполняются функциями библиотеки, которые сами захватывают
+// мьютексы своих плат, поэтому запросы к серверу можно выполнять параллельно.
+type Server struct {
+	ipk   *ipk.IPK
+	dacs  [14]ipk.DAC
+	speed ipk.Speed
+	mux   *http.ServeMux
+}
+
+// NewServer создаёт сервер для плат dev. teeth и diameter - параметры датчика скорости
+// и бандажа для пересчёта частоты ФЧС-3 в скорость и путь (см. ipk.Speed).
+func NewServer(dev *ipk.IPK, teeth, diameter uint32) (s *Server, err error) {
+	if nil == dev {
+		err = errNoBoard
+		return
+	}
+	s = &Server{ipk: dev, mux: http.NewServeMux()}
+	if nil != dev.AnalogDev {
+		for ch := range s.dacs {
+			if err = s.dacs[ch].Init(dev.AnalogDev, uint8(ch)); nil != err {
+				return
+			}
+		}
+	}
+	if nil != dev.FreqDev {
+		if err = s.speed.Init(dev.FreqDev, teeth, diameter); nil != err {
+			return
+		}
+	}
+
+	s.mux.HandleFunc("/api/status", s.handleStatus)
+	s.mux.HandleFunc("/api/schema", s.handleSchema)
+	s.mux.HandleFunc("/api/schema/", s.handleSchema)
+	s.mux.HandleFunc("/api/analog/dac", s.handleDAC)
+	s.mux.HandleFunc("/api/analog/dac/", s.handleDAC)
+	s.mux.HandleFunc("/api/analog/freq", s.handleAnlFreq)
+	s.mux.HandleFunc("/api/analog/freq/", s.handleAnlFreq)
+	s.mux.HandleFunc("/api/analog/inputs", s.handleInputs)
+	s.mux.HandleFunc("/api/binary/outputs", s.handleOutputs)
+	s.mux.HandleFunc("/api/binary/10v/", s.handleOutput10V)
+	s.mux.HandleFunc("/api/binary/50v/", s.handleOutput50V)
+	s.mux.HandleFunc("/api/binary/if", s.handleIF)
+	s.mux.HandleFunc("/api/binary/turt", s.handleTURT)
+	s.mux.HandleFunc("/api/freq", s.handleFreq)
+	s.mux.HandleFunc("/api/freq/speed", s.handleSpeed)
+	s.mux.HandleFunc("/api/freq/acceleration", s.handleAcceleration)
+	s.mux.HandleFunc("/api/freq/motion", s.handleMotion)
+	s.mux.HandleFunc("/api/freq/limit", s.handleLimit)
+	s.mux.HandleFunc("/api/freq/adc", s.handleADC)
+	s.mux.HandleFunc("/api/freq/version", s.handleVersion)
+	return
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+///////////////////////////////////////////////////////////////
+
+// errorResponse ответ с ошибкой
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// lang язык сообщений об ошибках: ?lang=en или заголовок Accept-Language
+func lang(r *http.Request) string {
+	l := r.URL.Query().Get("lang")
+	if "" == l {
+		l = r.Header.Get("Accept-Language")
+	}
+	if strings.HasPrefix(strings.ToLower(l), ipk.LangEN) {
+		return ipk.LangEN
+	}
+	return ipk.LangRU
+}
+
+// statusCode код ответа HTTP для ошибки библиотеки
+func statusCode(err error) int {
+	switch {
+	case errors.Is(err, errNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errNoBoard), errors.Is(err, ipk.ErrNotConnected):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ipk.ErrInvalidParam), errors.Is(err, ipk.ErrNotInitialized):
+		return http.StatusBadRequest
+	case errors.Is(err, ipk.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, ipk.ErrADCNotEnabled), errors.Is(err, ipk.ErrADCNoData):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// reply отправляет результат v или ошибку err
+func reply(w http.ResponseWriter, r *http.Request, v interface{}, err error) {
+	if nil != err {
+		writeJSON(w, statusCode(err), errorResponse{Error: ipk.Localize(err, lang(r))})
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+// methods проверяет метод запроса; если он не разрешён, отправляет ответ 405
+func methods(w http.ResponseWriter, r *http.Request, allowed ...string) bool {
+	for _, m := range allowed {
+		if r.Method == m {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+	return false
+}
+
+// decode читает тело запроса в v. Неизвестные поля считаются ошибкой.
+func decode(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); nil != err {
+		return fmt.Errorf("%v:%w", err, ipk.ErrInvalidParam)
+	}
+	return nil
+}
+
+// index разбирает номер в конце пути запроса после prefix; "" - номер не указан
+func index(r *http.Request, prefix string, min, max int) (n int, all bool, err error) {
+	tail := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+	if "" == tail {
+		all = true
+		return
+	}
+	n, err = strconv.Atoi(tail)
+	if nil != err || n < min || n > max {
+		err = errNotFound
+	}
+	return
+}
+
+///////////////////////////////////////////////////////////////
+
+// BoardStatus состояние платы в ответе /api/status
+type BoardStatus struct {
+	ProductID uint16 `json:"product_id"`
+	Name      string `json:"name"`
+	Present   bool   `json:"present"`
+	Version   string `json:"version,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// StatusResponse ответ /api/status
+type StatusResponse struct {
+	Analog BoardStatus `json:"analog"`
+	Binary BoardStatus `json:"binary"`
+	Freq   BoardStatus `json:"freq"`
+}
+
+func boardStatus(bs ipk.BoardStatus, l string) BoardStatus {
+	st := BoardStatus{ProductID: bs.ProductID, Name: bs.Name, Present: bs.Present, Version: bs.Version}
+	if nil != bs.Err {
+		st.Error = ipk.Localize(bs.Err, l)
+	}
+	return st
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if !methods(w, r, http.MethodGet) {
+		return
+	}
+	st := s.ipk.Status()
+	l := lang(r)
+	reply(w, r, StatusResponse{
+		Analog: boardStatus(st.Analog, l),
+		Binary: boardStatus(st.Binary, l),
+		Freq:   boardStatus(st.Freq, l),
+	}, nil)
+}
+
+///////////////////////////////////////////////////////////////
+
+// DACState состояние канала ЦАП ФАС-3
+type DACState struct {
+	Channel       int     `json:"channel"` // от 1 до 14
+	MilliAmper    float64 `json:"milliamper"`
+	MaxMilliAmper float64 `json:"max_milliamper"`
+}
+
+// DACRequest задание канала ЦАП: либо ток в мА, либо давление.
+// Давление задаётся в единицах unit ("kPa" или "at") при максимуме шкалы датчика max.
+type DACRequest struct {
+	MilliAmper *float64 `json:"milliamper,omitempty"`
+	Pressure   *float64 `json:"pressure,omitempty"`
+	Unit       string   `json:"unit,omitempty"`
+	Max        float64  `json:"max,omitempty"`
+}
+
+func (s *Server) dacState(ctx context.Context, ch int) (st DACState, err error) {
+	dac := &s.dacs[ch]
+	st = DACState{Channel: ch + 1, MaxMilliAmper: dac.GetMaxMilliAmper()}
+	st.MilliAmper, err = dac.GetMilliAmperCtx(ctx)
+	return
+}
+
+func (s *Server) setDAC(ctx context.Context, ch int, req DACRequest) (err error) {
+	dac := &s.dacs[ch]
+	switch {
+	case nil != req.MilliAmper && nil == req.Pressure:
+		return dac.SetMilliAmperCtx(ctx, *req.MilliAmper)
+	case nil != req.Pressure && nil == req.MilliAmper:
+		var unit uint8
+		switch strings.ToLower(req.Unit) {
+		case "kpa":
+			unit = ipk.DACKiloPascal
+		case "at":
+			unit = ipk.DACAtmosphere
+		default:
+			return fmt.Errorf("unit %q:%w", req.Unit, ipk.ErrInvalidParam)
+		}
+		var pres ipk.PressureOutput
+		if err = pres.Init(dac, unit, req.Max); nil != err {
+			return
+		}
+		return pres.SetCtx(ctx, *req.Pressure)
+	}
+	return fmt.Errorf("milliamper or pressure is required:%w", ipk.ErrInvalidParam)
+}
+
+func (s *Server) handleDAC(w http.ResponseWriter, r *http.Request) {
+	if !methods(w, r, http.MethodGet, http.MethodPut) {
+		return
+	}
+	if nil == s.ipk.AnalogDev {
+		reply(w, r, nil, errNoBoard)
+		return
+	}
+	n, all, err := index(r, "/api/analog/dac", 1, len(s.dacs))
+	if nil != err {
+		reply(w, r, nil, err)
+		return
+	}
+	if all {
+		if http.MethodPut == r.Method {
+			reply(w, r, nil, errNotFound)
+			return
+		}
+		states := make([]DACState, len(s.dacs))
+		for ch := range s.dacs {
+			if states[ch], err = s.dacState(r.Context(), ch); nil != err {
+				break
+			}
+		}
+		reply(w, r, states, err)
+		return
+	}
+
+	ch := n - 1
+	if http.MethodPut == r.Method {
+		var req DACRequest
+		if err = decode(r, &req); nil == err {
+			err = s.setDAC(r.Context(), ch, req)
+		}
+		if nil != err {
+			reply(w, r, nil, err)
+			return
+		}
+	}
+	st, err := s.dacState(r.Context(), ch)
+	reply(w, r, st, err)
+}
+
+///////////////////////////////////////////////////////////////
+
+// AnlFreqState состояние частотного выхода ФАС-3
+type AnlFreqState struct {
+	Channel int    `json:"channel"` // от 1 до 4
+	Code    uint16 `json:"code"`    // значение ipk.AnlFreq
+	Freq    string `json:"freq"`    // 200Hz, 500Hz, 1kHz, 2kHz, 4kHz или "" для других значений
+}
+
+// AnlFreqRequest задание частотного выхода: название частоты или значение ipk.AnlFreq
+type AnlFreqRequest struct {
+	Freq string  `json:"freq,omitempty"`
+	Code *uint16 `json:"code,omitempty"`
+}
+
+func (s *Server) anlFreqState(ctx context.Context, ch int) (st AnlFreqState, err error) {
+	st.Channel = ch + 1
+	st.Code, err = s.ipk.AnalogDev.GetOutputFreqCtx(ctx, uint8(ch))
+	st.Freq = ipk.AnlFreqName(st.Code)
+	return
+}
+
+func (s *Server) handleAnlFreq(w http.ResponseWriter, r *http.Request) {
+	if !methods(w, r, http.MethodGet, http.MethodPut) {
+		return
+	}
+	if nil == s.ipk.AnalogDev {
+		reply(w, r, nil, errNoBoard)
+		return
+	}
+	n, all, err := index(r, "/api/analog/freq", 1, ipk.FREQ4+1)
+	if nil != err {
+		reply(w, r, nil, err)
+		return
+	}
+	if all {
+		if http.MethodPut == r.Method {
+			reply(w, r, nil, errNotFound)
+			return
+		}
+		states := make([]AnlFreqState, ipk.FREQ4+1)
+		for ch := range states {
+			if states[ch], err = s.anlFreqState(r.Context(), ch); nil != err {
+				break
+			}
+		}
+		reply(w, r, states, err)
+		return
+	}
+
+	ch := n - 1
+	if http.MethodPut == r.Method {
+		var req AnlFreqRequest
+		err = decode(r, &req)
+		var code uint16
+		switch {
+		case nil != err:
+		case nil != req.Code && "" == req.Freq:
+			code = *req.Code
+		case nil == req.Code && "" != req.Freq:
+			code, err = ipk.ParseAnlFreq(req.Freq)
+		default:
+			err = fmt.Errorf("freq or code is required:%w", ipk.ErrInvalidParam)
+		}
+		if nil == err {
+			err = s.ipk.AnalogDev.SetFreqCtx(r.Context(), uint8(ch), code)
+		}
+		if nil != err {
+			reply(w, r, nil, err)
+			return
+		}
+	}
+	st, err := s.anlFreqState(r.Context(), ch)
+	reply(w, r, st, err)
+}
+
+///////////////////////////////////////////////////////////////
+
+// InputsState состояние двоичных входов ФАС-3
+type InputsState struct {
+	Value  uint16   `json:"value"`  // младший бит - вход 0
+	Inputs [16]bool `json:"inputs"` // входы от 0 до 15
+}
+
+func (s *Server) handleInputs(w http.ResponseWriter, r *http.Request) {
+	if !methods(w, r, http.MethodGet) {
+		return
+	}
+	if nil == s.ipk.AnalogDev {
+		reply(w, r, nil, errNoBoard)
+		return
+	}
+	var st InputsState
+	val, err := s.ipk.AnalogDev.UintGetBinaryInputCtx(r.Context())
+	st.Value = val
+	for i := range st.Inputs {
+		st.Inputs[i] = 0 != val&(1<<i)
+	}
+	reply(w, r, st, err)
+}
+
+///////////////////////////////////////////////////////////////
+
+// OutputsState состояние выходов ФДС-3
+type OutputsState struct {
+	Out10V [8]bool  `json:"10v"` // выходы 10 В от 0 до 7
+	Out50V [36]bool `json:"50v"` // выходы 50 В от 0 до 35
+	IF     uint8    `json:"if"`
+	TURT   bool     `json:"turt"`
+}
+
+// OutputRequest включение (true) или выключение (false) выхода, TURT
+type OutputRequest struct {
+	On *bool `json:"on"`
+}
+
+// IFRequest задание кода ИФ (от ipk.IFDisable до ipk.IFEnable)
+type IFRequest struct {
+	State *uint8 `json:"state"`
+}
+
+func (s *Server) outputsState(ctx context.Context) (st OutputsState, err error) {
+	dev := s.ipk.BinDev
+	v10, err := dev.UintGetOutput10VCtx(ctx)
+	if nil != err {
+		return
+	}
+	v50, err := dev.UintGetOutput50VCtx(ctx)
+	if nil != err {
+		return
+	}
+	for i := range st.Out10V {
+		st.Out10V[i] = 0 != v10&(1<<i)
+	}
+	for i := range st.Out50V {
+		st.Out50V[i] = 0 != v50&(1<<i)
+	}
+	if st.IF, err = dev.GetOutputIFCtx(ctx); nil != err {
+		return
+	}
+	st.TURT, err = dev.GetOutputTURTCtx(ctx)
+	return
+}
+
+func (s *Server) handleOutputs(w http.ResponseWriter, r *http.Request) {
+	if !methods(w, r, http.MethodGet) {
+		return
+	}
+	if nil == s.ipk.BinDev {
+		reply(w, r, nil, errNoBoard)
+		return
+	}
+	st, err := s.outputsState(r.Context())
+	reply(w, r, st, err)
+}
+
+// handleOutput обрабатывает /api/binary/10v/N и /api/binary/50v/N
+func (s *Server) handleOutput(w http.ResponseWriter, r *http.Request, prefix string, count int,
+	set func(ctx context.Context, num uint, val bool) error) {
+	if !methods(w, r, http.MethodPut) {
+		return
+	}
+	if nil == s.ipk.BinDev {
+		reply(w, r, nil, errNoBoard)
+		return
+	}
+	n, all, err := index(r, prefix, 0, count-1)
+	if all {
+		err = errNotFound
+	}
+	var req OutputRequest
+	if nil == err {
+		err = decode(r, &req)
+	}
+	if nil == err && nil == req.On {
+		err = fmt.Errorf("on is required:%w", ipk.ErrInvalidParam)
+	}
+	if nil == err {
+		err = set(r.Context(), uint(n), *req.On)
+	}
+	if nil != err {
+		reply(w, r, nil, err)
+		return
+	}
+	st, err := s.outputsState(r.Context())
+	reply(w, r, st, err)
+}
+
+func (s *Server) handleOutput10V(w http.ResponseWriter, r *http.Request) {
+	s.handleOutput(w, r, "/api/binary/10v", 8, func(ctx context.Context, num uint, val bool) error {
+		return s.ipk.BinDev.Set10VCtx(ctx, num, val)
+	})
+}
+
+func (s *Server) handleOutput50V(w http.ResponseWriter, r *http.Request) {
+	s.handleOutput(w, r, "/api/binary/50v", 36, func(ctx context.Context, num uint, val bool) error {
+		return s.ipk.BinDev.Set50VCtx(ctx, num, val)
+	})
+}
+
+func (s *Server) handleIF(w http.ResponseWriter, r *http.Request) {
+	if !methods(w, r, http.MethodGet, http.MethodPut) {
+		return
+	}
+	if nil == s.ipk.BinDev {
+		reply(w, r, nil, errNoBoard)
+		return
+	}
+	if http.MethodPut == r.Method {
+		var req IFRequest
+		err := decode(r, &req)
+		if nil == err && nil == req.State {
+			err = fmt.Errorf("state is required:%w", ipk.ErrInvalidParam)
+		}
+		if nil == err {
+			err = s.ipk.BinDev.SetIFCtx(r.Context(), *req.State)
+		}
+		if nil != err {
+			reply(w, r, nil, err)
+			return
+		}
+	}
+	state, err := s.ipk.BinDev.GetOutputIFCtx(r.Context())
+	reply(w, r, IFRequest{State: &state}, err)
+}
+
+func (s *Server) handleTURT(w http.ResponseWriter, r *http.Request) {
+	if !methods(w, r, http.MethodGet, http.MethodPut) {
+		return
+	}
+	if nil == s.ipk.BinDev {
+		reply(w, r, nil, errNoBoard)
+		return
+	}
+	if http.MethodPut == r.Method {
+		var req OutputRequest
+		err := decode(r, &req)
+		if nil == err && nil == req.On {
+			err = fmt.Errorf("on is required:%w", ipk.ErrInvalidParam)
+		}
+		if nil == err {
+			err = s.ipk.BinDev.SetTURTCtx(r.Context(), *req.On)
+		}
+		if nil != err {
+			reply(w, r, nil, err)
+			return
+		}
+	}
+	on, err := s.ipk.BinDev.GetOutputTURTCtx(r.Context())
+	reply(w, r, OutputRequest{On: &on}, err)
+}
+
+///////////////////////////////////////////////////////////////
+
+// FreqState состояние ФЧС-3
+type FreqState struct {
+	Speed1        float64 `json:"speed1"`        // км/ч
+	Speed2        float64 `json:"speed2"`        // км/ч
+	Acceleration1 float64 `json:"acceleration1"` // 0,01 м/с²
+	Acceleration2 float64 `json:"acceleration2"` // 0,01 м/с²
+	Motion        string  `json:"motion"`        // onward, backwards или unknown
+	Way1          uint32  `json:"way1"`          // пройденный путь, м
+	Way2          uint32  `json:"way2"`          // пройденный путь, м
+	LimitWay      uint32  `json:"limit_way"`     // предельный путь, м
+	Hz1           float64 `json:"hz1"`
+	Hz2           float64 `json:"hz2"`
+	ADCMode       bool    `json:"adc_mode"`
+}
+
+// SpeedRequest задание скорости (км/ч) обоих генераторов
+type SpeedRequest struct {
+	Speed1 float64 `json:"speed1"`
+	Speed2 float64 `json:"speed2"`
+}
+
+// AccelerationRequest задание ускорения (0,01 м/с²) обоих генераторов
+type AccelerationRequest struct {
+	Acceleration1 float64 `json:"acceleration1"`
+	Acceleration2 float64 `json:"acceleration2"`
+}
+
+// MotionRequest задание направления движения: onward или backwards
+type MotionRequest struct {
+	Motion string `json:"motion"`
+}
+
+// LimitRequest задание предельного пути, м
+type LimitRequest struct {
+	Meters *uint32 `json:"meters"`
+}
+
+// ADCState данные АЦП ФЧС-3 в мА
+type ADCState struct {
+	Enabled bool    `json:"enabled"`
+	Dat1    float64 `json:"dat1"`
+	Dat2    float64 `json:"dat2"`
+	Ref     float64 `json:"ref"`
+}
+
+// ADCRequest включение режима АЦП
+type ADCRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// VersionResponse версия прошивки ФЧС-3
+type VersionResponse struct {
+	Version string `json:"version"`
+}
+
+// motionName возвращает название направления движения (см. ipk.FreqSnapshot.Direction)
+func motionName(motion uint8) string {
+	switch motion {
+	case ipk.MotionOnward:
+		return "onward"
+	case ipk.MotionBackwards:
+		return "backwards"
+	}
+	return "unknown"
+}
+
+func (s *Server) freqState(ctx context.Context) (st FreqState, err error) {
+	if err = s.ipk.FreqDev.UpdateFreqDataUSBCtx(ctx); nil != err {
+		return
+	}
+	if st.Speed1, st.Speed2, err = s.speed.GetOutputSpeed(); nil != err {
+		return
+	}
+	if st.Acceleration1, st.Acceleration2, err = s.speed.GetOutputAcceleration(); nil != err {
+		return
+	}
+	if st.Way1, st.Way2, err = s.speed.GetWay(); nil != err {
+		return
+	}
+	if st.LimitWay, err = s.speed.GetLimitWay(); nil != err {
+		return
+	}
+	snap := s.ipk.FreqDev.Snapshot()
+	st.Hz1, st.Hz2, st.ADCMode = snap.Hz1, snap.Hz2, snap.ADCModeEnabled
+	st.Motion = motionName(snap.Direction())
+	return
+}
+
+// handleFreqCommand выполняет команду ФЧС-3 (PUT) и возвращает состояние платы
+func (s *Server) handleFreqCommand(w http.ResponseWriter, r *http.Request, req interface{}, command func(ctx context.Context) error) {
+	if !methods(w, r, http.MethodPut) {
+		return
+	}
+	if nil == s.ipk.FreqDev {
+		reply(w, r, nil, errNoBoard)
+		return
+	}
+	err := decode(r, req)
+	if nil == err {
+		err = command(r.Context())
+	}
+	if nil != err {
+		reply(w, r, nil, err)
+		return
+	}
+	st, err := s.freqState(r.Context())
+	reply(w, r, st, err)
+}
+
+func (s *Server) handleFreq(w http.ResponseWriter, r *http.Request) {
+	if !methods(w, r, http.MethodGet) {
+		return
+	}
+	if nil == s.ipk.FreqDev {
+		reply(w, r, nil, errNoBoard)
+		return
+	}
+	st, err := s.freqState(r.Context())
+	reply(w, r, st, err)
+}
+
+func (s *Server) handleSpeed(w http.ResponseWriter, r *http.Request) {
+	var req SpeedRequest
+	s.handleFreqCommand(w, r, &req, func(ctx context.Context) error {
+		return s.speed.SetSpeedCtx(ctx, req.Speed1, req.Speed2)
+	})
+}
+
+func (s *Server) handleAcceleration(w http.ResponseWriter, r *http.Request) {
+	var req AccelerationRequest
+	s.handleFreqCommand(w, r, &req, func(ctx context.Context) error {
+		return s.speed.SetAccelerationCtx(ctx, req.Acceleration1, req.Acceleration2)
+	})
+}
+
+func (s *Server) handleMotion(w http.ResponseWriter, r *http.Request) {
+	var req MotionRequest
+	s.handleFreqCommand(w, r, &req, func(ctx context.Context) error {
+		switch req.Motion {
+		case "onward":
+			return s.speed.SetMotionCtx(ctx, ipk.MotionOnward)
+		case "backwards":
+			return s.speed.SetMotionCtx(ctx, ipk.MotionBackwards)
+		}
+		return fmt.Errorf("motion %q:%w", req.Motion, ipk.ErrInvalidParam)
+	})
+}
+
+func (s *Server) handleLimit(w http.ResponseWriter, r *http.Request) {
+	var req LimitRequest
+	s.handleFreqCommand(w, r, &req, func(ctx context.Context) error {
+		if nil == req.Meters {
+			return fmt.Errorf("meters is required:%w", ipk.ErrInvalidParam)
+		}
+		return s.speed.SetLimitWayCtx(ctx, *req.Meters)
+	})
+}
+
+func (s *Server) adcState(ctx context.Context) (st ADCState, err error) {
+	dev := s.ipk.FreqDev
+	if err = dev.UpdateFreqDataUSBCtx(ctx); nil != err {
+		return
+	}
+	if st.Enabled = dev.Snapshot().ADCModeEnabled; !st.Enabled {
+		return
+	}
+	if err = dev.UpdateADCCtx(ctx); nil != err {
+		return
+	}
+	if st.Dat1, err = dev.GetDat1MilliAmper(); nil != err {
+		return
+	}
+	if st.Dat2, err = dev.GetDat2MilliAmper(); nil != err {
+		return
+	}
+	st.Ref, err = dev.GetRefValMilliAmper()
+	return
+}
+
+func (s *Server) handleADC(w http.ResponseWriter, r *http.Request) {
+	if !methods(w, r, http.MethodGet, http.MethodPut) {
+		return
+	}
+	if nil == s.ipk.FreqDev {
+		reply(w, r, nil, errNoBoard)
+		return
+	}
+	if http.MethodPut == r.Method {
+		var req ADCRequest
+		err := decode(r, &req)
+		if nil == err && nil == req.Enabled {
+			err = fmt.Errorf("enabled is required:%w", ipk.ErrInvalidParam)
+		}
+		if nil == err {
+			err = s.ipk.FreqDev.EnableADCCtx(r.Context(), *req.Enabled)
+		}
+		if nil != err {
+			reply(w, r, nil, err)
+			return
+		}
+	}
+	st, err := s.adcState(r.Context())
+	reply(w, r, st, err)
+}
+
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	if !methods(w, r, http.MethodGet) {
+		return
+	}
+	if nil == s.ipk.FreqDev {
+		reply(w, r, nil, errNoBoard)
+		return
+	}
+	version, err := s.ipk.FreqDev.GetVersionStringCtx(r.Context())
+	reply(w, r, VersionResponse{Version: version}, err)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/amdf/ipk"
+)
+
+// newSimServer запускает сервер ipkd с симулятором плат, как ipkd -sim
+func newSimServer(t *testing.T) (*httptest.Server, ipk.Simulators) {
+	t.Helper()
+	var dev ipk.IPK
+	sims := dev.OpenSimulators(ipk.IDProductANL16bit)
+	server, err := NewServer(&dev, 42, 1350)
+	if nil != err {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server)
+	t.Cleanup(func() {
+		ts.Close()
+		dev.CloseAll()
+	})
+	return ts, sims
+}
+
+// request выполняет запрос к серверу и возвращает код ответа и тело
+func request(t *testing.T, ts *httptest.Server, method, path, body string) (code int, text string) {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	if nil != err {
+		t.Fatal(err)
+	}
+	resp, err := ts.Client().Do(req)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if nil != err {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(b)
+}
+
+func TestServer(t *testing.T) {
+	ts, sims := newSimServer(t)
+	sims.Analog.SetBinaryInput(0x0005)
+
+	// запросы выполняются по порядку на одном сервере: ответы учитывают предыдущие команды
+	tests := []struct {
+		method, path, body string
+		wantCode           int
+		want               []string // фрагменты ответа
+	}{
+		{"GET", "/api/status", "", http.StatusOK, []string{`"analog":{"product_id":`, `"present":true`, `"version":"1.0.0"`}},
+		{"POST", "/api/status", "", http.StatusMethodNotAllowed, []string{`"error":`}},
+		{"GET", "/api/unknown", "", http.StatusNotFound, nil},
+
+		{"PUT", "/api/analog/dac/2", `{"milliamper": 20}`, http.StatusOK, []string{`{"channel":2,"milliamper":20,"max_milliamper":20}`}},
+		{"GET", "/api/analog/dac/2", "", http.StatusOK, []string{`"milliamper":20`}},
+		{"GET", "/api/analog/dac", "", http.StatusOK, []string{`{"channel":1,"milliamper":0,`, `{"channel":14,`}},
+		{"PUT", "/api/analog/dac/15", `{"milliamper": 1}`, http.StatusNotFound, nil},
+		{"PUT", "/api/analog/dac/1", `{}`, http.StatusBadRequest, []string{`"error":`}},
+		{"PUT", "/api/analog/dac/1", `{"milliamper": 1, "volts": 2}`, http.StatusBadRequest, []string{`"error":`}},
+		{"PUT", "/api/analog/dac/1", `{"pressure": 500, "unit": "psi", "max": 1000}`, http.StatusBadRequest, nil},
+		{"PUT", "/api/analog/freq/3", `{"freq": "1kHz"}`, http.StatusOK, []string{`"channel":3`, `"freq":"1kHz"`}},
+		{"PUT", "/api/analog/freq/3", `{"freq": "3kHz"}`, http.StatusBadRequest, nil},
+		{"GET", "/api/analog/inputs", "", http.StatusOK, []string{`"value":5`, `"inputs":[true,false,true,false`}},
+
+		{"PUT", "/api/binary/10v/3", `{"on": true}`, http.StatusOK, []string{`"10v":[false,false,false,true,false`}},
+		{"PUT", "/api/binary/10v/8", `{"on": true}`, http.StatusNotFound, nil},
+		{"PUT", "/api/binary/50v/0", `{}`, http.StatusBadRequest, nil},
+		{"PUT", "/api/binary/if", `{"state": 3}`, http.StatusOK, []string{`{"state":3}`}},
+		{"PUT", "/api/binary/if", `{"state": 8}`, http.StatusBadRequest, nil},
+		{"GET", "/api/binary/if", "", http.StatusOK, []string{`{"state":3}`}},
+		{"PUT", "/api/binary/turt", `{"on": true}`, http.StatusOK, []string{`{"on":true}`}},
+		{"GET", "/api/binary/outputs", "", http.StatusOK, []string{`"if":3`, `"turt":true`}},
+
+		{"PUT", "/api/freq/motion", `{"motion": "backwards"}`, http.StatusOK, []string{`"motion":"backwards"`}},
+		{"PUT", "/api/freq/motion", `{"motion": "up"}`, http.StatusBadRequest, nil},
+		{"PUT", "/api/freq/limit", `{"meters": 100}`, http.StatusOK, []string{`"limit_way":100`}},
+		{"PUT", "/api/freq/speed", `{"speed1": 60, "speed2": 60}`, http.StatusOK, []string{`"speed1":59.99`, `"motion":"backwards"`}},
+		{"GET", "/api/freq/adc", "", http.StatusOK, []string{`"enabled":false`}},
+		{"GET", "/api/freq/version", "", http.StatusOK, []string{`{"version":"1.0.0"}`}},
+
+		{"GET", "/api/schema", "", http.StatusOK, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			code, text := request(t, ts, tt.method, tt.path, tt.body)
+			if tt.wantCode != code {
+				t.Fatalf("%d %s, want %d", code, text, tt.wantCode)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(text, want) {
+					t.Errorf("ответ %s не содержит %s", text, want)
+				}
+			}
+		})
+	}
+	if !sims.Binary.TURT() || ipk.IFGreen16 != sims.Binary.IF() {
+		t.Errorf("симулятор ФДС-3: TURT %v, ИФ %d", sims.Binary.TURT(), sims.Binary.IF())
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	ts, sims := newSimServer(t)
+	tests := []struct {
+		name     string
+		lang     string
+		wantCode int
+		want     string
+	}{
+		{"по-русски", "", http.StatusServiceUnavailable, "отключено"},
+		{"по-английски", "?lang=en", http.StatusServiceUnavailable, "disconnected"},
+	}
+	sims.Freq.SetConnected(false)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, text := request(t, ts, "GET", "/api/freq"+tt.lang, "")
+			if tt.wantCode != code || !strings.Contains(text, tt.want) {
+				t.Errorf("%d %s, want %d %s", code, text, tt.wantCode, tt.want)
+			}
+		})
+	}
+	sims.Freq.SetConnected(true)
+	if code, text := request(t, ts, "GET", "/api/freq", ""); http.StatusOK != code {
+		t.Errorf("после подключения: %d %s", code, text)
+	}
+}
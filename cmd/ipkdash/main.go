@@ -0,0 +1,141 @@
+// Команда ipkdash - панель оператора для пусконаладки стойки ФПС-3 в терминале.
+//
+// Панель показывает состояние всех трёх плат и обновляет его с периодом -interval:
+// 14 каналов ЦАП ФАС-3 (в мА и, для каналов из -pressure, в единицах давления),
+// 4 частотных выхода и 16 двоичных входов ФАС-3, выходы 10 В и 50 В, код ИФ и TURT ФДС-3,
+// скорость, ускорение, направление, путь и данные АЦП ФЧС-3.
+//
+// Управление с клавиатуры:
+//
+//	стрелки, Tab        выбор параметра
+//	пробел              включить/выключить выход, TURT, режим АЦП, сменить направление
+//	+ и -               изменить значение (ЦАП, частоту, ИФ, скорость, ускорение, предел)
+//	Enter               ввести значение (5 - мА, 300kPa, 2.5at; 1kHz; 60 или 60 40 км/ч)
+//	Esc                 отменить ввод
+//	q, Ctrl+C           выход
+//
+// Запуск с оборудованием, с симулятором плат и с платами сервера ipkd -grpc:
+//
+//	ipkdash -pressure 1=kPa:1000,8=at:10
+//	ipkdash -sim
+//	ipkdash -remote bench1:9090
+//
+// С симулятором двоичные входы ФАС-3 тоже можно переключать пробелом.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amdf/ipk"
+	"github.com/amdf/ipk/ipkrpc"
+)
+
+// simADC данные АЦП ФЧС-3 в режиме -sim (около 2 мА на ДАТ 1 и ДАТ 2)
+var simADC = ipk.DataADC{Dat1: 400 * 16, Dat2: 100 * 16, ReferenceVal: 800 * 16, DivisorVal: 16}
+
+// pressureConfig датчик давления на канале ЦАП
+type pressureConfig struct {
+	ch   uint8 // с нуля
+	unit uint8 // ipk.DACKiloPascal или ipk.DACAtmosphere
+	max  float64
+}
+
+// parsePressure разбирает список датчиков давления вида "1=kPa:1000,8=at:10"
+// (номер канала ЦАП с 1, единицы и максимум шкалы)
+func parsePressure(s string) (list []pressureConfig, err error) {
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); "" == item {
+			continue
+		}
+		var ch, unit, max string
+		var ok bool
+		if ch, unit, ok = strings.Cut(item, "="); ok {
+			unit, max, ok = strings.Cut(unit, ":")
+		}
+		if !ok {
+			return nil, fmt.Errorf("датчик давления %q (ожидается канал=kPa:максимум):%w", item, ipk.ErrInvalidParam)
+		}
+		var pc pressureConfig
+		n, err := strconv.Atoi(ch)
+		if nil != err || n < 1 || n > ipk.DAC14+1 {
+			return nil, fmt.Errorf("канал ЦАП %q:%w", ch, ipk.ErrInvalidParam)
+		}
+		pc.ch = uint8(n - 1)
+		switch strings.ToLower(unit) {
+		case "kpa":
+			pc.unit = ipk.DACKiloPascal
+		case "at":
+			pc.unit = ipk.DACAtmosphere
+		default:
+			return nil, fmt.Errorf("единицы %q (kPa или at):%w", unit, ipk.ErrInvalidParam)
+		}
+		if pc.max, err = strconv.ParseFloat(max, 64); nil != err || pc.max <= 0 {
+			return nil, fmt.Errorf("максимум шкалы %q:%w", max, ipk.ErrInvalidParam)
+		}
+		list = append(list, pc)
+	}
+	return
+}
+
+func main() {
+	sim := flag.Bool("sim", false, "работать с симулятором плат вместо оборудования")
+	anl12 := flag.Bool("anl12", false, "симулировать ФАС-3 с 12-битными ЦАП")
+	remote := flag.String("remote", "", "адрес сервиса gRPC ipkd (например, bench1:9090)")
+	pressure := flag.String("pressure", "", "датчики давления на каналах ЦАП: 1=kPa:1000,8=at:10")
+	interval := flag.Duration("interval", 250*time.Millisecond, "период обновления")
+	teeth := flag.Uint("teeth", 42, "количество зубьев датчика скорости")
+	diameter := flag.Uint("diameter", 1350, "диаметр бандажа, мм")
+	flag.Parse()
+
+	pressures, err := parsePressure(*pressure)
+	if nil != err {
+		log.Fatal(err)
+	}
+
+	var dev ipk.IPK
+	var sims ipk.Simulators
+	switch {
+	case *sim:
+		product := uint16(ipk.IDProductANL16bit)
+		if *anl12 {
+			product = ipk.IDProductANL12bit
+		}
+		sims = dev.OpenSimulators(product)
+		sims.Freq.SetADC(simADC)
+	case "" != *remote:
+		client, err := ipkrpc.Dial(*remote)
+		if nil == err {
+			defer client.Close()
+			_, err = client.Open(context.Background(), &dev)
+		}
+		if nil != err {
+			log.Fatal(err)
+		}
+	default:
+		if !dev.OpenAll() {
+			log.Fatal("платы ФПС-3 не найдены")
+		}
+		supervisor := ipk.NewSupervisor(&dev)
+		supervisor.Start()
+		defer supervisor.Stop()
+	}
+	defer dev.CloseAll()
+
+	r, err := newRack(&dev, uint32(*teeth), uint32(*diameter), pressures)
+	if nil != err {
+		log.Fatal(err)
+	}
+	r.sims = sims
+	r.start(*interval)
+	defer r.stop()
+
+	if err = run(r, *interval); nil != err {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/amdf/ipk"
+)
+
+// commandTimeout время на выполнение команды оператора
+const commandTimeout = 2 * time.Second
+
+// rackState состояние всей стойки ФПС-3 на момент опроса
+type rackState struct {
+	Time   time.Time
+	Status ipk.Status
+
+	AnalogErr error
+	DAC       [ipk.DAC14 + 1]float64 // мА
+	Pressure  [ipk.DAC14 + 1]float64 // давление для каналов с датчиком давления
+	Freq      [ipk.FREQ4 + 1]uint16  // значения ipk.AnlFreq
+	Inputs    uint16
+	InputsOK  bool
+
+	BinErr error
+	Out10V uint8
+	Out50V uint64
+	IF     uint8
+	TURT   bool
+
+	FreqErr        error
+	Speed1, Speed2 float64 // км/ч
+	Accel1, Accel2 float64 // 0,01 м/с²
+	Motion         uint8
+	Way1, Way2     uint32 // м
+	LimitWay       uint32 // м
+	ADCEnabled     bool
+	ADCErr         error
+	Dat1, Dat2     float64 // мА
+	Ref            float64 // мА
+}
+
+// rack опрашивает все платы стойки: ФЧС-3 - через FreqPoller (с АЦП),
+// двоичные входы ФАС-3 - через InputWatcher, остальное - функциями Get* плат
+// в отдельной горутине.
+type rack struct {
+	ipk      *ipk.IPK
+	sims     ipk.Simulators
+	dacs     [ipk.DAC14 + 1]ipk.DAC
+	pressure [ipk.DAC14 + 1]*ipk.PressureOutput
+	units    [ipk.DAC14 + 1]uint8
+	speed    ipk.Speed
+
+	freqPoll *ipk.FreqPoller
+	inputs   *ipk.InputWatcher
+
+	mutex   sync.Mutex
+	state   rackState
+	refresh chan struct{}
+	stopped chan struct{}
+	done    chan struct{}
+}
+
+func newRack(dev *ipk.IPK, teeth, diameter uint32, pressures []pressureConfig) (r *rack, err error) {
+	r = &rack{ipk: dev, refresh: make(chan struct{}, 1)}
+	if nil != dev.AnalogDev {
+		for ch := range r.dacs {
+			if err = r.dacs[ch].Init(dev.AnalogDev, uint8(ch)); nil != err {
+				return
+			}
+		}
+		for _, pc := range pressures {
+			pres := new(ipk.PressureOutput)
+			if err = pres.Init(&r.dacs[pc.ch], pc.unit, pc.max); nil != err {
+				return
+			}
+			r.pressure[pc.ch], r.units[pc.ch] = pres, pc.unit
+		}
+	}
+	if nil != dev.FreqDev {
+		if err = r.speed.Init(dev.FreqDev, teeth, diameter); nil != err {
+			return
+		}
+	}
+	return
+}
+
+// start запускает опрос с периодом interval
+func (r *rack) start(interval time.Duration) {
+	if nil != r.ipk.FreqDev {
+		r.freqPoll = ipk.NewFreqPoller(r.ipk.FreqDev)
+		r.freqPoll.Interval = interval
+		r.freqPoll.ADC = true
+		r.freqPoll.Start()
+	}
+	if nil != r.ipk.AnalogDev {
+		r.inputs = ipk.NewInputWatcher(r.ipk.AnalogDev)
+		r.inputs.Interval = interval
+		r.inputs.Start()
+	}
+	r.mutex.Lock()
+	r.state.Status = r.ipk.Status()
+	r.mutex.Unlock()
+
+	r.stopped = make(chan struct{})
+	r.done = make(chan struct{})
+	go r.run(interval)
+}
+
+// stop останавливает опрос
+func (r *rack) stop() {
+	close(r.stopped)
+	<-r.done
+	r.freqPoll.Stop()
+	r.inputs.Stop()
+}
+
+func (r *rack) run(interval time.Duration) {
+	defer close(r.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		r.poll()
+		select {
+		case <-r.stopped:
+			return
+		case <-ticker.C:
+		case <-r.refresh:
+		}
+	}
+}
+
+// update запрашивает внеочередной опрос (после команды оператора)
+func (r *rack) update() {
+	select {
+	case r.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// snapshot возвращает последнее состояние стойки
+func (r *rack) snapshot() rackState {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.state
+}
+
+func (r *rack) poll() {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	st := r.snapshot()
+	st.Time = time.Now()
+	st.AnalogErr = r.pollAnalog(ctx, &st)
+	st.BinErr = r.pollBinary(ctx, &st)
+	st.FreqErr = r.pollFreq(&st)
+	st.Status.Analog.Present = r.ipk.AnalogDev.Active()
+	st.Status.Binary.Present = r.ipk.BinDev.Active()
+	st.Status.Freq.Present = r.ipk.FreqDev.Active()
+
+	r.mutex.Lock()
+	r.state = st
+	r.mutex.Unlock()
+}
+
+func (r *rack) pollAnalog(ctx context.Context, st *rackState) (err error) {
+	dev := r.ipk.AnalogDev
+	if nil == dev {
+		return
+	}
+	for ch := range r.dacs {
+		if st.DAC[ch], err = r.dacs[ch].GetMilliAmperCtx(ctx); nil != err {
+			return
+		}
+		st.Pressure[ch] = r.pressure[ch].FromMilliAmper(st.DAC[ch])
+	}
+	for ch := range st.Freq {
+		if st.Freq[ch], err = dev.GetOutputFreqCtx(ctx, uint8(ch)); nil != err {
+			return
+		}
+	}
+	st.Inputs, st.InputsOK = r.inputs.State()
+	return r.inputs.Err()
+}
+
+func (r *rack) pollBinary(ctx context.Context, st *rackState) (err error) {
+	dev := r.ipk.BinDev
+	if nil == dev {
+		return
+	}
+	if st.Out10V, err = dev.UintGetOutput10VCtx(ctx); nil != err {
+		return
+	}
+	if st.Out50V, err = dev.UintGetOutput50VCtx(ctx); nil != err {
+		return
+	}
+	if st.IF, err = dev.GetOutputIFCtx(ctx); nil != err {
+		return
+	}
+	st.TURT, err = dev.GetOutputTURTCtx(ctx)
+	return
+}
+
+// pollFreq берёт данные ФЧС-3 из FreqPoller; скорость и путь пересчитывает Speed
+func (r *rack) pollFreq(st *rackState) (err error) {
+	dev := r.ipk.FreqDev
+	if nil == dev {
+		return
+	}
+	snap := r.freqPoll.Snapshot()
+	if snap.Time.IsZero() {
+		return
+	}
+	st.Motion = snap.Direction()
+	st.ADCEnabled = snap.ADCModeEnabled
+	if nil != snap.Err {
+		return snap.Err
+	}
+	if st.Speed1, st.Speed2, err = r.speed.GetOutputSpeed(); nil != err {
+		return
+	}
+	if st.Accel1, st.Accel2, err = r.speed.GetOutputAcceleration(); nil != err {
+		return
+	}
+	if st.Way1, st.Way2, err = r.speed.GetWay(); nil != err {
+		return
+	}
+	if st.LimitWay, err = r.speed.GetLimitWay(); nil != err {
+		return
+	}
+	st.ADCErr = nil
+	if st.ADCEnabled {
+		st.Dat1, st.ADCErr = dev.GetDat1MilliAmper()
+		if nil == st.ADCErr {
+			st.Dat2, st.ADCErr = dev.GetDat2MilliAmper()
+		}
+		if nil == st.ADCErr {
+			st.Ref, st.ADCErr = dev.GetRefValMilliAmper()
+		}
+	}
+	return
+}
+
+///////////////////////////////////////////////////////////////
+
+// command выполняет команду оператора и запрашивает внеочередной опрос
+func (r *rack) command(fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	err := fn(ctx)
+	r.update()
+	return err
+}
+
+func (r *rack) analog() (*ipk.AnalogDevice, error) {
+	if nil == r.ipk.AnalogDev {
+		return nil, fmt.Errorf("ФАС-3 %w", errNoBoard)
+	}
+	return r.ipk.AnalogDev, nil
+}
+
+func (r *rack) binary() (*ipk.BinaryDevice, error) {
+	if nil == r.ipk.BinDev {
+		return nil, fmt.Errorf("ФДС-3 %w", errNoBoard)
+	}
+	return r.ipk.BinDev, nil
+}
+
+func (r *rack) freq() (*ipk.FreqDevice, error) {
+	if nil == r.ipk.FreqDev {
+		return nil, fmt.Errorf("ФЧС-3 %w", errNoBoard)
+	}
+	return r.ipk.FreqDev, nil
+}
+
+// setDAC устанавливает канал ЦАП в мА
+func (r *rack) setDAC(ch uint8, ma float64) error {
+	if _, err := r.analog(); nil != err {
+		return err
+	}
+	return r.command(func(ctx context.Context) error { return r.dacs[ch].SetMilliAmperCtx(ctx, ma) })
+}
+
+// setPressure устанавливает давление на канале ЦАП с датчиком давления
+func (r *rack) setPressure(ch uint8, val float64) error {
+	if _, err := r.analog(); nil != err {
+		return err
+	}
+	return r.command(func(ctx context.Context) error { return r.pressure[ch].SetCtx(ctx, val) })
+}
+
+func (r *rack) setFreq(ch uint8, code uint16) error {
+	dev, err := r.analog()
+	if nil != err {
+		return err
+	}
+	return r.command(func(ctx context.Context) error { return dev.SetFreqCtx(ctx, ch, code) })
+}
+
+// setInput переключает двоичный вход симулятора ФАС-3
+func (r *rack) setInput(num uint16, on bool) error {
+	if nil == r.sims.Analog {
+		return fmt.Errorf("входы переключаются только в симуляторе:%w", ipk.ErrInvalidParam)
+	}
+	r.sims.Analog.SetBinaryInputVal(num, on)
+	r.update()
+	return nil
+}
+
+func (r *rack) set10V(num uint, on bool) error {
+	dev, err := r.binary()
+	if nil != err {
+		return err
+	}
+	return r.command(func(ctx context.Context) error { return dev.Set10VCtx(ctx, num, on) })
+}
+
+func (r *rack) set50V(num uint, on bool) error {
+	dev, err := r.binary()
+	if nil != err {
+		return err
+	}
+	return r.command(func(ctx context.Context) error { return dev.Set50VCtx(ctx, num, on) })
+}
+
+func (r *rack) setIF(state uint8) error {
+	dev, err := r.binary()
+	if nil != err {
+		return err
+	}
+	return r.command(func(ctx context.Context) error { return dev.SetIFCtx(ctx, state) })
+}
+
+func (r *rack) setTURT(on bool) error {
+	dev, err := r.binary()
+	if nil != err {
+		return err
+	}
+	return r.command(func(ctx context.Context) error { return dev.SetTURTCtx(ctx, on) })
+}
+
+func (r *rack) setSpeed(kmh1, kmh2 float64) error {
+	if _, err := r.freq(); nil != err {
+		return err
+	}
+	return r.command(func(ctx context.Context) error { return r.speed.SetSpeedCtx(ctx, kmh1, kmh2) })
+}
+
+func (r *rack) setAcceleration(accel1, accel2 float64) error {
+	if _, err := r.freq(); nil != err {
+		return err
+	}
+	return r.command(func(ctx context.Context) error { return r.speed.SetAccelerationCtx(ctx, accel1, accel2) })
+}
+
+func (r *rack) setMotion(direction uint8) error {
+	if _, err := r.freq(); nil != err {
+		return err
+	}
+	return r.command(func(ctx context.Context) error { return r.speed.SetMotionCtx(ctx, direction) })
+}
+
+func (r *rack) setLimitWay(meters uint32) error {
+	if _, err := r.freq(); nil != err {
+		return err
+	}
+	return r.command(func(ctx context.Context) error { return r.speed.SetLimitWayCtx(ctx, meters) })
+}
+
+func (r *rack) enableADC(on bool) error {
+	dev, err := r.freq()
+	if nil != err {
+		return err
+	}
+	return r.command(func(ctx context.Context) error { return dev.EnableADCCtx(ctx, on) })
+}
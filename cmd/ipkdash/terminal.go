@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// Клавиши, которые передаются в panel.key (остальные - введённые символы)
+const (
+	keyUp        = "up"
+	keyDown      = "down"
+	keyLeft      = "left"
+	keyRight     = "right"
+	keyTab       = "tab"
+	keyBackTab   = "backtab"
+	keyEnter     = "enter"
+	keyEsc       = "esc"
+	keyBackspace = "backspace"
+	keyCtrlC     = "ctrl+c"
+)
+
+// terminal терминал в режиме панели: без построчного ввода, на альтернативном экране
+type terminal struct {
+	in    *os.File
+	out   *os.File
+	state *term.State
+}
+
+func openTerminal() (t *terminal, err error) {
+	t = &terminal{in: os.Stdin, out: os.Stdout}
+	fd := int(t.in.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, errors.New("ipkdash работает только в терминале")
+	}
+	if err = enableVT(t.out); nil != err {
+		return nil, err
+	}
+	if t.state, err = term.MakeRaw(fd); nil != err {
+		return nil, err
+	}
+	io.WriteString(t.out, "\x1b[?1049h\x1b[?25l\x1b[2J")
+	return
+}
+
+// close возвращает терминал в исходное состояние
+func (t *terminal) close() {
+	io.WriteString(t.out, "\x1b[0m\x1b[?25h\x1b[?1049l")
+	term.Restore(int(t.in.Fd()), t.state)
+}
+
+// readKeys читает клавиши из in и отправляет их в keys, пока in не будет закрыт
+func readKeys(in io.Reader, keys chan<- string) {
+	defer close(keys)
+	buf := make([]byte, 64)
+	for {
+		n, err := in.Read(buf)
+		for _, k := range parseKeys(buf[:n]) {
+			keys <- k
+		}
+		if nil != err {
+			return
+		}
+	}
+}
+
+// parseKeys разбирает прочитанные из терминала байты на клавиши
+func parseKeys(b []byte) (keys []string) {
+	for len(b) > 0 {
+		switch {
+		case len(b) >= 3 && 0x1b == b[0] && ('[' == b[1] || 'O' == b[1]):
+			switch b[2] {
+			case 'A':
+				keys = append(keys, keyUp)
+			case 'B':
+				keys = append(keys, keyDown)
+			case 'C':
+				keys = append(keys, keyRight)
+			case 'D':
+				keys = append(keys, keyLeft)
+			case 'Z':
+				keys = append(keys, keyBackTab)
+			}
+			b = b[3:]
+			continue
+		case 0x1b == b[0]:
+			keys = append(keys, keyEsc)
+		case 0x03 == b[0]:
+			keys = append(keys, keyCtrlC)
+		case '\r' == b[0], '\n' == b[0]:
+			keys = append(keys, keyEnter)
+		case '\t' == b[0]:
+			keys = append(keys, keyTab)
+		case 0x7f == b[0], 0x08 == b[0]:
+			keys = append(keys, keyBackspace)
+		default:
+			r, size := utf8.DecodeRune(b)
+			if utf8.RuneError != r && r >= ' ' {
+				keys = append(keys, string(r))
+			}
+			b = b[size:]
+			continue
+		}
+		b = b[1:]
+	}
+	return
+}
@@ -0,0 +1,8 @@
+package main
+
+import "os"
+
+// enableVT включает обработку управляющих последовательностей (в Linux не нужно)
+func enableVT(out *os.File) error {
+	return nil
+}
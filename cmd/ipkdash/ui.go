@@ -0,0 +1,661 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amdf/ipk"
+)
+
+var errNoBoard = fmt.Errorf("плата не открыта:%w", ipk.ErrNotConnected)
+
+// anlFreqs частоты, которые перебираются клавишами + и -
+var anlFreqs = []string{"200Hz", "500Hz", "1kHz", "2kHz", "4kHz"}
+
+// Оформление текста в терминале
+const (
+	styleReset   = "\x1b[0m"
+	styleBold    = "\x1b[1m"
+	styleReverse = "\x1b[7m"
+	styleRed     = "\x1b[31m"
+	styleGreen   = "\x1b[32m"
+	styleDim     = "\x1b[2m"
+)
+
+// item параметр панели, который оператор может выбрать и изменить
+type item struct {
+	text   func(st *rackState) string
+	on     func(st *rackState) bool                // параметр включен (выделяется цветом)
+	toggle func(st *rackState) error               // пробел
+	adjust func(st *rackState, step float64) error // + (step = 1) и - (step = -1)
+	prompt string                                  // приглашение для ввода значения (Enter)
+	set    func(text string) error
+}
+
+// segment часть строки панели: текст или параметр
+type segment struct {
+	text func(st *rackState) string
+	item *item
+}
+
+type line []segment
+
+// panel экран панели оператора
+type panel struct {
+	r     *rack
+	lines []line
+	grid  [][]*item // параметры по строкам экрана, для перемещения стрелками
+	row   int
+	col   int
+
+	editing bool
+	input   []rune
+	message string
+	isError bool
+}
+
+func static(s string) segment {
+	return segment{text: func(*rackState) string { return s }}
+}
+
+func dynamic(text func(st *rackState) string) segment {
+	return segment{text: text}
+}
+
+func selectable(it *item) segment {
+	return segment{text: it.text, item: it}
+}
+
+// title заголовок раздела с ошибкой опроса платы
+func title(name string, err func(st *rackState) error) line {
+	return line{dynamic(func(st *rackState) string {
+		s := styleBold + "── " + name + " " + strings.Repeat("─", 60-len([]rune(name))) + styleReset
+		if e := err(st); nil != e {
+			s += " " + styleRed + e.Error() + styleReset
+		}
+		return s
+	})}
+}
+
+// number разбирает число с плавающей точкой (допускается запятая)
+func number(s string) (v float64, err error) {
+	v, err = strconv.ParseFloat(strings.Replace(strings.TrimSpace(s), ",", ".", 1), 64)
+	if nil != err {
+		err = fmt.Errorf("число %q:%w", s, ipk.ErrInvalidParam)
+	}
+	return
+}
+
+// pair разбирает одно (для обоих генераторов) или два значения
+func pair(s string) (v1, v2 float64, err error) {
+	fields := strings.Fields(s)
+	if len(fields) < 1 || len(fields) > 2 {
+		err = fmt.Errorf("ожидается одно или два значения:%w", ipk.ErrInvalidParam)
+		return
+	}
+	if v1, err = number(fields[0]); nil != err {
+		return
+	}
+	v2 = v1
+	if 2 == len(fields) {
+		v2, err = number(fields[1])
+	}
+	return
+}
+
+func mark(on bool) string {
+	if on {
+		return "■"
+	}
+	return "·"
+}
+
+func onOffName(on bool) string {
+	if on {
+		return "вкл"
+	}
+	return "выкл"
+}
+
+func unitName(unit uint8) string {
+	if ipk.DACAtmosphere == unit {
+		return "ат"
+	}
+	return "кПа"
+}
+
+func newPanel(r *rack) *panel {
+	p := &panel{r: r}
+	add := func(segs ...segment) {
+		p.lines = append(p.lines, segs)
+	}
+
+	add(dynamic(func(st *rackState) string {
+		board := func(bs ipk.BoardStatus, name string) string {
+			if 0 != bs.ProductID {
+				name = bs.Name
+			}
+			if "" != bs.Version {
+				name += " " + bs.Version
+			}
+			if bs.Present {
+				return styleGreen + "●" + styleReset + " " + name
+			}
+			return styleRed + "○" + styleReset + " " + name
+		}
+		return styleBold + "ФПС-3" + styleReset + "   " +
+			board(st.Status.Analog, "ФАС-3") + "   " +
+			board(st.Status.Binary, "ФДС-3") + "   " +
+			board(st.Status.Freq, "ФЧС-3") + "   " +
+			st.Time.Format("15:04:05")
+	}))
+
+	// ФАС-3
+	add()
+	p.lines = append(p.lines, title("ФАС-3: ЦАП", func(st *rackState) error { return st.AnalogErr }))
+	half := (ipk.DAC14 + 1) / 2
+	for i := 0; i < half; i++ {
+		add(static(" "), selectable(p.dacItem(uint8(i))), static("    "), selectable(p.dacItem(uint8(i+half))))
+	}
+	segs := line{static(" Частотные выходы: ")}
+	for ch := uint8(ipk.FREQ1); ch <= ipk.FREQ4; ch++ {
+		segs = append(segs, selectable(p.freqItem(ch)), static("  "))
+	}
+	add(segs...)
+	segs = line{static(" Двоичные входы:  ")}
+	for num := uint16(0); num < 16; num++ {
+		segs = append(segs, static(fmt.Sprintf("%3d", num)))
+		if nil == r.sims.Analog {
+			n := num
+			segs = append(segs, dynamic(func(st *rackState) string {
+				on := st.InputsOK && 0 != st.Inputs&(1<<n)
+				if on {
+					return styleGreen + mark(on) + styleReset
+				}
+				return mark(on)
+			}))
+		} else {
+			segs = append(segs, selectable(p.inputItem(num)))
+		}
+	}
+	add(segs...)
+
+	// ФДС-3
+	add()
+	p.lines = append(p.lines, title("ФДС-3", func(st *rackState) error { return st.BinErr }))
+	segs = line{static(" 10 В  0-7:  ")}
+	for num := uint(0); num < 8; num++ {
+		segs = append(segs, static(fmt.Sprintf("%3d", num)), selectable(p.out10VItem(num)))
+	}
+	add(segs...)
+	for first := uint(0); first < 36; first += 12 {
+		segs = line{static(fmt.Sprintf(" 50 В %2d-%-2d: ", first, first+11))}
+		for num := first; num < first+12; num++ {
+			segs = append(segs, static(fmt.Sprintf("%3d", num)))
+			if 28 == num { // вместо выхода 28 - сигнал ИФ
+				segs = append(segs, static(styleDim+"И"+styleReset))
+				continue
+			}
+			segs = append(segs, selectable(p.out50VItem(num)))
+		}
+		add(segs...)
+	}
+	add(static(" "), selectable(p.ifItem()), static("    "), selectable(p.turtItem()))
+
+	// ФЧС-3
+	add()
+	p.lines = append(p.lines, title("ФЧС-3", func(st *rackState) error { return st.FreqErr }))
+	add(static(" Скорость:  "), selectable(p.speedItem()), static("   Ускорение: "), selectable(p.accelItem()))
+	add(static(" Движение:  "), selectable(p.motionItem()), static("   Предел пути: "), selectable(p.limitItem()))
+	add(dynamic(func(st *rackState) string {
+		return fmt.Sprintf(" Путь:      %d / %d м", st.Way1, st.Way2)
+	}))
+	add(static(" АЦП:       "), selectable(p.adcItem()), dynamic(func(st *rackState) string {
+		switch {
+		case !st.ADCEnabled:
+			return ""
+		case nil != st.ADCErr:
+			return "   " + styleRed + st.ADCErr.Error() + styleReset
+		}
+		return fmt.Sprintf("   ДАТ 1: %.3f мА   ДАТ 2: %.3f мА   эталон: %.3f мА", st.Dat1, st.Dat2, st.Ref)
+	}))
+
+	for _, ln := range p.lines {
+		var row []*item
+		for _, seg := range ln {
+			if nil != seg.item {
+				row = append(row, seg.item)
+			}
+		}
+		if len(row) > 0 {
+			p.grid = append(p.grid, row)
+		}
+	}
+	return p
+}
+
+///////////////////////////////////////////////////////////////
+
+func (p *panel) dacItem(ch uint8) *item {
+	r := p.r
+	max := r.dacs[ch].GetMaxMilliAmper()
+	it := &item{
+		text: func(st *rackState) string {
+			s := fmt.Sprintf("ЦАП %2d %7.3f мА", ch+1, st.DAC[ch])
+			if nil != r.pressure[ch] {
+				return s + fmt.Sprintf(" %9.2f %-3s", st.Pressure[ch], unitName(r.units[ch]))
+			}
+			return s + strings.Repeat(" ", 14)
+		},
+		adjust: func(st *rackState, step float64) error {
+			ma := math.Max(0, math.Min(max, st.DAC[ch]+step*0.5))
+			return r.setDAC(ch, ma)
+		},
+		prompt: fmt.Sprintf("ЦАП %d, мА", ch+1),
+		set: func(text string) error {
+			text = strings.ToLower(strings.TrimSpace(text))
+			if strings.HasSuffix(text, "ma") {
+				text = strings.TrimSuffix(text, "ma")
+			} else if nil != r.pressure[ch] {
+				text = strings.TrimSuffix(strings.TrimSuffix(text, "kpa"), "at")
+				val, err := number(text)
+				if nil != err {
+					return err
+				}
+				return r.setPressure(ch, val)
+			}
+			ma, err := number(text)
+			if nil != err {
+				return err
+			}
+			return r.setDAC(ch, ma)
+		},
+	}
+	if nil != r.pressure[ch] {
+		it.prompt = fmt.Sprintf("ЦАП %d, %s (или 5mA)", ch+1, unitName(r.units[ch]))
+	}
+	return it
+}
+
+func (p *panel) freqItem(ch uint8) *item {
+	r := p.r
+	return &item{
+		text: func(st *rackState) string {
+			name := ipk.AnlFreqName(st.Freq[ch])
+			if "" == name {
+				name = strconv.Itoa(int(st.Freq[ch]))
+			}
+			return fmt.Sprintf("%d: %-5s", ch+1, name)
+		},
+		adjust: func(st *rackState, step float64) error {
+			i := -1
+			for n, name := range anlFreqs {
+				if name == ipk.AnlFreqName(st.Freq[ch]) {
+					i = n
+				}
+			}
+			i += int(step)
+			if i < 0 {
+				i = 0
+			}
+			if i >= len(anlFreqs) {
+				i = len(anlFreqs) - 1
+			}
+			code, err := ipk.ParseAnlFreq(anlFreqs[i])
+			if nil != err {
+				return err
+			}
+			return r.setFreq(ch, code)
+		},
+		prompt: fmt.Sprintf("Частотный выход %d (%s)", ch+1, strings.Join(anlFreqs, ", ")),
+		set: func(text string) error {
+			code, err := ipk.ParseAnlFreq(strings.TrimSpace(text))
+			if nil != err {
+				return err
+			}
+			return r.setFreq(ch, code)
+		},
+	}
+}
+
+func (p *panel) inputItem(num uint16) *item {
+	r := p.r
+	on := func(st *rackState) bool { return st.InputsOK && 0 != st.Inputs&(1<<num) }
+	return &item{
+		text:   func(st *rackState) string { return mark(on(st)) },
+		on:     on,
+		toggle: func(st *rackState) error { return r.setInput(num, !on(st)) },
+	}
+}
+
+func (p *panel) out10VItem(num uint) *item {
+	r := p.r
+	on := func(st *rackState) bool { return 0 != st.Out10V&(1<<num) }
+	return &item{
+		text:   func(st *rackState) string { return mark(on(st)) },
+		on:     on,
+		toggle: func(st *rackState) error { return r.set10V(num, !on(st)) },
+	}
+}
+
+func (p *panel) out50VItem(num uint) *item {
+	r := p.r
+	on := func(st *rackState) bool { return 0 != st.Out50V&(1<<num) }
+	return &item{
+		text:   func(st *rackState) string { return mark(on(st)) },
+		on:     on,
+		toggle: func(st *rackState) error { return r.set50V(num, !on(st)) },
+	}
+}
+
+func (p *panel) ifItem() *item {
+	r := p.r
+	return &item{
+		text: func(st *rackState) string { return fmt.Sprintf("ИФ: %d", st.IF) },
+		adjust: func(st *rackState, step float64) error {
+			state := int(st.IF) + int(step)
+			if state < ipk.IFDisable || state > ipk.IFEnable {
+				return nil
+			}
+			return r.setIF(uint8(state))
+		},
+		prompt: fmt.Sprintf("Код ИФ (от %d до %d)", ipk.IFDisable, ipk.IFEnable),
+		set: func(text string) error {
+			state, err := strconv.ParseUint(strings.TrimSpace(text), 10, 8)
+			if nil != err {
+				return fmt.Errorf("код ИФ %q:%w", text, ipk.ErrInvalidParam)
+			}
+			return r.setIF(uint8(state))
+		},
+	}
+}
+
+func (p *panel) turtItem() *item {
+	r := p.r
+	return &item{
+		text:   func(st *rackState) string { return "TURT: " + onOffName(st.TURT) },
+		on:     func(st *rackState) bool { return st.TURT },
+		toggle: func(st *rackState) error { return r.setTURT(!st.TURT) },
+	}
+}
+
+func (p *panel) speedItem() *item {
+	r := p.r
+	return &item{
+		text: func(st *rackState) string {
+			return fmt.Sprintf("%7.2f / %7.2f км/ч", st.Speed1, st.Speed2)
+		},
+		adjust: func(st *rackState, step float64) error {
+			return r.setSpeed(math.Max(0, st.Speed1+step*5), math.Max(0, st.Speed2+step*5))
+		},
+		prompt: "Скорость, км/ч (одна для обоих генераторов или две)",
+		set: func(text string) error {
+			kmh1, kmh2, err := pair(text)
+			if nil != err {
+				return err
+			}
+			return r.setSpeed(kmh1, kmh2)
+		},
+	}
+}
+
+func (p *panel) accelItem() *item {
+	r := p.r
+	return &item{
+		text: func(st *rackState) string {
+			return fmt.Sprintf("%7.2f / %7.2f (0,01 м/с²)", st.Accel1, st.Accel2)
+		},
+		adjust: func(st *rackState, step float64) error {
+			return r.setAcceleration(st.Accel1+step*10, st.Accel2+step*10)
+		},
+		prompt: "Ускорение, 0,01 м/с² (одно для обоих генераторов или два)",
+		set: func(text string) error {
+			accel1, accel2, err := pair(text)
+			if nil != err {
+				return err
+			}
+			return r.setAcceleration(accel1, accel2)
+		},
+	}
+}
+
+func (p *panel) motionItem() *item {
+	r := p.r
+	return &item{
+		text: func(st *rackState) string {
+			switch st.Motion {
+			case ipk.MotionOnward:
+				return "вперёд"
+			case ipk.MotionBackwards:
+				return "назад "
+			}
+			return "?     "
+		},
+		toggle: func(st *rackState) error {
+			if ipk.MotionOnward == st.Motion {
+				return r.setMotion(ipk.MotionBackwards)
+			}
+			return r.setMotion(ipk.MotionOnward)
+		},
+	}
+}
+
+func (p *panel) limitItem() *item {
+	r := p.r
+	return &item{
+		text: func(st *rackState) string { return fmt.Sprintf("%d м", st.LimitWay) },
+		adjust: func(st *rackState, step float64) error {
+			meters := int64(st.LimitWay) + int64(step*100)
+			if meters < 0 {
+				meters = 0
+			}
+			return r.setLimitWay(uint32(meters))
+		},
+		prompt: "Предельный путь, м",
+		set: func(text string) error {
+			meters, err := strconv.ParseUint(strings.TrimSpace(text), 10, 32)
+			if nil != err {
+				return fmt.Errorf("путь %q:%w", text, ipk.ErrInvalidParam)
+			}
+			return r.setLimitWay(uint32(meters))
+		},
+	}
+}
+
+func (p *panel) adcItem() *item {
+	r := p.r
+	return &item{
+		text:   func(st *rackState) string { return onOffName(st.ADCEnabled) },
+		on:     func(st *rackState) bool { return st.ADCEnabled },
+		toggle: func(st *rackState) error { return r.enableADC(!st.ADCEnabled) },
+	}
+}
+
+///////////////////////////////////////////////////////////////
+
+func (p *panel) selected() *item {
+	if 0 == len(p.grid) {
+		return nil
+	}
+	return p.grid[p.row][p.col]
+}
+
+// move перемещает выбор на drow строк и dcol параметров
+func (p *panel) move(drow, dcol int) {
+	if 0 == len(p.grid) {
+		return
+	}
+	p.col += dcol
+	if p.col < 0 {
+		if p.row > 0 {
+			p.row--
+			p.col = len(p.grid[p.row]) - 1
+		} else {
+			p.col = 0
+		}
+	}
+	if p.col >= len(p.grid[p.row]) {
+		if p.row < len(p.grid)-1 {
+			p.row++
+			p.col = 0
+		} else {
+			p.col = len(p.grid[p.row]) - 1
+		}
+	}
+	p.row += drow
+	if p.row < 0 {
+		p.row = 0
+	}
+	if p.row >= len(p.grid) {
+		p.row = len(p.grid) - 1
+	}
+	if p.col >= len(p.grid[p.row]) {
+		p.col = len(p.grid[p.row]) - 1
+	}
+}
+
+// result показывает результат команды оператора
+func (p *panel) result(err error, done string) {
+	p.isError = nil != err
+	p.message = done
+	if nil != err {
+		p.message = err.Error()
+	}
+}
+
+// key обрабатывает нажатие клавиши; возвращает true для выхода из панели
+func (p *panel) key(k string) (quit bool) {
+	st := p.r.snapshot()
+	it := p.selected()
+
+	if p.editing {
+		switch k {
+		case keyEnter:
+			p.editing = false
+			text := string(p.input)
+			p.result(it.set(text), it.prompt+": "+text)
+		case keyEsc:
+			p.editing = false
+			p.message = ""
+		case keyBackspace:
+			if len(p.input) > 0 {
+				p.input = p.input[:len(p.input)-1]
+			}
+		case keyCtrlC:
+			return true
+		default:
+			if 1 == len([]rune(k)) {
+				p.input = append(p.input, []rune(k)...)
+			}
+		}
+		return false
+	}
+
+	switch k {
+	case "q", "Q", "й", "Й", keyCtrlC:
+		return true
+	case keyUp, "k":
+		p.move(-1, 0)
+	case keyDown, "j":
+		p.move(1, 0)
+	case keyLeft, "h":
+		p.move(0, -1)
+	case keyRight, "l", keyTab:
+		p.move(0, 1)
+	case keyBackTab:
+		p.move(0, -1)
+	case " ":
+		if nil != it && nil != it.toggle {
+			p.result(it.toggle(&st), "")
+		}
+	case "+", "=":
+		if nil != it && nil != it.adjust {
+			p.result(it.adjust(&st, 1), "")
+		}
+	case "-", "_":
+		if nil != it && nil != it.adjust {
+			p.result(it.adjust(&st, -1), "")
+		}
+	case keyEnter:
+		switch {
+		case nil == it:
+		case nil != it.set:
+			p.editing, p.input, p.message = true, nil, ""
+		case nil != it.toggle:
+			p.result(it.toggle(&st), "")
+		}
+	}
+	return false
+}
+
+// render выводит панель в w
+func (p *panel) render(w io.Writer, st *rackState) {
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	sel := p.selected()
+	for _, ln := range p.lines {
+		for _, seg := range ln {
+			text := seg.text(st)
+			switch {
+			case nil == seg.item:
+				b.WriteString(text)
+			case seg.item == sel:
+				b.WriteString(styleReverse + text + styleReset)
+			case nil != seg.item.on && seg.item.on(st):
+				b.WriteString(styleGreen + text + styleReset)
+			default:
+				b.WriteString(text)
+			}
+		}
+		b.WriteString("\x1b[K\r\n")
+	}
+
+	b.WriteString("\x1b[K\r\n")
+	switch {
+	case p.editing:
+		b.WriteString(sel.prompt + ": " + string(p.input) + "_")
+	case p.isError:
+		b.WriteString(styleRed + p.message + styleReset)
+	default:
+		b.WriteString(p.message)
+	}
+	b.WriteString("\x1b[K\r\n")
+	b.WriteString(styleDim + "стрелки - выбор, пробел - вкл/выкл, +/- - изменить, Enter - ввести значение, q - выход" + styleReset)
+	b.WriteString("\x1b[K\x1b[J")
+	io.WriteString(w, b.String())
+}
+
+// run показывает панель, пока оператор не выйдет из неё
+func run(r *rack, interval time.Duration) error {
+	t, err := openTerminal()
+	if nil != err {
+		return err
+	}
+	defer t.close()
+
+	p := newPanel(r)
+	keys := make(chan string, 16)
+	go readKeys(t.in, keys)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		st := r.snapshot()
+		p.render(t.out, &st)
+		select {
+		case <-ticker.C:
+		case k, ok := <-keys:
+			if !ok {
+				return errors.New("ввод с клавиатуры закрыт")
+			}
+			if p.key(k) {
+				return nil
+			}
+		}
+	}
+}
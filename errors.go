@@ -0,0 +1,180 @@
+package ipk
+
+import "errors"
+
+// Языки сообщений об ошибках (см. Localize)
+const (
+	LangRU = "ru"
+	LangEN = "en"
+)
+
+// Error ошибка библиотеки с сообщениями на русском и английском языках.
+// Error() возвращает русское сообщение. Конкретные ошибки (например, "нет соединения с ФДС-3")
+// относятся к одному из общих видов (ErrNotConnected и т.д.), что позволяет
+// проверять их с помощью errors.Is.
+type Error struct {
+	kind *Error // общий вид ошибки, nil для самих видов
+	ru   string
+	en   string
+}
+
+func newError(kind *Error, ru, en string) *Error {
+	return &Error{kind: kind, ru: ru, en: en}
+}
+
+func (e *Error) Error() string {
+	return e.ru
+}
+
+// Is позволяет errors.Is сопоставить конкретную ошибку с её общим видом
+func (e *Error) Is(target error) bool {
+	return nil != e.kind && e.kind == target
+}
+
+// Message возвращает сообщение об ошибке на языке lang (LangRU или LangEN)
+func (e *Error) Message(lang string) string {
+	if LangEN == lang {
+		return e.en
+	}
+	return e.ru
+}
+
+// Общие виды ошибок, для проверки с помощью errors.Is
+var (
+	ErrNotConnected   = newError(nil, `Нет соединения с устройством`, `Device is not connected`)
+	ErrTimeout        = newError(nil, `Слишком большое время отклика по USB`, `USB response timeout`)
+	ErrInvalidParam   = newError(nil, `Неверный параметр функции`, `Invalid function parameter`)
+	ErrNotInitialized = newError(nil, `Не инициализировано`, `Not initialized`)
+	ErrADCFault       = newError(nil, `Неисправен АЦП ФЧС-3`, `FChS-3 ADC fault`)
+	ErrADCNoData      = newError(nil, `Нет данных АЦП ФЧС-3`, `No FChS-3 ADC data`)
+	ErrADCNotEnabled  = newError(nil, `На ФЧС-3 не включен режим АЦП`, `FChS-3 ADC mode is not enabled`)
+	ErrBadResponse    = newError(nil, `Неверные данные от устройства`, `Wrong data from device`)
+	ErrInternal       = newError(nil, `Внутренняя ошибка`, `Internal error`)
+)
+
+// Каналы АЦП ФЧС-3 (см. ADCFaultError)
+const (
+	ADCDat1 = iota // вход ДАТ 1
+	ADCDat2        // вход ДАТ 2
+	ADCRef         // эталонное значение
+)
+
+// ADCFaultError неисправность АЦП ФЧС-3 на одном из каналов.
+// errors.Is(err, ErrADCFault) возвращает true, номер канала можно получить через errors.As.
+type ADCFaultError struct {
+	Channel int // ADCDat1, ADCDat2 или ADCRef
+}
+
+func (e *ADCFaultError) Error() string {
+	return e.Message(LangRU)
+}
+
+// Is позволяет errors.Is сопоставить ошибку с ErrADCFault
+func (e *ADCFaultError) Is(target error) bool {
+	return ErrADCFault == target
+}
+
+// Message возвращает сообщение об ошибке на языке lang (LangRU или LangEN)
+func (e *ADCFaultError) Message(lang string) string {
+	switch e.Channel {
+	case ADCDat1:
+		if LangEN == lang {
+			return `FChS-3 ADC fault (input DAT 1)`
+		}
+		return `Неисправен АЦП ФЧС-3 (вход ДАТ 1)`
+	case ADCDat2:
+		if LangEN == lang {
+			return `FChS-3 ADC fault (input DAT 2)`
+		}
+		return `Неисправен АЦП ФЧС-3 (вход ДАТ 2)`
+	}
+	if LangEN == lang {
+		return `FChS-3 ADC fault (reference value)`
+	}
+	return `Неисправен АЦП ФЧС-3 (эталонное значение)`
+}
+
+// kindError относит ошибку нижнего уровня (например, libusb) к одному из общих видов,
+// сохраняя её в цепочке для errors.As.
+type kindError struct {
+	kind *Error
+	err  error
+}
+
+func wrapError(kind *Error, err error) error {
+	if nil == err {
+		return nil
+	}
+	return &kindError{kind: kind, err: err}
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Is(target error) bool {
+	return e.kind == target || e.kind.Is(target)
+}
+
+func (e *kindError) Unwrap() error {
+	return e.err
+}
+
+func (e *kindError) Message(lang string) string {
+	return e.kind.Message(lang) + ": " + e.err.Error()
+}
+
+// errorKinds названия общих видов ошибок, по которым ошибку можно восстановить
+// при воспроизведении записи обмена (см. Capture) или при передаче по сети (см. пакет ipkrpc).
+// STALL (unknown_request) проверяется раньше invalid_param: по нему узнают старую ревизию платы.
+var errorKinds = []struct {
+	name string
+	kind *Error
+}{
+	{"unknown_request", errUnknownRequest},
+	{"not_connected", ErrNotConnected},
+	{"timeout", ErrTimeout},
+	{"invalid_param", ErrInvalidParam},
+	{"not_initialized", ErrNotInitialized},
+	{"adc_not_enabled", ErrADCNotEnabled},
+	{"adc_no_data", ErrADCNoData},
+	{"adc_fault", ErrADCFault},
+	{"bad_response", ErrBadResponse},
+	{"internal", ErrInternal},
+}
+
+// ErrorKindName возвращает название общего вида ошибки err (например, "not_connected"),
+// "" если вид неизвестен
+func ErrorKindName(err error) string {
+	for _, k := range errorKinds {
+		if errors.Is(err, k.kind) {
+			return k.name
+		}
+	}
+	return ""
+}
+
+// ErrorKind возвращает общий вид ошибки по названию, которое вернула ErrorKindName,
+// nil если название неизвестно
+func ErrorKind(name string) *Error {
+	for _, k := range errorKinds {
+		if k.name == name {
+			return k.kind
+		}
+	}
+	return nil
+}
+
+// Localize возвращает сообщение об ошибке для показа пользователю на языке lang
+// (LangRU или LangEN). Используется сообщение первой ошибки библиотеки в цепочке;
+// если её нет, возвращается err.Error().
+func Localize(err error, lang string) string {
+	if nil == err {
+		return ""
+	}
+	var le interface{ Message(lang string) string }
+	if errors.As(err, &le) {
+		return le.Message(lang)
+	}
+	return err.Error()
+}
@@ -0,0 +1,154 @@
+package ipk
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorsIs(t *testing.T) {
+	libusb := errors.New("LIBUSB_ERROR_PIPE")
+	kinds := []*Error{ErrNotConnected, ErrTimeout, ErrInvalidParam, ErrNotInitialized,
+		ErrADCFault, ErrADCNoData, ErrADCNotEnabled, ErrBadResponse, ErrInternal}
+	tests := []struct {
+		name string
+		err  error
+		want *Error
+	}{
+		{"вид ошибки", ErrTimeout, ErrTimeout},
+		{"конкретная ошибка", binErrorNoConnection, ErrNotConnected},
+		{"обёрнутая конкретная ошибка", fmt.Errorf("Set10V():%w", binErrorNoConnection), ErrNotConnected},
+		{"дважды обёрнутая", fmt.Errorf("Apply():%w", fmt.Errorf("AnalogDevice.getDataUSB():%w", anlErrorNoDevice)), ErrNotInitialized},
+		{"ошибка нижнего уровня", wrapError(ErrBadResponse, libusb), ErrBadResponse},
+		{"обёрнутая ошибка нижнего уровня", fmt.Errorf("GetVersion():%w", wrapError(ErrTimeout, libusb)), ErrTimeout},
+		{"неисправность АЦП", fmt.Errorf("GetDat1ADC():%w", frqErrorADCDat1), ErrADCFault},
+		{"нет режима АЦП", fmt.Errorf("EnableADC():%w", frqErrorNoADC), ErrInvalidParam},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, kind := range kinds {
+				if got := errors.Is(tt.err, kind); (kind == tt.want) != got {
+					t.Errorf("errors.Is(%v, %v) = %v", tt.err, kind, got)
+				}
+			}
+		})
+	}
+
+	// ошибка нижнего уровня сохраняется в цепочке
+	err := fmt.Errorf("GetVersion():%w", wrapError(ErrTimeout, libusb))
+	if !errors.Is(err, libusb) {
+		t.Errorf("errors.Is(%v, libusb) = false", err)
+	}
+	// последняя ошибка попыток относится и к ErrTimeout, и к своему виду
+	err = wrapError(ErrTimeout, fmt.Errorf("op():%w", simErrorDisconnected))
+	if !errors.Is(err, ErrNotConnected) || !errors.Is(err, simErrorDisconnected) {
+		t.Errorf("%v: вид исходной ошибки потерян", err)
+	}
+	if nil != wrapError(ErrTimeout, nil) {
+		t.Error("wrapError(nil) != nil")
+	}
+	// конкретные ошибки не равны друг другу, даже если у них один вид
+	if errors.Is(binErrorNoConnection, simErrorDisconnected) {
+		t.Error("errors.Is(binErrorNoConnection, simErrorDisconnected) = true")
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	libusb := errors.New("LIBUSB_ERROR_TIMEOUT")
+	tests := []struct {
+		name   string
+		err    error
+		ru, en string
+	}{
+		{"nil", nil, "", ""},
+		{"вид ошибки", ErrNotConnected, "Нет соединения с устройством", "Device is not connected"},
+		{"обёрнутая конкретная ошибка", fmt.Errorf("Set10V():%w", binErrorNoConnection), "Нет соединения с ФДС-3", "No connection to FDS-3"},
+		{"ошибка нижнего уровня", fmt.Errorf("GetVersion():%w", wrapError(ErrTimeout, libusb)),
+			"Слишком большое время отклика по USB: LIBUSB_ERROR_TIMEOUT", "USB response timeout: LIBUSB_ERROR_TIMEOUT"},
+		{"неисправность АЦП", fmt.Errorf("GetDat2ADC():%w", frqErrorADCDat2), "Неисправен АЦП ФЧС-3 (вход ДАТ 2)", "FChS-3 ADC fault (input DAT 2)"},
+		{"эталонное значение АЦП", frqErrorADCRef, "Неисправен АЦП ФЧС-3 (эталонное значение)", "FChS-3 ADC fault (reference value)"},
+		{"чужая ошибка", errors.New("сторонняя ошибка"), "сторонняя ошибка", "сторонняя ошибка"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Localize(tt.err, LangRU); tt.ru != got {
+				t.Errorf("Localize(ru) = %q, want %q", got, tt.ru)
+			}
+			if got := Localize(tt.err, LangEN); tt.en != got {
+				t.Errorf("Localize(en) = %q, want %q", got, tt.en)
+			}
+			// неизвестный язык - русский
+			if got := Localize(tt.err, "de"); tt.ru != got {
+				t.Errorf("Localize(de) = %q, want %q", got, tt.ru)
+			}
+		})
+	}
+}
+
+func TestADCFaultError(t *testing.T) {
+	tests := []struct {
+		name    string
+		adc     DataADC
+		get     func(dev *FreqDevice) error
+		channel int
+	}{
+		{"ДАТ 1", DataADC{Dat1: 0x2000, Dat2: 0x100, ReferenceVal: 0x100, DivisorVal: 1}, func(dev *FreqDevice) error {
+			_, err := dev.GetDat1ADC()
+			return err
+		}, ADCDat1},
+		{"ДАТ 2", DataADC{Dat1: 0x100, Dat2: 0x2000, ReferenceVal: 0x100, DivisorVal: 1}, func(dev *FreqDevice) error {
+			_, err := dev.GetDat2ADC()
+			return err
+		}, ADCDat2},
+		{"эталонное значение", DataADC{Dat1: 0x100, Dat2: 0x100, ReferenceVal: 0x2000, DivisorVal: 1}, func(dev *FreqDevice) error {
+			_, err := dev.GetRefValADC()
+			return err
+		}, ADCRef},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dev, sim, _ := openFreqSimulator(t)
+			sim.SetADC(tt.adc)
+			if err := dev.EnableADC(true); nil != err {
+				t.Fatal(err)
+			}
+			if err := dev.UpdateADC(); nil != err {
+				t.Fatal(err)
+			}
+			err := fmt.Errorf("тест:%w", tt.get(dev))
+			var fe *ADCFaultError
+			if !errors.As(err, &fe) || tt.channel != fe.Channel {
+				t.Fatalf("errors.As(%v) = %v", err, fe)
+			}
+			if !errors.Is(err, ErrADCFault) || errors.Is(err, ErrADCNoData) {
+				t.Errorf("errors.Is(%v, ErrADCFault) = false", err)
+			}
+			if "adc_fault" != ErrorKindName(err) {
+				t.Errorf("ErrorKindName() = %q", ErrorKindName(err))
+			}
+		})
+	}
+
+	if err := fmt.Errorf("тест:%w", ErrADCFault); errors.As(err, new(*ADCFaultError)) {
+		t.Error("errors.As(ErrADCFault) = true без номера канала")
+	}
+}
+
+func TestErrorKindName(t *testing.T) {
+	for _, k := range errorKinds {
+		err := fmt.Errorf("тест:%w", wrapError(k.kind, errors.New("ошибка")))
+		if got := ErrorKindName(err); k.name != got {
+			t.Errorf("ErrorKindName(%v) = %q, want %q", k.kind, got, k.name)
+		}
+		if got := ErrorKind(k.name); k.kind != got {
+			t.Errorf("ErrorKind(%q) = %v, want %v", k.name, got, k.kind)
+		}
+	}
+	// STALL тоже неверный параметр, но его вид определяется точнее
+	if got := ErrorKindName(fmt.Errorf("тест:%w", errUnknownRequest)); "unknown_request" != got {
+		t.Errorf("ErrorKindName(errUnknownRequest) = %q", got)
+	}
+	if "" != ErrorKindName(errors.New("ошибка")) || nil != ErrorKind("unknown") {
+		t.Error("вид чужой ошибки известен")
+	}
+}
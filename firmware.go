@@ -0,0 +1,236 @@
+package ipk
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Адресное пространство Flash STM32, в которое по умолчанию разрешено записывать прошивку
+// (см. FirmwareImage.Validate и FlashOptions)
+const (
+	FirmwareMinAddr = 0x08000000
+	FirmwareMaxAddr = 0x08100000
+)
+
+var fwErrorFormat = newError(ErrInvalidParam, `Неверный формат файла прошивки`, `Invalid firmware file format`)
+var fwErrorChecksum = newError(ErrInvalidParam, `Неверная контрольная сумма в файле прошивки`, `Firmware file checksum mismatch`)
+var fwErrorOverlap = newError(ErrInvalidParam, `Участки прошивки перекрываются`, `Firmware segments overlap`)
+var fwErrorRange = newError(ErrInvalidParam, `Прошивка выходит за допустимые адреса`, `Firmware is out of the allowed address range`)
+var fwErrorEmpty = newError(ErrInvalidParam, `Файл прошивки не содержит данных`, `Firmware file contains no data`)
+
+// FirmwareSegment непрерывный участок образа прошивки
+type FirmwareSegment struct {
+	Addr uint32 // адрес первого байта во Flash
+	Data []byte
+}
+
+// end возвращает адрес, следующий за последним байтом участка
+func (seg *FirmwareSegment) end() uint64 {
+	return uint64(seg.Addr) + uint64(len(seg.Data))
+}
+
+// FirmwareWord слово прошивки для WriteUpdate
+type FirmwareWord struct {
+	Addr uint32 // адрес, кратный 4
+	Word uint32 // значение слова (байты образа в порядке little endian, как во Flash STM32)
+}
+
+// FirmwareImage образ прошивки платы ФПС-3 на основе STM32: участки, упорядоченные
+// по адресу и не перекрывающие друг друга. Создаётся функциями ParseIntelHex,
+// ParseBinary и LoadFirmware.
+type FirmwareImage struct {
+	Segments []FirmwareSegment
+	Entry    uint32 // точка входа из записи 05 файла Intel HEX (для сведения)
+}
+
+// ParseIntelHex разбирает образ прошивки в формате Intel HEX.
+// Поддерживаются записи 00 - 05; контрольная сумма каждой записи проверяется.
+func ParseIntelHex(r io.Reader) (img *FirmwareImage, err error) {
+	img = new(FirmwareImage)
+	var segments []FirmwareSegment
+	var base uint32
+	eof := false
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if "" == text {
+			continue
+		}
+		if eof {
+			return nil, fmt.Errorf("ParseIntelHex(): строка %d после записи конца файла:%w", line, fwErrorFormat)
+		}
+		if !strings.HasPrefix(text, ":") {
+			return nil, fmt.Errorf("ParseIntelHex(): строка %d:%w", line, fwErrorFormat)
+		}
+		rec, err := hex.DecodeString(text[1:])
+		if nil != err || len(rec) < 5 || len(rec) != 5+int(rec[0]) {
+			return nil, fmt.Errorf("ParseIntelHex(): строка %d:%w", line, fwErrorFormat)
+		}
+		var sum byte
+		for _, b := range rec {
+			sum += b
+		}
+		if 0 != sum {
+			return nil, fmt.Errorf("ParseIntelHex(): строка %d:%w", line, fwErrorChecksum)
+		}
+
+		offset := uint32(binary.BigEndian.Uint16(rec[1:]))
+		data := rec[4 : len(rec)-1]
+		switch rec[3] {
+		case 0x00: // данные
+			if len(data) > 0 {
+				segments = append(segments, FirmwareSegment{Addr: base + offset, Data: data})
+			}
+		case 0x01: // конец файла
+			eof = true
+		case 0x02: // расширенный адрес сегмента
+			if 2 != len(data) {
+				return nil, fmt.Errorf("ParseIntelHex(): строка %d:%w", line, fwErrorFormat)
+			}
+			base = uint32(binary.BigEndian.Uint16(data)) << 4
+		case 0x03: // начальный адрес сегмента (CS:IP)
+			if 4 != len(data) {
+				return nil, fmt.Errorf("ParseIntelHex(): строка %d:%w", line, fwErrorFormat)
+			}
+		case 0x04: // расширенный линейный адрес
+			if 2 != len(data) {
+				return nil, fmt.Errorf("ParseIntelHex(): строка %d:%w", line, fwErrorFormat)
+			}
+			base = uint32(binary.BigEndian.Uint16(data)) << 16
+		case 0x05: // начальный линейный адрес
+			if 4 != len(data) {
+				return nil, fmt.Errorf("ParseIntelHex(): строка %d:%w", line, fwErrorFormat)
+			}
+			img.Entry = binary.BigEndian.Uint32(data)
+		default:
+			return nil, fmt.Errorf("ParseIntelHex(): строка %d, запись %02X:%w", line, rec[3], fwErrorFormat)
+		}
+	}
+	if err = scanner.Err(); nil != err {
+		return nil, fmt.Errorf("ParseIntelHex():%w", err)
+	}
+	if !eof {
+		return nil, fmt.Errorf("ParseIntelHex(): нет записи конца файла:%w", fwErrorFormat)
+	}
+
+	if err = img.setSegments(segments); nil != err {
+		err = fmt.Errorf("ParseIntelHex():%w", err)
+		return nil, err
+	}
+	return
+}
+
+// ParseBinary создаёт образ прошивки из двоичного файла (.bin), который записывается во Flash
+// начиная с адреса base (обычно FirmwareMinAddr)
+func ParseBinary(data []byte, base uint32) (img *FirmwareImage, err error) {
+	if 0 == len(data) {
+		return nil, fmt.Errorf("ParseBinary():%w", fwErrorEmpty)
+	}
+	if uint64(base)+uint64(len(data)) > 1<<32 {
+		return nil, fmt.Errorf("ParseBinary():%w", fwErrorRange)
+	}
+	img = &FirmwareImage{Segments: []FirmwareSegment{{Addr: base, Data: append([]byte(nil), data...)}}}
+	return
+}
+
+// LoadFirmware читает образ прошивки из файла. Файлы с расширением .hex и .ihex
+// разбираются как Intel HEX, остальные - как двоичные с начальным адресом base.
+func LoadFirmware(path string, base uint32) (img *FirmwareImage, err error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".hex", ".ihex":
+		f, err := os.Open(path)
+		if nil != err {
+			return nil, fmt.Errorf("LoadFirmware():%w", err)
+		}
+		defer f.Close()
+		return ParseIntelHex(f)
+	}
+	data, err := os.ReadFile(path)
+	if nil != err {
+		return nil, fmt.Errorf("LoadFirmware():%w", err)
+	}
+	return ParseBinary(data, base)
+}
+
+// setSegments упорядочивает участки по адресу, объединяет смежные и проверяет,
+// что они не перекрываются
+func (img *FirmwareImage) setSegments(segments []FirmwareSegment) error {
+	if 0 == len(segments) {
+		return fwErrorEmpty
+	}
+	sort.SliceStable(segments, func(i, j int) bool { return segments[i].Addr < segments[j].Addr })
+	merged := []FirmwareSegment{{Addr: segments[0].Addr, Data: append([]byte(nil), segments[0].Data...)}}
+	for _, seg := range segments[1:] {
+		last := &merged[len(merged)-1]
+		switch {
+		case uint64(seg.Addr) < last.end():
+			return fmt.Errorf("адрес %08X:%w", seg.Addr, fwErrorOverlap)
+		case uint64(seg.Addr) == last.end():
+			last.Data = append(last.Data, seg.Data...)
+		default:
+			merged = append(merged, FirmwareSegment{Addr: seg.Addr, Data: append([]byte(nil), seg.Data...)})
+		}
+	}
+	for _, seg := range merged {
+		if seg.end() > 1<<32 {
+			return fmt.Errorf("адрес %08X:%w", seg.Addr, fwErrorRange)
+		}
+	}
+	img.Segments = merged
+	return nil
+}
+
+// Size возвращает количество байт прошивки
+func (img *FirmwareImage) Size() (size int) {
+	if nil == img {
+		return
+	}
+	for _, seg := range img.Segments {
+		size += len(seg.Data)
+	}
+	return
+}
+
+// Validate проверяет, что образ не пуст и все участки лежат в диапазоне адресов [min, max)
+func (img *FirmwareImage) Validate(min, max uint32) error {
+	if nil == img || 0 == img.Size() {
+		return fmt.Errorf("FirmwareImage.Validate():%w", fwErrorEmpty)
+	}
+	for _, seg := range img.Segments {
+		if seg.Addr < min || seg.end() > uint64(max) {
+			return fmt.Errorf("FirmwareImage.Validate(): участок %08X-%08X вне %08X-%08X:%w",
+				seg.Addr, seg.end()-1, min, max-1, fwErrorRange)
+		}
+	}
+	return nil
+}
+
+// Words разбивает образ на 32-битные слова для записи в плату, упорядоченные по адресу.
+// Байты слова, не заданные в образе (на невыровненных границах участков), заполняются 0xFF,
+// как в стёртой Flash.
+func (img *FirmwareImage) Words() (words []FirmwareWord) {
+	if nil == img {
+		return
+	}
+	for _, seg := range img.Segments {
+		for i, b := range seg.Data {
+			addr := seg.Addr + uint32(i)
+			aligned := addr &^ 3
+			shift := 8 * (addr & 3)
+			if n := len(words); 0 == n || words[n-1].Addr != aligned {
+				words = append(words, FirmwareWord{Addr: aligned, Word: 0xFFFFFFFF})
+			}
+			w := &words[len(words)-1]
+			w.Word = w.Word&^(0xFF<<shift) | uint32(b)<<shift
+		}
+	}
+	return
+}
@@ -0,0 +1,133 @@
+package ipk
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// hexRecord формирует запись Intel HEX с правильной контрольной суммой
+func hexRecord(typ byte, offset uint16, data ...byte) string {
+	rec := append([]byte{byte(len(data)), byte(offset >> 8), byte(offset), typ}, data...)
+	var sum byte
+	for _, b := range rec {
+		sum += b
+	}
+	rec = append(rec, -sum)
+	return ":" + strings.ToUpper(hex.EncodeToString(rec))
+}
+
+func hexFile(records ...string) string {
+	return strings.Join(records, "\n") + "\n"
+}
+
+var hexEOF = hexRecord(0x01, 0)
+
+func TestParseIntelHex(t *testing.T) {
+	img, err := ParseIntelHex(strings.NewReader(hexFile(
+		hexRecord(0x04, 0, 0x08, 0x00),
+		hexRecord(0x00, 0x0010, 0x11, 0x12, 0x13),
+		hexRecord(0x00, 0x0000, 0x00, 0x01, 0x02, 0x03, 0x04, 0x05),
+		hexRecord(0x00, 0x0006, 0x06, 0x07, 0x08, 0x09),
+		"",
+		hexRecord(0x05, 0, 0x08, 0x00, 0x01, 0x01),
+		hexEOF,
+	)))
+	if nil != err {
+		t.Fatal(err)
+	}
+	wantSegments := []FirmwareSegment{
+		{Addr: 0x08000000, Data: []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}},
+		{Addr: 0x08000010, Data: []byte{0x11, 0x12, 0x13}},
+	}
+	if !reflect.DeepEqual(wantSegments, img.Segments) {
+		t.Errorf("Segments = %X, want %X", img.Segments, wantSegments)
+	}
+	if 0x08000101 != img.Entry {
+		t.Errorf("Entry = %08X", img.Entry)
+	}
+	if 13 != img.Size() {
+		t.Errorf("Size() = %d, want 13", img.Size())
+	}
+	// не заданные в образе байты слова - 0xFF, как в стёртой Flash
+	wantWords := []FirmwareWord{
+		{0x08000000, 0x03020100},
+		{0x08000004, 0x07060504},
+		{0x08000008, 0xFFFF0908},
+		{0x08000010, 0xFF131211},
+	}
+	if words := img.Words(); !reflect.DeepEqual(wantWords, words) {
+		t.Errorf("Words() = %X, want %X", words, wantWords)
+	}
+}
+
+func TestParseIntelHexErrors(t *testing.T) {
+	data := hexRecord(0x00, 0, 0x01, 0x02, 0x03, 0x04)
+	tests := []struct {
+		name string
+		file string
+		want error
+	}{
+		{"нет двоеточия", hexFile(data[1:], hexEOF), fwErrorFormat},
+		{"не шестнадцатеричные символы", hexFile(":0400000001020304ZZ", hexEOF), fwErrorFormat},
+		{"нечётное количество символов", hexFile(data[:len(data)-1], hexEOF), fwErrorFormat},
+		{"короткая запись", hexFile(":0000", hexEOF), fwErrorFormat},
+		{"длина не совпадает с данными", hexFile(":0500000001020304F2", hexEOF), fwErrorFormat},
+		{"контрольная сумма", hexFile(data[:len(data)-2]+"00", hexEOF), fwErrorChecksum},
+		{"нет записи конца файла", hexFile(data), fwErrorFormat},
+		{"запись после конца файла", hexFile(data, hexEOF, data), fwErrorFormat},
+		{"неизвестная запись", hexFile(data, hexRecord(0x06, 0), hexEOF), fwErrorFormat},
+		{"неверный адрес сегмента", hexFile(hexRecord(0x02, 0, 0x10), data, hexEOF), fwErrorFormat},
+		{"неверный линейный адрес", hexFile(hexRecord(0x04, 0, 0x08, 0x00, 0x00), data, hexEOF), fwErrorFormat},
+		{"неверная точка входа", hexFile(data, hexRecord(0x05, 0, 0x08), hexEOF), fwErrorFormat},
+		{"неверный CS:IP", hexFile(data, hexRecord(0x03, 0, 0x00, 0x00), hexEOF), fwErrorFormat},
+		{"участки перекрываются", hexFile(data, hexRecord(0x00, 0x0002, 0xAA, 0xBB), hexEOF), fwErrorOverlap},
+		{"нет данных", hexFile(hexRecord(0x04, 0, 0x08, 0x00), hexEOF), fwErrorEmpty},
+		{"пустой файл", "", fwErrorFormat},
+		{"выход за 4 ГБ", hexFile(hexRecord(0x04, 0, 0xFF, 0xFF), hexRecord(0x00, 0xFFFF, 0x01, 0x02), hexEOF), fwErrorRange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := ParseIntelHex(strings.NewReader(tt.file))
+			if !errors.Is(err, tt.want) || !errors.Is(err, ErrInvalidParam) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+			if nil != img {
+				t.Errorf("img = %+v, want nil", img)
+			}
+		})
+	}
+}
+
+func TestFirmwareValidate(t *testing.T) {
+	tests := []struct {
+		data []byte
+		base uint32
+		want error
+	}{
+		{[]byte{1, 2, 3, 4}, FirmwareMinAddr, nil},
+		{[]byte{1, 2, 3, 4}, FirmwareMinAddr - 4, fwErrorRange},
+		{[]byte{1, 2, 3, 4}, FirmwareMaxAddr - 2, fwErrorRange},
+		{[]byte{1, 2, 3, 4}, FirmwareMaxAddr - 4, nil},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%08X", tt.base), func(t *testing.T) {
+			img, err := ParseBinary(tt.data, tt.base)
+			if nil != err {
+				t.Fatal(err)
+			}
+			if err := img.Validate(FirmwareMinAddr, FirmwareMaxAddr); !errors.Is(err, tt.want) {
+				t.Errorf("Validate() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+	if _, err := ParseBinary(nil, FirmwareMinAddr); !errors.Is(err, fwErrorEmpty) {
+		t.Errorf("ParseBinary(nil) = %v, want %v", err, fwErrorEmpty)
+	}
+	if _, err := ParseBinary([]byte{1, 2}, 0xFFFFFFFF); !errors.Is(err, fwErrorRange) {
+		t.Errorf("ParseBinary() за 4 ГБ = %v, want %v", err, fwErrorRange)
+	}
+}
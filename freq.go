@@ -2,8 +2,12 @@ package ipk
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
+	"fmt"
+	"sync"
+	"time"
 )
 
 //направление движения
@@ -13,9 +17,25 @@ const (
 	MotionBackwards = 0    // назад
 )
 
-const frqErrorNoConnection = `Нет соединения с ФАС-3`
-const frqErrorWrongParam = `Неверный параметр функции`
-const frqErrorNoDevice = `FreqDevice == nil`
+var frqErrorNoConnection = newError(ErrNotConnected, `Нет соединения с ФЧС-3`, `No connection to FChS-3`)
+var frqErrorWrongParam = newError(ErrInvalidParam, `Неверный параметр функции`, `Invalid function parameter`)
+var frqErrorNoDevice = newError(ErrNotInitialized, `FreqDevice == nil`, `FreqDevice == nil`)
+
+// FreqDevice это тип для работы с ФЧС-3
+// Поля ADC и ADCModeEnabled обновляются функциями UpdateFreqDataUSB и UpdateADC;
+// если они вызываются из другой горутины (например, FreqPoller), то читать
+// значения следует через Snapshot или функции Get...
+type FreqDevice struct {
+	usbConnection
+	ADC            DataADC
+	ADCModeEnabled bool
+	freqdata       dataFreq
+	Teeth          uint32
+	Diameter       uint32
+
+	mutexData sync.RWMutex // защищает ADC, ADCModeEnabled, freqdata и updated
+	updated   time.Time    // время последнего обновления данных
+}
 
 const dataADCsize = 14
 
@@ -105,42 +125,58 @@ func (data *dataFreq) setFromBytes(inbuf []byte) bool {
 
 //UpdateFreqDataUSB получает значения, связанные с частотой, по USB из ФЧС-3.
 //Нужно регулярно вызывать эту функцию, чтобы значения обновлялись.
+//Для периодического опроса в отдельной горутине есть FreqPoller.
 func (dev *FreqDevice) UpdateFreqDataUSB() (err error) {
+	return dev.UpdateFreqDataUSBCtx(context.Background())
+}
+
+//UpdateFreqDataUSBCtx то же, что UpdateFreqDataUSB, но с возможностью отмены и ограничения времени через ctx.
+func (dev *FreqDevice) UpdateFreqDataUSBCtx(ctx context.Context) (err error) {
 
 	if nil == dev {
-		err = errors.New("FreqDevice.getFreqDataUSB():" + frqErrorNoDevice)
+		err = fmt.Errorf("FreqDevice.getFreqDataUSB():%w", frqErrorNoDevice)
 		return
 	}
 
 	if !dev.opened() {
-		err = errors.New("FreqDevice.getFreqDataUSB():" + frqErrorNoConnection)
+		err = fmt.Errorf("FreqDevice.getFreqDataUSB():%w", frqErrorNoConnection)
 		return
 	}
 
 	freqbytes := make([]byte, dataFreqSize)
 
-	err = dev.deviceIoControl(VendorRequestInput, 0xB0, freqbytes, len(freqbytes))
+	var enabled bool
+	err = dev.retry(ctx, func(ctx context.Context) (err error) {
+		err = dev.deviceIoControl(ctx, VendorRequestInput, 0xB0, freqbytes, len(freqbytes))
+		if nil == err {
+			enabled, err = dev.isADCEnabled(ctx)
+		}
+		return
+	})
 
 	if nil != err {
-		err = errors.New("FreqDevice.getFreqDataUSB():" + err.Error())
+		err = fmt.Errorf("FreqDevice.getFreqDataUSB():%w", err)
 		return
 	}
 
+	dev.mutexData.Lock()
 	dev.freqdata.setFromBytes(freqbytes)
-	dev.ADCModeEnabled, err = dev.isADCEnabled()
+	dev.ADCModeEnabled = enabled
+	dev.updated = time.Now()
+	dev.mutexData.Unlock()
 
 	return
 }
 
 //установить путь перемещения (в импульсах)
-func (dev *FreqDevice) setLimitWayUSB(wayImpulseCount uint32) (err error) {
+func (dev *FreqDevice) setLimitWayUSB(ctx context.Context, wayImpulseCount uint32) (err error) {
 	if nil == dev {
-		err = errors.New("FreqDevice.setLimitWayUSB():" + frqErrorNoDevice)
+		err = fmt.Errorf("FreqDevice.setLimitWayUSB():%w", frqErrorNoDevice)
 		return
 	}
 
 	if !dev.opened() {
-		err = errors.New("FreqDevice.setLimitWayUSB():" + frqErrorNoConnection)
+		err = fmt.Errorf("FreqDevice.setLimitWayUSB():%w", frqErrorNoConnection)
 		return
 	}
 
@@ -151,24 +187,24 @@ func (dev *FreqDevice) setLimitWayUSB(wayImpulseCount uint32) (err error) {
 
 	freqbytes := dataout.toBytes()
 
-	err = dev.deviceIoControl(VendorRequestOutput, 0xB0, freqbytes, len(freqbytes))
+	err = dev.deviceIoControl(ctx, VendorRequestOutput, 0xB0, freqbytes, len(freqbytes))
 
 	if nil != err {
-		err = errors.New("FreqDevice.setCrsWayUSB():" + err.Error())
+		err = fmt.Errorf("FreqDevice.setCrsWayUSB():%w", err)
 	}
 
 	return
 }
 
 //установка нового значения обоих генераторов частоты
-func (dev *FreqDevice) setFreqUSB(freq1, freq2 uint32) (err error) {
+func (dev *FreqDevice) setFreqUSB(ctx context.Context, freq1, freq2 uint32) (err error) {
 	if nil == dev {
-		err = errors.New("FreqDevice.setFreqUSB():" + frqErrorNoDevice)
+		err = fmt.Errorf("FreqDevice.setFreqUSB():%w", frqErrorNoDevice)
 		return
 	}
 
 	if !dev.opened() {
-		err = errors.New("FreqDevice.setFreqUSB():" + frqErrorNoConnection)
+		err = fmt.Errorf("FreqDevice.setFreqUSB():%w", frqErrorNoConnection)
 		return
 	}
 
@@ -179,24 +215,24 @@ func (dev *FreqDevice) setFreqUSB(freq1, freq2 uint32) (err error) {
 
 	freqbytes := dataout.toBytes()
 
-	err = dev.deviceIoControl(VendorRequestOutput, 0xB0, freqbytes, len(freqbytes))
+	err = dev.deviceIoControl(ctx, VendorRequestOutput, 0xB0, freqbytes, len(freqbytes))
 
 	if nil != err {
-		err = errors.New("FreqDevice.setFreqUSB():" + err.Error())
+		err = fmt.Errorf("FreqDevice.setFreqUSB():%w", err)
 	}
 
 	return
 }
 
 //установка нового значения обоих генераторов частоты
-func (dev *FreqDevice) setDeltaUSB(delta1, delta2 int32) (err error) {
+func (dev *FreqDevice) setDeltaUSB(ctx context.Context, delta1, delta2 int32) (err error) {
 	if nil == dev {
-		err = errors.New("FreqDevice.setDeltaUSB():" + frqErrorNoDevice)
+		err = fmt.Errorf("FreqDevice.setDeltaUSB():%w", frqErrorNoDevice)
 		return
 	}
 
 	if !dev.opened() {
-		err = errors.New("FreqDevice.setDeltaUSB():" + frqErrorNoConnection)
+		err = fmt.Errorf("FreqDevice.setDeltaUSB():%w", frqErrorNoConnection)
 		return
 	}
 
@@ -207,23 +243,23 @@ func (dev *FreqDevice) setDeltaUSB(delta1, delta2 int32) (err error) {
 
 	freqbytes := dataout.toBytes()
 
-	err = dev.deviceIoControl(VendorRequestOutput, 0xB0, freqbytes, len(freqbytes))
+	err = dev.deviceIoControl(ctx, VendorRequestOutput, 0xB0, freqbytes, len(freqbytes))
 
 	if nil != err {
-		err = errors.New("FreqDevice.setDeltaUSB():" + err.Error())
+		err = fmt.Errorf("FreqDevice.setDeltaUSB():%w", err)
 	}
 
 	return
 }
 
-func (dev *FreqDevice) setWayCountUSB(way1, way2 uint32) (err error) {
+func (dev *FreqDevice) setWayCountUSB(ctx context.Context, way1, way2 uint32) (err error) {
 	if nil == dev {
-		err = errors.New("FreqDevice.setDeltaUSB():" + frqErrorNoDevice)
+		err = fmt.Errorf("FreqDevice.setDeltaUSB():%w", frqErrorNoDevice)
 		return
 	}
 
 	if !dev.opened() {
-		err = errors.New("FreqDevice.setDeltaUSB():" + frqErrorNoConnection)
+		err = fmt.Errorf("FreqDevice.setDeltaUSB():%w", frqErrorNoConnection)
 		return
 	}
 
@@ -234,45 +270,228 @@ func (dev *FreqDevice) setWayCountUSB(way1, way2 uint32) (err error) {
 
 	freqbytes := dataout.toBytes()
 
-	err = dev.deviceIoControl(VendorRequestOutput, 0xB0, freqbytes, len(freqbytes))
+	err = dev.deviceIoControl(ctx, VendorRequestOutput, 0xB0, freqbytes, len(freqbytes))
 
 	if nil != err {
-		err = errors.New("FreqDevice.setDeltaUSB():" + err.Error())
+		err = fmt.Errorf("FreqDevice.setDeltaUSB():%w", err)
 	}
 
 	return
 }
 
-func (dev *FreqDevice) setMotionUSB(direction uint8) (err error) {
+func (dev *FreqDevice) setMotionUSB(ctx context.Context, direction uint8) (err error) {
 	if nil == dev {
-		err = errors.New("FreqDevice.setDeltaUSB():" + frqErrorNoDevice)
+		err = fmt.Errorf("FreqDevice.setDeltaUSB():%w", frqErrorNoDevice)
 		return
 	}
 
 	if !dev.opened() {
-		err = errors.New("FreqDevice.setDeltaUSB():" + frqErrorNoConnection)
+		err = fmt.Errorf("FreqDevice.setDeltaUSB():%w", frqErrorNoConnection)
 		return
 	}
 
 	var dataout dataFreq
 	dataout.cmd = 5
-	switch direction {
-	case MotionOnward:
-		dataout.motion = 0 //было 1; решили поменять в мае 2022
-	case MotionBackwards:
-		dataout.motion = 1 //было 0; решили поменять в мае 2022
-	default:
-		err = errors.New("FreqDevice.setDeltaUSB():" + frqErrorWrongParam)
+	// полярность направления зависит от прошивки (см. Capabilities.MotionInverted)
+	caps := dev.capabilities()
+	var ok bool
+	if dataout.motion, ok = caps.motionToBoard(direction); !ok {
+		err = fmt.Errorf("FreqDevice.setDeltaUSB():%w", frqErrorWrongParam)
 		return
 	}
 
 	freqbytes := dataout.toBytes()
 
-	err = dev.deviceIoControl(VendorRequestOutput, 0xB0, freqbytes, len(freqbytes))
+	err = dev.deviceIoControl(ctx, VendorRequestOutput, 0xB0, freqbytes, len(freqbytes))
+
+	if nil != err {
+		err = fmt.Errorf("FreqDevice.setDeltaUSB():%w", err)
+	}
+
+	return
+}
+
+// Потокобезопасный обмен данными с микроконтроллером.
+func (dev *FreqDevice) deviceIoControl(ctx context.Context, direction, request byte, bytes []byte, length int) (err error) {
+	if nil == dev {
+		err = fmt.Errorf("deviceIoControl():%w", frqErrorNoDevice)
+		return
+	}
+	err = dev.transfer(ctx, direction, request, bytes, length)
+	// для восстановления запоминаем команды задания частоты и режим АЦП, но не обновление прошивки
+	if nil == err && VendorRequestOutput == direction && 0xB4 != request {
+		key := int(request) << 8
+		if 0xB0 == request {
+			switch bytes[0] {
+			case 3, 6: // повтор сбросил бы счётчики пути и начал путь перемещения заново
+				return
+			}
+			key |= int(bytes[0]) // команды изменения dataFreq независимы друг от друга
+		}
+		dev.remember(key, request, bytes[:length])
+	}
+	return
+}
+
+// opened показывает открыто ли соединение с ФЧС-3
+func (dev *FreqDevice) opened() bool {
+	if nil == dev {
+		return false
+	}
+	return dev.connected()
+}
+
+/////////////////////ИНТЕРФЕЙСНЫЕ ФУНКЦИИ/////////////////////
+
+// Open соединиться с ФЧС-3
+func (dev *FreqDevice) Open() (ok bool) {
+	if dev == nil {
+		return
+	}
+	reopen := func() (Transport, bool) { return OpenUSBTransport(IDProductFRQ) }
+	var t Transport
+	t, ok = reopen()
+	if ok {
+		dev.attach(t, reopen)
+		dev.detect(context.Background())
+	}
+	return
+}
+
+// GetProductID возвращает идентификатор продукта ФЧС-3
+func (dev *FreqDevice) GetProductID() uint16 {
+	if nil != dev {
+		return IDProductFRQ
+	}
+	return 0
+}
+
+// OpenBy соединиться с конкретной платой ФЧС-3 из списка Enumerate.
+func (dev *FreqDevice) OpenBy(info DeviceInfo) (ok bool) {
+	if dev == nil || IDProductFRQ != info.ProductID {
+		return
+	}
+	reopen := func() (Transport, bool) { return OpenUSBTransportBy(info) }
+	var t Transport
+	t, ok = reopen()
+	if ok {
+		dev.attach(t, reopen)
+		dev.detect(context.Background())
+	}
+	return
+}
+
+// OpenTransport соединиться с ФЧС-3 через заданный транспорт.
+// Возможности платы определяются без обмена с ней (см. DetectCapabilities).
+func (dev *FreqDevice) OpenTransport(t Transport) (ok bool) {
+	if dev == nil || t == nil {
+		return
+	}
+	dev.attach(t, nil)
+	dev.detectCapabilities(context.Background(), IDProductFRQ, nil)
+	ok = true
+	return
+}
+
+// Close закрыть соединение с ФЧС-3
+func (dev *FreqDevice) Close() {
+	if dev == nil {
+		return
+	}
+	dev.close()
+}
+
+// Active показывает активно ли соединение с ФЧС-3
+func (dev *FreqDevice) Active() (ok bool) {
+	if dev == nil {
+		return
+	}
+	return dev.present()
+}
+
+// SetRetryPolicy задаёт политику повторных попыток обмена с ФЧС-3
+func (dev *FreqDevice) SetRetryPolicy(p RetryPolicy) {
+	if dev == nil {
+		return
+	}
+	dev.setRetryPolicy(p)
+}
+
+// GetRetryPolicy возвращает политику повторных попыток обмена с ФЧС-3
+func (dev *FreqDevice) GetRetryPolicy() (p RetryPolicy) {
+	if dev == nil {
+		return DefaultRetryPolicy()
+	}
+	return dev.retryPolicy()
+}
+
+// GetRetryStats возвращает счётчики операций, повторных попыток и ошибок обмена с ФЧС-3
+func (dev *FreqDevice) GetRetryStats() (st RetryStats) {
+	if dev == nil {
+		return
+	}
+	return dev.stats.get()
+}
+
+// ResetRetryStats обнуляет счётчики обмена с ФЧС-3
+func (dev *FreqDevice) ResetRetryStats() {
+	if dev == nil {
+		return
+	}
+	dev.stats.reset()
+}
 
+// Capabilities возвращает возможности ФЧС-3, определённые при открытии платы
+// или последним вызовом DetectCapabilities
+func (dev *FreqDevice) Capabilities() (caps Capabilities) {
+	if dev == nil {
+		return
+	}
+	return dev.capabilities()
+}
+
+// DetectCapabilities запрашивает у ФЧС-3 версию прошивки и по ней уточняет возможности платы
+func (dev *FreqDevice) DetectCapabilities() (caps Capabilities, err error) {
+	return dev.DetectCapabilitiesCtx(context.Background())
+}
+
+// DetectCapabilitiesCtx то же, что DetectCapabilities, но с возможностью отмены и ограничения времени через ctx.
+func (dev *FreqDevice) DetectCapabilitiesCtx(ctx context.Context) (caps Capabilities, err error) {
+	if dev == nil {
+		err = fmt.Errorf("FreqDevice.DetectCapabilities():%w", frqErrorNoDevice)
+		return
+	}
+	caps, err = dev.detect(ctx)
 	if nil != err {
-		err = errors.New("FreqDevice.setDeltaUSB():" + err.Error())
+		err = fmt.Errorf("FreqDevice.DetectCapabilities():%w", err)
 	}
+	return
+}
 
+// detect определяет возможности ФЧС-3 по версии прошивки и проверяет, есть ли у платы
+// режим АЦП: плата без него не знает запроса 0xB2 и отвечает STALL
+func (dev *FreqDevice) detect(ctx context.Context) (caps Capabilities, err error) {
+	if caps, err = dev.detectCapabilities(ctx, IDProductFRQ, dev.GetVersionCtx); nil != err {
+		return
+	}
+	err = dev.retry(ctx, func(ctx context.Context) (err error) {
+		_, err = dev.isADCEnabled(ctx)
+		return
+	})
+	if errors.Is(err, errUnknownRequest) {
+		caps.ADC = false
+		dev.setCapabilities(caps)
+		err = nil
+	}
 	return
 }
+
+// SetCapabilities заменяет возможности ФЧС-3, определённые библиотекой
+// (например, для платы с нестандартной прошивкой)
+func (dev *FreqDevice) SetCapabilities(caps Capabilities) {
+	if dev == nil {
+		return
+	}
+	caps.ProductID = dev.GetProductID()
+	dev.setCapabilities(caps)
+}
@@ -1,28 +1,41 @@
 package ipk
 
 import (
-	"errors"
+	"context"
+	"fmt"
+	"time"
 )
 
 const maxADC = 0x3FF //максимальное значение 12-битного АЦП
 
-const frqErrorADCNoData = `Нет данных АЦП ФЧС-3`
-const frqErrorADCNotEnabled = `На ФЧС-3 не включен режим АЦП`
-const frqErrorADCDat1 = `Неисправен АЦП ФЧС-3 (вход ДАТ 1)`
-const frqErrorADCDat2 = `Неисправен АЦП ФЧС-3 (вход ДАТ 2)`
-const frqErrorADCRef = `Неисправен АЦП ФЧС-3 (эталонное значение)`
+var frqErrorNoADC = newError(ErrInvalidParam, "У ФЧС-3 нет режима АЦП", "FChS-3 has no ADC mode")
+var frqErrorADCNoData = ErrADCNoData
+var frqErrorADCNotEnabled = ErrADCNotEnabled
+var frqErrorADCDat1 = &ADCFaultError{Channel: ADCDat1}
+var frqErrorADCDat2 = &ADCFaultError{Channel: ADCDat2}
+var frqErrorADCRef = &ADCFaultError{Channel: ADCRef}
 
 //EnableADC включает режим АЦП на ФЧС-3.
 //enableADC - если true, то ФЧС-3 работает в режиме АЦП (функции задании частоты не работают),
 //если false, то задание частоты работает, а АЦП нет.
 func (dev *FreqDevice) EnableADC(enableADC bool) (err error) {
+	return dev.EnableADCCtx(context.Background(), enableADC)
+}
+
+//EnableADCCtx то же, что EnableADC, но с возможностью отмены и ограничения времени через ctx.
+func (dev *FreqDevice) EnableADCCtx(ctx context.Context, enableADC bool) (err error) {
 	if nil == dev {
-		err = errors.New("FreqDevice.EnableADC():" + frqErrorNoDevice)
+		err = fmt.Errorf("FreqDevice.EnableADC():%w", frqErrorNoDevice)
 		return
 	}
 
 	if !dev.opened() {
-		err = errors.New("FreqDevice.EnableADC():" + frqErrorNoConnection)
+		err = fmt.Errorf("FreqDevice.EnableADC():%w", frqErrorNoConnection)
+		return
+	}
+
+	if !dev.capabilities().ADC {
+		err = fmt.Errorf("FreqDevice.EnableADC():%w", frqErrorNoADC)
 		return
 	}
 
@@ -31,32 +44,34 @@ func (dev *FreqDevice) EnableADC(enableADC bool) (err error) {
 		adcEnabled = 1
 	}
 
-	err = dev.deviceIoControl(VendorRequestOutput, 0xB2, []byte{adcEnabled}, 1)
+	err = dev.retry(ctx, func(ctx context.Context) error {
+		return dev.deviceIoControl(ctx, VendorRequestOutput, 0xB2, []byte{adcEnabled}, 1)
+	})
 
 	if nil != err {
-		err = errors.New("FreqDevice.setEnableADC():" + err.Error())
+		err = fmt.Errorf("FreqDevice.setEnableADC():%w", err)
 	}
 	return
 }
 
 //isADCEnabled возвращает true если включен режим АЦП.
-func (dev *FreqDevice) isADCEnabled() (enabled bool, err error) {
+func (dev *FreqDevice) isADCEnabled(ctx context.Context) (enabled bool, err error) {
 	if nil == dev {
-		err = errors.New("FreqDevice.IsADCEnabled():" + frqErrorNoDevice)
+		err = fmt.Errorf("FreqDevice.IsADCEnabled():%w", frqErrorNoDevice)
 		return
 	}
 
 	if !dev.opened() {
-		err = errors.New("FreqDevice.IsADCEnabled():" + frqErrorNoConnection)
+		err = fmt.Errorf("FreqDevice.IsADCEnabled():%w", frqErrorNoConnection)
 		return
 	}
 
 	adcEnabled := make([]byte, 1)
 
-	err = dev.deviceIoControl(VendorRequestInput, 0xB2, adcEnabled, 1)
+	err = dev.deviceIoControl(ctx, VendorRequestInput, 0xB2, adcEnabled, 1)
 
 	if nil != err {
-		err = errors.New("FreqDevice.IsADCEnabled():" + err.Error())
+		err = fmt.Errorf("FreqDevice.IsADCEnabled():%w", err)
 		return
 	}
 
@@ -70,28 +85,49 @@ func (dev *FreqDevice) isADCEnabled() (enabled bool, err error) {
 //UpdateADC получает данные АЦП. Предварительно надо включить режим АЦП функцией SetEnableADC.
 //Полученные данные доступны в поле ADC переменной типа FreqDevice.
 //Функция расчитана на то, что её будут регулярно вызывать для обновления данных.
+//Для периодического опроса в отдельной горутине есть FreqPoller (поле ADC).
 func (dev *FreqDevice) UpdateADC() (err error) {
+	return dev.UpdateADCCtx(context.Background())
+}
+
+//UpdateADCCtx то же, что UpdateADC, но с возможностью отмены и ограничения времени через ctx.
+func (dev *FreqDevice) UpdateADCCtx(ctx context.Context) (err error) {
 	if nil == dev {
-		err = errors.New("FreqDevice.UpdateADC():" + frqErrorNoDevice)
+		err = fmt.Errorf("FreqDevice.UpdateADC():%w", frqErrorNoDevice)
 		return
 	}
 
 	if !dev.opened() {
-		err = errors.New("FreqDevice.UpdateADC():" + frqErrorNoConnection)
+		err = fmt.Errorf("FreqDevice.UpdateADC():%w", frqErrorNoConnection)
+		return
+	}
+
+	if !dev.capabilities().ADC {
+		err = fmt.Errorf("FreqDevice.UpdateADC():%w", frqErrorNoADC)
 		return
 	}
 
 	bdat := make([]byte, dataADCsize)
 
-	err = dev.deviceIoControl(VendorRequestInput, 0xB1, bdat, len(bdat))
+	var enabled bool
+	err = dev.retry(ctx, func(ctx context.Context) (err error) {
+		err = dev.deviceIoControl(ctx, VendorRequestInput, 0xB1, bdat, len(bdat))
+		if nil == err {
+			enabled, err = dev.isADCEnabled(ctx)
+		}
+		return
+	})
 
 	if nil != err {
-		err = errors.New("FreqDevice.UpdateADC():" + err.Error())
+		err = fmt.Errorf("FreqDevice.UpdateADC():%w", err)
 		return
 	}
 
+	dev.mutexData.Lock()
 	dev.ADC.setFromBytes(bdat)
-	dev.ADCModeEnabled, err = dev.isADCEnabled()
+	dev.ADCModeEnabled = enabled
+	dev.updated = time.Now()
+	dev.mutexData.Unlock()
 
 	return
 }
@@ -102,24 +138,25 @@ func (dev *FreqDevice) UpdateADC() (err error) {
 //а также где-то на фоне должна периодически вызываться UpdateADC().
 func (dev *FreqDevice) GetDat1ADC() (dat1 uint16, err error) {
 	if nil == dev {
-		err = errors.New("FreqDevice.GetDat1ADC():" + frqErrorNoDevice)
+		err = fmt.Errorf("FreqDevice.GetDat1ADC():%w", frqErrorNoDevice)
 		return
 	}
-	if 0 == dev.ADC.DivisorVal {
-		err = errors.New(frqErrorADCNoData)
+	adc, enabled := dev.adcData()
+	if 0 == adc.DivisorVal {
+		err = frqErrorADCNoData
 		return
 	}
-	if !dev.ADCModeEnabled {
-		err = errors.New(frqErrorADCNotEnabled)
+	if !enabled {
+		err = frqErrorADCNotEnabled
 		return
 	}
 
-	rawdat1 := uint16((dev.ADC.Dat1 / uint32(dev.ADC.DivisorVal)) & 0xFFFF)
+	rawdat1 := uint16((adc.Dat1 / uint32(adc.DivisorVal)) & 0xFFFF)
 
 	// если значения превышают разрядность АЦП, значит АЦП неисправен
 	if rawdat1 > maxADC {
 		dat1 = maxADC
-		err = errors.New(frqErrorADCDat1)
+		err = frqErrorADCDat1
 		return
 	}
 
@@ -134,24 +171,25 @@ func (dev *FreqDevice) GetDat1ADC() (dat1 uint16, err error) {
 //а также где-то на фоне должна периодически вызываться UpdateADC().
 func (dev *FreqDevice) GetDat2ADC() (dat2 uint16, err error) {
 	if nil == dev {
-		err = errors.New("FreqDevice.GetDat2ADC():" + frqErrorNoDevice)
+		err = fmt.Errorf("FreqDevice.GetDat2ADC():%w", frqErrorNoDevice)
 		return
 	}
-	if 0 == dev.ADC.DivisorVal {
-		err = errors.New(frqErrorADCNoData)
+	adc, enabled := dev.adcData()
+	if 0 == adc.DivisorVal {
+		err = frqErrorADCNoData
 		return
 	}
-	if !dev.ADCModeEnabled {
-		err = errors.New(frqErrorADCNotEnabled)
+	if !enabled {
+		err = frqErrorADCNotEnabled
 		return
 	}
 
-	rawdat2 := uint16((dev.ADC.Dat2 / uint32(dev.ADC.DivisorVal)) & 0xFFFF)
+	rawdat2 := uint16((adc.Dat2 / uint32(adc.DivisorVal)) & 0xFFFF)
 
 	// если значения превышают разрядность АЦП, значит АЦП неисправен
 	if rawdat2 > maxADC {
 		dat2 = maxADC
-		err = errors.New(frqErrorADCDat2)
+		err = frqErrorADCDat2
 		return
 	}
 
@@ -167,24 +205,25 @@ func (dev *FreqDevice) GetDat2ADC() (dat2 uint16, err error) {
 //а также где-то на фоне должна периодически вызываться UpdateADC().
 func (dev *FreqDevice) GetRefValADC() (refval uint16, err error) {
 	if nil == dev {
-		err = errors.New("FreqDevice.GetRefValADC():" + frqErrorNoDevice)
+		err = fmt.Errorf("FreqDevice.GetRefValADC():%w", frqErrorNoDevice)
 		return
 	}
-	if 0 == dev.ADC.DivisorVal {
-		err = errors.New(frqErrorADCNoData)
+	adc, enabled := dev.adcData()
+	if 0 == adc.DivisorVal {
+		err = frqErrorADCNoData
 		return
 	}
-	if !dev.ADCModeEnabled {
-		err = errors.New(frqErrorADCNotEnabled)
+	if !enabled {
+		err = frqErrorADCNotEnabled
 		return
 	}
 
-	rawRefVal := uint16((dev.ADC.ReferenceVal / uint32(dev.ADC.DivisorVal)) & 0xFFFF)
+	rawRefVal := uint16((adc.ReferenceVal / uint32(adc.DivisorVal)) & 0xFFFF)
 
 	// если значения превышают разрядность АЦП, значит АЦП неисправен
 	if rawRefVal > maxADC {
 		refval = maxADC
-		err = errors.New(frqErrorADCRef)
+		err = frqErrorADCRef
 		return
 	}
 
@@ -214,26 +253,27 @@ func convertDACToMilliAmper(nBitData uint32, divisor, r, maxU uint16) float64 {
 //а также где-то на фоне должна периодически вызываться UpdateADC().
 func (dev *FreqDevice) GetDat1MilliAmper() (ma float64, err error) {
 	if nil == dev {
-		err = errors.New("FreqDevice.GetDat1ADC():" + frqErrorNoDevice)
+		err = fmt.Errorf("FreqDevice.GetDat1ADC():%w", frqErrorNoDevice)
 		return
 	}
-	if 0 == dev.ADC.DivisorVal {
-		err = errors.New(frqErrorADCNoData)
+	adc, enabled := dev.adcData()
+	if 0 == adc.DivisorVal {
+		err = frqErrorADCNoData
 		return
 	}
-	if !dev.ADCModeEnabled {
-		err = errors.New(frqErrorADCNotEnabled)
+	if !enabled {
+		err = frqErrorADCNotEnabled
 		return
 	}
-	rawdat1 := uint16((dev.ADC.Dat1 / uint32(dev.ADC.DivisorVal)) & 0xFFFF)
+	rawdat1 := uint16((adc.Dat1 / uint32(adc.DivisorVal)) & 0xFFFF)
 
 	// если значения превышают разрядность АЦП, значит АЦП неисправен
 	if rawdat1 > maxADC {
-		err = errors.New(frqErrorADCDat1)
+		err = frqErrorADCDat1
 		return
 	}
 
-	ma = convertDACToMilliAmper(dev.ADC.Dat1, dev.ADC.DivisorVal, 487, 2500)
+	ma = convertDACToMilliAmper(adc.Dat1, adc.DivisorVal, 487, 2500)
 
 	return
 }
@@ -243,26 +283,27 @@ func (dev *FreqDevice) GetDat1MilliAmper() (ma float64, err error) {
 //а также где-то на фоне должна периодически вызываться UpdateADC().
 func (dev *FreqDevice) GetDat2MilliAmper() (ma float64, err error) {
 	if nil == dev {
-		err = errors.New("FreqDevice.GetDat2ADC():" + frqErrorNoDevice)
+		err = fmt.Errorf("FreqDevice.GetDat2ADC():%w", frqErrorNoDevice)
 		return
 	}
-	if 0 == dev.ADC.DivisorVal {
-		err = errors.New(frqErrorADCNoData)
+	adc, enabled := dev.adcData()
+	if 0 == adc.DivisorVal {
+		err = frqErrorADCNoData
 		return
 	}
-	if !dev.ADCModeEnabled {
-		err = errors.New(frqErrorADCNotEnabled)
+	if !enabled {
+		err = frqErrorADCNotEnabled
 		return
 	}
-	rawdat2 := uint16((dev.ADC.Dat2 / uint32(dev.ADC.DivisorVal)) & 0xFFFF)
+	rawdat2 := uint16((adc.Dat2 / uint32(adc.DivisorVal)) & 0xFFFF)
 
 	// если значения превышают разрядность АЦП, значит АЦП неисправен
 	if rawdat2 > maxADC {
-		err = errors.New(frqErrorADCDat2)
+		err = frqErrorADCDat2
 		return
 	}
 
-	ma = convertDACToMilliAmper(dev.ADC.Dat2, dev.ADC.DivisorVal, 121, 2500)
+	ma = convertDACToMilliAmper(adc.Dat2, adc.DivisorVal, 121, 2500)
 
 	return
 }
@@ -272,27 +313,28 @@ func (dev *FreqDevice) GetDat2MilliAmper() (ma float64, err error) {
 //а также где-то на фоне должна периодически вызываться UpdateADC().
 func (dev *FreqDevice) GetRefValMilliAmper() (ma float64, err error) {
 	if nil == dev {
-		err = errors.New("FreqDevice.GetRefValADC():" + frqErrorNoDevice)
+		err = fmt.Errorf("FreqDevice.GetRefValADC():%w", frqErrorNoDevice)
 		return
 	}
-	if 0 == dev.ADC.DivisorVal {
-		err = errors.New(frqErrorADCNoData)
+	adc, enabled := dev.adcData()
+	if 0 == adc.DivisorVal {
+		err = frqErrorADCNoData
 		return
 	}
-	if !dev.ADCModeEnabled {
-		err = errors.New(frqErrorADCNotEnabled)
+	if !enabled {
+		err = frqErrorADCNotEnabled
 		return
 	}
-	rawRefVal := uint16((dev.ADC.ReferenceVal / uint32(dev.ADC.DivisorVal)) & 0xFFFF)
+	rawRefVal := uint16((adc.ReferenceVal / uint32(adc.DivisorVal)) & 0xFFFF)
 
 	// если значения превышают разрядность АЦП, значит АЦП неисправен
 	if rawRefVal > maxADC {
 
-		err = errors.New(frqErrorADCRef)
+		err = frqErrorADCRef
 		return
 	}
 
-	ma = convertDACToMilliAmper(dev.ADC.ReferenceVal, dev.ADC.DivisorVal, 487, 2500)
+	ma = convertDACToMilliAmper(adc.ReferenceVal, adc.DivisorVal, 487, 2500)
 
 	return
 }
@@ -1,8 +1,8 @@
 package ipk
 
 import (
-	"errors"
-	"time"
+	"context"
+	"fmt"
 )
 
 const magicK = float64(0xFFFFFFFF)
@@ -10,28 +10,25 @@ const magicClock = float64(12000000) //12 МГц частота микрокон
 
 //SetHz устанавливает значение в герцах обоих генераторов частоты
 func (dev *FreqDevice) SetHz(freqHz1, freqHz2 float64) (err error) {
+	return dev.SetHzCtx(context.Background(), freqHz1, freqHz2)
+}
+
+//SetHzCtx то же, что SetHz, но с возможностью отмены и ограничения времени через ctx.
+func (dev *FreqDevice) SetHzCtx(ctx context.Context, freqHz1, freqHz2 float64) (err error) {
 	if nil == dev {
-		err = errors.New("FreqDevice.SetHz():" + frqErrorNoDevice)
+		err = fmt.Errorf("FreqDevice.SetHz():%w", frqErrorNoDevice)
 		return
 	}
 	if (freqHz1 < 0) || (freqHz2 < 0) {
-		err = errors.New("FreqDevice.SetHz():" + frqErrorWrongParam)
+		err = fmt.Errorf("FreqDevice.SetHz():%w", frqErrorWrongParam)
 		return
 	}
 
 	Freq1 := (magicK * freqHz1 / magicClock) * 4
 	Freq2 := (magicK * freqHz2 / magicClock) * 4
-	ok := false
-	timeout := false
-	t := time.Now()
-	for !ok && !timeout {
-		err = dev.setFreqUSB(uint32(Freq1), uint32(Freq2))
-		ok = (nil == err)
-		timeout = time.Since(t) >= maxDelayUSB
-	}
-	if ok && timeout {
-		err = errors.New("SetHz():" + errUsbTimeout)
-	}
+	err = dev.retry(ctx, func(ctx context.Context) error {
+		return dev.setFreqUSB(ctx, uint32(Freq1), uint32(Freq2))
+	})
 
 	return
 }
@@ -39,47 +36,46 @@ func (dev *FreqDevice) SetHz(freqHz1, freqHz2 float64) (err error) {
 //GetOutputHz получить значение частоты в герцах обоих генераторов частоты
 func (dev *FreqDevice) GetOutputHz() (hz1, hz2 float64, err error) {
 	if nil == dev {
-		err = errors.New("FreqDevice.GetOutputHz():" + frqErrorNoDevice)
+		err = fmt.Errorf("FreqDevice.GetOutputHz():%w", frqErrorNoDevice)
 		return
 	}
 
-	hz1 = (float64(dev.freqdata.freq1) * magicClock) / (magicK * 4)
-	hz2 = (float64(dev.freqdata.freq2) * magicClock) / (magicK * 4)
+	fd := dev.freqData()
+	hz1 = (float64(fd.freq1) * magicClock) / (magicK * 4)
+	hz2 = (float64(fd.freq2) * magicClock) / (magicK * 4)
 	return
 }
 
 //SetDeltaHz устанавливает значение ускорения в герцах обоих генераторов частоты.
 //Значения могут быть как положительными, так и отрицательными.
 func (dev *FreqDevice) SetDeltaHz(deltaHz1, deltaHz2 float64) (err error) {
+	return dev.SetDeltaHzCtx(context.Background(), deltaHz1, deltaHz2)
+}
+
+//SetDeltaHzCtx то же, что SetDeltaHz, но с возможностью отмены и ограничения времени через ctx.
+func (dev *FreqDevice) SetDeltaHzCtx(ctx context.Context, deltaHz1, deltaHz2 float64) (err error) {
 	if nil == dev {
-		err = errors.New("FreqDevice.SetDeltaHz():" + frqErrorNoDevice)
+		err = fmt.Errorf("FreqDevice.SetDeltaHz():%w", frqErrorNoDevice)
 		return
 	}
 
 	delta1 := magicK * deltaHz1 * 4 / magicClock
 	delta2 := magicK * deltaHz2 * 4 / magicClock
-	ok := false
-	timeout := false
-	t := time.Now()
-	for !ok && !timeout {
-		err = dev.setDeltaUSB(int32(delta1), int32(delta2))
-		ok = (nil == err)
-		timeout = time.Since(t) >= maxDelayUSB
-	}
-	if ok && timeout {
-		err = errors.New("SetDeltaHz():" + errUsbTimeout)
-	}
+	err = dev.retry(ctx, func(ctx context.Context) error {
+		return dev.setDeltaUSB(ctx, int32(delta1), int32(delta2))
+	})
 	return
 }
 
 //GetDeltaHz получить значение частоты в герцах обоих генераторов частоты
 func (dev *FreqDevice) GetDeltaHz() (hz1, hz2 float64, err error) {
 	if nil == dev {
-		err = errors.New("FreqDevice.GetDeltaHz():" + frqErrorNoDevice)
+		err = fmt.Errorf("FreqDevice.GetDeltaHz():%w", frqErrorNoDevice)
 		return
 	}
 
-	hz1 = (float64(dev.freqdata.freq1delta) * magicClock / magicK) / 4
-	hz2 = (float64(dev.freqdata.freq2delta) * magicClock / magicK) / 4
+	fd := dev.freqData()
+	hz1 = (float64(fd.freq1delta) * magicClock / magicK) / 4
+	hz2 = (float64(fd.freq2delta) * magicClock / magicK) / 4
 	return
 }
@@ -0,0 +1,245 @@
+package ipk
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultPollInterval период опроса ФЧС-3 по умолчанию
+const DefaultPollInterval = 100 * time.Millisecond
+
+// FreqSnapshot состояние ФЧС-3, полученное последним обновлением данных.
+// Снимок не меняется после создания, его можно передавать между горутинами.
+type FreqSnapshot struct {
+	Time               time.Time // время обновления данных, нулевое если данных ещё нет
+	Hz1, Hz2           float64   // частота генераторов, Гц
+	DeltaHz1, DeltaHz2 float64   // ускорение генераторов, Гц/с
+	WayCount1          uint32    // пройденный путь первого генератора, импульсы
+	WayCount2          uint32    // пройденный путь второго генератора, импульсы
+	LimitWay1          uint32    // предельный путь первого генератора, импульсы
+	LimitWay2          uint32    // предельный путь второго генератора, импульсы
+	Motion             uint8     // направление движения в том виде, в котором его вернула плата
+	MotionInverted     bool      // плата получает направление инвертированным (Capabilities.MotionInverted)
+	ADC                DataADC   // данные АЦП (если опрашиваются)
+	ADCModeEnabled     bool      // включен режим АЦП
+	Err                error     // ошибка последнего опроса (только для FreqPoller)
+}
+
+// freqData возвращает копию данных частоты
+func (dev *FreqDevice) freqData() (fd dataFreq) {
+	dev.mutexData.RLock()
+	fd = dev.freqdata
+	dev.mutexData.RUnlock()
+	return
+}
+
+// adcData возвращает копию данных АЦП
+func (dev *FreqDevice) adcData() (adc DataADC, enabled bool) {
+	dev.mutexData.RLock()
+	adc, enabled = dev.ADC, dev.ADCModeEnabled
+	dev.mutexData.RUnlock()
+	return
+}
+
+// Snapshot возвращает состояние ФЧС-3, полученное последними вызовами UpdateFreqDataUSB и UpdateADC.
+// Безопасно вызывать из нескольких горутин одновременно с обновлением данных.
+func (dev *FreqDevice) Snapshot() (s FreqSnapshot) {
+	if nil == dev {
+		return
+	}
+	dev.mutexData.RLock()
+	fd := dev.freqdata
+	s.Time = dev.updated
+	s.ADC = dev.ADC
+	s.ADCModeEnabled = dev.ADCModeEnabled
+	dev.mutexData.RUnlock()
+	s.MotionInverted = dev.capabilities().MotionInverted
+
+	s.Hz1 = (float64(fd.freq1) * magicClock) / (magicK * 4)
+	s.Hz2 = (float64(fd.freq2) * magicClock) / (magicK * 4)
+	s.DeltaHz1 = (float64(fd.freq1delta) * magicClock / magicK) / 4
+	s.DeltaHz2 = (float64(fd.freq2delta) * magicClock / magicK) / 4
+	s.WayCount1, s.WayCount2 = fd.way1count, fd.way2count
+	s.LimitWay1, s.LimitWay2 = fd.limitWay1, fd.limitWay2
+	s.Motion = fd.motion
+	return
+}
+
+// Direction возвращает направление движения: MotionOnward (вперёд) или MotionBackwards (назад).
+// Если плата получает направление инвертированным (MotionInverted), значение Motion,
+// которое вернула плата, нужно пересчитать.
+func (s *FreqSnapshot) Direction() uint8 {
+	caps := Capabilities{MotionInverted: s.MotionInverted}
+	return caps.motionFromBoard(s.Motion)
+}
+
+// equal сравнивает снимки без учёта времени
+func (s *FreqSnapshot) equal(other *FreqSnapshot) bool {
+	a, b := *s, *other
+	a.Time, b.Time = time.Time{}, time.Time{}
+	a.Err, b.Err = nil, nil
+	return a == b && errorText(s.Err) == errorText(other.Err)
+}
+
+func errorText(err error) string {
+	if nil == err {
+		return ""
+	}
+	return err.Error()
+}
+
+// FreqPoller периодически опрашивает ФЧС-3 в отдельной горутине (UpdateFreqDataUSB
+// и, если ADC - true, UpdateADC) и рассылает изменившиеся снимки состояния подписчикам.
+// Последний снимок доступен через Snapshot.
+type FreqPoller struct {
+	Interval time.Duration // период опроса
+	ADC      bool          // опрашивать также АЦП
+
+	dev         *FreqDevice
+	mutex       sync.Mutex
+	snapshot    FreqSnapshot
+	subscribers []chan FreqSnapshot
+	stop        context.CancelFunc
+	done        chan struct{}
+}
+
+// NewFreqPoller создаёт опрос ФЧС-3 dev
+func NewFreqPoller(dev *FreqDevice) *FreqPoller {
+	return &FreqPoller{Interval: DefaultPollInterval, dev: dev}
+}
+
+// Start запускает опрос в отдельной горутине
+func (p *FreqPoller) Start() {
+	if nil == p {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if nil != p.stop {
+		return
+	}
+	interval := p.Interval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p.stop = cancel
+	p.done = make(chan struct{})
+	go p.run(ctx, interval, p.done)
+}
+
+// Stop останавливает опрос
+func (p *FreqPoller) Stop() {
+	if nil == p {
+		return
+	}
+	p.mutex.Lock()
+	stop, done := p.stop, p.done
+	p.stop, p.done = nil, nil
+	p.mutex.Unlock()
+	if nil != stop {
+		stop()
+		<-done
+	}
+}
+
+func (p *FreqPoller) run(ctx context.Context, interval time.Duration, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		p.Poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll однократно опрашивает ФЧС-3. Вызывается периодически после Start,
+// но может вызываться и вручную.
+func (p *FreqPoller) Poll(ctx context.Context) (s FreqSnapshot) {
+	if nil == p {
+		return
+	}
+	err := p.dev.UpdateFreqDataUSBCtx(ctx)
+	if nil == err && p.ADC {
+		err = p.dev.UpdateADCCtx(ctx)
+	}
+	if nil != ctx.Err() {
+		return p.Snapshot()
+	}
+	s = p.dev.Snapshot()
+	s.Err = err
+
+	p.mutex.Lock()
+	changed := !s.equal(&p.snapshot)
+	p.snapshot = s
+	var subscribers []chan FreqSnapshot
+	if changed {
+		subscribers = append(subscribers, p.subscribers...)
+	}
+	p.mutex.Unlock()
+
+	for _, ch := range subscribers {
+		publish(ch, s)
+	}
+	return
+}
+
+// publish отправляет снимок в канал, не блокируясь. Если подписчик не успел
+// прочитать предыдущий снимок, он заменяется новым.
+func publish(ch chan FreqSnapshot, s FreqSnapshot) {
+	for {
+		select {
+		case ch <- s:
+			return
+		default:
+		}
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
+
+// Snapshot возвращает последний полученный снимок состояния ФЧС-3
+func (p *FreqPoller) Snapshot() (s FreqSnapshot) {
+	if nil == p {
+		return
+	}
+	p.mutex.Lock()
+	s = p.snapshot
+	p.mutex.Unlock()
+	return
+}
+
+// Subscribe возвращает канал, в который отправляется снимок при каждом изменении
+// состояния ФЧС-3. Канал хранит только последний непрочитанный снимок.
+func (p *FreqPoller) Subscribe() <-chan FreqSnapshot {
+	if nil == p {
+		return nil
+	}
+	ch := make(chan FreqSnapshot, 1)
+	p.mutex.Lock()
+	p.subscribers = append(p.subscribers, ch)
+	p.mutex.Unlock()
+	return ch
+}
+
+// Unsubscribe прекращает отправку снимков в канал ch, полученный от Subscribe
+func (p *FreqPoller) Unsubscribe(ch <-chan FreqSnapshot) {
+	if nil == p {
+		return
+	}
+	p.mutex.Lock()
+	for i, c := range p.subscribers {
+		if c == ch {
+			p.subscribers = append(p.subscribers[:i], p.subscribers[i+1:]...)
+			break
+		}
+	}
+	p.mutex.Unlock()
+}
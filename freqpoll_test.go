@@ -0,0 +1,127 @@
+package ipk
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+// wayNear сравнивает путь в импульсах: дробная часть частоты, переданной плате,
+// даёт погрешность в один импульс
+func wayNear(got, want uint32) bool {
+	return got <= want && got+1 >= want
+}
+
+func TestFreqPollerPoll(t *testing.T) {
+	dev, sim, clock := openFreqSimulator(t)
+	if err := dev.SetHz(1000, 500); nil != err {
+		t.Fatal(err)
+	}
+	p := NewFreqPoller(dev)
+	ch := p.Subscribe()
+	ctx := context.Background()
+
+	s := p.Poll(ctx)
+	if nil != s.Err || math.Abs(s.Hz1-1000) > 0.01 || math.Abs(s.Hz2-500) > 0.01 || s.Time.IsZero() {
+		t.Fatalf("Poll() = %+v", s)
+	}
+	select {
+	case got := <-ch:
+		if !got.equal(&s) {
+			t.Errorf("снимок %+v, want %+v", got, s)
+		}
+	default:
+		t.Fatal("снимок не отправлен подписчику")
+	}
+
+	// без изменений снимок не отправляется
+	p.Poll(ctx)
+	select {
+	case got := <-ch:
+		t.Errorf("снимок без изменений: %+v", got)
+	default:
+	}
+
+	// подписчик не читал канал: в нём остаётся только последний снимок
+	clock.add(time.Second)
+	p.Poll(ctx)
+	clock.add(time.Second)
+	last := p.Poll(ctx)
+	if !wayNear(last.WayCount1, 2000) || !wayNear(last.WayCount2, 1000) {
+		t.Errorf("путь %d, %d, want 2000, 1000", last.WayCount1, last.WayCount2)
+	}
+	if got := <-ch; !got.equal(&last) {
+		t.Errorf("снимок %+v, want последний %+v", got, last)
+	}
+	if got := p.Snapshot(); !got.equal(&last) {
+		t.Errorf("Snapshot() = %+v, want %+v", got, last)
+	}
+
+	// ошибка опроса тоже изменение
+	dev.SetRetryPolicy(RetryPolicy{MaxAttempts: 1})
+	sim.SetConnected(false)
+	if s := p.Poll(ctx); !errors.Is(s.Err, ErrNotConnected) || s.WayCount1 != last.WayCount1 {
+		t.Errorf("Poll() при отключении = %+v", s)
+	}
+	if got := <-ch; !errors.Is(got.Err, ErrNotConnected) {
+		t.Errorf("снимок при отключении: Err = %v", got.Err)
+	}
+
+	p.Unsubscribe(ch)
+	sim.SetConnected(true)
+	p.Poll(ctx)
+	select {
+	case got := <-ch:
+		t.Errorf("снимок после Unsubscribe: %+v", got)
+	default:
+	}
+}
+
+func TestFreqPollerStartStop(t *testing.T) {
+	dev, _, clock := openFreqSimulator(t)
+	if err := dev.SetHz(100, 100); nil != err {
+		t.Fatal(err)
+	}
+	p := NewFreqPoller(dev)
+	p.Interval = time.Millisecond
+	ch := p.Subscribe()
+	p.Start()
+	p.Start() // повторный запуск ничего не меняет
+	defer p.Stop()
+
+	clock.add(10 * time.Second)
+	eventually(t, "снимок с путём 1000 импульсов", func() bool {
+		select {
+		case s := <-ch:
+			return wayNear(s.WayCount1, 1000)
+		default:
+			return false
+		}
+	})
+
+	p.Stop()
+	p.Stop()
+	// после Stop плата не опрашивается и снимки не отправляются
+	for len(ch) > 0 {
+		<-ch
+	}
+	before := p.Snapshot()
+	clock.add(10 * time.Second)
+	time.Sleep(10 * time.Millisecond)
+	if after := p.Snapshot(); !after.equal(&before) {
+		t.Errorf("снимок изменился после Stop: %+v", after)
+	}
+	select {
+	case s := <-ch:
+		t.Errorf("снимок после Stop: %+v", s)
+	default:
+	}
+
+	// опрос можно запустить снова
+	p.Start()
+	eventually(t, "снимок после повторного запуска", func() bool {
+		return wayNear(p.Snapshot().WayCount1, 2000)
+	})
+}
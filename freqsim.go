@@ -0,0 +1,402 @@
+package ipk
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// simGenerator - состояние одного генератора частоты симулятора ФЧС-3.
+// Частота и приращение хранятся в тех же единицах, что и в dataFreq.
+type simGenerator struct {
+	freq  float64 // значение частоты
+	delta float64 // приращение частоты в секунду
+	way   float64 // счётчик пути (импульсы), с дробной частью
+	limit uint32  // путь перемещения (импульсы), 0 - без ограничения
+	pos   float64 // положение (импульсы): растёт при движении вперёд, уменьшается при движении назад
+}
+
+// hz переводит значение частоты генератора в герцы
+func (gen *simGenerator) hz(freq float64) float64 {
+	return (freq * magicClock) / (magicK * 4)
+}
+
+// advance интегрирует частоту и путь генератора за время dt (в секундах).
+// Счётчик пути, как и на плате, считает пройденные импульсы в любом направлении
+// (по нему отсчитывается путь перемещения), а положение pos - с учётом направления.
+func (gen *simGenerator) advance(dt float64, backwards bool) {
+	if dt <= 0 {
+		return
+	}
+	f0 := gen.freq
+	f1 := f0 + gen.delta*dt
+	tc := dt // время, за которое частота достигнет границы диапазона
+	switch {
+	case f1 < 0:
+		f1 = 0
+		tc = f0 / -gen.delta
+	case f1 > magicK:
+		f1 = magicK
+		tc = (magicK - f0) / gen.delta
+	}
+	pulses := (gen.hz(f0)+gen.hz(f1))/2*tc + gen.hz(f1)*(dt-tc)
+
+	gen.freq = f1
+	if 0 == f1 && gen.delta < 0 {
+		gen.delta = 0
+	}
+
+	// проехали заданный путь - останавливаемся
+	if 0 != gen.limit && gen.way+pulses >= float64(gen.limit) {
+		pulses = math.Max(float64(gen.limit)-gen.way, 0)
+		gen.freq = 0
+		gen.delta = 0
+	}
+	gen.way += pulses
+	if backwards {
+		gen.pos -= pulses
+	} else {
+		gen.pos += pulses
+	}
+}
+
+// FreqSimulator - программная модель ФЧС-3, реализующая Transport.
+// Команды задания частоты (запрос 0xB0) выполняются так же, как на плате:
+// частота генераторов меняется с заданным приращением в реальном времени,
+// считаются импульсы пути, при достижении заданного пути генераторы останавливаются.
+// Направление движения (команда 5) определяет знак скорости и изменения положения
+// (см. Hz, Position). Полярность зависит от версии прошивки так же, как у платы
+// (см. Capabilities.MotionInverted): прошивки до 1.0.0 получают направление как есть,
+// остальные - инвертированным: 0 - вперёд, 1 - назад.
+// Также поддерживается режим АЦП (запросы 0xB1, 0xB2; см. SetADCSupported)
+// и канал версии/обновления (0xB4).
+type FreqSimulator struct {
+	mutex sync.Mutex
+	now   func() time.Time
+	last  time.Time
+
+	gen    [2]simGenerator
+	cmd    uint8
+	motion uint8
+
+	adc        DataADC
+	adcEnabled bool
+	noADC      bool // плата без режима АЦП: запросы 0xB1, 0xB2 неизвестны
+
+	firmware simFirmware
+
+	disconnected bool
+}
+
+// NewFreqSimulator создаёт симулятор ФЧС-3 с версией прошивки 1.0.0.
+func NewFreqSimulator() *FreqSimulator {
+	sim := &FreqSimulator{now: time.Now, firmware: simFirmware{major: 1}}
+	sim.last = sim.now()
+	return sim
+}
+
+// SetClock заменяет источник времени симулятора (например, для детерминированных тестов).
+func (sim *FreqSimulator) SetClock(now func() time.Time) {
+	if nil == sim || nil == now {
+		return
+	}
+	sim.mutex.Lock()
+	sim.advance()
+	sim.now = now
+	sim.last = now()
+	sim.mutex.Unlock()
+}
+
+// advance приводит состояние генераторов к текущему моменту времени.
+// Вызывается с захваченным mutex.
+func (sim *FreqSimulator) advance() {
+	t := sim.now()
+	dt := t.Sub(sim.last).Seconds()
+	sim.last = t
+	for i := range sim.gen {
+		sim.gen[i].advance(dt, sim.backwards())
+	}
+}
+
+// backwards показывает, что задано движение назад с учётом полярности, которую
+// использует прошивка симулируемой версии
+func (sim *FreqSimulator) backwards() bool {
+	if motionInverted(sim.firmware.major, sim.firmware.minor, sim.firmware.patch) {
+		return 1 == sim.motion
+	}
+	return 0 == sim.motion
+}
+
+func (sim *FreqSimulator) freqData() (data dataFreq) {
+	data.cmd = sim.cmd
+	data.freq1 = uint32(sim.gen[0].freq)
+	data.freq1delta = int32(sim.gen[0].delta)
+	data.way1count = uint32(sim.gen[0].way)
+	data.limitWay1 = sim.gen[0].limit
+	data.freq2 = uint32(sim.gen[1].freq)
+	data.freq2delta = int32(sim.gen[1].delta)
+	data.way2count = uint32(sim.gen[1].way)
+	data.limitWay2 = sim.gen[1].limit
+	data.motion = sim.motion
+	return
+}
+
+// ControlIn реализует чтение данных из ФЧС-3.
+func (sim *FreqSimulator) ControlIn(request byte, data []byte, timeout time.Duration) (n int, err error) {
+	if nil == sim {
+		err = fmt.Errorf("FreqSimulator.ControlIn():%w", frqErrorNoDevice)
+		return
+	}
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	if sim.disconnected {
+		err = fmt.Errorf("FreqSimulator.ControlIn():%w", simErrorDisconnected)
+		return
+	}
+	sim.advance()
+
+	if sim.noADC && (0xB1 == request || 0xB2 == request) {
+		err = fmt.Errorf("FreqSimulator.ControlIn():%w", simErrorUnknownRequest)
+		return
+	}
+
+	switch request {
+	case 0xB0:
+		fd := sim.freqData()
+		n = copy(data, fd.toBytes())
+	case 0xB1:
+		buf := make([]byte, dataADCsize)
+		binary.BigEndian.PutUint32(buf[0:], sim.adc.Dat1)
+		binary.BigEndian.PutUint32(buf[4:], sim.adc.Dat2)
+		binary.BigEndian.PutUint32(buf[8:], sim.adc.ReferenceVal)
+		binary.BigEndian.PutUint16(buf[12:], sim.adc.DivisorVal)
+		n = copy(data, buf)
+	case 0xB2:
+		var enabled byte
+		if sim.adcEnabled {
+			enabled = 1
+		}
+		n = copy(data, []byte{enabled})
+	case 0xB4:
+		n = sim.firmware.version(data)
+	default:
+		err = fmt.Errorf("FreqSimulator.ControlIn():%w", simErrorUnknownRequest)
+	}
+	return
+}
+
+// ControlOut реализует запись данных в ФЧС-3.
+func (sim *FreqSimulator) ControlOut(request byte, data []byte, timeout time.Duration) (n int, err error) {
+	if nil == sim {
+		err = fmt.Errorf("FreqSimulator.ControlOut():%w", frqErrorNoDevice)
+		return
+	}
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	if sim.disconnected {
+		err = fmt.Errorf("FreqSimulator.ControlOut():%w", simErrorDisconnected)
+		return
+	}
+	sim.advance()
+
+	switch request {
+	case 0xB0:
+		var fd dataFreq
+		if !fd.setFromBytes(data) {
+			err = fmt.Errorf("FreqSimulator.ControlOut():%w", simErrorShortData)
+			return
+		}
+		err = sim.command(&fd)
+		n = dataFreqSize
+	case 0xB2:
+		if sim.noADC {
+			err = fmt.Errorf("FreqSimulator.ControlOut():%w", simErrorUnknownRequest)
+			return
+		}
+		if 0 == len(data) {
+			err = fmt.Errorf("FreqSimulator.ControlOut():%w", simErrorShortData)
+			return
+		}
+		sim.adcEnabled = data[0] != 0
+		n = 1
+	case 0xB4:
+		// старая ревизия платы отвечает на запрос версии (отладка АЦП), но команд обновления не знает
+		if sim.firmware.legacy() {
+			err = fmt.Errorf("FreqSimulator.ControlOut():%w", simErrorUnknownRequest)
+			return
+		}
+		err = sim.firmware.update(data)
+		n = len(data)
+	default:
+		err = fmt.Errorf("FreqSimulator.ControlOut():%w", simErrorUnknownRequest)
+	}
+	return
+}
+
+// command выполняет команду изменения, пришедшую в структуре dataFreq.
+func (sim *FreqSimulator) command(fd *dataFreq) (err error) {
+	switch fd.cmd {
+	case 1:
+		if sim.adcEnabled { // в режиме АЦП задание частоты не работает
+			return
+		}
+		sim.gen[0].delta = float64(fd.freq1delta)
+		sim.gen[1].delta = float64(fd.freq2delta)
+	case 2:
+		if sim.adcEnabled {
+			return
+		}
+		sim.gen[0].freq = float64(fd.freq1)
+		sim.gen[1].freq = float64(fd.freq2)
+	case 3:
+		sim.gen[0].way = float64(fd.way1count)
+		sim.gen[1].way = float64(fd.way2count)
+	case 5:
+		sim.motion = fd.motion
+	case 6:
+		// новый путь перемещения отсчитывается от текущего места
+		sim.gen[0].limit, sim.gen[0].way = fd.limitWay1, 0
+		sim.gen[1].limit, sim.gen[1].way = fd.limitWay2, 0
+	default:
+		err = fmt.Errorf("FreqSimulator.command():%w", frqErrorWrongParam)
+		return
+	}
+	sim.cmd = fd.cmd
+	return
+}
+
+// SetConnected имитирует отключение (false) и подключение (true) кабеля USB.
+// Пока плата отключена, обмен данными завершается ошибкой, а Present возвращает false.
+func (sim *FreqSimulator) SetConnected(connected bool) {
+	if nil == sim {
+		return
+	}
+	sim.mutex.Lock()
+	sim.disconnected = !connected
+	sim.mutex.Unlock()
+}
+
+// Present показывает, подключена ли плата (см. SetConnected).
+func (sim *FreqSimulator) Present() (ok bool) {
+	if nil == sim {
+		return
+	}
+	sim.mutex.Lock()
+	ok = !sim.disconnected
+	sim.mutex.Unlock()
+	return
+}
+
+// Close ничего не делает: состояние симулятора сохраняется, как у включенной платы.
+func (sim *FreqSimulator) Close() error {
+	return nil
+}
+
+// SetADC задаёт данные, которые ФЧС-3 вернёт в режиме АЦП.
+func (sim *FreqSimulator) SetADC(adc DataADC) {
+	if nil == sim {
+		return
+	}
+	sim.mutex.Lock()
+	sim.adc = adc
+	sim.mutex.Unlock()
+}
+
+// SetADCSupported задаёт, есть ли у платы режим АЦП. Плата без него
+// отвечает на запросы 0xB1 и 0xB2 ошибкой (STALL).
+func (sim *FreqSimulator) SetADCSupported(supported bool) {
+	if nil == sim {
+		return
+	}
+	sim.mutex.Lock()
+	sim.noADC = !supported
+	if sim.noADC {
+		sim.adcEnabled = false
+	}
+	sim.mutex.Unlock()
+}
+
+// SetVersion задаёт версию прошивки. Версия 0.0.0 соответствует старой ревизии платы,
+// которая не передаёт сигнатуру версии и не знает команд обновления.
+// После записи прошивки и перезагрузки с другого банка памяти симулятор сообщает
+// версию с номером патча на 1 больше, после возврата на прежний банк - прежнюю.
+// От версии зависит полярность направления движения (см. FreqSimulator).
+func (sim *FreqSimulator) SetVersion(major, minor, patch uint32) {
+	if nil == sim {
+		return
+	}
+	sim.mutex.Lock()
+	sim.advance()
+	sim.firmware.major, sim.firmware.minor, sim.firmware.patch = major, minor, patch
+	sim.mutex.Unlock()
+}
+
+// Hz возвращает текущую частоту обоих генераторов в герцах.
+// При движении назад значения отрицательные.
+func (sim *FreqSimulator) Hz() (hz1, hz2 float64) {
+	if nil == sim {
+		return
+	}
+	sim.mutex.Lock()
+	sim.advance()
+	hz1 = sim.gen[0].hz(sim.gen[0].freq)
+	hz2 = sim.gen[1].hz(sim.gen[1].freq)
+	if sim.backwards() {
+		hz1, hz2 = -hz1, -hz2
+	}
+	sim.mutex.Unlock()
+	return
+}
+
+// WayCount возвращает значения счётчиков пути (в импульсах) обоих генераторов.
+func (sim *FreqSimulator) WayCount() (way1, way2 uint32) {
+	if nil == sim {
+		return
+	}
+	sim.mutex.Lock()
+	sim.advance()
+	way1 = uint32(math.Floor(sim.gen[0].way))
+	way2 = uint32(math.Floor(sim.gen[1].way))
+	sim.mutex.Unlock()
+	return
+}
+
+// Position возвращает положение (в импульсах) обоих генераторов относительно места создания
+// симулятора: при движении вперёд оно растёт, при движении назад - уменьшается.
+// В отличие от счётчиков пути, команды 3 и 6 его не сбрасывают.
+func (sim *FreqSimulator) Position() (pos1, pos2 int64) {
+	if nil == sim {
+		return
+	}
+	sim.mutex.Lock()
+	sim.advance()
+	pos1 = int64(math.Floor(sim.gen[0].pos))
+	pos2 = int64(math.Floor(sim.gen[1].pos))
+	sim.mutex.Unlock()
+	return
+}
+
+// Motion возвращает направление движения в том виде, в котором оно было передано плате.
+func (sim *FreqSimulator) Motion() (motion uint8) {
+	if nil == sim {
+		return
+	}
+	sim.mutex.Lock()
+	motion = sim.motion
+	sim.mutex.Unlock()
+	return
+}
+
+// Flash возвращает копию слов прошивки, записанных последним обновлением, и номер текущего банка памяти.
+func (sim *FreqSimulator) Flash() (words map[uint32]uint32, bank int) {
+	if nil == sim {
+		return
+	}
+	sim.mutex.Lock()
+	words = sim.firmware.words()
+	bank = sim.firmware.bank
+	sim.mutex.Unlock()
+	return
+}
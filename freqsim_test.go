@@ -0,0 +1,209 @@
+package ipk
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock источник времени для симуляторов, который идёт только по команде теста.
+// Может использоваться из разных горутин.
+type fakeClock struct {
+	mutex sync.Mutex
+	t     time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{t: time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) add(d time.Duration) {
+	c.mutex.Lock()
+	c.t = c.t.Add(d)
+	c.mutex.Unlock()
+}
+
+func openFreqSimulator(t *testing.T) (*FreqDevice, *FreqSimulator, *fakeClock) {
+	t.Helper()
+	clock := newFakeClock()
+	sim := NewFreqSimulator()
+	sim.SetClock(clock.now)
+	dev := new(FreqDevice)
+	if !dev.OpenTransport(sim) {
+		t.Fatal("OpenTransport() = false")
+	}
+	return dev, sim, clock
+}
+
+func TestFreqSimulatorMotion(t *testing.T) {
+	tests := []struct {
+		name    string
+		motion  uint8   // направление движения
+		hz      float64 // начальная частота
+		delta   float64 // приращение частоты, Гц/с
+		limit   uint32  // путь перемещения, 0 - без ограничения
+		elapsed time.Duration
+		wantHz  float64 // частота в конце, со знаком направления
+		wantWay uint32
+		wantPos int64
+	}{
+		{"постоянная частота", MotionOnward, 1000, 0, 0, 2 * time.Second, 1000, 2000, 2000},
+		{"назад", MotionBackwards, 1000, 0, 0, time.Second, -1000, 1000, -1000},
+		{"разгон", MotionOnward, 0, 100, 0, 2 * time.Second, 200, 200, 200},
+		{"торможение до остановки", MotionOnward, 100, -100, 0, 3 * time.Second, 0, 50, 50},
+		{"путь перемещения", MotionOnward, 1000, 0, 500, 2 * time.Second, 0, 500, 500},
+		{"путь перемещения назад", MotionBackwards, 1000, 0, 300, time.Second, 0, 300, -300},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dev, sim, clock := openFreqSimulator(t)
+			ctx := context.Background()
+			if err := dev.setMotionUSB(ctx, tt.motion); nil != err {
+				t.Fatal(err)
+			}
+			if err := dev.setLimitWayUSB(ctx, tt.limit); nil != err {
+				t.Fatal(err)
+			}
+			if err := dev.SetHz(tt.hz, tt.hz); nil != err {
+				t.Fatal(err)
+			}
+			if err := dev.SetDeltaHz(tt.delta, tt.delta); nil != err {
+				t.Fatal(err)
+			}
+			clock.add(tt.elapsed)
+
+			hz1, hz2 := sim.Hz()
+			if math.Abs(hz1-tt.wantHz) > 0.01 || hz1 != hz2 {
+				t.Errorf("Hz() = %v, %v, want %v", hz1, hz2, tt.wantHz)
+			}
+			// дробная часть частоты, переданной плате, даёт погрешность в один импульс
+			way1, _ := sim.WayCount()
+			if way1+1 < tt.wantWay || way1 > tt.wantWay {
+				t.Errorf("WayCount() = %d, want %d", way1, tt.wantWay)
+			}
+			pos1, _ := sim.Position()
+			if pos1 < tt.wantPos-1 || pos1 > tt.wantPos {
+				t.Errorf("Position() = %d, want %d", pos1, tt.wantPos)
+			}
+
+			if err := dev.UpdateFreqDataUSB(); nil != err {
+				t.Fatal(err)
+			}
+			snap := dev.Snapshot()
+			if got := snap.Direction(); tt.motion != got {
+				t.Errorf("Direction() = %d, want %d", got, tt.motion)
+			}
+			hz, _, err := dev.GetOutputHz()
+			if nil != err || math.Abs(hz-math.Abs(tt.wantHz)) > 0.01 {
+				t.Errorf("GetOutputHz() = %v, %v, want %v", hz, err, math.Abs(tt.wantHz))
+			}
+		})
+	}
+}
+
+func TestFreqSimulatorBoardMotion(t *testing.T) {
+	// полярность направления, которое получает плата, зависит от версии прошивки
+	tests := []struct {
+		name     string
+		version  [3]uint32
+		inverted bool
+	}{
+		{"старая ревизия", [3]uint32{0, 0, 0}, true},
+		{"до мая 2022", [3]uint32{0, 9, 3}, false},
+		{"с мая 2022", [3]uint32{1, 0, 0}, true},
+		{"новая версия", [3]uint32{1, 2, 0}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dev, sim, clock := openFreqSimulator(t)
+			sim.SetVersion(tt.version[0], tt.version[1], tt.version[2])
+			caps, err := dev.DetectCapabilities()
+			if nil != err {
+				t.Fatal(err)
+			}
+			if tt.inverted != caps.MotionInverted {
+				t.Fatalf("MotionInverted = %v, want %v", caps.MotionInverted, tt.inverted)
+			}
+			var sp Speed
+			if err := sp.Init(dev, 42, 1350); nil != err {
+				t.Fatal(err)
+			}
+			if err := dev.SetHz(100, 100); nil != err {
+				t.Fatal(err)
+			}
+			for _, motion := range []uint8{MotionOnward, MotionBackwards} {
+				if err := dev.setMotionUSB(context.Background(), motion); nil != err {
+					t.Fatal(err)
+				}
+				board := motion
+				if tt.inverted {
+					board ^= 1
+				}
+				if got := sim.Motion(); board != got {
+					t.Errorf("motion %d: sim.Motion() = %d, want %d", motion, got, board)
+				}
+				clock.add(time.Second)
+				if hz1, _ := sim.Hz(); (hz1 < 0) != (MotionBackwards == motion) {
+					t.Errorf("motion %d: sim.Hz() = %v", motion, hz1)
+				}
+				if err := dev.UpdateFreqDataUSB(); nil != err {
+					t.Fatal(err)
+				}
+				snap := dev.Snapshot()
+				if got := snap.Direction(); motion != got {
+					t.Errorf("Direction() = %d, want %d", got, motion)
+				}
+				if got, err := sp.GetMotion(); nil != err || motion != got {
+					t.Errorf("GetMotion() = %d, %v, want %d", got, err, motion)
+				}
+			}
+		})
+	}
+	dev, _, _ := openFreqSimulator(t)
+	if err := dev.setMotionUSB(context.Background(), MotionUnknown); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("setMotionUSB(MotionUnknown) = %v, want %v", err, ErrInvalidParam)
+	}
+}
+
+func TestFreqSimulatorNoADC(t *testing.T) {
+	dev, sim, _ := openFreqSimulator(t)
+	if caps := dev.Capabilities(); !caps.ADC {
+		t.Fatal("ADC = false у платы с режимом АЦП")
+	}
+	if err := dev.EnableADC(true); nil != err {
+		t.Fatal(err)
+	}
+	if err := dev.UpdateADC(); nil != err {
+		t.Fatal(err)
+	}
+
+	sim.SetADCSupported(false)
+	caps, err := dev.DetectCapabilities()
+	if nil != err || caps.ADC {
+		t.Fatalf("DetectCapabilities() = ADC %v, %v", caps.ADC, err)
+	}
+	if err := dev.EnableADC(true); !errors.Is(err, frqErrorNoADC) {
+		t.Errorf("EnableADC() = %v, want %v", err, frqErrorNoADC)
+	}
+	if err := dev.UpdateADC(); !errors.Is(err, frqErrorNoADC) {
+		t.Errorf("UpdateADC() = %v, want %v", err, frqErrorNoADC)
+	}
+	// без режима АЦП частота задаётся как обычно
+	if err := dev.SetHz(100, 100); nil != err {
+		t.Errorf("SetHz() = %v", err)
+	}
+
+	sim.SetADCSupported(true)
+	if caps, err := dev.DetectCapabilities(); nil != err || !caps.ADC {
+		t.Errorf("DetectCapabilities() = ADC %v, %v", caps.ADC, err)
+	}
+}
@@ -1,12 +1,12 @@
 package ipk
 
 import (
-	"errors"
+	"context"
+	"fmt"
 	"math"
-	"time"
 )
 
-const frqErrorSpeedNotInitialized = `структура Speed не инициализирована`
+var frqErrorSpeedNotInitialized = newError(ErrNotInitialized, `структура Speed не инициализирована`, `Speed is not initialized`)
 
 //Speed тип для работы с заданием скорости и получением пройденного пути на ФЧС-3
 type Speed struct {
@@ -21,7 +21,7 @@ type Speed struct {
 //diameter - диаметр бандажа в мм (например, 1350 или 600)
 func (sp *Speed) Init(dev *FreqDevice, teeth, diameter uint32) (err error) {
 	if nil == sp || nil == dev || 0 == teeth || 0 == diameter {
-		err = errors.New("Speed.setFreqUSB():" + frqErrorWrongParam)
+		err = fmt.Errorf("Speed.setFreqUSB():%w", frqErrorWrongParam)
 		return
 	}
 	sp.dev = dev
@@ -42,23 +42,20 @@ func (sp *Speed) initialized() bool {
 //а затем остановится (скорость и ускорение станут равны 0).
 //Пройденный путь можно будет получить с помощью функции GetWay
 func (sp *Speed) SetLimitWay(meters uint32) (err error) {
+	return sp.SetLimitWayCtx(context.Background(), meters)
+}
+
+//SetLimitWayCtx то же, что SetLimitWay, но с возможностью отмены и ограничения времени через ctx.
+func (sp *Speed) SetLimitWayCtx(ctx context.Context, meters uint32) (err error) {
 	if !sp.initialized() {
-		err = errors.New("Speed.SetLimitWay():" + frqErrorSpeedNotInitialized)
+		err = fmt.Errorf("Speed.SetLimitWay():%w", frqErrorSpeedNotInitialized)
 		return
 	}
 	c := (float64(meters) * 1000 * float64(sp.teeth)) / (math.Pi * float64(sp.diameter))
 	count := uint32(c)
-	ok := false
-	timeout := false
-	t := time.Now()
-	for !ok && !timeout {
-		err = sp.dev.setLimitWayUSB(count)
-		ok = (nil == err)
-		timeout = time.Since(t) >= maxDelayUSB
-	}
-	if ok && timeout {
-		err = errors.New("Speed.SetLimitWay():" + errUsbTimeout)
-	}
+	err = sp.dev.retry(ctx, func(ctx context.Context) error {
+		return sp.dev.setLimitWayUSB(ctx, count)
+	})
 
 	return
 }
@@ -66,10 +63,11 @@ func (sp *Speed) SetLimitWay(meters uint32) (err error) {
 //GetLimitWay получить заданный предельный путь перемещения (в метрах)
 func (sp *Speed) GetLimitWay() (meters uint32, err error) {
 	if !sp.initialized() {
-		err = errors.New("Speed.GetLimitWay():" + frqErrorSpeedNotInitialized)
+		err = fmt.Errorf("Speed.GetLimitWay():%w", frqErrorSpeedNotInitialized)
 		return
 	}
-	c := (float64(sp.dev.freqdata.limitWay1) * math.Pi * float64(sp.diameter)) / (1000 * float64(sp.teeth))
+	fd := sp.dev.freqData()
+	c := (float64(fd.limitWay1) * math.Pi * float64(sp.diameter)) / (1000 * float64(sp.teeth))
 	meters = uint32(math.Ceil(c))
 	return
 }
@@ -77,15 +75,16 @@ func (sp *Speed) GetLimitWay() (meters uint32, err error) {
 //GetOutputSpeed получить скорость (в км/ч) обоих генераторов частоты
 func (sp *Speed) GetOutputSpeed() (kmh1, kmh2 float64, err error) {
 	if !sp.initialized() {
-		err = errors.New("Speed.GetOutputSpeed():" + frqErrorSpeedNotInitialized)
+		err = fmt.Errorf("Speed.GetOutputSpeed():%w", frqErrorSpeedNotInitialized)
 		return
 	}
 
-	Fout1 := (float64(sp.dev.freqdata.freq1) * magicClock) / (magicK * 4)
+	fd := sp.dev.freqData()
+	Fout1 := (float64(fd.freq1) * magicClock) / (magicK * 4)
 
 	kmh1 = (((Fout1 * math.Pi * float64(sp.diameter)) / float64(sp.teeth)) * 3600) / 1000000
 
-	Fout2 := (float64(sp.dev.freqdata.freq2) * magicClock) / (magicK * 4)
+	Fout2 := (float64(fd.freq2) * magicClock) / (magicK * 4)
 
 	kmh2 = (((Fout2 * math.Pi * float64(sp.diameter)) / float64(sp.teeth)) * 3600) / 1000000
 
@@ -95,26 +94,23 @@ func (sp *Speed) GetOutputSpeed() (kmh1, kmh2 float64, err error) {
 //SetMotion устанавливает направление движения.
 //direction - допустимые параметры: MotionOnward (вперёд), MotionBackwards (назад)
 func (sp *Speed) SetMotion(direction uint8) (err error) {
+	return sp.SetMotionCtx(context.Background(), direction)
+}
+
+//SetMotionCtx то же, что SetMotion, но с возможностью отмены и ограничения времени через ctx.
+func (sp *Speed) SetMotionCtx(ctx context.Context, direction uint8) (err error) {
 	if !sp.initialized() {
-		err = errors.New("Speed.SetMotion():" + frqErrorSpeedNotInitialized)
+		err = fmt.Errorf("Speed.SetMotion():%w", frqErrorSpeedNotInitialized)
 		return
 	}
 
 	switch direction {
 	case MotionBackwards, MotionOnward:
-		ok := false
-		timeout := false
-		t := time.Now()
-		for !ok && !timeout {
-			err = sp.dev.setMotionUSB(direction)
-			ok = (nil == err)
-			timeout = time.Since(t) >= maxDelayUSB
-		}
-		if ok && timeout {
-			err = errors.New("Speed.SetMotion():" + errUsbTimeout)
-		}
+		err = sp.dev.retry(ctx, func(ctx context.Context) error {
+			return sp.dev.setMotionUSB(ctx, direction)
+		})
 	default:
-		err = errors.New("Speed.SetMotion():" + frqErrorWrongParam)
+		err = fmt.Errorf("Speed.SetMotion():%w", frqErrorWrongParam)
 	}
 
 	return
@@ -124,22 +120,29 @@ func (sp *Speed) SetMotion(direction uint8) (err error) {
 func (sp *Speed) GetMotion() (direction uint8, err error) {
 	direction = MotionUnknown
 	if !sp.initialized() {
-		err = errors.New("Speed.GetMotion():" + frqErrorSpeedNotInitialized)
+		err = fmt.Errorf("Speed.GetMotion():%w", frqErrorSpeedNotInitialized)
 		return
 	}
 
-	direction = sp.dev.freqdata.motion
+	fd := sp.dev.freqData()
+	caps := sp.dev.capabilities()
+	direction = caps.motionFromBoard(fd.motion)
 	return
 }
 
 //SetSpeed устанавливает скорость (в км/ч) обоих генераторов
 func (sp *Speed) SetSpeed(kmh1, kmh2 float64) (err error) {
+	return sp.SetSpeedCtx(context.Background(), kmh1, kmh2)
+}
+
+//SetSpeedCtx то же, что SetSpeed, но с возможностью отмены и ограничения времени через ctx.
+func (sp *Speed) SetSpeedCtx(ctx context.Context, kmh1, kmh2 float64) (err error) {
 	if !sp.initialized() {
-		err = errors.New("Speed.SetSpeed():" + frqErrorSpeedNotInitialized)
+		err = fmt.Errorf("Speed.SetSpeed():%w", frqErrorSpeedNotInitialized)
 		return
 	}
 	if (kmh1 < 0) || (kmh2 < 0) {
-		return errors.New("Speed.SetSpeed():" + frqErrorWrongParam)
+		return fmt.Errorf("Speed.SetSpeed():%w", frqErrorWrongParam)
 	}
 
 	s1 := kmh1 * 1000 * 1000
@@ -157,25 +160,22 @@ func (sp *Speed) SetSpeed(kmh1, kmh2 float64) (err error) {
 	setFreq1 := (magicK * Fout1 / magicClock) * 4
 	setFreq2 := (magicK * Fout2 / magicClock) * 4
 
-	ok := false
-	timeout := false
-	t := time.Now()
-	for !ok && !timeout {
-		err = sp.dev.setFreqUSB(uint32(setFreq1), uint32(setFreq2))
-		ok = (nil == err)
-		timeout = time.Since(t) >= maxDelayUSB
-	}
-	if ok && timeout {
-		err = errors.New("Speed.SetSpeed():" + errUsbTimeout)
-	}
+	err = sp.dev.retry(ctx, func(ctx context.Context) error {
+		return sp.dev.setFreqUSB(ctx, uint32(setFreq1), uint32(setFreq2))
+	})
 
 	return
 }
 
 //SetAcceleration устанавливает ускорение (в 0,01 м/с²) обоих генераторов
 func (sp *Speed) SetAcceleration(accel1, accel2 float64) (err error) {
+	return sp.SetAccelerationCtx(context.Background(), accel1, accel2)
+}
+
+//SetAccelerationCtx то же, что SetAcceleration, но с возможностью отмены и ограничения времени через ctx.
+func (sp *Speed) SetAccelerationCtx(ctx context.Context, accel1, accel2 float64) (err error) {
 	if !sp.initialized() {
-		err = errors.New("Speed.SetAcceleration():" + frqErrorSpeedNotInitialized)
+		err = fmt.Errorf("Speed.SetAcceleration():%w", frqErrorSpeedNotInitialized)
 		return
 	}
 
@@ -192,17 +192,9 @@ func (sp *Speed) SetAcceleration(accel1, accel2 float64) (err error) {
 	setFreq1 := (magicK * Fout1 * 4) / magicClock
 	setFreq2 := (magicK * Fout2 * 4) / magicClock
 
-	ok := false
-	timeout := false
-	t := time.Now()
-	for !ok && !timeout {
-		err = sp.dev.setDeltaUSB(int32(setFreq1), int32(setFreq2))
-		ok = (nil == err)
-		timeout = time.Since(t) >= maxDelayUSB
-	}
-	if ok && timeout {
-		err = errors.New("Speed.SetAcceleration():" + errUsbTimeout)
-	}
+	err = sp.dev.retry(ctx, func(ctx context.Context) error {
+		return sp.dev.setDeltaUSB(ctx, int32(setFreq1), int32(setFreq2))
+	})
 
 	return
 }
@@ -210,12 +202,13 @@ func (sp *Speed) SetAcceleration(accel1, accel2 float64) (err error) {
 //GetOutputAcceleration получить ускорение (в 0,01 м/с²) обоих генераторов частоты
 func (sp *Speed) GetOutputAcceleration() (accel1, accel2 float64, err error) {
 	if !sp.initialized() {
-		err = errors.New("Speed.GetOutputAcceleration():" + frqErrorSpeedNotInitialized)
+		err = fmt.Errorf("Speed.GetOutputAcceleration():%w", frqErrorSpeedNotInitialized)
 		return
 	}
 
-	Delta1 := float64(sp.dev.freqdata.freq1delta)
-	Delta2 := float64(sp.dev.freqdata.freq2delta)
+	fd := sp.dev.freqData()
+	Delta1 := float64(fd.freq1delta)
+	Delta2 := float64(fd.freq2delta)
 
 	d := float64(sp.diameter)
 	z := float64(sp.teeth)
@@ -235,13 +228,14 @@ func (sp *Speed) GetOutputAcceleration() (accel1, accel2 float64, err error) {
 //GetWay получает пройденный путь в метрах с обоих генераторов
 func (sp *Speed) GetWay() (way1, way2 uint32, err error) {
 	if !sp.initialized() {
-		err = errors.New("Speed.GetWay():" + frqErrorSpeedNotInitialized)
+		err = fmt.Errorf("Speed.GetWay():%w", frqErrorSpeedNotInitialized)
 		return
 	}
 	d := float64(sp.diameter)
 	n := float64(sp.teeth)
-	count1 := float64(sp.dev.freqdata.way1count)
-	count2 := float64(sp.dev.freqdata.way2count)
+	fd := sp.dev.freqData()
+	count1 := float64(fd.way1count)
+	count2 := float64(fd.way2count)
 	s1 := ((math.Pi * d * count1) / n) / 1000
 	s2 := ((math.Pi * d * count2) / n) / 1000
 
@@ -250,3 +244,13 @@ func (sp *Speed) GetWay() (way1, way2 uint32, err error) {
 
 	return
 }
+
+// kmh переводит частоту генератора (Гц) в скорость (км/ч)
+func (sp *Speed) kmh(hz float64) float64 {
+	return (((hz * math.Pi * float64(sp.diameter)) / float64(sp.teeth)) * 3600) / 1000000
+}
+
+// meters переводит количество импульсов датчика в путь (м)
+func (sp *Speed) meters(count uint32) float64 {
+	return ((math.Pi * float64(sp.diameter) * float64(count)) / float64(sp.teeth)) / 1000
+}
@@ -2,11 +2,15 @@ package ipk
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
 )
 
+var updErrorNoDevice = newError(ErrNotInitialized, `Плата не создана (nil)`, `Device is nil`)
+var updErrorNoConnection = newError(ErrNotConnected, `Нет соединения с платой`, `No connection to device`)
+
 const (
 	ipkUpdatePrepare = iota
 	ipkUpdateWrite
@@ -48,6 +52,75 @@ type debugADC struct {
 
 const debugADCsize = 20
 
+// сигнатура, по которой новая ревизия платы (STM32) отличает ответ с версией прошивки
+const verSignature = 0xDEADC0DE
+
+// parseVersion определяет версию прошивки по ответу на запрос 0xB4.
+// Если в ответе нет сигнатуры verSignature, то это старая ревизия платы - версия 0.0.0.
+func parseVersion(inbuf []byte) (major, minor, patch uint32, err error) {
+	var data debugADC
+
+	if !data.setFromBytes(inbuf) {
+		err = ErrBadResponse
+		return
+	}
+	if verSignature == data.rawData[0] {
+		major = data.rawData[1]
+		minor = data.convData[0]
+		patch = data.convData[1]
+	}
+	return
+}
+
+// sendUpdate отправляет плате команду обновления прошивки (запрос 0xB4, см. UpdateIPK).
+// Общая часть sendUpdateCommand для всех плат ФПС-3.
+// Команду перезагрузки не повторяем: плата могла выполнить её, не успев ответить.
+func (c *usbConnection) sendUpdate(ctx context.Context, bytes []byte) (err error) {
+	send := func(ctx context.Context) error {
+		return c.transfer(ctx, VendorRequestOutput, 0xB4, bytes, len(bytes))
+	}
+	if ipkUpdateReboot == bytes[0] {
+		return send(ctx)
+	}
+	return c.retry(ctx, send)
+}
+
+// getVersion запрашивает версию прошивки платы (запрос 0xB4). Общая часть GetVersion для всех плат ФПС-3.
+// Старые ревизии ФАС-3 и ФДС-3 не знают этого запроса и отвечают STALL (errUnknownRequest),
+// старая ревизия ФЧС-3 возвращает данные отладки АЦП без сигнатуры: в обоих случаях версия 0.0.0.
+// Остальные ошибки (например, превышение времени ожидания) возвращаются как есть.
+func (c *usbConnection) getVersion(ctx context.Context) (major, minor, patch uint32, err error) {
+	if nil == c {
+		err = fmt.Errorf("getVersion():%w", updErrorNoDevice)
+		return
+	}
+
+	if !c.connected() {
+		err = fmt.Errorf("getVersion():%w", updErrorNoConnection)
+		return
+	}
+
+	bdat := make([]byte, debugADCsize)
+	err = c.retry(ctx, func(ctx context.Context) error {
+		return c.transfer(ctx, VendorRequestInput, 0xB4, bdat, len(bdat))
+	})
+	if errors.Is(err, errUnknownRequest) {
+		return 0, 0, 0, nil
+	}
+	if nil != err {
+		return
+	}
+	return parseVersion(bdat)
+}
+
+// getVersionString возвращает версию прошивки в виде строки (например, 1.0.0).
+// Общая часть GetVersionString для всех плат ФПС-3.
+func (c *usbConnection) getVersionString(ctx context.Context) (version string, err error) {
+	major, minor, patch, err := c.getVersion(ctx)
+	version = fmt.Sprintf("%d.%d.%d", major, minor, patch)
+	return
+}
+
 func (data *debugADC) setFromBytes(inbuf []byte) bool {
 	// должно быть достаточное количество байт чтобы заполнить структуру
 	if nil == inbuf || nil == data || len(inbuf) < 20 {
@@ -64,140 +137,136 @@ func (data *debugADC) setFromBytes(inbuf []byte) bool {
 	return true
 }
 
-func (dev *FreqDevice) sendUpdateCommand(bytes []byte) (err error) {
-	if nil == dev {
-		err = errors.New("FreqDevice.sendUpdateCommand():" + frqErrorNoDevice)
+// sendUpdateCommand проверяет соединение и отправляет плате команду обновления прошивки.
+// Общая часть функций обновления прошивки для всех плат ФПС-3.
+func (c *usbConnection) sendUpdateCommand(ctx context.Context, bytes []byte) (err error) {
+	if nil == c {
+		err = fmt.Errorf("sendUpdateCommand():%w", updErrorNoDevice)
 		return
 	}
 
-	if !dev.opened() {
-		err = errors.New("FreqDevice.sendUpdateCommand():" + frqErrorNoConnection)
+	if !c.connected() {
+		err = fmt.Errorf("sendUpdateCommand():%w", updErrorNoConnection)
 		return
 	}
 
 	if 0 == len(bytes) {
-		err = errors.New("FreqDevice.sendUpdateCommand():" + frqErrorWrongParam)
+		err = fmt.Errorf("sendUpdateCommand():%w", ErrInvalidParam)
 		return
 	}
 
-	err = dev.deviceIoControl(VendorRequestOutput, 0xB4, bytes, len(bytes))
+	err = c.sendUpdate(ctx, bytes)
 
 	if nil != err {
-		err = errors.New("FreqDevice.sendUpdateCommand():" + err.Error())
+		err = fmt.Errorf("sendUpdateCommand():%w", err)
 	}
 
 	return
 }
 
-//PrepareUpdate подготавливает запись обновления прошивки
-func (dev *FreqDevice) PrepareUpdate() (err error) {
-	if nil == dev {
-		err = errors.New("FreqDevice.PrepareUpdate():" + frqErrorNoDevice)
-		return
-	}
-
+// prepareUpdate подготавливает запись обновления прошивки
+func (c *usbConnection) prepareUpdate(ctx context.Context) error {
 	dataout := UpdateIPK{code: ipkUpdatePrepare}
+	return c.sendUpdateCommand(ctx, dataout.toBytes())
+}
 
-	err = dev.sendUpdateCommand(dataout.toBytes())
+// writeUpdate записывает слово uWord по адресу uFlashAddress
+func (c *usbConnection) writeUpdate(ctx context.Context, uFlashAddress uint32, uWord uint32) error {
+	dataout := UpdateIPK{code: ipkUpdateWrite, uFlashAddress: uFlashAddress, uWord: uWord}
+	return c.sendUpdateCommand(ctx, dataout.toBytes())
+}
 
-	return
+// finishUpdate завершает запись обновления прошивки
+func (c *usbConnection) finishUpdate(ctx context.Context) error {
+	dataout := UpdateIPK{code: ipkUpdateFinish}
+	return c.sendUpdateCommand(ctx, dataout.toBytes())
 }
 
-//WriteUpdate записывает слово по адресу (пишет прошивку в память)
-func (dev *FreqDevice) WriteUpdate(uFlashAddress uint32, uWord uint32) (err error) {
+// restartToAnotherBank отправляет команду перезагрузиться с другого банка памяти
+func (c *usbConnection) restartToAnotherBank(ctx context.Context) error {
+	dataout := UpdateIPK{code: ipkUpdateReboot}
+	return c.sendUpdateCommand(ctx, dataout.toBytes())
+}
+
+// updateError добавляет к ошибке err общей функции обновления или версии прошивки
+// имя функции платы fn (например, "FreqDevice.PrepareUpdate()")
+func updateError(fn string, err error) error {
+	if nil == err {
+		return nil
+	}
+	return fmt.Errorf("%s:%w", fn, err)
+}
+
+///////////////////////////////////////////////////////////////
+
+// connection возвращает соединение с ФЧС-3, nil если платы нет
+func (dev *FreqDevice) connection() *usbConnection {
 	if nil == dev {
-		err = errors.New("FreqDevice.WriteUpdate():" + frqErrorNoDevice)
-		return
+		return nil
 	}
+	return &dev.usbConnection
+}
 
-	dataout := UpdateIPK{code: ipkUpdateWrite}
-	dataout.uFlashAddress = uFlashAddress
-	dataout.uWord = uWord
+//PrepareUpdate подготавливает запись обновления прошивки
+func (dev *FreqDevice) PrepareUpdate() (err error) {
+	return dev.PrepareUpdateCtx(context.Background())
+}
 
-	err = dev.sendUpdateCommand(dataout.toBytes())
+//PrepareUpdateCtx то же, что PrepareUpdate, но с возможностью отмены и ограничения времени через ctx.
+func (dev *FreqDevice) PrepareUpdateCtx(ctx context.Context) (err error) {
+	return updateError("FreqDevice.PrepareUpdate()", dev.connection().prepareUpdate(ctx))
+}
 
-	return
+//WriteUpdate записывает слово по адресу (пишет прошивку в память)
+func (dev *FreqDevice) WriteUpdate(uFlashAddress uint32, uWord uint32) (err error) {
+	return dev.WriteUpdateCtx(context.Background(), uFlashAddress, uWord)
+}
+
+//WriteUpdateCtx то же, что WriteUpdate, но с возможностью отмены и ограничения времени через ctx.
+func (dev *FreqDevice) WriteUpdateCtx(ctx context.Context, uFlashAddress uint32, uWord uint32) (err error) {
+	return updateError("FreqDevice.WriteUpdate()", dev.connection().writeUpdate(ctx, uFlashAddress, uWord))
 }
 
 //FinishUpdate завершает запись обновления прошивки
 func (dev *FreqDevice) FinishUpdate() (err error) {
-	if nil == dev {
-		err = errors.New("FreqDevice.FinishUpdate():" + frqErrorNoDevice)
-		return
-	}
-
-	dataout := UpdateIPK{code: ipkUpdateFinish}
-
-	err = dev.sendUpdateCommand(dataout.toBytes())
+	return dev.FinishUpdateCtx(context.Background())
+}
 
-	return
+//FinishUpdateCtx то же, что FinishUpdate, но с возможностью отмены и ограничения времени через ctx.
+func (dev *FreqDevice) FinishUpdateCtx(ctx context.Context) (err error) {
+	return updateError("FreqDevice.FinishUpdate()", dev.connection().finishUpdate(ctx))
 }
 
 //RestartToAnotherBank отправляет команду устройству перезагрузиться с другого банка памяти
 func (dev *FreqDevice) RestartToAnotherBank() (err error) {
-	if nil == dev {
-		err = errors.New("FreqDevice.RestartToAnotherBank():" + frqErrorNoDevice)
-		return
-	}
-
-	dataout := UpdateIPK{code: ipkUpdateReboot}
-
-	err = dev.sendUpdateCommand(dataout.toBytes())
+	return dev.RestartToAnotherBankCtx(context.Background())
+}
 
-	return
+//RestartToAnotherBankCtx то же, что RestartToAnotherBank, но с возможностью отмены и ограничения времени через ctx.
+func (dev *FreqDevice) RestartToAnotherBankCtx(ctx context.Context) (err error) {
+	return updateError("FreqDevice.RestartToAnotherBank()", dev.connection().restartToAnotherBank(ctx))
 }
 
 //GetVersionString возвращает версию прошивки ФЧС-3 (например, 1.0.0) в виде строки
 //Старая версия платы - 0.0.0
 func (dev *FreqDevice) GetVersionString() (version string, err error) {
-	if nil == dev {
-		err = errors.New("FreqDevice.GetVersion():" + frqErrorNoDevice)
-		return
-	}
-	major, minor, patch, err2 := dev.GetVersion()
-	err = err2
+	return dev.GetVersionStringCtx(context.Background())
+}
 
-	version = fmt.Sprintf("%d.%d.%d", major, minor, patch)
-	return
+//GetVersionStringCtx то же, что GetVersionString, но с возможностью отмены и ограничения времени через ctx.
+func (dev *FreqDevice) GetVersionStringCtx(ctx context.Context) (version string, err error) {
+	version, err = dev.connection().getVersionString(ctx)
+	return version, updateError("FreqDevice.GetVersion()", err)
 }
 
 //GetVersion возвращает версию прошивки ФЧС-3 (например, 1.0.0)
 //Старая версия платы - 0.0.0
 func (dev *FreqDevice) GetVersion() (major, minor, patch uint32, err error) {
-	if nil == dev {
-		err = errors.New("FreqDevice.GetVersion():" + frqErrorNoDevice)
-		return
-	}
-
-	if !dev.opened() {
-		err = errors.New("FreqDevice.GetVersion():" + frqErrorNoConnection)
-		return
-	}
-
-	bdat := make([]byte, debugADCsize)
-
-	err = dev.deviceIoControl(VendorRequestInput, 0xB4, bdat, len(bdat))
-
-	if nil != err {
-		err = errors.New("FreqDevice.GetVersion():" + err.Error())
-		return
-	}
-
-	//определяем номер версии прошивки
-
-	var data debugADC
-
-	if data.setFromBytes(bdat) {
-		const verSignature = 0xDEADC0DE
-		if verSignature == data.rawData[0] {
-			major = data.rawData[1]
-			minor = data.convData[0]
-			patch = data.convData[1]
-		}
-	} else {
-		err = errors.New("FreqDevice.GetVersion():" + "wrong data from device")
-		return
-	}
+	return dev.GetVersionCtx(context.Background())
+}
 
-	return
+//GetVersionCtx то же, что GetVersion, но с возможностью отмены и ограничения времени через ctx.
+func (dev *FreqDevice) GetVersionCtx(ctx context.Context) (major, minor, patch uint32, err error) {
+	major, minor, patch, err = dev.connection().getVersion(ctx)
+	return major, minor, patch, updateError("FreqDevice.GetVersion()", err)
 }
@@ -0,0 +1,243 @@
+package ipk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultRestartTimeout время ожидания платы после перезагрузки с другого банка памяти по умолчанию
+const DefaultRestartTimeout = 10 * time.Second
+
+// restartPollInterval период опроса версии прошивки после перезагрузки
+const restartPollInterval = 100 * time.Millisecond
+
+var fwErrorLegacy = newError(ErrInvalidParam, `Плата старой ревизии не поддерживает обновление прошивки`, `Legacy board does not support firmware update`)
+var fwErrorVersion = newError(ErrBadResponse, `После обновления плата сообщает другую версию прошивки`, `Board reports unexpected firmware version after update`)
+
+// Этапы обновления прошивки (см. FlashProgress, FlashError)
+const (
+	FlashPrepare = iota // подготовка (стирание другого банка памяти)
+	FlashWrite          // запись слов прошивки
+	FlashFinish         // завершение записи
+	FlashRestart        // перезагрузка с другого банка памяти
+	FlashVerify         // ожидание платы и проверка версии прошивки
+	FlashDone           // обновление завершено
+)
+
+var flashStageNames = [...]string{"prepare", "write", "finish", "restart", "verify", "done"}
+
+// FlashStageName возвращает название этапа обновления прошивки
+func FlashStageName(stage int) string {
+	if stage < 0 || stage >= len(flashStageNames) {
+		return "unknown"
+	}
+	return flashStageNames[stage]
+}
+
+// FlashProgress ход обновления прошивки
+type FlashProgress struct {
+	Stage   int    // этап FlashPrepare ... FlashDone
+	Written int    // записано слов
+	Total   int    // всего слов
+	Addr    uint32 // адрес последнего записанного слова (FlashWrite)
+	Version string // версия прошивки: до обновления, на FlashDone - после
+}
+
+// FlashOptions параметры обновления прошивки
+type FlashOptions struct {
+	MinAddr, MaxAddr uint32 // допустимые адреса [MinAddr, MaxAddr); если оба 0 - FirmwareMinAddr и FirmwareMaxAddr
+	// Resume - количество слов, уже записанных прерванным обновлением (FlashError.Written).
+	// Если больше 0, подготовка не выполняется (банк не стирается), запись продолжается с этого слова.
+	Resume         int
+	ExpectVersion  string                 // ожидаемая версия после обновления (например, 1.2.0); "" - любая, кроме прежней
+	RestartTimeout time.Duration          // время ожидания платы после перезагрузки, 0 - DefaultRestartTimeout
+	NoRestart      bool                   // не перезагружать плату после записи (новая прошивка запустится при следующем включении)
+	Progress       func(ev FlashProgress) // вызывается в начале каждого этапа и после записи каждого слова
+}
+
+// FlashError ошибка обновления прошивки с указанием этапа.
+// Если ошибка произошла на этапе FlashWrite, то банк памяти, с которого работает плата,
+// не затронут, а обновление можно продолжить с Resume = Written.
+type FlashError struct {
+	Stage   int    // этап, на котором произошла ошибка
+	Written int    // успешно записано слов
+	Addr    uint32 // адрес слова, при записи которого произошла ошибка (FlashWrite)
+	Err     error
+}
+
+func (e *FlashError) Error() string {
+	if FlashWrite == e.Stage {
+		return fmt.Sprintf("обновление прошивки (%s, записано слов: %d, адрес %08X): %v",
+			FlashStageName(e.Stage), e.Written, e.Addr, e.Err)
+	}
+	return fmt.Sprintf("обновление прошивки (%s): %v", FlashStageName(e.Stage), e.Err)
+}
+
+func (e *FlashError) Unwrap() error {
+	return e.Err
+}
+
+// firmwareUpdater реализуется платами, которые поддерживают обновление прошивки по запросу 0xB4
+type firmwareUpdater interface {
+	PrepareUpdateCtx(ctx context.Context) error
+	WriteUpdateCtx(ctx context.Context, uFlashAddress uint32, uWord uint32) error
+	FinishUpdateCtx(ctx context.Context) error
+	RestartToAnotherBankCtx(ctx context.Context) error
+	DetectCapabilitiesCtx(ctx context.Context) (caps Capabilities, err error)
+	connection() *usbConnection
+}
+
+var _ firmwareUpdater = (*FreqDevice)(nil)
+
+// FlashFirmware записывает образ прошивки img в другой банк памяти ФЧС-3, перезагружает плату
+// с этого банка и проверяет версию прошивки. Возвращает версию после обновления.
+// При ошибке возвращается *FlashError. Ошибка при записи не затрагивает работающую
+// прошивку: завершение записи и перезагрузка не выполняются.
+func (dev *FreqDevice) FlashFirmware(img *FirmwareImage, opts FlashOptions) (version string, err error) {
+	return dev.FlashFirmwareCtx(context.Background(), img, opts)
+}
+
+// FlashFirmwareCtx то же, что FlashFirmware, но с возможностью отмены и ограничения времени через ctx.
+// Отмена ctx прерывает обновление так же, как ошибка записи.
+func (dev *FreqDevice) FlashFirmwareCtx(ctx context.Context, img *FirmwareImage, opts FlashOptions) (version string, err error) {
+	version, err = flashFirmware(ctx, dev, img, opts)
+	return version, updateError("FreqDevice.FlashFirmware()", err)
+}
+
+func flashFirmware(ctx context.Context, dev firmwareUpdater, img *FirmwareImage, opts FlashOptions) (version string, err error) {
+	if 0 == opts.MinAddr && 0 == opts.MaxAddr {
+		opts.MinAddr, opts.MaxAddr = FirmwareMinAddr, FirmwareMaxAddr
+	}
+	if err = img.Validate(opts.MinAddr, opts.MaxAddr); nil != err {
+		return
+	}
+	words := img.Words()
+	if opts.Resume < 0 || opts.Resume > len(words) {
+		err = fmt.Errorf("Resume = %d (всего слов %d):%w", opts.Resume, len(words), ErrInvalidParam)
+		return
+	}
+
+	ev := FlashProgress{Stage: FlashPrepare, Written: opts.Resume, Total: len(words)}
+	progress := func(stage int) {
+		ev.Stage = stage
+		if nil != opts.Progress {
+			opts.Progress(ev)
+		}
+	}
+	fail := func(stage int, err error) error {
+		return &FlashError{Stage: stage, Written: ev.Written, Addr: ev.Addr, Err: err}
+	}
+
+	// старая ревизия платы не знает команд обновления (см. Capabilities)
+	caps, err := dev.DetectCapabilitiesCtx(ctx)
+	if nil != err {
+		return "", fail(FlashPrepare, err)
+	}
+	if !caps.FirmwareUpdate {
+		return "", fail(FlashPrepare, fwErrorLegacy)
+	}
+	ev.Version = caps.Version
+
+	progress(FlashPrepare)
+	if 0 == opts.Resume {
+		if err = dev.PrepareUpdateCtx(ctx); nil != err {
+			return "", fail(FlashPrepare, err)
+		}
+	}
+
+	progress(FlashWrite)
+	for _, w := range words[opts.Resume:] {
+		if err = ctx.Err(); nil == err {
+			err = dev.WriteUpdateCtx(ctx, w.Addr, w.Word)
+		}
+		if nil != err {
+			ev.Addr = w.Addr
+			return "", fail(FlashWrite, err)
+		}
+		ev.Written++
+		ev.Addr = w.Addr
+		progress(FlashWrite)
+	}
+
+	progress(FlashFinish)
+	if err = dev.FinishUpdateCtx(ctx); nil != err {
+		return "", fail(FlashFinish, err)
+	}
+	if opts.NoRestart {
+		progress(FlashDone)
+		return ev.Version, nil
+	}
+
+	progress(FlashRestart)
+	// плата может перезагрузиться, не успев подтвердить команду, поэтому ошибка обмена
+	// здесь не считается неудачей: результат проверяется по версии прошивки
+	if err = dev.RestartToAnotherBankCtx(ctx); errors.Is(err, ErrInvalidParam) || errors.Is(err, ErrNotInitialized) {
+		return "", fail(FlashRestart, err)
+	}
+
+	progress(FlashVerify)
+	if version, err = waitRestart(ctx, dev, opts.RestartTimeout); nil != err {
+		return "", fail(FlashVerify, err)
+	}
+	switch {
+	case "" != opts.ExpectVersion && opts.ExpectVersion != version:
+		return version, fail(FlashVerify, fmt.Errorf("%s вместо %s:%w", version, opts.ExpectVersion, fwErrorVersion))
+	case "" == opts.ExpectVersion && ev.Version == version:
+		// плата, скорее всего, загрузилась с прежнего банка памяти
+		return version, fail(FlashVerify, fmt.Errorf("версия %s не изменилась:%w", version, fwErrorVersion))
+	}
+	ev.Version = version
+	progress(FlashDone)
+	return
+}
+
+// waitRestart ждёт, пока плата после перезагрузки снова ответит на запрос версии
+// прошивки с поддержкой обновления (не 0.0.0), и заново определяет её возможности.
+// Пропавшая на время перезагрузки плата переподключается так же, как это делает Supervisor.
+func waitRestart(ctx context.Context, dev firmwareUpdater, timeout time.Duration) (version string, err error) {
+	if timeout <= 0 {
+		timeout = DefaultRestartTimeout
+	}
+	parent := ctx
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	c := dev.connection()
+	ticker := time.NewTicker(restartPollInterval)
+	defer ticker.Stop()
+	for {
+		switch {
+		case c.connected():
+			if c.stale() {
+				c.detach()
+			}
+		case c.canReconnect():
+			c.reconnect(false)
+		}
+		if c.connected() {
+			var caps Capabilities
+			switch caps, err = dev.DetectCapabilitiesCtx(ctx); {
+			case nil != err:
+				// после перезагрузки старый дескриптор устройства может быть недействителен
+				c.detach()
+			case caps.FirmwareUpdate:
+				return caps.Version, nil
+			default:
+				// прошивка, записанная обновлением, не может быть старой ревизии (0.0.0)
+				err = fmt.Errorf("версия %s:%w", caps.Version, fwErrorVersion)
+			}
+		} else {
+			err = ErrNotConnected
+		}
+		select {
+		case <-ctx.Done():
+			if nil != parent.Err() {
+				return "", parent.Err()
+			}
+			return "", fmt.Errorf("плата не ответила за %v (%v):%w", timeout, err, ErrTimeout)
+		case <-ticker.C:
+		}
+	}
+}
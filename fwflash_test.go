@@ -0,0 +1,177 @@
+package ipk
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// failingTransport завершает ошибкой запись слова прошивки с номером fail (с 1)
+type failingTransport struct {
+	*FreqSimulator
+	written, fail int
+}
+
+func (ft *failingTransport) ControlOut(request byte, data []byte, timeout time.Duration) (int, error) {
+	if 0xB4 == request && len(data) > 0 && ipkUpdateWrite == data[0] {
+		ft.written++
+		if ft.written == ft.fail {
+			return 0, wrapError(ErrBadResponse, errors.New("сбой записи"))
+		}
+	}
+	return ft.FreqSimulator.ControlOut(request, data, timeout)
+}
+
+// staleBankTransport не выполняет перезагрузку с другого банка памяти:
+// плата продолжает работать с прежней прошивкой
+type staleBankTransport struct {
+	*FreqSimulator
+}
+
+func (st *staleBankTransport) ControlOut(request byte, data []byte, timeout time.Duration) (int, error) {
+	if 0xB4 == request && len(data) > 0 && ipkUpdateReboot == data[0] {
+		return len(data), nil
+	}
+	return st.FreqSimulator.ControlOut(request, data, timeout)
+}
+
+func TestFlashFirmware(t *testing.T) {
+	img, err := ParseBinary([]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, FirmwareMinAddr)
+	if nil != err {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name        string
+		version     [3]uint32 // версия прошивки до обновления
+		opts        FlashOptions
+		wantVersion string
+		wantStage   int   // этап ошибки
+		wantErr     error // nil - обновление успешно
+		wantBank    int
+	}{
+		{"новая версия", [3]uint32{1, 0, 0}, FlashOptions{}, "1.0.1", 0, nil, 1},
+		{"ожидаемая версия", [3]uint32{1, 2, 0}, FlashOptions{ExpectVersion: "1.2.1"}, "1.2.1", 0, nil, 1},
+		{"другая версия", [3]uint32{1, 2, 0}, FlashOptions{ExpectVersion: "1.3.0"}, "1.2.1", FlashVerify, fwErrorVersion, 1},
+		{"без перезагрузки", [3]uint32{1, 0, 0}, FlashOptions{NoRestart: true}, "1.0.0", 0, nil, 0},
+		{"старая ревизия", [3]uint32{0, 0, 0}, FlashOptions{}, "", FlashPrepare, fwErrorLegacy, 0},
+		{"вне допустимых адресов", [3]uint32{1, 0, 0}, FlashOptions{MinAddr: FirmwareMinAddr, MaxAddr: FirmwareMinAddr + 8}, "", 0, fwErrorRange, 0},
+		{"неверное продолжение", [3]uint32{1, 0, 0}, FlashOptions{Resume: 4}, "", 0, ErrInvalidParam, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := NewFreqSimulator()
+			sim.SetVersion(tt.version[0], tt.version[1], tt.version[2])
+			dev := new(FreqDevice)
+			dev.OpenTransport(sim)
+			version, err := dev.FlashFirmware(img, tt.opts)
+			if !errors.Is(err, tt.wantErr) || (nil == tt.wantErr) != (nil == err) {
+				t.Fatalf("FlashFirmware() = %v, want %v", err, tt.wantErr)
+			}
+			var fe *FlashError
+			if errors.As(err, &fe) && tt.wantStage != fe.Stage {
+				t.Errorf("Stage = %s, want %s", FlashStageName(fe.Stage), FlashStageName(tt.wantStage))
+			}
+			if tt.wantVersion != version {
+				t.Errorf("version = %q, want %q", version, tt.wantVersion)
+			}
+			if _, bank := sim.Flash(); tt.wantBank != bank {
+				t.Errorf("bank = %d, want %d", bank, tt.wantBank)
+			}
+		})
+	}
+}
+
+func TestFlashFirmwareResume(t *testing.T) {
+	img, err := ParseBinary([]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}, FirmwareMinAddr)
+	if nil != err {
+		t.Fatal(err)
+	}
+	ft := &failingTransport{FreqSimulator: NewFreqSimulator(), fail: 2}
+	dev := new(FreqDevice)
+	dev.OpenTransport(ft)
+	dev.SetRetryPolicy(RetryPolicy{MaxAttempts: 1})
+
+	var stages []int
+	_, err = dev.FlashFirmware(img, FlashOptions{Progress: func(ev FlashProgress) {
+		if n := len(stages); 0 == n || stages[n-1] != ev.Stage {
+			stages = append(stages, ev.Stage)
+		}
+	}})
+	var fe *FlashError
+	if !errors.As(err, &fe) || FlashWrite != fe.Stage || 1 != fe.Written || FirmwareMinAddr+4 != fe.Addr {
+		t.Fatalf("FlashFirmware() = %v", err)
+	}
+	if len(stages) != 2 || FlashPrepare != stages[0] || FlashWrite != stages[1] {
+		t.Errorf("этапы %v", stages)
+	}
+	// работающая прошивка не затронута
+	if _, bank := ft.Flash(); 0 != bank {
+		t.Errorf("bank = %d после ошибки записи", bank)
+	}
+
+	version, err := dev.FlashFirmware(img, FlashOptions{Resume: fe.Written})
+	if nil != err || "1.0.1" != version {
+		t.Fatalf("FlashFirmware(Resume) = %q, %v", version, err)
+	}
+	words, bank := ft.Flash()
+	if 1 != bank || 3 != len(words) {
+		t.Errorf("Flash() = %X, %d", words, bank)
+	}
+	for _, w := range img.Words() {
+		if words[w.Addr] != w.Word {
+			t.Errorf("слово %08X = %08X, want %08X", w.Addr, words[w.Addr], w.Word)
+		}
+	}
+}
+
+func TestFlashFirmwareUnchanged(t *testing.T) {
+	img, err := ParseBinary([]byte{1, 2, 3, 4}, FirmwareMinAddr)
+	if nil != err {
+		t.Fatal(err)
+	}
+	dev := new(FreqDevice)
+	dev.OpenTransport(&staleBankTransport{NewFreqSimulator()})
+	version, err := dev.FlashFirmware(img, FlashOptions{})
+	var fe *FlashError
+	if !errors.Is(err, fwErrorVersion) || !errors.As(err, &fe) || FlashVerify != fe.Stage {
+		t.Errorf("FlashFirmware() = %v, want %v", err, fwErrorVersion)
+	}
+	if "1.0.0" != version {
+		t.Errorf("version = %q, want 1.0.0", version)
+	}
+}
+
+func TestUpdateWithoutDevice(t *testing.T) {
+	var nilAnalog *AnalogDevice
+	var nilBinary *BinaryDevice
+	var nilFreq *FreqDevice
+	tests := []struct {
+		name string
+		dev  firmwareUpdater
+		want error
+	}{
+		{"ФАС-3 nil", nilAnalog, ErrNotInitialized},
+		{"ФДС-3 nil", nilBinary, ErrNotInitialized},
+		{"ФЧС-3 nil", nilFreq, ErrNotInitialized},
+		{"ФАС-3 не открыта", new(AnalogDevice), ErrNotConnected},
+		{"ФДС-3 не открыта", new(BinaryDevice), ErrNotConnected},
+		{"ФЧС-3 не открыта", new(FreqDevice), ErrNotConnected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if err := tt.dev.PrepareUpdateCtx(ctx); !errors.Is(err, tt.want) {
+				t.Errorf("PrepareUpdate() = %v, want %v", err, tt.want)
+			}
+			if err := tt.dev.WriteUpdateCtx(ctx, FirmwareMinAddr, 0); !errors.Is(err, tt.want) {
+				t.Errorf("WriteUpdate() = %v, want %v", err, tt.want)
+			}
+			if _, _, _, err := tt.dev.(interface {
+				GetVersionCtx(ctx context.Context) (major, minor, patch uint32, err error)
+			}).GetVersionCtx(ctx); !errors.Is(err, tt.want) {
+				t.Errorf("GetVersion() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package ipk
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+var simErrorNotPrepared = newError(ErrInvalidParam, `Запись прошивки без подготовки`, `Firmware write without prepare`)
+
+// simFirmware модель прошивки платы ФПС-3 на основе STM32 для симуляторов:
+// ответ на запрос версии и команды обновления по запросу 0xB4 (см. UpdateIPK).
+// Методы вызываются с захваченным mutex симулятора.
+type simFirmware struct {
+	major, minor, patch uint32
+	flash               map[uint32]uint32
+	updating            bool
+	bank                int
+	other               [3]uint32 // версия прошивки в другом банке памяти, 0.0.0 - банк стёрт или пуст
+}
+
+// legacy показывает, что симулируется старая ревизия платы (версия 0.0.0)
+func (fw *simFirmware) legacy() bool {
+	return 0 == fw.major && 0 == fw.minor && 0 == fw.patch
+}
+
+// version записывает в data ответ на запрос версии. Старая ревизия платы
+// не передаёт сигнатуру версии.
+func (fw *simFirmware) version(data []byte) (n int) {
+	buf := make([]byte, debugADCsize)
+	if !fw.legacy() {
+		binary.BigEndian.PutUint32(buf[0:], verSignature)
+		binary.BigEndian.PutUint32(buf[4:], fw.major)
+		binary.BigEndian.PutUint32(buf[8:], fw.minor)
+		binary.BigEndian.PutUint32(buf[12:], fw.patch)
+	}
+	return copy(data, buf)
+}
+
+// update выполняет команду обновления прошивки (см. UpdateIPK).
+func (fw *simFirmware) update(data []byte) (err error) {
+	if len(data) < 9 {
+		err = fmt.Errorf("simFirmware.update():%w", simErrorShortData)
+		return
+	}
+	code := data[0]
+	addr := binary.BigEndian.Uint32(data[1:])
+	word := binary.BigEndian.Uint32(data[5:])
+
+	switch code {
+	case ipkUpdatePrepare:
+		fw.flash = make(map[uint32]uint32)
+		fw.updating = true
+		fw.other = [3]uint32{}
+	case ipkUpdateWrite:
+		if !fw.updating {
+			err = fmt.Errorf("simFirmware.update():%w", simErrorNotPrepared)
+			return
+		}
+		fw.flash[addr] = word
+	case ipkUpdateFinish:
+		// версию записанной прошивки симулятор не знает: считаем её следующей по номеру патча
+		if fw.updating && len(fw.flash) > 0 {
+			fw.other = [3]uint32{fw.major, fw.minor, fw.patch + 1}
+		}
+		fw.updating = false
+	case ipkUpdateReboot:
+		// с пустого банка плата не загрузится и останется на прежней прошивке
+		if [3]uint32{} != fw.other {
+			fw.bank ^= 1
+			current := [3]uint32{fw.major, fw.minor, fw.patch}
+			fw.major, fw.minor, fw.patch = fw.other[0], fw.other[1], fw.other[2]
+			fw.other = current
+		}
+	default:
+		err = fmt.Errorf("simFirmware.update():%w", simErrorUnknownRequest)
+	}
+	return
+}
+
+// words возвращает копию слов прошивки, записанных последним обновлением
+func (fw *simFirmware) words() (words map[uint32]uint32) {
+	words = make(map[uint32]uint32, len(fw.flash))
+	for addr, word := range fw.flash {
+		words[addr] = word
+	}
+	return
+}
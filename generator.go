@@ -0,0 +1,248 @@
+package ipk
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var genErrorNoOutput = newError(ErrNotInitialized, `генератор не связан с каналом ЦАП`, `generator has no DAC output`)
+
+// DefaultGeneratorInterval период обновления значения генератора по умолчанию
+const DefaultGeneratorInterval = 50 * time.Millisecond
+
+// Generator выводит форму Waveform на канал ЦАП ФАС-3, обновляя значение
+// с периодом Interval. Создаётся функциями NewDACGenerator (значения в мА)
+// и NewPressureGenerator (значения в единицах датчика давления: кПа или кгс/см²).
+// Воспроизведение управляется методами Start, Stop, Pause и Resume.
+type Generator struct {
+	Interval time.Duration   // период обновления значения
+	OnError  func(err error) // вызывается при ошибке вывода значения, воспроизведение продолжается
+
+	waveform Waveform
+	set      func(ctx context.Context, val float64) error
+	now      func() time.Time
+
+	mutex   sync.Mutex
+	elapsed time.Duration // время воспроизведения без учёта пауз до момента started
+	started time.Time     // момент запуска или снятия с паузы
+	paused  bool
+	value   float64
+	err     error
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// NewDACGenerator создаёт генератор, выводящий форму w на канал ЦАП dac. Значения формы - в мА.
+func NewDACGenerator(dac *DAC, w Waveform) *Generator {
+	g := &Generator{Interval: DefaultGeneratorInterval, waveform: w, now: time.Now}
+	if nil != dac {
+		g.set = dac.SetMilliAmperCtx
+	}
+	return g
+}
+
+// NewPressureGenerator создаёт генератор, выводящий форму w на датчик давления pres.
+// Значения формы - в единицах, заданных при инициализации pres (кПа или кгс/см²).
+func NewPressureGenerator(pres *PressureOutput, w Waveform) *Generator {
+	g := &Generator{Interval: DefaultGeneratorInterval, waveform: w, now: time.Now}
+	if nil != pres {
+		g.set = pres.SetCtx
+	}
+	return g
+}
+
+// Start запускает воспроизведение формы с начала в отдельной горутине.
+// Если генератор уже запущен, воспроизведение начинается заново.
+func (g *Generator) Start() (err error) {
+	if nil == g || nil == g.set || nil == g.waveform {
+		err = fmt.Errorf("Generator.Start():%w", genErrorNoOutput)
+		return
+	}
+	g.Stop()
+
+	interval := g.Interval
+	if interval <= 0 {
+		interval = DefaultGeneratorInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	g.mutex.Lock()
+	g.elapsed = 0
+	g.started = g.now()
+	g.paused = false
+	g.err = nil
+	g.cancel = cancel
+	g.done = done
+	g.mutex.Unlock()
+
+	go g.run(ctx, interval, done)
+	return
+}
+
+// Stop останавливает воспроизведение. На выходе остаётся последнее выведенное значение.
+func (g *Generator) Stop() {
+	if nil == g {
+		return
+	}
+	g.mutex.Lock()
+	cancel, done := g.cancel, g.done
+	g.cancel = nil
+	g.mutex.Unlock()
+	if nil != cancel {
+		cancel()
+		<-done
+	}
+}
+
+// Pause приостанавливает воспроизведение. Время формы на паузе не идёт.
+func (g *Generator) Pause() {
+	if nil == g {
+		return
+	}
+	g.mutex.Lock()
+	if !g.paused {
+		g.elapsed += g.now().Sub(g.started)
+		g.paused = true
+	}
+	g.mutex.Unlock()
+}
+
+// Resume продолжает воспроизведение после Pause с того же места формы.
+func (g *Generator) Resume() {
+	if nil == g {
+		return
+	}
+	g.mutex.Lock()
+	if g.paused {
+		g.started = g.now()
+		g.paused = false
+	}
+	g.mutex.Unlock()
+}
+
+// Done возвращает канал, который закрывается, когда воспроизведение закончено
+// (форма закончилась или вызван Stop). До первого Start возвращает nil.
+func (g *Generator) Done() <-chan struct{} {
+	if nil == g {
+		return nil
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.done
+}
+
+// Running показывает, идёт ли воспроизведение (в том числе на паузе)
+func (g *Generator) Running() bool {
+	if nil == g {
+		return false
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return nil != g.cancel
+}
+
+// Paused показывает, приостановлено ли воспроизведение
+func (g *Generator) Paused() bool {
+	if nil == g {
+		return false
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.paused
+}
+
+// Elapsed возвращает время воспроизведения формы без учёта пауз
+func (g *Generator) Elapsed() time.Duration {
+	if nil == g {
+		return 0
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.position()
+}
+
+// Value возвращает последнее выведенное значение
+func (g *Generator) Value() float64 {
+	if nil == g {
+		return 0
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.value
+}
+
+// Err возвращает последнюю ошибку вывода значения
+func (g *Generator) Err() error {
+	if nil == g {
+		return nil
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.err
+}
+
+// position возвращает время формы. Вызывается под mutex.
+func (g *Generator) position() time.Duration {
+	if g.paused {
+		return g.elapsed
+	}
+	return g.elapsed + g.now().Sub(g.started)
+}
+
+func (g *Generator) run(ctx context.Context, interval time.Duration, done chan struct{}) {
+	defer func() {
+		g.mutex.Lock()
+		if g.done == done {
+			g.cancel = nil
+		}
+		g.mutex.Unlock()
+		close(done)
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if finished := g.update(ctx); finished {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// update выводит значение формы для текущего момента. Возвращает true, если форма закончилась.
+func (g *Generator) update(ctx context.Context) (finished bool) {
+	g.mutex.Lock()
+	paused := g.paused
+	t := g.position()
+	g.mutex.Unlock()
+	if paused {
+		return
+	}
+
+	val, finished := g.waveform.Value(t)
+	if val < 0 {
+		val = 0
+	}
+	err := g.set(ctx, val)
+	if nil != ctx.Err() {
+		return true
+	}
+
+	g.mutex.Lock()
+	if nil == err {
+		g.value = val
+	}
+	g.err = err
+	g.mutex.Unlock()
+	if nil != err && nil != g.OnError {
+		g.OnError(err)
+	}
+	return
+}
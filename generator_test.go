@@ -0,0 +1,145 @@
+package ipk
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// eventually ждёт выполнения cond, проверяя его каждую миллисекунду в течение секунды
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("не дождались: %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWaveforms(t *testing.T) {
+	const ms = time.Millisecond
+	tests := []struct {
+		name     string
+		w        Waveform
+		at       time.Duration
+		want     float64
+		wantDone bool
+	}{
+		{"Ramp: начало", Ramp{From: 2, To: 10, Duration: time.Second}, 0, 2, false},
+		{"Ramp: середина", Ramp{From: 2, To: 10, Duration: time.Second}, 500 * ms, 6, false},
+		{"Ramp: конец", Ramp{From: 2, To: 10, Duration: time.Second}, time.Second, 10, true},
+		{"Ramp: спад", Ramp{From: 10, To: 0, Duration: time.Second}, 250 * ms, 7.5, false},
+		{"Sine: четверть периода", Sine{Offset: 5, Amplitude: 2, Period: time.Second}, 250 * ms, 7, false},
+		{"Sine: три четверти", Sine{Offset: 5, Amplitude: 2, Period: time.Second}, 750 * ms, 3, false},
+		{"Sine: окончание", Sine{Offset: 5, Amplitude: 2, Period: time.Second, Duration: 250 * ms}, time.Second, 7, true},
+		{"Triangle: вершина", Triangle{Min: 4, Max: 20, Period: time.Second}, 500 * ms, 20, false},
+		{"Triangle: спад", Triangle{Min: 4, Max: 20, Period: time.Second}, 750 * ms, 12, false},
+		{"Triangle: следующий период", Triangle{Min: 4, Max: 20, Period: time.Second}, 1250 * ms, 12, false},
+		{"Steps: вторая ступень", Steps{Steps: []Step{{1, 100 * ms}, {2, 100 * ms}}}, 150 * ms, 2, false},
+		{"Steps: после последней", Steps{Steps: []Step{{1, 100 * ms}, {2, 100 * ms}}}, 300 * ms, 2, true},
+		{"Steps: повтор", Steps{Steps: []Step{{1, 100 * ms}, {2, 100 * ms}}, Repeat: true}, 250 * ms, 1, false},
+		{"Table: ступенька", Table{Points: []TablePoint{{0, 0}, {100 * ms, 10}, {200 * ms, 0}}}, 150 * ms, 10, false},
+		{"Table: интерполяция", Table{Points: []TablePoint{{0, 0}, {100 * ms, 10}, {200 * ms, 0}}, Interpolate: true}, 150 * ms, 5, false},
+		{"Table: конец", Table{Points: []TablePoint{{0, 0}, {100 * ms, 10}, {200 * ms, 4}}}, time.Second, 4, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			val, done := tt.w.Value(tt.at)
+			if math.Abs(val-tt.want) > 1e-9 || tt.wantDone != done {
+				t.Errorf("Value(%v) = %v, %v, want %v, %v", tt.at, val, done, tt.want, tt.wantDone)
+			}
+		})
+	}
+}
+
+func TestGeneratorPlay(t *testing.T) {
+	dev, sim := openAnalogSimulator(t, IDProductANL16bit)
+	var dac DAC
+	if err := dac.Init(dev, DAC3); nil != err {
+		t.Fatal(err)
+	}
+	clock := newFakeClock()
+	g := NewDACGenerator(&dac, Ramp{From: 2, To: 10, Duration: time.Second})
+	g.now = clock.now
+	g.Interval = time.Millisecond
+	if err := g.Start(); nil != err {
+		t.Fatal(err)
+	}
+	defer g.Stop()
+
+	valueIs := func(want float64) func() bool {
+		return func() bool { return want == g.Value() }
+	}
+	eventually(t, "начальное значение 2 мА", valueIs(2))
+	clock.add(500 * time.Millisecond)
+	eventually(t, "6 мА через 0,5 с", valueIs(6))
+
+	// на паузе время формы не идёт
+	g.Pause()
+	clock.add(10 * time.Second)
+	if elapsed := g.Elapsed(); 500*time.Millisecond != elapsed {
+		t.Errorf("Elapsed() на паузе = %v, want 0.5s", elapsed)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if !g.Paused() || !g.Running() || 6 != g.Value() {
+		t.Errorf("на паузе: Paused %v, Running %v, Value %v", g.Paused(), g.Running(), g.Value())
+	}
+	g.Resume()
+	clock.add(250 * time.Millisecond)
+	eventually(t, "8 мА через 0,75 с", valueIs(8))
+
+	// форма закончилась: воспроизведение останавливается само
+	clock.add(time.Second)
+	select {
+	case <-g.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Done() не закрыт после окончания формы")
+	}
+	if g.Running() || 10 != g.Value() || nil != g.Err() {
+		t.Errorf("после окончания: Running %v, Value %v, Err %v", g.Running(), g.Value(), g.Err())
+	}
+	if want := MilliAmperToDAC(10, dac.maxDAC, dac.maxMilliAmper); want != sim.DAC(DAC3) {
+		t.Errorf("sim.DAC() = %04X, want %04X", sim.DAC(DAC3), want)
+	}
+}
+
+func TestGeneratorStop(t *testing.T) {
+	dev, sim := openAnalogSimulator(t, IDProductANL16bit)
+	var dac DAC
+	if err := dac.Init(dev, DAC3); nil != err {
+		t.Fatal(err)
+	}
+	clock := newFakeClock()
+	g := NewDACGenerator(&dac, Triangle{Min: 4, Max: 20, Period: time.Second})
+	g.now = clock.now
+	g.Interval = time.Millisecond
+	if err := g.Start(); nil != err {
+		t.Fatal(err)
+	}
+	clock.add(250 * time.Millisecond)
+	eventually(t, "12 мА через 0,25 с", func() bool { return 12 == g.Value() })
+
+	g.Stop()
+	select {
+	case <-g.Done():
+	default:
+		t.Fatal("Done() не закрыт после Stop")
+	}
+	if g.Running() {
+		t.Error("Running() = true после Stop")
+	}
+	// на выходе остаётся последнее значение, новых записей нет
+	val := sim.DAC(DAC3)
+	clock.add(250 * time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if val != sim.DAC(DAC3) || 12 != g.Value() {
+		t.Errorf("после Stop: sim.DAC() = %04X, Value %v, want %04X, 12", sim.DAC(DAC3), g.Value(), val)
+	}
+	g.Stop() // повторный вызов не блокируется
+
+	if err := NewDACGenerator(nil, Ramp{}).Start(); nil == err {
+		t.Error("Start() без канала ЦАП = nil")
+	}
+}
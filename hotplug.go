@@ -0,0 +1,121 @@
+package ipk
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultSupervisorInterval период проверки подключения плат по умолчанию
+const DefaultSupervisorInterval = 500 * time.Millisecond
+
+// Supervisor следит за подключением плат ФПС-3 (производитель 0x0547).
+// Если плата пропала (например, задели кабель) или обмен с ней завершился ошибкой
+// "устройство отключено", соединение с ней помечается как разорванное и все обращения
+// к ней возвращают ошибку "нет соединения". Плата, переподключенная между двумя
+// проверками, тоже переоткрывается: её старый хэндл недействителен.
+// Когда плата снова появляется, соединение открывается заново и, если
+// RestoreOutputs - true, в плату повторно отправляются последние команды
+// (значения ЦАП, выходы 10/50 В, код ИФ, TURT, частоты, ускорения и направление движения ФЧС-3).
+// Счётчики пути и путь перемещения ФЧС-3 не восстанавливаются, чтобы переподключение
+// не сбрасывало пройденный путь.
+// Платы, закрытые приложением через Close, не переоткрываются.
+type Supervisor struct {
+	Interval       time.Duration                  // период проверки
+	RestoreOutputs bool                           // восстанавливать выходы после переподключения
+	OnChange       func(dev Device, present bool) // вызывается при отключении и подключении платы
+
+	ipk   *IPK
+	mutex sync.Mutex
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// NewSupervisor создаёт наблюдателя за платами ipk
+func NewSupervisor(ipk *IPK) *Supervisor {
+	return &Supervisor{Interval: DefaultSupervisorInterval, ipk: ipk}
+}
+
+// Start запускает наблюдение в отдельной горутине
+func (s *Supervisor) Start() {
+	if nil == s {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if nil != s.stop {
+		return
+	}
+	interval := s.Interval
+	if interval <= 0 {
+		interval = DefaultSupervisorInterval
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.run(interval, s.stop, s.done)
+}
+
+// Stop останавливает наблюдение
+func (s *Supervisor) Stop() {
+	if nil == s {
+		return
+	}
+	s.mutex.Lock()
+	stop, done := s.stop, s.done
+	s.stop, s.done = nil, nil
+	s.mutex.Unlock()
+	if nil != stop {
+		close(stop)
+		<-done
+	}
+}
+
+func (s *Supervisor) run(interval time.Duration, stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.Check()
+		}
+	}
+}
+
+// Check однократно проверяет подключение всех плат. Вызывается периодически после Start,
+// но может вызываться и вручную.
+func (s *Supervisor) Check() {
+	if nil == s || nil == s.ipk {
+		return
+	}
+	if nil != s.ipk.AnalogDev {
+		s.check(s.ipk.AnalogDev, &s.ipk.AnalogDev.usbConnection)
+	}
+	if nil != s.ipk.BinDev {
+		s.check(s.ipk.BinDev, &s.ipk.BinDev.usbConnection)
+	}
+	if nil != s.ipk.FreqDev {
+		s.check(s.ipk.FreqDev, &s.ipk.FreqDev.usbConnection)
+	}
+}
+
+func (s *Supervisor) check(dev Device, c *usbConnection) {
+	switch {
+	case c.connected():
+		if c.stale() {
+			c.detach()
+			s.notify(dev, false)
+		}
+	case c.canReconnect():
+		if c.reconnect(s.RestoreOutputs) {
+			s.notify(dev, true)
+		}
+	}
+}
+
+func (s *Supervisor) notify(dev Device, present bool) {
+	if nil != s.OnChange {
+		s.OnChange(dev, present)
+	}
+}
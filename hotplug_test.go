@@ -0,0 +1,214 @@
+package ipk
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// powerOn имитирует включение питания отключенных плат: выходы сброшены в исходное состояние
+func powerOn(t *testing.T, sims Simulators) {
+	t.Helper()
+	sims.Analog.SetConnected(true)
+	sims.Binary.SetConnected(true)
+	sims.Freq.SetConnected(true)
+	var as analogDeviceData
+	out := []struct {
+		sim     Transport
+		request byte
+		data    []byte
+	}{
+		{sims.Analog, 0xB0, as.toBytes()},
+		{sims.Binary, 0xB0, []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}},
+		{sims.Binary, 0xB1, []byte{IFDisable}},
+		{sims.Binary, 0xB2, []byte{0}},
+		{sims.Freq, 0xB0, (&dataFreq{cmd: 2}).toBytes()},
+		{sims.Freq, 0xB0, (&dataFreq{cmd: 1}).toBytes()},
+		{sims.Freq, 0xB0, (&dataFreq{cmd: 5, motion: 1}).toBytes()},
+	}
+	for _, o := range out {
+		if _, err := o.sim.ControlOut(o.request, o.data, maxDelayUSB); nil != err {
+			t.Fatal(err)
+		}
+	}
+}
+
+// hotplugEvent вызов Supervisor.OnChange
+type hotplugEvent struct {
+	product uint16
+	present bool
+}
+
+func TestSupervisorReconnect(t *testing.T) {
+	tests := []struct {
+		name    string
+		restore bool
+	}{
+		{"с восстановлением выходов", true},
+		{"без восстановления выходов", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			var ipk IPK
+			sims := ipk.OpenSimulators(IDProductANL16bit)
+			defer ipk.CloseAll()
+			sims.Binary.SetClock(clock.now)
+			sims.Freq.SetClock(clock.now)
+
+			s := NewSupervisor(&ipk)
+			s.RestoreOutputs = tt.restore
+			var mutex sync.Mutex
+			var events []hotplugEvent
+			s.OnChange = func(dev Device, present bool) {
+				mutex.Lock()
+				events = append(events, hotplugEvent{dev.GetProductID(), present})
+				mutex.Unlock()
+			}
+			takeEvents := func() (ev []hotplugEvent) {
+				mutex.Lock()
+				ev, events = events, nil
+				mutex.Unlock()
+				return
+			}
+			check := func(err error) {
+				t.Helper()
+				if nil != err {
+					t.Fatal(err)
+				}
+			}
+
+			var dac DAC
+			check(dac.Init(ipk.AnalogDev, DAC3))
+			check(dac.SetMilliAmper(12))
+			check(ipk.AnalogDev.SetFreq(FREQ2, AnlFreq1kHz))
+			check(ipk.BinDev.Set10V(1, true))
+			check(ipk.BinDev.Set50V(5, true))
+			check(ipk.BinDev.SetIF(IFYellow16))
+			check(ipk.BinDev.SetTURT(true))
+			var sp Speed
+			check(sp.Init(ipk.FreqDev, 42, 1350))
+			check(sp.SetMotion(MotionBackwards))
+			check(ipk.FreqDev.SetHz(1000, 800))
+			check(ipk.FreqDev.SetDeltaHz(10, 20))
+			clock.add(time.Second)
+			board := sims.Freq.Motion()
+			wantDAC := sims.Analog.DAC(DAC3)
+			wantFreq := sims.Freq.freqData()
+			wantWay, _ := sims.Freq.WayCount()
+
+			s.Check()
+			if ev := takeEvents(); 0 != len(ev) {
+				t.Fatalf("события без отключения: %v", ev)
+			}
+
+			// отключение
+			sims.Analog.SetConnected(false)
+			sims.Binary.SetConnected(false)
+			sims.Freq.SetConnected(false)
+			s.Check()
+			want := []hotplugEvent{{IDProductANL16bit, false}, {IDProductBIN, false}, {IDProductFRQ, false}}
+			if ev := takeEvents(); !reflect.DeepEqual(ev, want) {
+				t.Fatalf("OnChange при отключении: %v, want %v", ev, want)
+			}
+			if err := dac.SetMilliAmper(5); !errors.Is(err, ErrNotConnected) {
+				t.Errorf("SetMilliAmper() после отключения = %v, want %v", err, ErrNotConnected)
+			}
+			if ipk.BinDev.Active() {
+				t.Error("BinDev.Active() = true после отключения")
+			}
+			s.Check()
+			if ev := takeEvents(); 0 != len(ev) {
+				t.Fatalf("повторные события отключения: %v", ev)
+			}
+
+			// подключение: платы включаются со сброшенными выходами
+			powerOn(t, sims)
+			s.Check()
+			want = []hotplugEvent{{IDProductANL16bit, true}, {IDProductBIN, true}, {IDProductFRQ, true}}
+			if ev := takeEvents(); !reflect.DeepEqual(ev, want) {
+				t.Fatalf("OnChange при подключении: %v, want %v", ev, want)
+			}
+			s.Check()
+			if ev := takeEvents(); 0 != len(ev) {
+				t.Fatalf("повторные события подключения: %v", ev)
+			}
+
+			type state struct {
+				dac    uint16
+				freq   uint16
+				out10V uint8
+				out50V uint64
+				ifCode uint8
+				turt   bool
+				hz1    float64
+				delta  int32
+				motion uint8
+			}
+			hz1, _ := sims.Freq.Hz()
+			got := state{
+				dac:    sims.Analog.DAC(DAC3),
+				freq:   sims.Analog.Freq(FREQ2),
+				out10V: sims.Binary.Output10V(),
+				out50V: sims.Binary.Output50V() &^ (1 << binIF50V),
+				ifCode: sims.Binary.IF(),
+				turt:   sims.Binary.TURT(),
+				hz1:    math.Abs(math.Round(hz1)),
+				delta:  sims.Freq.freqData().freq2delta,
+				motion: sims.Freq.Motion(),
+			}
+			reset := state{motion: 1}
+			// восстанавливается последняя команда, а не частота, до которой дошёл разгон
+			restored := state{wantDAC, AnlFreq1kHz, 1 << 1, 1 << 5, IFYellow16, true, 1000, wantFreq.freq2delta, board}
+			if wantState := map[bool]state{true: restored, false: reset}[tt.restore]; wantState != got {
+				t.Errorf("состояние плат %+v, want %+v", got, wantState)
+			}
+			// счётчики пути не сбрасываются
+			if way1, _ := sims.Freq.WayCount(); wantWay != way1 {
+				t.Errorf("WayCount() = %d, want %d", way1, wantWay)
+			}
+			// после подключения платы снова работают
+			if ma, err := dac.GetMilliAmper(); nil != err || (tt.restore && math.Abs(ma-12) > 0.01) {
+				t.Errorf("GetMilliAmper() = %v, %v", ma, err)
+			}
+		})
+	}
+}
+
+func TestSupervisorReplugged(t *testing.T) {
+	// кабель переподключили между двумя проверками: обмен завершился ошибкой "устройство отключено",
+	// поэтому соединение открывается заново, хотя плата уже на месте
+	var ipk IPK
+	sims := ipk.OpenSimulators(IDProductANL16bit)
+	defer ipk.CloseAll()
+	s := NewSupervisor(&ipk)
+	s.RestoreOutputs = true
+	var events []hotplugEvent
+	s.OnChange = func(dev Device, present bool) {
+		events = append(events, hotplugEvent{dev.GetProductID(), present})
+	}
+	if err := ipk.BinDev.SetTURT(true); nil != err {
+		t.Fatal(err)
+	}
+	ipk.BinDev.SetRetryPolicy(RetryPolicy{MaxAttempts: 1})
+
+	sims.Binary.SetConnected(false)
+	if err := ipk.BinDev.Set10V(0, true); !errors.Is(err, ErrNotConnected) {
+		t.Fatalf("Set10V() = %v, want %v", err, ErrNotConnected)
+	}
+	sims.Binary.SetConnected(true)
+	powerOn(t, sims)
+
+	s.Check()
+	s.Check()
+	want := []hotplugEvent{{IDProductBIN, false}, {IDProductBIN, true}}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("OnChange: %v, want %v", events, want)
+	}
+	if !sims.Binary.TURT() {
+		t.Error("TURT не восстановлен")
+	}
+}
@@ -1,14 +1,31 @@
 package ipk
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
+
+// IPK все три устройства в одной структуре для удобства
+type IPK struct {
+	AnalogDev *AnalogDevice
+	BinDev    *BinaryDevice
+	FreqDev   *FreqDevice
+}
 
 //Device - интерфейс устройств, составных частей ФПС-3
 type Device interface {
-	Open(ok bool)
+	Open() (ok bool)
 	Close()
 	Active() bool
+	GetProductID() uint16
 }
 
+var (
+	_ Device = (*AnalogDevice)(nil)
+	_ Device = (*BinaryDevice)(nil)
+	_ Device = (*FreqDevice)(nil)
+)
+
 //UBS-идентификаторы оборудования ИПК-3
 const (
 	IDVendorElmeh     = uint16(0x0547)
@@ -20,7 +37,272 @@ const (
 
 //Максимально допустимое время реакции во время обращения к оборудованию по USB.
 const maxDelayUSB = 100 * time.Millisecond
-const errUsbTimeout = `Слишком большое время отклика по USB`
 
 const VendorRequestInput = 0xC0
 const VendorRequestOutput = 0x40
+
+// ProductName возвращает название платы ФПС-3 по идентификатору продукта
+func ProductName(product uint16) string {
+	switch product {
+	case IDProductANL12bit:
+		return "ФАС-3 (12 бит)"
+	case IDProductANL16bit:
+		return "ФАС-3 (16 бит)"
+	case IDProductBIN:
+		return "ФДС-3"
+	case IDProductFRQ:
+		return "ФЧС-3"
+	}
+	return fmt.Sprintf("неизвестное устройство %04X", product)
+}
+
+// knownProduct показывает, является ли устройство платой ФПС-3
+func knownProduct(product uint16) bool {
+	switch product {
+	case IDProductANL12bit, IDProductANL16bit, IDProductBIN, IDProductFRQ:
+		return true
+	}
+	return false
+}
+
+// DeviceInfo описание платы ФПС-3, подключенной к компьютеру (см. Enumerate)
+type DeviceInfo struct {
+	ProductID uint16 // идентификатор продукта (IDProductANL12bit, IDProductBIN и т.д.)
+	Serial    string // серийный номер USB, если плата его сообщает
+	Bus       int    // номер шины USB
+	Port      int    // номер порта USB на ближайшем концентраторе
+	Address   int    // адрес устройства на шине USB (меняется при каждом подключении)
+	// Path путь к устройству: в Linux - цепочка портов "шина-порт.порт..." (как в /sys/bus/usb/devices),
+	// в Windows - \\.\ezusb-N
+	Path string
+}
+
+// Is16bit показывает, что плата - ФАС-3 с 16-битными ЦАП
+func (info DeviceInfo) Is16bit() bool {
+	return IDProductANL16bit == info.ProductID
+}
+
+func (info DeviceInfo) String() string {
+	s := ProductName(info.ProductID) + " " + info.Path
+	if "" != info.Serial {
+		s += " S/N " + info.Serial
+	}
+	return s
+}
+
+// OpenBy соединяется с платами, перечисленными в devices (например, результат Enumerate,
+// отфильтрованный по серийным номерам или пути одной стойки).
+// Платы, которых нет в списке, не открываются. Возвращает true, если удалось открыть все
+// платы из списка.
+func (ipk *IPK) OpenBy(devices []DeviceInfo) (ok bool) {
+	if nil == ipk {
+		return
+	}
+	ok = true
+	for _, info := range devices {
+		switch info.ProductID {
+		case IDProductANL12bit, IDProductANL16bit:
+			if nil == ipk.AnalogDev {
+				ipk.AnalogDev = new(AnalogDevice)
+			}
+			ok = ipk.AnalogDev.OpenBy(info) && ok
+		case IDProductBIN:
+			if nil == ipk.BinDev {
+				ipk.BinDev = new(BinaryDevice)
+			}
+			ok = ipk.BinDev.OpenBy(info) && ok
+		case IDProductFRQ:
+			if nil == ipk.FreqDev {
+				ipk.FreqDev = new(FreqDevice)
+			}
+			ok = ipk.FreqDev.OpenBy(info) && ok
+		default:
+			ok = false
+		}
+	}
+	return
+}
+
+// Simulators симуляторы всех трёх плат ФПС-3 (см. IPK.OpenSimulators)
+type Simulators struct {
+	Analog *AnalogSimulator
+	Binary *BinarySimulator
+	Freq   *FreqSimulator
+}
+
+// OpenSimulators соединяет ipk с симуляторами всех трёх плат вместо оборудования
+// (для обучения, отладки и тестов). idProductANL - вариант ФАС-3 (IDProductANL12bit
+// или IDProductANL16bit). Через возвращаемые симуляторы можно задавать двоичные входы,
+// данные АЦП и т.д.
+func (ipk *IPK) OpenSimulators(idProductANL uint16) (sims Simulators) {
+	if nil == ipk {
+		return
+	}
+	sims.Analog = NewAnalogSimulator(idProductANL)
+	sims.Binary = NewBinarySimulator()
+	sims.Freq = NewFreqSimulator()
+	if nil == ipk.AnalogDev {
+		ipk.AnalogDev = new(AnalogDevice)
+	}
+	if nil == ipk.BinDev {
+		ipk.BinDev = new(BinaryDevice)
+	}
+	if nil == ipk.FreqDev {
+		ipk.FreqDev = new(FreqDevice)
+	}
+	if !ipk.AnalogDev.OpenTransport(sims.Analog, idProductANL) {
+		ipk.AnalogDev = nil
+		sims.Analog = nil
+	}
+	ipk.BinDev.OpenTransport(sims.Binary)
+	ipk.FreqDev.OpenTransport(sims.Freq)
+	// симуляторы, как и платы, сообщают версию прошивки
+	ipk.AnalogDev.DetectCapabilities()
+	ipk.BinDev.DetectCapabilities()
+	ipk.FreqDev.DetectCapabilities()
+	return
+}
+
+// versioner реализуется платами, которые умеют сообщать версию прошивки
+type versioner interface {
+	GetVersionString() (version string, err error)
+}
+
+type retryStatser interface {
+	GetRetryStats() RetryStats
+}
+
+type capabler interface {
+	Capabilities() Capabilities
+}
+
+// BoardStatus состояние одной платы ФПС-3 (см. IPK.Status)
+type BoardStatus struct {
+	ProductID uint16     // идентификатор продукта, 0 если плата не открыта
+	Name      string     // название платы
+	Present   bool       // плата открыта и подключена
+	Version   string     // версия прошивки, если плата её сообщает
+	Err       error      // ошибка при запросе версии
+	Retry     RetryStats // счётчики обмена с платой
+}
+
+// Status состояние всех трёх плат ФПС-3
+type Status struct {
+	Analog BoardStatus
+	Binary BoardStatus
+	Freq   BoardStatus
+}
+
+// OpenAll соединяется со всеми платами ФПС-3. Отсутствующие платы пропускаются,
+// соответствующие поля IPK становятся nil.
+// Возвращает true, если удалось открыть хотя бы одну плату.
+func (ipk *IPK) OpenAll() (ok bool) {
+	if nil == ipk {
+		return
+	}
+	if nil == ipk.AnalogDev {
+		ipk.AnalogDev = new(AnalogDevice)
+	}
+	if nil == ipk.BinDev {
+		ipk.BinDev = new(BinaryDevice)
+	}
+	if nil == ipk.FreqDev {
+		ipk.FreqDev = new(FreqDevice)
+	}
+
+	if ipk.AnalogDev.opened() || ipk.AnalogDev.Open() {
+		ok = true
+	} else {
+		ipk.AnalogDev = nil
+	}
+	if ipk.BinDev.opened() || ipk.BinDev.Open() {
+		ok = true
+	} else {
+		ipk.BinDev = nil
+	}
+	if ipk.FreqDev.opened() || ipk.FreqDev.Open() {
+		ok = true
+	} else {
+		ipk.FreqDev = nil
+	}
+	return
+}
+
+// CloseAll закрывает соединение со всеми платами ФПС-3
+func (ipk *IPK) CloseAll() {
+	if nil == ipk {
+		return
+	}
+	// закрываем и платы, соединение с которыми разорвано (см. Supervisor)
+	if nil != ipk.AnalogDev {
+		ipk.AnalogDev.Close()
+	}
+	if nil != ipk.BinDev {
+		ipk.BinDev.Close()
+	}
+	if nil != ipk.FreqDev {
+		ipk.FreqDev.Close()
+	}
+}
+
+// SetRetryPolicy задаёт политику повторных попыток обмена для всех плат ФПС-3.
+// Действует на платы, уже созданные в ipk (например, после OpenAll).
+func (ipk *IPK) SetRetryPolicy(p RetryPolicy) {
+	if nil == ipk {
+		return
+	}
+	ipk.AnalogDev.SetRetryPolicy(p)
+	ipk.BinDev.SetRetryPolicy(p)
+	ipk.FreqDev.SetRetryPolicy(p)
+}
+
+// Devices возвращает открытые платы ФПС-3 в виде общего интерфейса Device
+func (ipk *IPK) Devices() (devices []Device) {
+	if nil == ipk {
+		return
+	}
+	if ipk.AnalogDev.opened() {
+		devices = append(devices, ipk.AnalogDev)
+	}
+	if ipk.BinDev.opened() {
+		devices = append(devices, ipk.BinDev)
+	}
+	if ipk.FreqDev.opened() {
+		devices = append(devices, ipk.FreqDev)
+	}
+	return
+}
+
+// Status возвращает отчёт о наличии плат ФПС-3 и версиях их прошивок
+func (ipk *IPK) Status() (st Status) {
+	if nil == ipk {
+		return
+	}
+	st.Analog = boardStatus(ipk.AnalogDev, ipk.AnalogDev.opened())
+	st.Binary = boardStatus(ipk.BinDev, ipk.BinDev.opened())
+	st.Freq = boardStatus(ipk.FreqDev, ipk.FreqDev.opened())
+	return
+}
+
+func boardStatus(dev Device, opened bool) (bs BoardStatus) {
+	if !opened {
+		return
+	}
+	bs.ProductID = dev.GetProductID()
+	bs.Name = ProductName(bs.ProductID)
+	bs.Present = dev.Active()
+	if rs, ok := dev.(retryStatser); ok {
+		bs.Retry = rs.GetRetryStats()
+	}
+	// у старой ревизии платы версия не меняется и известна с момента открытия
+	if c, ok := dev.(capabler); ok {
+		if caps := c.Capabilities(); caps.Detected && !caps.VersionQuery {
+			bs.Version = caps.Version
+			return
+		}
+	}
+	if v, ok := dev.(versioner); ok && bs.Present {
+		bs.Version, bs.Err = v.GetVersionString()
+	}
+	return
+}
@@ -1,16 +1,15 @@
 package ipk
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
 	"github.com/gotmc/libusb"
 )
 
-// IPK все три устройства в одной структуре для удобства
-type IPK struct {
-	AnalogDev *AnalogDevice
-	BinDev    *BinaryDevice
-	FreqDev   *FreqDevice
-}
-
 /*
 не используем структуру, потому что неизвестно как Go упакует её в памяти
 type DeviceDescriptor struct {
@@ -48,3 +47,92 @@ func USBOpen(product uint16) (handle *libusb.DeviceHandle, ok bool) {
 
 	return
 }
+
+// Enumerate возвращает список плат ФПС-3, подключенных к компьютеру.
+// Серийный номер читается, если плату удалось открыть.
+func Enumerate() (devices []DeviceInfo, err error) {
+	ctx, err := libusb.NewContext()
+	if err != nil {
+		return
+	}
+	defer ctx.Close()
+
+	list, err := ctx.GetDeviceList()
+	if err != nil {
+		return
+	}
+
+	for _, device := range list {
+		info, desc, ok := deviceInfo(device)
+		if !ok {
+			continue
+		}
+		if 0 != desc.SerialNumberIndex {
+			if handle, err := device.Open(); err == nil {
+				info.Serial = deviceSerial(handle, desc)
+				handle.Close()
+			}
+		}
+		devices = append(devices, info)
+	}
+	return
+}
+
+// deviceInfo заполняет описание платы ФПС-3, кроме серийного номера (для его чтения
+// плату нужно открыть, см. deviceSerial). Возвращает false, если это другое устройство.
+func deviceInfo(device *libusb.Device) (info DeviceInfo, desc *libusb.DeviceDescriptor, ok bool) {
+	desc, err := device.GetDeviceDescriptor()
+	if err != nil || IDVendorElmeh != desc.VendorID || !knownProduct(desc.ProductID) {
+		return
+	}
+	info.ProductID = desc.ProductID
+	info.Bus, _ = device.GetBusNumber()
+	info.Port, _ = device.GetPortNumber()
+	info.Address, _ = device.GetDeviceAddress()
+	info.Path = usbPortPath(info.Bus, info.Address)
+	ok = true
+	return
+}
+
+// deviceSerial читает серийный номер открытой платы, "" если плата его не сообщает
+func deviceSerial(handle *libusb.DeviceHandle, desc *libusb.DeviceDescriptor) (serial string) {
+	if 0 != desc.SerialNumberIndex {
+		serial, _ = handle.GetStringDescriptorASCII(desc.SerialNumberIndex)
+	}
+	return
+}
+
+// каталог sysfs, в котором ядро Linux создаёт по подкаталогу на каждое устройство USB
+const sysfsUSBDevices = "/sys/bus/usb/devices"
+
+// usbPortPath возвращает путь к устройству с адресом address на шине bus - цепочку портов
+// от корневого концентратора, как её называет ядро (например, 1-1.4.2). В отличие от
+// номера порта, цепочка уникальна и для плат за разными концентраторами, а в отличие от
+// адреса не меняется при переподключении платы в тот же разъём.
+// Если sysfs недоступна, возвращается "шина:адрес".
+func usbPortPath(bus, address int) string {
+	dirs, _ := filepath.Glob(filepath.Join(sysfsUSBDevices, "[0-9]*-*"))
+	for _, dir := range dirs {
+		name := filepath.Base(dir)
+		if strings.Contains(name, ":") { // интерфейсы устройства
+			continue
+		}
+		if sysfsInt(dir, "busnum") == bus && sysfsInt(dir, "devnum") == address {
+			return name
+		}
+	}
+	return fmt.Sprintf("%d:%d", bus, address)
+}
+
+// sysfsInt читает целое число из файла name каталога устройства dir, -1 при ошибке
+func sysfsInt(dir, name string) int {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return -1
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return -1
+	}
+	return v
+}
@@ -6,13 +6,6 @@ import (
 	"golang.org/x/sys/windows"
 )
 
-//IPK все три устройства в одной структуре для удобства
-type IPK struct {
-	AnalogDev *AnalogDevice
-	BinDev    *BinaryDevice
-	FreqDev   *FreqDevice
-}
-
 /*
 не используем структуру, потому что неизвестно как Go упакует её в памяти
 type DeviceDescriptor struct {
@@ -140,3 +133,38 @@ func MakeVendorOrClassRequestControlStruct(direction, requestType, recepient, re
 	buf[4] = request
 	return buf
 }
+
+// Enumerate возвращает список плат ФПС-3, подключенных к компьютеру.
+// Драйвер EZ-USB не сообщает серийный номер и номер порта, поэтому плата
+// определяется только путём \\.\ezusb-N.
+func Enumerate() (devices []DeviceInfo, err error) {
+	for i := 0; i < 10; i++ {
+		ename := fmt.Sprintf("%s%d", ezprefix, i)
+		handle, ok := openEZUSB(ename)
+		if !ok {
+			continue
+		}
+		vendorID, productID := GetVendorProduct(handle)
+		windows.CloseHandle(handle)
+		if IDVendorElmeh == vendorID && knownProduct(productID) {
+			devices = append(devices, DeviceInfo{ProductID: productID, Path: ename})
+		}
+	}
+	return
+}
+
+// openEZUSB открывает устройство EZ-USB по имени \\.\ezusb-N
+func openEZUSB(ename string) (handle windows.Handle, ok bool) {
+	ezusbname, err := windows.UTF16PtrFromString(ename)
+	if err != nil {
+		return
+	}
+	handle, err = windows.CreateFile(ezusbname,
+		windows.GENERIC_WRITE,
+		windows.FILE_SHARE_WRITE,
+		nil,
+		windows.OPEN_EXISTING,
+		windows.FILE_ATTRIBUTE_NORMAL, 0)
+	ok = (err == nil)
+	return
+}
@@ -0,0 +1,348 @@
+package ipkrpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/amdf/ipk"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// DefaultNetworkTimeout время на передачу запроса по сети по умолчанию (см. Client.NetworkTimeout)
+const DefaultNetworkTimeout = time.Second
+
+// Client клиент сервиса gRPC плат ФПС-3
+type Client struct {
+	// NetworkTimeout добавляется ко времени ожидания обмена с платой в RemoteTransport
+	NetworkTimeout time.Duration
+
+	conn *grpc.ClientConn
+}
+
+// Dial соединяется с сервером target (например, "bench1:9090").
+// Если opts не заданы, соединение устанавливается без TLS.
+func Dial(target string, opts ...grpc.DialOption) (c *Client, err error) {
+	if 0 == len(opts) {
+		opts = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	}
+	conn, err := grpc.NewClient(target, opts...)
+	if nil != err {
+		return
+	}
+	c = NewClient(conn)
+	return
+}
+
+// NewClient создаёт клиент для уже установленного соединения conn
+func NewClient(conn *grpc.ClientConn) *Client {
+	return &Client{NetworkTimeout: DefaultNetworkTimeout, conn: conn}
+}
+
+// Close закрывает соединение с сервером
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) invoke(ctx context.Context, method string, req, resp interface{}) error {
+	return fromStatus(c.conn.Invoke(ctx, "/"+ServiceName+"/"+method, req, resp, grpc.CallContentSubtype(codecName)))
+}
+
+// watchStream вызывает fn для каждого сообщения потока method, пока ctx не будет отменён
+// или не произойдёт ошибка. Отмена ctx не считается ошибкой.
+func watchStream[Resp any](c *Client, ctx context.Context, method string, fn func(resp *Resp)) (err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	desc := &grpc.StreamDesc{StreamName: method, ServerStreams: true}
+	stream, err := c.conn.NewStream(ctx, desc, "/"+ServiceName+"/"+method, grpc.CallContentSubtype(codecName))
+	if nil == err {
+		err = stream.SendMsg(&Empty{})
+	}
+	if nil == err {
+		err = stream.CloseSend()
+	}
+	for nil == err {
+		resp := new(Resp)
+		if err = stream.RecvMsg(resp); nil == err {
+			fn(resp)
+		}
+	}
+	if nil != ctx.Err() {
+		return nil
+	}
+	return fromStatus(err)
+}
+
+///////////////////////////////////////////////////////////////
+
+// Boards возвращает платы, открытые на сервере
+func (c *Client) Boards(ctx context.Context) (boards []Board, err error) {
+	var resp BoardsReply
+	err = c.invoke(ctx, "Boards", &Empty{}, &resp)
+	boards = resp.Boards
+	return
+}
+
+// Transport возвращает транспорт, передающий обмен с платой product через сервер
+func (c *Client) Transport(product uint16) *RemoteTransport {
+	return &RemoteTransport{client: c, product: product}
+}
+
+// Open соединяет платы dev со всеми платами, открытыми на сервере, через RemoteTransport.
+// После этого с удалённой стойкой можно работать обычными функциями библиотеки.
+// Чтение-изменение-запись выполняется при этом двумя вызовами сервера, поэтому так
+// с удалённой стойкой может работать только один клиент (см. описание пакета).
+// Возможности плат (см. ipk.Capabilities) уточняются по версиям их прошивок.
+// Возвращает true, если на сервере открыта хотя бы одна плата.
+func (c *Client) Open(ctx context.Context, dev *ipk.IPK) (ok bool, err error) {
+	boards, err := c.Boards(ctx)
+	if nil != err {
+		return
+	}
+	for _, b := range boards {
+		switch b.Product {
+		case ipk.IDProductANL12bit, ipk.IDProductANL16bit:
+			if nil == dev.AnalogDev {
+				dev.AnalogDev = new(ipk.AnalogDevice)
+			}
+			if dev.AnalogDev.OpenTransport(c.Transport(b.Product), b.Product) {
+				dev.AnalogDev.DetectCapabilitiesCtx(ctx)
+				ok = true
+			}
+		case ipk.IDProductBIN:
+			if nil == dev.BinDev {
+				dev.BinDev = new(ipk.BinaryDevice)
+			}
+			if dev.BinDev.OpenTransport(c.Transport(b.Product)) {
+				dev.BinDev.DetectCapabilitiesCtx(ctx)
+				ok = true
+			}
+		case ipk.IDProductFRQ:
+			if nil == dev.FreqDev {
+				dev.FreqDev = new(ipk.FreqDevice)
+			}
+			if dev.FreqDev.OpenTransport(c.Transport(b.Product)) {
+				dev.FreqDev.DetectCapabilitiesCtx(ctx)
+				ok = true
+			}
+		}
+	}
+	return
+}
+
+///////////////////////////////////////////////////////////////
+
+// SetDAC устанавливает значение канала ЦАП ch (от ipk.DAC1 до ipk.DAC14) в мА
+func (c *Client) SetDAC(ctx context.Context, ch uint8, milliAmper float64) (resp DACReply, err error) {
+	err = c.invoke(ctx, "SetDAC", &DACRequest{Channel: ch, MilliAmper: milliAmper}, &resp)
+	return
+}
+
+// GetDAC возвращает значение канала ЦАП ch в мА
+func (c *Client) GetDAC(ctx context.Context, ch uint8) (resp DACReply, err error) {
+	err = c.invoke(ctx, "GetDAC", &ChannelRequest{Channel: ch}, &resp)
+	return
+}
+
+// SetPressure устанавливает давление value на канале ЦАП ch.
+// unit - ipk.DACKiloPascal или ipk.DACAtmosphere, max - максимальное значение шкалы датчика.
+func (c *Client) SetPressure(ctx context.Context, ch, unit uint8, max, value float64) (resp DACReply, err error) {
+	err = c.invoke(ctx, "SetPressure", &PressureRequest{Channel: ch, Unit: unit, Max: max, Value: value}, &resp)
+	return
+}
+
+// SetFreq выводит на частотный выход ch (от ipk.FREQ1 до ipk.FREQ4) значение ipk.AnlFreq
+func (c *Client) SetFreq(ctx context.Context, ch uint8, code uint16) (resp FreqReply, err error) {
+	err = c.invoke(ctx, "SetFreq", &FreqRequest{Channel: ch, Code: code}, &resp)
+	return
+}
+
+// GetFreq возвращает значение частотного выхода ch
+func (c *Client) GetFreq(ctx context.Context, ch uint8) (resp FreqReply, err error) {
+	err = c.invoke(ctx, "GetFreq", &ChannelRequest{Channel: ch}, &resp)
+	return
+}
+
+// GetBinaryInputs возвращает двоичные входы ФАС-3, младший бит - вход 0
+func (c *Client) GetBinaryInputs(ctx context.Context) (val uint16, err error) {
+	var resp InputsReply
+	err = c.invoke(ctx, "GetBinaryInputs", &Empty{}, &resp)
+	val = resp.Value
+	return
+}
+
+// Set10V включает или выключает выход 10 В с номером num (от 0 до 7)
+func (c *Client) Set10V(ctx context.Context, num uint, on bool) (resp OutputsReply, err error) {
+	err = c.invoke(ctx, "Set10V", &OutputRequest{Num: num, On: on}, &resp)
+	return
+}
+
+// Set50V включает или выключает выход 50 В с номером num (от 0 до 35, кроме 28)
+func (c *Client) Set50V(ctx context.Context, num uint, on bool) (resp OutputsReply, err error) {
+	err = c.invoke(ctx, "Set50V", &OutputRequest{Num: num, On: on}, &resp)
+	return
+}
+
+// UintSet10V устанавливает все выходы 10 В, младший бит - выход 0
+func (c *Client) UintSet10V(ctx context.Context, val uint8) (resp OutputsReply, err error) {
+	err = c.invoke(ctx, "UintSet10V", &Uint10VRequest{Value: val}, &resp)
+	return
+}
+
+// UintSet50V устанавливает все выходы 50 В, младший бит - выход 0
+func (c *Client) UintSet50V(ctx context.Context, val uint64) (resp OutputsReply, err error) {
+	err = c.invoke(ctx, "UintSet50V", &Uint50VRequest{Value: val}, &resp)
+	return
+}
+
+// GetOutputs возвращает состояние выходов ФДС-3
+func (c *Client) GetOutputs(ctx context.Context) (resp OutputsReply, err error) {
+	err = c.invoke(ctx, "GetOutputs", &Empty{}, &resp)
+	return
+}
+
+// SetIF устанавливает код ИФ
+func (c *Client) SetIF(ctx context.Context, state uint8) (resp OutputsReply, err error) {
+	err = c.invoke(ctx, "SetIF", &IFRequest{State: state}, &resp)
+	return
+}
+
+// SetTURT включает или выключает TURT
+func (c *Client) SetTURT(ctx context.Context, on bool) (resp OutputsReply, err error) {
+	err = c.invoke(ctx, "SetTURT", &TURTRequest{On: on}, &resp)
+	return
+}
+
+// SetSpeed устанавливает скорость (км/ч) обоих генераторов ФЧС-3
+func (c *Client) SetSpeed(ctx context.Context, kmh1, kmh2 float64) (resp SpeedReply, err error) {
+	err = c.invoke(ctx, "SetSpeed", &SpeedRequest{Speed1: kmh1, Speed2: kmh2}, &resp)
+	return
+}
+
+// SetAcceleration устанавливает ускорение (0,01 м/с²) обоих генераторов ФЧС-3
+func (c *Client) SetAcceleration(ctx context.Context, accel1, accel2 float64) (resp SpeedReply, err error) {
+	err = c.invoke(ctx, "SetAcceleration", &AccelerationRequest{Acceleration1: accel1, Acceleration2: accel2}, &resp)
+	return
+}
+
+// SetMotion устанавливает направление движения: ipk.MotionOnward или ipk.MotionBackwards
+func (c *Client) SetMotion(ctx context.Context, direction uint8) (resp SpeedReply, err error) {
+	err = c.invoke(ctx, "SetMotion", &MotionRequest{Motion: direction}, &resp)
+	return
+}
+
+// SetLimitWay устанавливает предельный путь, м
+func (c *Client) SetLimitWay(ctx context.Context, meters uint32) (resp SpeedReply, err error) {
+	err = c.invoke(ctx, "SetLimitWay", &LimitRequest{Meters: meters}, &resp)
+	return
+}
+
+// GetSpeed возвращает скорость, ускорение, направление и путь ФЧС-3
+func (c *Client) GetSpeed(ctx context.Context) (resp SpeedReply, err error) {
+	err = c.invoke(ctx, "GetSpeed", &Empty{}, &resp)
+	return
+}
+
+// EnableADC включает или выключает режим АЦП ФЧС-3
+func (c *Client) EnableADC(ctx context.Context, enabled bool) (resp ADCReply, err error) {
+	err = c.invoke(ctx, "EnableADC", &ADCRequest{Enabled: enabled}, &resp)
+	return
+}
+
+// GetADC возвращает данные АЦП ФЧС-3 в мА
+func (c *Client) GetADC(ctx context.Context) (resp ADCReply, err error) {
+	err = c.invoke(ctx, "GetADC", &Empty{}, &resp)
+	return
+}
+
+// GetVersion возвращает версию прошивки ФЧС-3
+func (c *Client) GetVersion(ctx context.Context) (resp VersionReply, err error) {
+	err = c.invoke(ctx, "GetVersion", &Empty{}, &resp)
+	return
+}
+
+///////////////////////////////////////////////////////////////
+
+// WatchSpeed вызывает fn при каждом изменении скорости, ускорения, направления или пути ФЧС-3,
+// пока ctx не будет отменён
+func (c *Client) WatchSpeed(ctx context.Context, fn func(resp *SpeedReply)) error {
+	return watchStream(c, ctx, "WatchSpeed", fn)
+}
+
+// WatchWay вызывает fn при каждом изменении счётчиков пути ФЧС-3, пока ctx не будет отменён
+func (c *Client) WatchWay(ctx context.Context, fn func(resp *WayUpdate)) error {
+	return watchStream(c, ctx, "WatchWay", fn)
+}
+
+// WatchADC вызывает fn при каждом изменении данных АЦП ФЧС-3, пока ctx не будет отменён
+func (c *Client) WatchADC(ctx context.Context, fn func(resp *ADCReply)) error {
+	return watchStream(c, ctx, "WatchADC", fn)
+}
+
+// WatchInputs вызывает fn при каждом изменении двоичных входов ФАС-3, пока ctx не будет отменён
+func (c *Client) WatchInputs(ctx context.Context, fn func(edge *InputEdge)) error {
+	return watchStream(c, ctx, "WatchInputs", fn)
+}
+
+///////////////////////////////////////////////////////////////
+
+// RemoteTransport реализует ipk.Transport, передавая запросы производителя
+// на сервер (см. Client.Transport и Client.Open). Время ожидания каждого обмена
+// сервер ограничивает значением timeout из ControlIn/ControlOut. Сервер не повторяет
+// обмен после ошибки: повторные попытки делает плата, которая работает через
+// RemoteTransport, согласно своей RetryPolicy.
+type RemoteTransport struct {
+	client  *Client
+	product uint16
+}
+
+var _ ipk.Transport = (*RemoteTransport)(nil)
+
+// ControlIn читает данные из удалённой платы
+func (t *RemoteTransport) ControlIn(request byte, data []byte, timeout time.Duration) (n int, err error) {
+	return t.control(ipk.VendorRequestInput, request, data, timeout)
+}
+
+// ControlOut отправляет данные в удалённую плату
+func (t *RemoteTransport) ControlOut(request byte, data []byte, timeout time.Duration) (n int, err error) {
+	return t.control(ipk.VendorRequestOutput, request, data, timeout)
+}
+
+func (t *RemoteTransport) control(direction, request byte, data []byte, timeout time.Duration) (n int, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout+t.client.NetworkTimeout)
+	defer cancel()
+	req := TransferRequest{Product: t.product, Direction: direction, Request: request, Data: data}
+	if timeout > 0 {
+		req.Timeout = uint32((timeout + time.Millisecond - 1) / time.Millisecond)
+	}
+	if ipk.VendorRequestInput == direction {
+		req.Data = make([]byte, len(data))
+	}
+	var resp TransferReply
+	if err = t.client.invoke(ctx, "Transfer", &req, &resp); nil != err {
+		return
+	}
+	n = copy(data, resp.Data)
+	return
+}
+
+// Close ничего не делает: соединение с сервером закрывается через Client.Close
+func (t *RemoteTransport) Close() error {
+	return nil
+}
+
+// Present показывает, что плата открыта на сервере и подключена
+func (t *RemoteTransport) Present() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), t.client.NetworkTimeout)
+	defer cancel()
+	boards, err := t.client.Boards(ctx)
+	if nil != err {
+		return false
+	}
+	for _, b := range boards {
+		if b.Product == t.product {
+			return b.Present
+		}
+	}
+	return false
+}
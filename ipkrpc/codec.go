@@ -0,0 +1,32 @@
+package ipkrpc
+
+import (
+	"encoding/json"
+
+	"google.golang.org/grpc/encoding"
+)
+
+// codecName подтип содержимого gRPC (application/grpc+ipkjson).
+// Сообщения сервиса - обычные структуры Go, они передаются в JSON,
+// поэтому для сервиса не нужен protoc и сгенерированный код.
+// Имя отличается от "json", чтобы регистрация кодека не заменяла кодек
+// с тем же именем из других пакетов программы.
+const codecName = "ipkjson"
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return codecName
+}
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
@@ -0,0 +1,354 @@
+package ipkrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/amdf/ipk"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestClient запускает сервис для плат dev в памяти (bufconn) и соединяется с ним
+func newTestClient(t *testing.T, dev *ipk.IPK) *Client {
+	t.Helper()
+	service, err := NewServer(dev, 42, 1350)
+	if nil != err {
+		t.Fatal(err)
+	}
+	listener := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	service.Register(gs)
+	go gs.Serve(listener)
+
+	dialer := func(ctx context.Context, _ string) (net.Conn, error) {
+		return listener.DialContext(ctx)
+	}
+	c, err := Dial("passthrough:///bufnet", grpc.WithContextDialer(dialer),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if nil != err {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		c.Close()
+		gs.Stop()
+		service.Close()
+	})
+	return c
+}
+
+// newSimClient запускает сервис для симуляторов всех трёх плат
+func newSimClient(t *testing.T) (*Client, ipk.Simulators) {
+	t.Helper()
+	dev := new(ipk.IPK)
+	sims := dev.OpenSimulators(ipk.IDProductANL16bit)
+	t.Cleanup(dev.CloseAll)
+	return newTestClient(t, dev), sims
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestUnary(t *testing.T) {
+	c, sims := newSimClient(t)
+	ctx := testContext(t)
+
+	boards, err := c.Boards(ctx)
+	if nil != err || 3 != len(boards) {
+		t.Fatalf("Boards() = %+v, %v", boards, err)
+	}
+	for _, b := range boards {
+		if !b.Present || "" == b.Name {
+			t.Errorf("плата %+v", b)
+		}
+	}
+
+	dac, err := c.SetDAC(ctx, ipk.DAC3, 12)
+	if nil != err || ipk.DAC3 != dac.Channel || math.Abs(dac.MilliAmper-12) > 0.01 || 20 != dac.MaxMilliAmper {
+		t.Errorf("SetDAC() = %+v, %v", dac, err)
+	}
+	if dac, err = c.GetDAC(ctx, ipk.DAC3); nil != err || math.Abs(dac.MilliAmper-12) > 0.01 {
+		t.Errorf("GetDAC() = %+v, %v", dac, err)
+	}
+
+	sims.Analog.SetBinaryInput(0x0081)
+	if val, err := c.GetBinaryInputs(ctx); nil != err || 0x0081 != val {
+		t.Errorf("GetBinaryInputs() = %04X, %v", val, err)
+	}
+
+	if _, err := c.Set10V(ctx, 2, true); nil != err {
+		t.Fatal(err)
+	}
+	out, err := c.SetIF(ctx, ipk.IFYellow16)
+	if nil != err || 0x04 != out.Out10V || ipk.IFYellow16 != out.IF {
+		t.Errorf("SetIF() = %+v, %v", out, err)
+	}
+	if ipk.IFYellow16 != sims.Binary.IF() || 0x04 != sims.Binary.Output10V() {
+		t.Errorf("симулятор ФДС-3: ИФ %d, 10 В %02X", sims.Binary.IF(), sims.Binary.Output10V())
+	}
+
+	if _, err := c.SetMotion(ctx, ipk.MotionBackwards); nil != err {
+		t.Fatal(err)
+	}
+	speed, err := c.SetSpeed(ctx, 60, 40)
+	if nil != err || math.Abs(speed.Speed1-60) > 0.1 || math.Abs(speed.Speed2-40) > 0.1 || ipk.MotionBackwards != speed.Motion {
+		t.Errorf("SetSpeed() = %+v, %v", speed, err)
+	}
+
+	version, err := c.GetVersion(ctx)
+	if nil != err || "1.0.0" != version.Version || 1 != version.Major {
+		t.Errorf("GetVersion() = %+v, %v", version, err)
+	}
+}
+
+func TestStreams(t *testing.T) {
+	c, sims := newSimClient(t)
+	if _, err := c.SetSpeed(testContext(t), 30, 30); nil != err {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var speed SpeedReply
+	err := c.WatchSpeed(ctx, func(resp *SpeedReply) {
+		speed = *resp
+		cancel()
+	})
+	if nil != err || math.Abs(speed.Speed1-30) > 0.1 || speed.Time.IsZero() {
+		t.Errorf("WatchSpeed() = %+v, %v", speed, err)
+	}
+
+	// вход переключается, пока клиент не получит изменение: поток мог начаться после первого
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		var input uint16
+		for nil == ctx.Err() {
+			input ^= 0x0008
+			sims.Analog.SetBinaryInput(input)
+			time.Sleep(2 * ipk.DefaultWatchInterval)
+		}
+	}()
+	var edge InputEdge
+	err = c.WatchInputs(ctx, func(e *InputEdge) {
+		edge = *e
+		cancel()
+	})
+	wg.Wait()
+	if nil != err || 3 != edge.Input || edge.Time.IsZero() {
+		t.Errorf("WatchInputs() = %+v, %v", edge, err)
+	}
+
+	// поток завершается ошибкой сервера
+	dev := new(ipk.IPK)
+	dev.OpenSimulators(ipk.IDProductANL12bit)
+	dev.FreqDev = nil
+	defer dev.CloseAll()
+	if err := newTestClient(t, dev).WatchADC(testContext(t), func(*ADCReply) {}); !errors.Is(err, ipk.ErrNotConnected) {
+		t.Errorf("WatchADC() без ФЧС-3 = %v, want %v", err, ipk.ErrNotConnected)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	c, sims := newSimClient(t)
+	sims.Freq.SetADCSupported(false)
+	ctx := testContext(t)
+
+	tests := []struct {
+		name     string
+		call     func() error
+		wantCode codes.Code
+		wantKind string
+		want     error
+	}{
+		{"неверный параметр", func() error {
+			_, err := c.SetIF(ctx, 8)
+			return err
+		}, codes.InvalidArgument, "invalid_param", ipk.ErrInvalidParam},
+		{"запрос не разрешён", func() error {
+			_, err := c.Transport(ipk.IDProductFRQ).ControlIn(0xB3, make([]byte, 1), time.Second)
+			return err
+		}, codes.InvalidArgument, "invalid_param", ipk.ErrInvalidParam},
+		{"STALL", func() error {
+			_, err := c.Transport(ipk.IDProductFRQ).ControlIn(0xB2, make([]byte, 1), time.Second)
+			return err
+		}, codes.InvalidArgument, "unknown_request", ipk.ErrInvalidParam},
+		{"STALL в вызове сервиса", func() error {
+			_, err := c.EnableADC(ctx, true)
+			return err
+		}, codes.InvalidArgument, "unknown_request", ipk.ErrInvalidParam},
+		{"плата отключена", func() error {
+			sims.Binary.SetConnected(false)
+			defer sims.Binary.SetConnected(true)
+			_, err := c.GetOutputs(ctx)
+			return err
+		}, codes.Unavailable, "not_connected", ipk.ErrNotConnected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			var re *RemoteError
+			if !errors.As(err, &re) {
+				t.Fatalf("err = %v (%T), want *RemoteError", err, err)
+			}
+			if tt.wantCode != re.Code || tt.wantKind != re.Kind {
+				t.Errorf("Code = %v, Kind = %q, want %v, %q", re.Code, re.Kind, tt.wantCode, tt.wantKind)
+			}
+			if !errors.Is(err, tt.want) || tt.wantKind != ipk.ErrorKindName(err) {
+				t.Errorf("errors.Is(%v, %v) = false", err, tt.want)
+			}
+			if msg := re.Message(ipk.LangEN); "" == msg || msg == re.Text {
+				t.Errorf("Message(en) = %q", msg)
+			}
+		})
+	}
+}
+
+// serverTransport транспорт платы на сервере: считает обмены с запросом fail
+// и завершает их ошибкой, запоминает время ожидания каждого обмена
+type serverTransport struct {
+	*ipk.FreqSimulator
+	mutex    sync.Mutex
+	fail     byte
+	attempts int
+	timeouts []time.Duration
+}
+
+func (st *serverTransport) ControlIn(request byte, data []byte, timeout time.Duration) (int, error) {
+	st.mutex.Lock()
+	st.timeouts = append(st.timeouts, timeout)
+	failed := st.fail == request
+	if failed {
+		st.attempts++
+	}
+	st.mutex.Unlock()
+	if failed {
+		return 0, fmt.Errorf("сбой обмена:%w", ipk.ErrBadResponse)
+	}
+	return st.FreqSimulator.ControlIn(request, data, timeout)
+}
+
+func (st *serverTransport) lastTimeout() time.Duration {
+	st.mutex.Lock()
+	defer st.mutex.Unlock()
+	return st.timeouts[len(st.timeouts)-1]
+}
+
+func newTransportClient(t *testing.T) (*Client, *serverTransport) {
+	t.Helper()
+	st := &serverTransport{FreqSimulator: ipk.NewFreqSimulator()}
+	dev := &ipk.IPK{FreqDev: new(ipk.FreqDevice)}
+	dev.FreqDev.OpenTransport(st)
+	t.Cleanup(dev.CloseAll)
+	return newTestClient(t, dev), st
+}
+
+func TestTransferTimeout(t *testing.T) {
+	c, st := newTransportClient(t)
+	tr := c.Transport(ipk.IDProductFRQ)
+	for _, timeout := range []time.Duration{5 * time.Millisecond, 50 * time.Millisecond} {
+		if _, err := tr.ControlIn(0xB0, make([]byte, 64), timeout); nil != err {
+			t.Fatal(err)
+		}
+		// время на передачу по сети (NetworkTimeout) не добавляется ко времени обмена с платой
+		if got := st.lastTimeout(); got > timeout || got <= 0 {
+			t.Errorf("timeout %v: на сервере %v", timeout, got)
+		}
+	}
+}
+
+func TestTransferRetry(t *testing.T) {
+	c, st := newTransportClient(t)
+	remote := new(ipk.IPK)
+	if ok, err := c.Open(testContext(t), remote); !ok || nil != err {
+		t.Fatalf("Open() = %v, %v", ok, err)
+	}
+	defer remote.CloseAll()
+	remote.FreqDev.SetRetryPolicy(ipk.RetryPolicy{MaxAttempts: 3, Timeout: 5 * time.Second})
+
+	st.fail = 0xB0
+	err := remote.FreqDev.UpdateFreqDataUSB()
+	if !errors.Is(err, ipk.ErrBadResponse) {
+		t.Errorf("UpdateFreqDataUSB() = %v, want %v", err, ipk.ErrBadResponse)
+	}
+	// каждая попытка клиента - ровно один обмен на сервере
+	if 3 != st.attempts {
+		t.Errorf("обменов на сервере %d, want 3", st.attempts)
+	}
+	if stats := remote.FreqDev.GetRetryStats(); 2 != stats.Retries || 1 != stats.Failures {
+		t.Errorf("RetryStats() = %+v", stats)
+	}
+}
+
+func TestClientOpen(t *testing.T) {
+	c, sims := newSimClient(t)
+	sims.Binary.SetVersion(0, 0, 0)
+	sims.Freq.SetADCSupported(false)
+	ctx := testContext(t)
+
+	remote := new(ipk.IPK)
+	ok, err := c.Open(ctx, remote)
+	if !ok || nil != err {
+		t.Fatalf("Open() = %v, %v", ok, err)
+	}
+	defer remote.CloseAll()
+	if nil == remote.AnalogDev || nil == remote.BinDev || nil == remote.FreqDev {
+		t.Fatalf("Open(): %+v", remote)
+	}
+
+	// возможности определены по ответам плат, переданным через сервер, в том числе по STALL
+	if caps := remote.BinDev.Capabilities(); !caps.Detected || "0.0.0" != caps.Version || caps.VersionQuery {
+		t.Errorf("ФДС-3: %+v", caps)
+	}
+	if caps := remote.FreqDev.Capabilities(); !caps.Detected || "1.0.0" != caps.Version || caps.ADC {
+		t.Errorf("ФЧС-3: %+v", caps)
+	}
+	if caps := remote.AnalogDev.Capabilities(); !caps.Detected || 16 != caps.DACBits {
+		t.Errorf("ФАС-3: %+v", caps)
+	}
+
+	// чтение-изменение-запись через Transfer
+	if err := remote.BinDev.Set10V(5, true); nil != err {
+		t.Fatal(err)
+	}
+	if err := remote.BinDev.SetIF(ipk.IFRedYellow16); nil != err {
+		t.Fatal(err)
+	}
+	if 0x20 != sims.Binary.Output10V() || ipk.IFRedYellow16 != sims.Binary.IF() {
+		t.Errorf("симулятор ФДС-3: 10 В %02X, ИФ %d", sims.Binary.Output10V(), sims.Binary.IF())
+	}
+	var dac ipk.DAC
+	if err := dac.Init(remote.AnalogDev, ipk.DAC7); nil != err {
+		t.Fatal(err)
+	}
+	if err := dac.SetMilliAmper(5); nil != err {
+		t.Fatal(err)
+	}
+	if resp, err := c.GetDAC(ctx, ipk.DAC7); nil != err || math.Abs(resp.MilliAmper-5) > 0.01 {
+		t.Errorf("GetDAC() = %+v, %v", resp, err)
+	}
+	if version, err := remote.FreqDev.GetVersionString(); nil != err || "1.0.0" != version {
+		t.Errorf("GetVersionString() = %q, %v", version, err)
+	}
+	// обновление прошивки через сервер запрещено
+	if err := remote.FreqDev.PrepareUpdate(); !errors.Is(err, ipk.ErrInvalidParam) {
+		t.Errorf("PrepareUpdate() = %v, want %v", err, ipk.ErrInvalidParam)
+	}
+	if !remote.FreqDev.Active() {
+		t.Error("Active() = false")
+	}
+}
@@ -0,0 +1,185 @@
+package ipkrpc
+
+import "time"
+
+// Empty пустой запрос или ответ
+type Empty struct{}
+
+// Board плата ФПС-3, открытая на сервере
+type Board struct {
+	Product uint16 `json:"product"` // ipk.IDProductANL12bit, ipk.IDProductBIN и т.д.
+	Name    string `json:"name"`
+	Present bool   `json:"present"` // плата подключена
+}
+
+// BoardsReply список плат сервера
+type BoardsReply struct {
+	Boards []Board `json:"boards"`
+}
+
+// TransferRequest запрос производителя к плате product (см. ipk.Transport).
+// Для чтения (ipk.VendorRequestInput) Data задаёт только количество читаемых байт.
+type TransferRequest struct {
+	Product   uint16 `json:"product"`
+	Direction byte   `json:"direction"`
+	Request   byte   `json:"request"`
+	Data      []byte `json:"data"`
+	Timeout   uint32 `json:"timeout_ms"` // время ожидания обмена с платой, мс; 0 - по умолчанию
+}
+
+// TransferReply результат запроса производителя: прочитанные или отправленные байты
+type TransferReply struct {
+	Data []byte `json:"data"`
+}
+
+// ChannelRequest номер канала ЦАП (от ipk.DAC1 до ipk.DAC14) или частотного выхода
+// (от ipk.FREQ1 до ipk.FREQ4) ФАС-3
+type ChannelRequest struct {
+	Channel uint8 `json:"channel"`
+}
+
+// DACRequest задание значения канала ЦАП в мА
+type DACRequest struct {
+	Channel    uint8   `json:"channel"`
+	MilliAmper float64 `json:"milliamper"`
+}
+
+// DACReply значение канала ЦАП в мА
+type DACReply struct {
+	Channel       uint8   `json:"channel"`
+	MilliAmper    float64 `json:"milliamper"`
+	MaxMilliAmper float64 `json:"max_milliamper"`
+}
+
+// PressureRequest задание давления на канале ЦАП (см. ipk.PressureOutput)
+type PressureRequest struct {
+	Channel uint8   `json:"channel"`
+	Unit    uint8   `json:"unit"` // ipk.DACKiloPascal или ipk.DACAtmosphere
+	Max     float64 `json:"max"`  // максимальное значение шкалы датчика
+	Value   float64 `json:"value"`
+}
+
+// FreqRequest задание частотного выхода ФАС-3 (значение ipk.AnlFreq)
+type FreqRequest struct {
+	Channel uint8  `json:"channel"`
+	Code    uint16 `json:"code"`
+}
+
+// FreqReply значение частотного выхода ФАС-3
+type FreqReply struct {
+	Channel uint8  `json:"channel"`
+	Code    uint16 `json:"code"`
+}
+
+// InputsReply двоичные входы ФАС-3, младший бит - вход 0
+type InputsReply struct {
+	Value uint16 `json:"value"`
+}
+
+// OutputRequest включение выхода 10 В или 50 В ФДС-3 с номером Num (с нуля)
+type OutputRequest struct {
+	Num uint `json:"num"`
+	On  bool `json:"on"`
+}
+
+// Uint10VRequest значения всех выходов 10 В, младший бит - выход 0
+type Uint10VRequest struct {
+	Value uint8 `json:"value"`
+}
+
+// Uint50VRequest значения всех выходов 50 В, младший бит - выход 0
+type Uint50VRequest struct {
+	Value uint64 `json:"value"`
+}
+
+// OutputsReply состояние выходов ФДС-3
+type OutputsReply struct {
+	Out10V uint8  `json:"out10v"`
+	Out50V uint64 `json:"out50v"`
+	IF     uint8  `json:"if"`
+	TURT   bool   `json:"turt"`
+}
+
+// IFRequest код ИФ (от ipk.IFDisable до ipk.IFEnable)
+type IFRequest struct {
+	State uint8 `json:"state"`
+}
+
+// TURTRequest включение TURT
+type TURTRequest struct {
+	On bool `json:"on"`
+}
+
+// SpeedRequest скорость (км/ч) обоих генераторов ФЧС-3
+type SpeedRequest struct {
+	Speed1 float64 `json:"speed1"`
+	Speed2 float64 `json:"speed2"`
+}
+
+// AccelerationRequest ускорение (0,01 м/с²) обоих генераторов ФЧС-3
+type AccelerationRequest struct {
+	Acceleration1 float64 `json:"acceleration1"`
+	Acceleration2 float64 `json:"acceleration2"`
+}
+
+// MotionRequest направление движения: ipk.MotionOnward или ipk.MotionBackwards
+type MotionRequest struct {
+	Motion uint8 `json:"motion"`
+}
+
+// LimitRequest предельный путь, м
+type LimitRequest struct {
+	Meters uint32 `json:"meters"`
+}
+
+// SpeedReply состояние генераторов ФЧС-3
+type SpeedReply struct {
+	Time          time.Time `json:"time"`
+	Speed1        float64   `json:"speed1"`        // км/ч
+	Speed2        float64   `json:"speed2"`        // км/ч
+	Acceleration1 float64   `json:"acceleration1"` // 0,01 м/с²
+	Acceleration2 float64   `json:"acceleration2"` // 0,01 м/с²
+	Motion        uint8     `json:"motion"`        // ipk.MotionOnward, ipk.MotionBackwards или ipk.MotionUnknown
+	Way1          uint32    `json:"way1"`          // пройденный путь, м
+	Way2          uint32    `json:"way2"`          // пройденный путь, м
+	LimitWay      uint32    `json:"limit_way"`     // предельный путь, м
+}
+
+// WayUpdate счётчики пути ФЧС-3
+type WayUpdate struct {
+	Time      time.Time `json:"time"`
+	Way1      uint32    `json:"way1"` // м
+	Way2      uint32    `json:"way2"` // м
+	WayCount1 uint32    `json:"way_count1"`
+	WayCount2 uint32    `json:"way_count2"`
+}
+
+// ADCRequest включение режима АЦП ФЧС-3
+type ADCRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// ADCReply данные АЦП ФЧС-3 в мА
+type ADCReply struct {
+	Time    time.Time `json:"time"`
+	Enabled bool      `json:"enabled"`
+	Dat1    float64   `json:"dat1"`
+	Dat2    float64   `json:"dat2"`
+	Ref     float64   `json:"ref"`
+	Err     string    `json:"error,omitempty"` // ошибка АЦП (только в WatchADC)
+}
+
+// VersionReply версия прошивки ФЧС-3
+type VersionReply struct {
+	Major   uint32 `json:"major"`
+	Minor   uint32 `json:"minor"`
+	Patch   uint32 `json:"patch"`
+	Version string `json:"version"`
+}
+
+// InputEdge изменение двоичного входа ФАС-3 (см. ipk.InputEdge)
+type InputEdge struct {
+	Input  uint16    `json:"input"`
+	Rising bool      `json:"rising"`
+	Time   time.Time `json:"time"`
+}
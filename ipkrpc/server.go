@@ -0,0 +1,547 @@
+package ipkrpc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/amdf/ipk"
+	"google.golang.org/grpc"
+)
+
+var errNoBoard = fmt.Errorf("плата не открыта:%w", ipk.ErrNotConnected)
+
+// transferAllowed показывает, что запрос производителя можно передать плате через Transfer:
+// обмен данными 0xB0 - 0xB2 и чтение версии прошивки 0xB4. Команды обновления
+// прошивки (запись 0xB4) и неизвестные плате запросы не передаются.
+func transferAllowed(direction, request byte) bool {
+	switch direction {
+	case ipk.VendorRequestInput:
+		return request >= 0xB0 && request <= 0xB2 || 0xB4 == request
+	case ipk.VendorRequestOutput:
+		return request >= 0xB0 && request <= 0xB2
+	}
+	return false
+}
+
+// Server реализация сервиса gRPC для плат ФПС-3.
+// Обращения к платам выполняются функциями библиотеки, которые сами захватывают
+// мьютексы своих плат, поэтому вызовы можно выполнять параллельно.
+// Потоковые вызовы используют общие для всех клиентов FreqPoller и InputWatcher,
+// которые запускаются при первом обращении и останавливаются в Close.
+type Server struct {
+	ipk   *ipk.IPK
+	dacs  [ipk.DAC14 + 1]ipk.DAC
+	speed ipk.Speed
+
+	mutex     sync.Mutex
+	freqPoll  *ipk.FreqPoller
+	adcPoll   *ipk.FreqPoller
+	inputPoll *ipk.InputWatcher
+}
+
+// NewServer создаёт сервис для плат dev. teeth и diameter - параметры датчика скорости
+// и бандажа для пересчёта частоты ФЧС-3 в скорость и путь (см. ipk.Speed).
+func NewServer(dev *ipk.IPK, teeth, diameter uint32) (s *Server, err error) {
+	if nil == dev {
+		err = errNoBoard
+		return
+	}
+	s = &Server{ipk: dev}
+	if nil != dev.AnalogDev {
+		for ch := range s.dacs {
+			if err = s.dacs[ch].Init(dev.AnalogDev, uint8(ch)); nil != err {
+				return
+			}
+		}
+	}
+	if nil != dev.FreqDev {
+		err = s.speed.Init(dev.FreqDev, teeth, diameter)
+	}
+	return
+}
+
+// Register регистрирует сервис в сервере gRPC
+func (s *Server) Register(gs *grpc.Server) {
+	gs.RegisterService(&serviceDesc, s)
+}
+
+// Close останавливает опрос плат, запущенный потоковыми вызовами
+func (s *Server) Close() {
+	s.mutex.Lock()
+	freqPoll, adcPoll, inputPoll := s.freqPoll, s.adcPoll, s.inputPoll
+	s.freqPoll, s.adcPoll, s.inputPoll = nil, nil, nil
+	s.mutex.Unlock()
+	freqPoll.Stop()
+	adcPoll.Stop()
+	inputPoll.Stop()
+}
+
+func (s *Server) analog() (*ipk.AnalogDevice, error) {
+	if nil == s.ipk.AnalogDev {
+		return nil, errNoBoard
+	}
+	return s.ipk.AnalogDev, nil
+}
+
+func (s *Server) binary() (*ipk.BinaryDevice, error) {
+	if nil == s.ipk.BinDev {
+		return nil, errNoBoard
+	}
+	return s.ipk.BinDev, nil
+}
+
+func (s *Server) freq() (*ipk.FreqDevice, error) {
+	if nil == s.ipk.FreqDev {
+		return nil, errNoBoard
+	}
+	return s.ipk.FreqDev, nil
+}
+
+func (s *Server) dac(ch uint8) (*ipk.DAC, error) {
+	if _, err := s.analog(); nil != err {
+		return nil, err
+	}
+	if int(ch) >= len(s.dacs) {
+		return nil, fmt.Errorf("канал ЦАП %d:%w", ch, ipk.ErrInvalidParam)
+	}
+	return &s.dacs[ch], nil
+}
+
+///////////////////////////////////////////////////////////////
+
+func (s *Server) boards(ctx context.Context, req *Empty) (*BoardsReply, error) {
+	resp := new(BoardsReply)
+	for _, dev := range s.ipk.Devices() {
+		product := dev.GetProductID()
+		resp.Boards = append(resp.Boards, Board{Product: product, Name: ipk.ProductName(product), Present: dev.Active()})
+	}
+	return resp, nil
+}
+
+func (s *Server) transfer(ctx context.Context, req *TransferRequest) (*TransferReply, error) {
+	var dev ipk.Device
+	for _, d := range s.ipk.Devices() {
+		if d.GetProductID() == req.Product {
+			dev = d
+		}
+	}
+	if nil == dev {
+		return nil, errNoBoard
+	}
+	if !transferAllowed(req.Direction, req.Request) {
+		return nil, fmt.Errorf("запрос %02X (направление %02X):%w", req.Request, req.Direction, ipk.ErrInvalidParam)
+	}
+	if req.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(req.Timeout)*time.Millisecond)
+		defer cancel()
+	}
+	// обмен выполняется один раз: повторные попытки делает клиент (см. RemoteTransport)
+	data := append([]byte(nil), req.Data...)
+	if err := ipk.Transfer(ctx, dev, req.Direction, req.Request, data); nil != err {
+		return nil, err
+	}
+	return &TransferReply{Data: data}, nil
+}
+
+///////////////////////////////////////////////////////////////
+
+func (s *Server) setDAC(ctx context.Context, req *DACRequest) (*DACReply, error) {
+	dac, err := s.dac(req.Channel)
+	if nil == err {
+		err = dac.SetMilliAmperCtx(ctx, req.MilliAmper)
+	}
+	if nil != err {
+		return nil, err
+	}
+	return s.getDAC(ctx, &ChannelRequest{Channel: req.Channel})
+}
+
+func (s *Server) getDAC(ctx context.Context, req *ChannelRequest) (*DACReply, error) {
+	dac, err := s.dac(req.Channel)
+	if nil != err {
+		return nil, err
+	}
+	resp := &DACReply{Channel: req.Channel, MaxMilliAmper: dac.GetMaxMilliAmper()}
+	resp.MilliAmper, err = dac.GetMilliAmperCtx(ctx)
+	return resp, err
+}
+
+func (s *Server) setPressure(ctx context.Context, req *PressureRequest) (*DACReply, error) {
+	dac, err := s.dac(req.Channel)
+	var pres ipk.PressureOutput
+	if nil == err {
+		err = pres.Init(dac, req.Unit, req.Max)
+	}
+	if nil == err {
+		err = pres.SetCtx(ctx, req.Value)
+	}
+	if nil != err {
+		return nil, err
+	}
+	return s.getDAC(ctx, &ChannelRequest{Channel: req.Channel})
+}
+
+func (s *Server) setFreq(ctx context.Context, req *FreqRequest) (*FreqReply, error) {
+	dev, err := s.analog()
+	if nil == err {
+		err = dev.SetFreqCtx(ctx, req.Channel, req.Code)
+	}
+	if nil != err {
+		return nil, err
+	}
+	return s.getFreq(ctx, &ChannelRequest{Channel: req.Channel})
+}
+
+func (s *Server) getFreq(ctx context.Context, req *ChannelRequest) (*FreqReply, error) {
+	dev, err := s.analog()
+	if nil != err {
+		return nil, err
+	}
+	resp := &FreqReply{Channel: req.Channel}
+	resp.Code, err = dev.GetOutputFreqCtx(ctx, req.Channel)
+	return resp, err
+}
+
+func (s *Server) getBinaryInputs(ctx context.Context, req *Empty) (*InputsReply, error) {
+	dev, err := s.analog()
+	if nil != err {
+		return nil, err
+	}
+	resp := new(InputsReply)
+	resp.Value, err = dev.UintGetBinaryInputCtx(ctx)
+	return resp, err
+}
+
+///////////////////////////////////////////////////////////////
+
+func (s *Server) set10V(ctx context.Context, req *OutputRequest) (*OutputsReply, error) {
+	dev, err := s.binary()
+	if nil == err {
+		err = dev.Set10VCtx(ctx, req.Num, req.On)
+	}
+	if nil != err {
+		return nil, err
+	}
+	return s.getOutputs(ctx, nil)
+}
+
+func (s *Server) set50V(ctx context.Context, req *OutputRequest) (*OutputsReply, error) {
+	dev, err := s.binary()
+	if nil == err {
+		err = dev.Set50VCtx(ctx, req.Num, req.On)
+	}
+	if nil != err {
+		return nil, err
+	}
+	return s.getOutputs(ctx, nil)
+}
+
+func (s *Server) uintSet10V(ctx context.Context, req *Uint10VRequest) (*OutputsReply, error) {
+	dev, err := s.binary()
+	if nil == err {
+		err = dev.UintSet10VCtx(ctx, req.Value)
+	}
+	if nil != err {
+		return nil, err
+	}
+	return s.getOutputs(ctx, nil)
+}
+
+func (s *Server) uintSet50V(ctx context.Context, req *Uint50VRequest) (*OutputsReply, error) {
+	dev, err := s.binary()
+	if nil == err {
+		err = dev.UintSet50VCtx(ctx, req.Value)
+	}
+	if nil != err {
+		return nil, err
+	}
+	return s.getOutputs(ctx, nil)
+}
+
+func (s *Server) getOutputs(ctx context.Context, req *Empty) (*OutputsReply, error) {
+	dev, err := s.binary()
+	if nil != err {
+		return nil, err
+	}
+	resp := new(OutputsReply)
+	if resp.Out10V, err = dev.UintGetOutput10VCtx(ctx); nil != err {
+		return nil, err
+	}
+	if resp.Out50V, err = dev.UintGetOutput50VCtx(ctx); nil != err {
+		return nil, err
+	}
+	if resp.IF, err = dev.GetOutputIFCtx(ctx); nil != err {
+		return nil, err
+	}
+	if resp.TURT, err = dev.GetOutputTURTCtx(ctx); nil != err {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s *Server) setIF(ctx context.Context, req *IFRequest) (*OutputsReply, error) {
+	dev, err := s.binary()
+	if nil == err {
+		err = dev.SetIFCtx(ctx, req.State)
+	}
+	if nil != err {
+		return nil, err
+	}
+	return s.getOutputs(ctx, nil)
+}
+
+func (s *Server) setTURT(ctx context.Context, req *TURTRequest) (*OutputsReply, error) {
+	dev, err := s.binary()
+	if nil == err {
+		err = dev.SetTURTCtx(ctx, req.On)
+	}
+	if nil != err {
+		return nil, err
+	}
+	return s.getOutputs(ctx, nil)
+}
+
+///////////////////////////////////////////////////////////////
+
+func (s *Server) setSpeed(ctx context.Context, req *SpeedRequest) (*SpeedReply, error) {
+	if _, err := s.freq(); nil != err {
+		return nil, err
+	}
+	if err := s.speed.SetSpeedCtx(ctx, req.Speed1, req.Speed2); nil != err {
+		return nil, err
+	}
+	return s.getSpeed(ctx, nil)
+}
+
+func (s *Server) setAcceleration(ctx context.Context, req *AccelerationRequest) (*SpeedReply, error) {
+	if _, err := s.freq(); nil != err {
+		return nil, err
+	}
+	if err := s.speed.SetAccelerationCtx(ctx, req.Acceleration1, req.Acceleration2); nil != err {
+		return nil, err
+	}
+	return s.getSpeed(ctx, nil)
+}
+
+func (s *Server) setMotion(ctx context.Context, req *MotionRequest) (*SpeedReply, error) {
+	if _, err := s.freq(); nil != err {
+		return nil, err
+	}
+	if err := s.speed.SetMotionCtx(ctx, req.Motion); nil != err {
+		return nil, err
+	}
+	return s.getSpeed(ctx, nil)
+}
+
+func (s *Server) setLimitWay(ctx context.Context, req *LimitRequest) (*SpeedReply, error) {
+	if _, err := s.freq(); nil != err {
+		return nil, err
+	}
+	if err := s.speed.SetLimitWayCtx(ctx, req.Meters); nil != err {
+		return nil, err
+	}
+	return s.getSpeed(ctx, nil)
+}
+
+func (s *Server) getSpeed(ctx context.Context, req *Empty) (*SpeedReply, error) {
+	dev, err := s.freq()
+	if nil == err {
+		err = dev.UpdateFreqDataUSBCtx(ctx)
+	}
+	if nil != err {
+		return nil, err
+	}
+	snap := dev.Snapshot()
+	return s.speedReply(&snap)
+}
+
+// speedReply заполняет ответ по последним данным ФЧС-3
+func (s *Server) speedReply(snap *ipk.FreqSnapshot) (resp *SpeedReply, err error) {
+	resp = &SpeedReply{Time: snap.Time, Motion: snap.Direction()}
+	if resp.Speed1, resp.Speed2, err = s.speed.GetOutputSpeed(); nil != err {
+		return
+	}
+	if resp.Acceleration1, resp.Acceleration2, err = s.speed.GetOutputAcceleration(); nil != err {
+		return
+	}
+	if resp.Way1, resp.Way2, err = s.speed.GetWay(); nil != err {
+		return
+	}
+	resp.LimitWay, err = s.speed.GetLimitWay()
+	return
+}
+
+func (s *Server) enableADC(ctx context.Context, req *ADCRequest) (*ADCReply, error) {
+	dev, err := s.freq()
+	if nil == err {
+		err = dev.EnableADCCtx(ctx, req.Enabled)
+	}
+	if nil != err {
+		return nil, err
+	}
+	return s.getADC(ctx, nil)
+}
+
+func (s *Server) getADC(ctx context.Context, req *Empty) (*ADCReply, error) {
+	dev, err := s.freq()
+	if nil == err {
+		err = dev.UpdateFreqDataUSBCtx(ctx)
+	}
+	if nil != err {
+		return nil, err
+	}
+	resp := &ADCReply{Enabled: dev.Snapshot().ADCModeEnabled}
+	if !resp.Enabled {
+		return resp, nil
+	}
+	if err = dev.UpdateADCCtx(ctx); nil != err {
+		return nil, err
+	}
+	err = s.adcValues(dev, resp)
+	return resp, err
+}
+
+// adcValues заполняет ответ последними данными АЦП в мА
+func (s *Server) adcValues(dev *ipk.FreqDevice, resp *ADCReply) (err error) {
+	if resp.Dat1, err = dev.GetDat1MilliAmper(); nil != err {
+		return
+	}
+	if resp.Dat2, err = dev.GetDat2MilliAmper(); nil != err {
+		return
+	}
+	resp.Ref, err = dev.GetRefValMilliAmper()
+	return
+}
+
+func (s *Server) getVersion(ctx context.Context, req *Empty) (*VersionReply, error) {
+	dev, err := s.freq()
+	if nil != err {
+		return nil, err
+	}
+	resp := new(VersionReply)
+	resp.Major, resp.Minor, resp.Patch, err = dev.GetVersionCtx(ctx)
+	resp.Version = fmt.Sprintf("%d.%d.%d", resp.Major, resp.Minor, resp.Patch)
+	return resp, err
+}
+
+///////////////////////////////////////////////////////////////
+
+// poller возвращает запущенный опрос ФЧС-3 (с АЦП или без)
+func (s *Server) poller(dev *ipk.FreqDevice, adc bool) (p *ipk.FreqPoller) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	poll := &s.freqPoll
+	if adc {
+		poll = &s.adcPoll
+	}
+	if nil == *poll {
+		*poll = ipk.NewFreqPoller(dev)
+		(*poll).ADC = adc
+		(*poll).Start()
+	}
+	return *poll
+}
+
+// watchFreq отправляет клиенту ответы, построенные по снимкам ФЧС-3 функцией reply,
+// пока клиент не отменит вызов. Одинаковые ответы подряд не отправляются.
+func watchFreq[Resp comparable](s *Server, ctx context.Context, adc bool, reply func(snap *ipk.FreqSnapshot) Resp, send func(resp *Resp, snap *ipk.FreqSnapshot) error) error {
+	dev, err := s.freq()
+	if nil != err {
+		return err
+	}
+	p := s.poller(dev, adc)
+	ch := p.Subscribe()
+	defer p.Unsubscribe(ch)
+
+	snap := p.Snapshot()
+	var last Resp
+	first := true
+	for {
+		if !snap.Time.IsZero() {
+			resp := reply(&snap)
+			if first || resp != last {
+				first, last = false, resp
+				if err = send(&resp, &snap); nil != err {
+					return err
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case snap = <-ch:
+		}
+	}
+}
+
+func (s *Server) watchSpeed(ctx context.Context, send func(*SpeedReply) error) error {
+	return watchFreq(s, ctx, false, func(snap *ipk.FreqSnapshot) SpeedReply {
+		resp, _ := s.speedReply(snap)
+		resp.Time = time.Time{}
+		return *resp
+	}, func(resp *SpeedReply, snap *ipk.FreqSnapshot) error {
+		resp.Time = snap.Time
+		return send(resp)
+	})
+}
+
+func (s *Server) watchWay(ctx context.Context, send func(*WayUpdate) error) error {
+	return watchFreq(s, ctx, false, func(snap *ipk.FreqSnapshot) WayUpdate {
+		resp := WayUpdate{WayCount1: snap.WayCount1, WayCount2: snap.WayCount2}
+		resp.Way1, resp.Way2, _ = s.speed.GetWay()
+		return resp
+	}, func(resp *WayUpdate, snap *ipk.FreqSnapshot) error {
+		resp.Time = snap.Time
+		return send(resp)
+	})
+}
+
+func (s *Server) watchADC(ctx context.Context, send func(*ADCReply) error) error {
+	return watchFreq(s, ctx, true, func(snap *ipk.FreqSnapshot) ADCReply {
+		resp := ADCReply{Enabled: snap.ADCModeEnabled}
+		err := snap.Err
+		if nil == err && resp.Enabled {
+			err = s.adcValues(s.ipk.FreqDev, &resp)
+		}
+		if nil != err {
+			resp.Err = err.Error()
+		}
+		return resp
+	}, func(resp *ADCReply, snap *ipk.FreqSnapshot) error {
+		resp.Time = snap.Time
+		return send(resp)
+	})
+}
+
+func (s *Server) watchInputs(ctx context.Context, send func(*InputEdge) error) error {
+	dev, err := s.analog()
+	if nil != err {
+		return err
+	}
+	s.mutex.Lock()
+	if nil == s.inputPoll {
+		s.inputPoll = ipk.NewInputWatcher(dev)
+		s.inputPoll.Start()
+	}
+	w := s.inputPoll
+	s.mutex.Unlock()
+
+	ch := w.Subscribe()
+	defer w.Unsubscribe(ch)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case edge, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			if err = send(&InputEdge{Input: edge.Input, Rising: edge.Rising, Time: edge.Time}); nil != err {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,237 @@
+// Package ipkrpc - сервис gRPC для удалённого управления платами ФПС-3 и клиент к нему.
+//
+// Сервис повторяет функции AnalogDevice, BinaryDevice, FreqDevice и Speed (унарные вызовы)
+// и передаёт потоком изменения скорости, пути, данных АЦП ФЧС-3 и двоичных входов ФАС-3.
+// Кроме того, вызов Transfer передаёт на сервер запросы производителя как есть, поэтому
+// Client.Transport реализует ipk.Transport и позволяет работать с удалённой стойкой
+// через обычные функции библиотеки (см. Client.Open). Transfer передаёт только обмен
+// данными (запросы 0xB0 - 0xB2) и чтение версии прошивки (0xB4), обновить прошивку через
+// сервер нельзя. Функции чтения-изменения-записи (например, SetDAC, Set10V) выполняются
+// через Transport двумя вызовами Transfer, поэтому через Transport с платой может работать
+// только один клиент; несколько клиентов должны пользоваться вызовами сервиса,
+// которые выполняют чтение-изменение-запись на сервере.
+//
+// Повторные попытки обмена выполняются только на одной стороне. Вызовы сервиса (SetDAC и т.д.)
+// повторяет сервер согласно RetryPolicy своих плат, клиент их не повторяет. Transfer сервер
+// выполняет один раз, а повторяет его клиент согласно RetryPolicy платы, открытой через
+// RemoteTransport. Время ожидания обмена с платой клиент передаёт в TransferRequest.Timeout.
+//
+// Общий вид ошибки библиотеки (ipk.ErrorKindName) передаётся в подробностях статуса gRPC
+// (google.rpc.ErrorInfo, Reason - название вида, Domain - ServiceName), поэтому на клиенте
+// errors.Is работает с RemoteError так же, как с исходной ошибкой, в том числе для STALL,
+// по которому библиотека узнаёт старые ревизии плат.
+//
+// Сервис не описан в .proto: сообщения - это структуры Go из messages.go, они передаются
+// в JSON (подтип содержимого application/grpc+ipkjson, см. codec.go). Поэтому клиенты
+// на других языках не могут сгенерировать код по описанию сервиса: им нужно передавать JSON
+// с тем же подтипом содержимого. Формат сообщений описан в README.md пакета.
+package ipkrpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/amdf/ipk"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ServiceName полное имя сервиса gRPC
+const ServiceName = "ipk.v1.IPK"
+
+// errorCodes соответствие общих видов ошибок библиотеки кодам gRPC
+var errorCodes = []struct {
+	kind *ipk.Error
+	code codes.Code
+}{
+	{ipk.ErrNotConnected, codes.Unavailable},
+	{ipk.ErrTimeout, codes.DeadlineExceeded},
+	{ipk.ErrInvalidParam, codes.InvalidArgument},
+	{ipk.ErrNotInitialized, codes.FailedPrecondition},
+	{ipk.ErrADCNotEnabled, codes.Unimplemented},
+	{ipk.ErrADCNoData, codes.NotFound},
+	{ipk.ErrADCFault, codes.Aborted},
+	{ipk.ErrBadResponse, codes.DataLoss},
+	{ipk.ErrInternal, codes.Internal},
+}
+
+// toStatus переводит ошибку библиотеки в статус gRPC. Общий вид ошибки
+// передаётся в подробностях статуса (errdetails.ErrorInfo).
+func toStatus(err error) error {
+	if nil == err {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	code := codes.Unknown
+	switch {
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	default:
+		for _, ec := range errorCodes {
+			if errors.Is(err, ec.kind) {
+				code = ec.code
+				break
+			}
+		}
+	}
+	st := status.New(code, err.Error())
+	if kind := ipk.ErrorKindName(err); "" != kind {
+		if detailed, derr := st.WithDetails(&errdetails.ErrorInfo{Reason: kind, Domain: ServiceName}); nil == derr {
+			st = detailed
+		}
+	}
+	return st.Err()
+}
+
+// RemoteError ошибка, полученная от сервера. errors.Is сопоставляет её
+// с общим видом ошибки библиотеки (ipk.ErrNotConnected и т.д.) по Kind,
+// а если сервер его не передал - по коду gRPC.
+type RemoteError struct {
+	Code codes.Code
+	Kind string // общий вид ошибки (см. ipk.ErrorKindName), "" если неизвестен
+	Text string // сообщение сервера
+}
+
+func (e *RemoteError) Error() string {
+	return e.Text
+}
+
+// Is позволяет errors.Is сопоставить ошибку с общим видом ошибки библиотеки
+func (e *RemoteError) Is(target error) bool {
+	if kind := ipk.ErrorKind(e.Kind); nil != kind {
+		return kind == target || kind.Is(target)
+	}
+	switch e.Code {
+	case codes.Canceled:
+		return context.Canceled == target
+	case codes.DeadlineExceeded:
+		return ipk.ErrTimeout == target || context.DeadlineExceeded == target
+	}
+	for _, ec := range errorCodes {
+		if ec.code == e.Code && ec.kind == target {
+			return true
+		}
+	}
+	return false
+}
+
+// Message возвращает сообщение об ошибке на языке lang (см. ipk.Localize).
+// Сервер передаёт сообщение на русском, поэтому на других языках
+// возвращается сообщение общего вида ошибки.
+func (e *RemoteError) Message(lang string) string {
+	if ipk.LangRU == lang {
+		return e.Text
+	}
+	if kind := ipk.ErrorKind(e.Kind); nil != kind {
+		return kind.Message(lang)
+	}
+	for _, ec := range errorCodes {
+		if ec.code == e.Code {
+			return ec.kind.Message(lang)
+		}
+	}
+	return e.Text
+}
+
+// fromStatus переводит статус gRPC в RemoteError
+func fromStatus(err error) error {
+	if nil == err {
+		return nil
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	re := &RemoteError{Code: st.Code(), Text: st.Message()}
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok && ServiceName == info.Domain {
+			re.Kind = info.Reason
+		}
+	}
+	return re
+}
+
+// unary описывает унарный вызов сервиса
+func unary[Req, Resp any](name string, call func(s *Server, ctx context.Context, req *Req) (*Resp, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := new(Req)
+			if err := dec(req); nil != err {
+				return nil, err
+			}
+			s := srv.(*Server)
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				resp, err := call(s, ctx, req.(*Req))
+				return resp, toStatus(err)
+			}
+			if nil == interceptor {
+				return handler(ctx, req)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + ServiceName + "/" + name}
+			return interceptor(ctx, req, info, handler)
+		},
+	}
+}
+
+// watch описывает потоковый вызов сервиса (поток от сервера к клиенту)
+func watch[Resp any](name string, call func(s *Server, ctx context.Context, send func(*Resp) error) error) grpc.StreamDesc {
+	return grpc.StreamDesc{
+		StreamName:    name,
+		ServerStreams: true,
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			var req Empty
+			if err := stream.RecvMsg(&req); nil != err {
+				return err
+			}
+			send := func(resp *Resp) error { return stream.SendMsg(resp) }
+			return toStatus(call(srv.(*Server), stream.Context(), send))
+		},
+	}
+}
+
+// serviceDesc описание сервиса для grpc.Server.RegisterService
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		unary("Boards", (*Server).boards),
+		unary("Transfer", (*Server).transfer),
+
+		unary("SetDAC", (*Server).setDAC),
+		unary("GetDAC", (*Server).getDAC),
+		unary("SetPressure", (*Server).setPressure),
+		unary("SetFreq", (*Server).setFreq),
+		unary("GetFreq", (*Server).getFreq),
+		unary("GetBinaryInputs", (*Server).getBinaryInputs),
+
+		unary("Set10V", (*Server).set10V),
+		unary("Set50V", (*Server).set50V),
+		unary("UintSet10V", (*Server).uintSet10V),
+		unary("UintSet50V", (*Server).uintSet50V),
+		unary("GetOutputs", (*Server).getOutputs),
+		unary("SetIF", (*Server).setIF),
+		unary("SetTURT", (*Server).setTURT),
+
+		unary("SetSpeed", (*Server).setSpeed),
+		unary("SetAcceleration", (*Server).setAcceleration),
+		unary("SetMotion", (*Server).setMotion),
+		unary("SetLimitWay", (*Server).setLimitWay),
+		unary("GetSpeed", (*Server).getSpeed),
+		unary("EnableADC", (*Server).enableADC),
+		unary("GetADC", (*Server).getADC),
+		unary("GetVersion", (*Server).getVersion),
+	},
+	Streams: []grpc.StreamDesc{
+		watch("WatchSpeed", (*Server).watchSpeed),
+		watch("WatchWay", (*Server).watchWay),
+		watch("WatchADC", (*Server).watchADC),
+		watch("WatchInputs", (*Server).watchInputs),
+	},
+}
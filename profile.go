@@ -0,0 +1,292 @@
+package ipk
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+var prfErrorMoving = newError(ErrInvalidParam, `направление движения можно менять только после остановки`, `direction can be changed only when stopped`)
+
+// DefaultProfileInterval период опроса ФЧС-3 при воспроизведении профиля по умолчанию
+const DefaultProfileInterval = 20 * time.Millisecond
+
+// Виды участков профиля движения (см. Segment)
+const (
+	SegmentAccelerate = iota // разгон или торможение до скорости Speed с ускорением Accel
+	SegmentCruise            // движение с постоянной скоростью на расстояние Distance или в течение Duration
+	SegmentMotion            // смена направления движения на Direction (только после остановки)
+	SegmentDwell             // стоянка или движение без изменений в течение Duration
+	SegmentBrake             // торможение до скорости Speed (не ниже 0) с замедлением Accel, скорость не повышается
+)
+
+// Segment участок профиля движения. Удобнее создавать функциями
+// Accelerate, Brake, BrakeToStop, Cruise, CruiseFor, Onward, Backwards, Dwell.
+type Segment struct {
+	Kind      int
+	Speed     float64       // целевая скорость, км/ч (SegmentAccelerate, SegmentBrake)
+	Accel     float64       // модуль ускорения, м/с² (SegmentAccelerate, SegmentBrake); 0 - скорость задаётся сразу
+	Distance  float64       // путь, м (SegmentCruise)
+	Duration  time.Duration // время (SegmentCruise, если Distance равно 0, и SegmentDwell)
+	Direction uint8         // направление MotionOnward или MotionBackwards (SegmentMotion)
+}
+
+// Accelerate разгон до скорости kmh (км/ч) с ускорением accel (м/с²)
+func Accelerate(kmh, accel float64) Segment {
+	return Segment{Kind: SegmentAccelerate, Speed: kmh, Accel: accel}
+}
+
+// Brake торможение до скорости kmh (км/ч) с замедлением accel (м/с²).
+// В отличие от Accelerate, знак accel не важен, отрицательная скорость kmh заменяется на 0,
+// а если текущая скорость уже не выше kmh, участок заканчивается сразу без изменения скорости.
+func Brake(kmh, accel float64) Segment {
+	return Segment{Kind: SegmentBrake, Speed: kmh, Accel: accel}
+}
+
+// BrakeToStop торможение до остановки с замедлением accel (м/с²)
+func BrakeToStop(accel float64) Segment {
+	return Brake(0, accel)
+}
+
+// Cruise движение с постоянной скоростью на расстояние meters (м)
+func Cruise(meters float64) Segment {
+	return Segment{Kind: SegmentCruise, Distance: meters}
+}
+
+// CruiseFor движение с постоянной скоростью в течение d
+func CruiseFor(d time.Duration) Segment {
+	return Segment{Kind: SegmentCruise, Duration: d}
+}
+
+// Onward смена направления движения на "вперёд"
+func Onward() Segment {
+	return Segment{Kind: SegmentMotion, Direction: MotionOnward}
+}
+
+// Backwards смена направления движения на "назад" (реверс)
+func Backwards() Segment {
+	return Segment{Kind: SegmentMotion, Direction: MotionBackwards}
+}
+
+// Dwell стоянка (или движение без изменений) в течение d
+func Dwell(d time.Duration) Segment {
+	return Segment{Kind: SegmentDwell, Duration: d}
+}
+
+// ProfileEvent событие на границе участка профиля
+type ProfileEvent struct {
+	Index   int       // номер участка
+	Segment Segment   // участок
+	Begin   bool      // true - начало участка, false - окончание
+	Time    time.Time // момент события
+	Way     uint32    // пройденный путь по первому генератору, м
+	Speed   float64   // скорость первого генератора, км/ч
+	Err     error     // ошибка, прервавшая участок
+}
+
+// ProfilePlayer воспроизводит профиль движения на ФЧС-3: последовательно выполняет участки,
+// задавая скорость, ускорение и направление обоих генераторов и следя за пройденным
+// путём (GetWay) и скоростью (GetOutputSpeed). Если ФЧС-3 остановилась сама (пройден
+// предельный путь SetLimitWay), разгон и движение на расстояние заканчиваются досрочно.
+type ProfilePlayer struct {
+	Interval time.Duration         // период опроса ФЧС-3
+	OnEvent  func(ev ProfileEvent) // вызывается в начале и в конце каждого участка
+	speed    *Speed
+	now      func() time.Time
+}
+
+// NewProfilePlayer создаёт проигрыватель профиля для ФЧС-3, инициализированного в sp
+func NewProfilePlayer(sp *Speed) *ProfilePlayer {
+	return &ProfilePlayer{Interval: DefaultProfileInterval, speed: sp, now: time.Now}
+}
+
+// Play выполняет участки профиля по порядку и возвращается после окончания последнего.
+// При отмене ctx или ошибке воспроизведение прекращается, а генераторы останавливаются
+// (скорость и ускорение сбрасываются в 0).
+func (pp *ProfilePlayer) Play(ctx context.Context, segments []Segment) (err error) {
+	if nil == pp || !pp.speed.initialized() {
+		err = fmt.Errorf("ProfilePlayer.Play():%w", frqErrorSpeedNotInitialized)
+		return
+	}
+	defer func() {
+		if nil != err {
+			pp.halt()
+		}
+	}()
+
+	for i, seg := range segments {
+		pp.notify(ctx, i, seg, true, nil)
+		err = pp.play(ctx, seg)
+		pp.notify(ctx, i, seg, false, err)
+		if nil != err {
+			err = fmt.Errorf("ProfilePlayer.Play():%w", err)
+			return
+		}
+	}
+	return
+}
+
+func (pp *ProfilePlayer) play(ctx context.Context, seg Segment) (err error) {
+	sp := pp.speed
+	switch seg.Kind {
+	case SegmentAccelerate:
+		err = pp.accelerate(ctx, seg.Speed, seg.Accel)
+	case SegmentBrake:
+		err = pp.brake(ctx, seg.Speed, seg.Accel)
+	case SegmentCruise:
+		var start uint32
+		if start, _, err = pp.way(ctx); nil != err {
+			return
+		}
+		if seg.Distance > 0 {
+			err = pp.wait(ctx, 0, func() (done bool, err error) {
+				var way uint32
+				way, _, err = pp.way(ctx)
+				done = (way >= start && float64(way-start) >= seg.Distance) || pp.stopped()
+				return
+			})
+		} else {
+			err = pp.wait(ctx, seg.Duration, nil)
+		}
+	case SegmentMotion:
+		var kmh float64
+		if kmh, err = pp.currentSpeed(ctx); nil != err {
+			return
+		}
+		if kmh > 0 {
+			err = prfErrorMoving
+			return
+		}
+		err = sp.SetMotionCtx(ctx, seg.Direction)
+	case SegmentDwell:
+		err = pp.wait(ctx, seg.Duration, nil)
+	default:
+		err = frqErrorWrongParam
+	}
+	return
+}
+
+// accelerate меняет скорость до kmh с ускорением accel (м/с²)
+func (pp *ProfilePlayer) accelerate(ctx context.Context, kmh, accel float64) (err error) {
+	sp := pp.speed
+	if kmh < 0 || accel < 0 {
+		return frqErrorWrongParam
+	}
+	var v0 float64
+	if v0, err = pp.currentSpeed(ctx); nil != err {
+		return
+	}
+	if 0 != accel && v0 != kmh {
+		a := accel * 100 // SetAcceleration принимает ускорение в 0,01 м/с²
+		up := kmh > v0
+		if !up {
+			a = -a
+		}
+		if err = sp.SetAccelerationCtx(ctx, a, a); nil != err {
+			return
+		}
+		var stopped bool
+		err = pp.wait(ctx, 0, func() (done bool, err error) {
+			var v float64
+			v, err = pp.currentSpeed(ctx)
+			stopped = nil == err && pp.stopped()
+			done = (up && v >= kmh) || (!up && v <= kmh) || stopped
+			return
+		})
+		if nil != err {
+			return
+		}
+		if err = sp.SetAccelerationCtx(ctx, 0, 0); nil != err || stopped {
+			// плата остановилась сама, скорость kmh не задаём, чтобы не продолжить движение
+			return
+		}
+	}
+	err = sp.SetSpeedCtx(ctx, kmh, kmh)
+	return
+}
+
+// brake снижает скорость до kmh (не ниже 0) с замедлением accel (м/с²). Скорость не повышается.
+func (pp *ProfilePlayer) brake(ctx context.Context, kmh, accel float64) (err error) {
+	kmh = math.Max(kmh, 0)
+	var v0 float64
+	if v0, err = pp.currentSpeed(ctx); nil != err || v0 <= kmh {
+		return
+	}
+	return pp.accelerate(ctx, kmh, math.Abs(accel))
+}
+
+// wait опрашивает ФЧС-3 с периодом Interval, пока check не вернёт true
+// или не пройдёт время d (если больше 0). Без check и при d не больше 0 сразу возвращается.
+func (pp *ProfilePlayer) wait(ctx context.Context, d time.Duration, check func() (bool, error)) (err error) {
+	if nil == check && d <= 0 {
+		return ctx.Err()
+	}
+	interval := pp.Interval
+	if interval <= 0 {
+		interval = DefaultProfileInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	start := pp.now()
+	for {
+		if nil != check {
+			var done bool
+			if done, err = check(); nil != err || done {
+				return
+			}
+		}
+		if d > 0 && pp.now().Sub(start) >= d {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// stopped показывает, что по последним данным ФЧС-3 первый генератор остановился сам:
+// пройден предельный путь (SetLimitWay) или скорость и ускорение равны 0
+func (pp *ProfilePlayer) stopped() bool {
+	fd := pp.speed.dev.freqData()
+	return (0 != fd.limitWay1 && fd.way1count >= fd.limitWay1) || (0 == fd.freq1 && 0 == fd.freq1delta)
+}
+
+// way обновляет данные ФЧС-3 и возвращает пройденный путь
+func (pp *ProfilePlayer) way(ctx context.Context) (way1, way2 uint32, err error) {
+	if err = pp.speed.dev.UpdateFreqDataUSBCtx(ctx); nil != err {
+		return
+	}
+	return pp.speed.GetWay()
+}
+
+// currentSpeed обновляет данные ФЧС-3 и возвращает скорость первого генератора
+func (pp *ProfilePlayer) currentSpeed(ctx context.Context) (kmh float64, err error) {
+	if err = pp.speed.dev.UpdateFreqDataUSBCtx(ctx); nil != err {
+		return
+	}
+	kmh, _, err = pp.speed.GetOutputSpeed()
+	kmh = math.Round(kmh*1000) / 1000 // погрешность пересчёта из значения частоты
+	return
+}
+
+// halt останавливает генераторы после прерывания профиля
+func (pp *ProfilePlayer) halt() {
+	ctx := context.Background()
+	pp.speed.SetAccelerationCtx(ctx, 0, 0)
+	pp.speed.SetSpeedCtx(ctx, 0, 0)
+}
+
+func (pp *ProfilePlayer) notify(ctx context.Context, index int, seg Segment, begin bool, err error) {
+	if nil == pp.OnEvent {
+		return
+	}
+	ev := ProfileEvent{Index: index, Segment: seg, Begin: begin, Time: pp.now(), Err: err}
+	if nil == pp.speed.dev.UpdateFreqDataUSBCtx(ctx) {
+		ev.Way, _, _ = pp.speed.GetWay()
+		ev.Speed, _, _ = pp.speed.GetOutputSpeed()
+	}
+	pp.OnEvent(ev)
+}
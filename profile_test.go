@@ -0,0 +1,123 @@
+package ipk
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+// playProfile воспроизводит профиль на симуляторе ФЧС-3. Время симулятора и проигрывателя
+// идёт по часам теста: 20 мс за каждую миллисекунду реального времени.
+func playProfile(t *testing.T, segments []Segment) (events []ProfileEvent, err error) {
+	t.Helper()
+	dev, _, clock := openFreqSimulator(t)
+	var sp Speed
+	if err := sp.Init(dev, 42, 1350); nil != err {
+		t.Fatal(err)
+	}
+	pp := NewProfilePlayer(&sp)
+	pp.now = clock.now
+	pp.Interval = time.Millisecond
+	var mutex sync.Mutex
+	pp.OnEvent = func(ev ProfileEvent) {
+		mutex.Lock()
+		events = append(events, ev)
+		mutex.Unlock()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				clock.add(20 * time.Millisecond)
+			}
+		}
+	}()
+	err = pp.Play(ctx, segments)
+	close(done)
+	return
+}
+
+func TestProfilePlayer(t *testing.T) {
+	events, err := playProfile(t, []Segment{
+		Accelerate(36, 2),  // 0 -> 10 м/с за 5 с
+		Cruise(50),         // 50 м при 10 м/с - 5 с
+		Brake(72, 1),       // скорость ниже 72 км/ч: участок заканчивается сразу
+		Brake(0, -2),       // знак замедления не важен: 5 с до остановки
+		Dwell(time.Second), // стоянка 1 с
+		Backwards(),        // смена направления после остановки
+		Accelerate(18, 0),  // скорость задаётся сразу
+		Brake(-10, 0),      // отрицательная скорость - до остановки
+	})
+	if nil != err {
+		t.Fatal(err)
+	}
+	const tolerance = 400 * time.Millisecond // запаздывание опроса по часам теста
+	tests := []struct {
+		name     string
+		duration time.Duration // наименьшая длительность участка
+		speed    float64       // скорость в конце участка, км/ч
+	}{
+		{"разгон", 5 * time.Second, 36},
+		{"движение на 50 м", 4900 * time.Millisecond, 36}, // путь считается целыми метрами: 0,1 с при 10 м/с
+		{"торможение до большей скорости", 0, 36},
+		{"торможение до остановки", 5 * time.Second, 0},
+		{"стоянка", time.Second, 0},
+		{"назад", 0, 0},
+		{"скорость сразу", 0, 18},
+		{"торможение до отрицательной скорости", 0, 0},
+	}
+	if 2*len(tests) != len(events) {
+		t.Fatalf("событий %d, want %d", len(events), 2*len(tests))
+	}
+	for i, tt := range tests {
+		begin, end := events[2*i], events[2*i+1]
+		if !begin.Begin || end.Begin || i != begin.Index || i != end.Index || nil != end.Err {
+			t.Errorf("%s: события %+v, %+v", tt.name, begin, end)
+			continue
+		}
+		if d := end.Time.Sub(begin.Time); d < tt.duration || d > tt.duration+tolerance {
+			t.Errorf("%s: длительность %v, want %v", tt.name, d, tt.duration)
+		}
+		if math.Abs(end.Speed-tt.speed) > 0.01 {
+			t.Errorf("%s: скорость в конце %v, want %v", tt.name, end.Speed, tt.speed)
+		}
+	}
+	// путь за разгон 25 м, за движение - ещё 50 м
+	if way := events[3].Way; way < 75 || way > 80 {
+		t.Errorf("путь после движения на 50 м: %d м, want 75", way)
+	}
+}
+
+func TestProfilePlayerErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		segments []Segment
+		want     error
+	}{
+		{"отрицательная скорость разгона", []Segment{Accelerate(-10, 1)}, ErrInvalidParam},
+		{"смена направления в движении", []Segment{Accelerate(18, 0), Backwards()}, prfErrorMoving},
+		{"неизвестный участок", []Segment{{Kind: -1}}, ErrInvalidParam},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := playProfile(t, tt.segments)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Play() = %v, want %v", err, tt.want)
+			}
+			if last := events[len(events)-1]; !errors.Is(last.Err, tt.want) {
+				t.Errorf("последнее событие: Err = %v", last.Err)
+			}
+		})
+	}
+}
@@ -0,0 +1,422 @@
+package ipk
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Виды записей Recorder
+const (
+	RecordCommand     = "command"     // команда, отправленная в плату
+	RecordMeasurement = "measurement" // значение, полученное из платы
+)
+
+// Record одна запись журнала Recorder
+type Record struct {
+	Time   time.Time `json:"time"`
+	Device string    `json:"device"`          // плата: ФАС-3, ФДС-3, ФЧС-3
+	Kind   string    `json:"kind"`            // RecordCommand или RecordMeasurement
+	Name   string    `json:"name"`            // величина, например DAC3, 50V.12, Hz1, Dat1
+	Value  float64   `json:"value"`           // значение
+	Unit   string    `json:"unit,omitempty"`  // единицы измерения
+	Err    string    `json:"error,omitempty"` // ошибка отправки команды
+}
+
+// recordWriter формат файла журнала
+type recordWriter interface {
+	write(rec *Record) error
+	flush() error
+}
+
+type csvRecordWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func (cw *csvRecordWriter) write(rec *Record) error {
+	if !cw.header {
+		cw.header = true
+		if err := cw.w.Write([]string{"time", "device", "kind", "name", "value", "unit", "error"}); nil != err {
+			return err
+		}
+	}
+	return cw.w.Write([]string{
+		rec.Time.Format(time.RFC3339Nano),
+		rec.Device,
+		rec.Kind,
+		rec.Name,
+		strconv.FormatFloat(rec.Value, 'f', -1, 64),
+		rec.Unit,
+		rec.Err,
+	})
+}
+
+func (cw *csvRecordWriter) flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type jsonRecordWriter struct {
+	enc *json.Encoder
+}
+
+func (jw *jsonRecordWriter) write(rec *Record) error {
+	return jw.enc.Encode(rec)
+}
+
+func (jw *jsonRecordWriter) flush() error {
+	return nil
+}
+
+// Recorder записывает в журнал с метками времени все команды, отправленные в платы ФПС-3
+// (значения ЦАП в мА, выходы 10/50 В, код ИФ, TURT, частота, ускорение, направление,
+// путь перемещения), и все полученные из плат значения (двоичные входы ФАС-3,
+// счётчики пути и частота ФЧС-3, данные АЦП в мА).
+// Записи делаются на уровне обмена по USB, поэтому фиксируются все вызовы
+// функций библиотеки, в том числе из Generator, ProfilePlayer, FreqPoller и т.д.
+// Значения, полученные из плат, записываются только при изменении.
+type Recorder struct {
+	// Speed - если задан, для ФЧС-3 дополнительно записываются скорость (км/ч) и путь (м)
+	Speed *Speed
+
+	mutex sync.Mutex
+	out   recordWriter
+	now   func() time.Time
+	err   error
+	ipk   *IPK
+
+	// последние известные значения для записи только изменений
+	dac     [analogCount]uint16
+	dacOK   bool
+	freq    [freqCount]uint16
+	freqOK  bool
+	inputs  uint16
+	inOK    bool
+	binOut  uint64
+	binOK   bool
+	values  map[string]float64 // измерения ФЧС-3
+	anlCaps Capabilities       // возможности ФАС-3 (диапазоны ЦАП)
+	frqCaps Capabilities       // возможности ФЧС-3 (полярность направления движения)
+}
+
+// NewCSVRecorder создаёт журнал в формате CSV (первая строка - заголовок)
+func NewCSVRecorder(w io.Writer) *Recorder {
+	return &Recorder{out: &csvRecordWriter{w: csv.NewWriter(w)}, now: time.Now}
+}
+
+// NewJSONRecorder создаёт журнал в формате JSON lines (одна запись Record на строку)
+func NewJSONRecorder(w io.Writer) *Recorder {
+	return &Recorder{out: &jsonRecordWriter{enc: json.NewEncoder(w)}, now: time.Now}
+}
+
+// Attach начинает запись обмена со всеми платами ipk, созданными к моменту вызова
+// (например, после OpenAll). Запись продолжается после переподключения плат.
+func (r *Recorder) Attach(ipk *IPK) {
+	if nil == r || nil == ipk {
+		return
+	}
+	r.Detach()
+	r.mutex.Lock()
+	r.ipk = ipk
+	r.dacOK, r.freqOK, r.inOK, r.binOK = false, false, false, false
+	r.values = make(map[string]float64)
+	r.anlCaps = ipk.AnalogDev.Capabilities()
+	r.frqCaps = ipk.FreqDev.Capabilities()
+	r.mutex.Unlock()
+
+	if nil != ipk.AnalogDev {
+		ipk.AnalogDev.observe(r.analog)
+	}
+	if nil != ipk.BinDev {
+		ipk.BinDev.observe(r.binary)
+	}
+	if nil != ipk.FreqDev {
+		ipk.FreqDev.observe(r.frequency)
+	}
+}
+
+// Detach прекращает запись и сбрасывает буферы журнала
+func (r *Recorder) Detach() (err error) {
+	if nil == r {
+		return
+	}
+	r.mutex.Lock()
+	ipk := r.ipk
+	r.ipk = nil
+	r.mutex.Unlock()
+	if nil != ipk {
+		if nil != ipk.AnalogDev {
+			ipk.AnalogDev.observe(nil)
+		}
+		if nil != ipk.BinDev {
+			ipk.BinDev.observe(nil)
+		}
+		if nil != ipk.FreqDev {
+			ipk.FreqDev.observe(nil)
+		}
+	}
+	return r.Flush()
+}
+
+// Flush сбрасывает буферы журнала
+func (r *Recorder) Flush() (err error) {
+	if nil == r {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err = r.out.flush(); nil != err && nil == r.err {
+		r.err = err
+	}
+	return
+}
+
+// Err возвращает первую ошибку записи в журнал
+func (r *Recorder) Err() error {
+	if nil == r {
+		return nil
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.err
+}
+
+// Record добавляет в журнал произвольную запись (например, отметку о начале этапа испытаний).
+// Если rec.Time не задано, используется текущее время.
+func (r *Recorder) Record(rec Record) {
+	if nil == r {
+		return
+	}
+	r.mutex.Lock()
+	if rec.Time.IsZero() {
+		rec.Time = r.now()
+	}
+	r.write(&rec)
+	r.mutex.Unlock()
+}
+
+// write записывает запись в журнал. Вызывается под mutex.
+func (r *Recorder) write(rec *Record) {
+	if err := r.out.write(rec); nil != err && nil == r.err {
+		r.err = err
+	}
+}
+
+// add записывает значение с текущим временем. Вызывается под mutex.
+func (r *Recorder) add(device, kind, name string, value float64, unit string, err error) {
+	rec := Record{Time: r.now(), Device: device, Kind: kind, Name: name, Value: value, Unit: unit}
+	if nil != err {
+		rec.Err = err.Error()
+	}
+	r.write(&rec)
+}
+
+// measure записывает измерение ФЧС-3, если оно изменилось. Вызывается под mutex.
+func (r *Recorder) measure(name string, value float64, unit string) {
+	if last, ok := r.values[name]; ok && last == value {
+		return
+	}
+	r.values[name] = value
+	r.add(deviceFRQ, RecordMeasurement, name, value, unit, nil)
+}
+
+const (
+	deviceANL = "ФАС-3"
+	deviceBIN = "ФДС-3"
+	deviceFRQ = "ФЧС-3"
+)
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// analog обрабатывает обмен с ФАС-3
+func (r *Recorder) analog(direction, request byte, data []byte, err error) {
+	if 0xB0 != request {
+		return
+	}
+	var as analogDeviceData
+	if !as.setFromBytes(data) {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if VendorRequestInput == direction {
+		if nil != err {
+			return
+		}
+		if !r.inOK || r.inputs != as.binary[0] {
+			for i := uint16(0); i < 16; i++ {
+				bit := uint16(1) << i
+				if !r.inOK || (r.inputs^as.binary[0])&bit != 0 {
+					r.add(deviceANL, RecordMeasurement, fmt.Sprintf("IN%d", i), boolValue(0 != as.binary[0]&bit), "", nil)
+				}
+			}
+			r.inputs, r.inOK = as.binary[0], true
+		}
+		r.dac, r.dacOK = as.analog, true
+		r.freq, r.freqOK = as.freq, true
+		return
+	}
+
+	for ch, val := range as.analog {
+		if r.dacOK && r.dac[ch] == val {
+			continue
+		}
+		if maxDAC, maxMilliAmper, ok := r.anlCaps.dacChannel(uint8(ch)); ok {
+			ma := float64(val) * float64(maxMilliAmper) / float64(maxDAC)
+			r.add(deviceANL, RecordCommand, fmt.Sprintf("DAC%d", ch+1), ma, "mA", err)
+		} else {
+			r.add(deviceANL, RecordCommand, fmt.Sprintf("DAC%d", ch+1), float64(val), "", err)
+		}
+	}
+	for ch, val := range as.freq {
+		if r.freqOK && r.freq[ch] == val {
+			continue
+		}
+		r.add(deviceANL, RecordCommand, fmt.Sprintf("FREQ%d", ch+1), float64(val), "code", err)
+	}
+	if nil == err {
+		r.dac, r.dacOK = as.analog, true
+		r.freq, r.freqOK = as.freq, true
+	}
+}
+
+// binary обрабатывает обмен с ФДС-3
+func (r *Recorder) binary(direction, request byte, data []byte, err error) {
+	if 0 == len(data) {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if VendorRequestInput == direction {
+		if nil == err && 0xB0 == request && len(data) >= 8 {
+			var bindata binaryData
+			copy(bindata.data[:], data)
+			r.binOut, r.binOK = bindata.Uint64(), true
+		}
+		return
+	}
+
+	switch request {
+	case 0xB0:
+		if len(data) < 8 {
+			return
+		}
+		var bindata binaryData
+		copy(bindata.data[:], data)
+		image := bindata.Uint64()
+		// выход включен, когда бит сброшен
+		for i := uint(0); i < 8; i++ {
+			bit := uint64(1) << i
+			if !r.binOK || (r.binOut^image)&bit != 0 {
+				r.add(deviceBIN, RecordCommand, fmt.Sprintf("10V.%d", i), boolValue(0 == image&bit), "", err)
+			}
+		}
+		for i := uint(0); i < 36; i++ {
+			bit := uint64(1) << (i + 8)
+			if binIF50V == i {
+				continue
+			}
+			if !r.binOK || (r.binOut^image)&bit != 0 {
+				r.add(deviceBIN, RecordCommand, fmt.Sprintf("50V.%d", i), boolValue(0 == image&bit), "", err)
+			}
+		}
+		if nil == err {
+			r.binOut, r.binOK = image, true
+		}
+	case 0xB1:
+		r.add(deviceBIN, RecordCommand, "IF", float64(data[0]), "code", err)
+	case 0xB2:
+		r.add(deviceBIN, RecordCommand, "TURT", boolValue(0 != data[0]), "", err)
+	}
+}
+
+// frequency обрабатывает обмен с ФЧС-3
+func (r *Recorder) frequency(direction, request byte, data []byte, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	sp := r.Speed
+	if !sp.initialized() {
+		sp = nil
+	}
+	hz := func(freq uint32) float64 { return (float64(freq) * magicClock) / (magicK * 4) }
+	deltaHz := func(delta int32) float64 { return (float64(delta) * magicClock / magicK) / 4 }
+
+	if VendorRequestInput == direction {
+		if nil != err {
+			return
+		}
+		switch request {
+		case 0xB0:
+			var fd dataFreq
+			if !fd.setFromBytes(data) {
+				return
+			}
+			r.measure("Hz1", hz(fd.freq1), "Hz")
+			r.measure("Hz2", hz(fd.freq2), "Hz")
+			r.measure("WayCount1", float64(fd.way1count), "pulses")
+			r.measure("WayCount2", float64(fd.way2count), "pulses")
+			if nil != sp {
+				r.measure("Speed1", sp.kmh(hz(fd.freq1)), "km/h")
+				r.measure("Speed2", sp.kmh(hz(fd.freq2)), "km/h")
+				r.measure("Way1", sp.meters(fd.way1count), "m")
+				r.measure("Way2", sp.meters(fd.way2count), "m")
+			}
+		case 0xB1:
+			var adc DataADC
+			if !adc.setFromBytes(data) || 0 == adc.DivisorVal {
+				return
+			}
+			r.measure("Dat1", convertDACToMilliAmper(adc.Dat1, adc.DivisorVal, 487, 2500), "mA")
+			r.measure("Dat2", convertDACToMilliAmper(adc.Dat2, adc.DivisorVal, 121, 2500), "mA")
+			r.measure("Ref", convertDACToMilliAmper(adc.ReferenceVal, adc.DivisorVal, 487, 2500), "mA")
+		}
+		return
+	}
+
+	switch request {
+	case 0xB0:
+		var fd dataFreq
+		if !fd.setFromBytes(data) {
+			return
+		}
+		switch fd.cmd {
+		case 1:
+			r.add(deviceFRQ, RecordCommand, "DeltaHz1", deltaHz(fd.freq1delta), "Hz/s", err)
+			r.add(deviceFRQ, RecordCommand, "DeltaHz2", deltaHz(fd.freq2delta), "Hz/s", err)
+		case 2:
+			r.add(deviceFRQ, RecordCommand, "Hz1", hz(fd.freq1), "Hz", err)
+			r.add(deviceFRQ, RecordCommand, "Hz2", hz(fd.freq2), "Hz", err)
+			if nil != sp {
+				r.add(deviceFRQ, RecordCommand, "Speed1", sp.kmh(hz(fd.freq1)), "km/h", err)
+				r.add(deviceFRQ, RecordCommand, "Speed2", sp.kmh(hz(fd.freq2)), "km/h", err)
+			}
+		case 3:
+			r.add(deviceFRQ, RecordCommand, "WayCount1", float64(fd.way1count), "pulses", err)
+			r.add(deviceFRQ, RecordCommand, "WayCount2", float64(fd.way2count), "pulses", err)
+		case 5:
+			r.add(deviceFRQ, RecordCommand, "Motion", float64(r.frqCaps.motionFromBoard(fd.motion)), "", err)
+		case 6:
+			r.add(deviceFRQ, RecordCommand, "LimitWay", float64(fd.limitWay1), "pulses", err)
+			if nil != sp {
+				r.add(deviceFRQ, RecordCommand, "LimitWayMeters", sp.meters(fd.limitWay1), "m", err)
+			}
+		}
+	case 0xB2:
+		if len(data) > 0 {
+			r.add(deviceFRQ, RecordCommand, "ADCMode", boolValue(0 != data[0]), "", err)
+		}
+	}
+}
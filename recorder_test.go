@@ -0,0 +1,166 @@
+package ipk
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// recordSession выполняет на симуляторах сценарий с шагом 1 с по часам теста и записывает
+// его в журнал r. Возвращает ожидаемые записи журнала.
+func recordSession(t *testing.T, r *Recorder) (want []Record) {
+	t.Helper()
+	clock := newFakeClock()
+	var ipk IPK
+	sims := ipk.OpenSimulators(IDProductANL16bit)
+	defer ipk.CloseAll()
+	sims.Binary.SetClock(clock.now)
+	sims.Freq.SetClock(clock.now)
+	sims.Analog.SetBinaryInput(0x0005)
+	r.now = clock.now
+	r.Attach(&ipk)
+	check := func(err error) {
+		t.Helper()
+		if nil != err {
+			t.Fatal(err)
+		}
+	}
+	expect := func(device, kind, name string, value float64, unit string) {
+		want = append(want, Record{Time: clock.now(), Device: device, Kind: kind, Name: name, Value: value, Unit: unit})
+	}
+
+	r.Record(Record{Kind: "mark", Name: "начало"})
+	expect("", "mark", "начало", 0, "")
+
+	// ФАС-3: при первом чтении записываются все двоичные входы, при записи - изменённый канал ЦАП
+	clock.add(time.Second)
+	var dac DAC
+	check(dac.Init(ipk.AnalogDev, DAC2))
+	check(dac.SetMilliAmper(10))
+	for i := 0; i < 16; i++ {
+		expect(deviceANL, RecordMeasurement, "IN"+strconv.Itoa(i), boolValue(0 == i || 2 == i), "")
+	}
+	code := MilliAmperToDAC(10, dac.maxDAC, dac.maxMilliAmper)
+	expect(deviceANL, RecordCommand, "DAC2", float64(code)*float64(dac.maxMilliAmper)/float64(dac.maxDAC), "mA")
+
+	// ФДС-3
+	clock.add(time.Second)
+	check(ipk.BinDev.Set50V(3, true))
+	expect(deviceBIN, RecordCommand, "50V.3", 1, "")
+	check(ipk.BinDev.SetIF(IFGreen16))
+	expect(deviceBIN, RecordCommand, "IF", IFGreen16, "code")
+	check(ipk.BinDev.SetTURT(true))
+	expect(deviceBIN, RecordCommand, "TURT", 1, "")
+
+	// ФЧС-3
+	clock.add(time.Second)
+	var sp Speed
+	check(sp.Init(ipk.FreqDev, 42, 1350))
+	check(sp.SetMotion(MotionBackwards))
+	expect(deviceFRQ, RecordCommand, "Motion", MotionBackwards, "")
+	check(ipk.FreqDev.SetHz(1000, 500))
+	expect(deviceFRQ, RecordCommand, "Hz1", 1000, "Hz")
+	expect(deviceFRQ, RecordCommand, "Hz2", 500, "Hz")
+
+	// измерения записываются только при изменении
+	clock.add(time.Second)
+	check(ipk.FreqDev.UpdateFreqDataUSB())
+	check(ipk.FreqDev.UpdateFreqDataUSB())
+	expect(deviceFRQ, RecordMeasurement, "Hz1", 1000, "Hz")
+	expect(deviceFRQ, RecordMeasurement, "Hz2", 500, "Hz")
+	expect(deviceFRQ, RecordMeasurement, "WayCount1", 1000, "pulses")
+	expect(deviceFRQ, RecordMeasurement, "WayCount2", 500, "pulses")
+	clock.add(time.Second)
+	check(ipk.FreqDev.UpdateFreqDataUSB())
+	expect(deviceFRQ, RecordMeasurement, "WayCount1", 2000, "pulses")
+	expect(deviceFRQ, RecordMeasurement, "WayCount2", 1000, "pulses")
+
+	// после Detach обмен не записывается
+	check(r.Detach())
+	check(ipk.BinDev.SetTURT(false))
+	return
+}
+
+// sameRecord сравнивает записи журнала. Частота, переданная плате, отличается от заданной
+// на погрешность пересчёта, путь - на один импульс.
+func sameRecord(got, want *Record) bool {
+	tolerance := 0.01
+	if "pulses" == want.Unit {
+		tolerance = 1
+	}
+	return got.Time.Equal(want.Time) && got.Device == want.Device && got.Kind == want.Kind &&
+		got.Name == want.Name && got.Unit == want.Unit && got.Err == want.Err &&
+		math.Abs(got.Value-want.Value) <= tolerance
+}
+
+func TestRecorderJSON(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewJSONRecorder(&buf)
+	want := recordSession(t, r)
+	if nil != r.Err() {
+		t.Fatal(r.Err())
+	}
+
+	var got []Record
+	dec := json.NewDecoder(&buf)
+	for {
+		var rec Record
+		if err := dec.Decode(&rec); io.EOF == err {
+			break
+		} else if nil != err {
+			t.Fatal(err)
+		}
+		got = append(got, rec)
+	}
+	if len(want) != len(got) {
+		t.Fatalf("записей %d, want %d:\n%+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if !sameRecord(&got[i], &want[i]) {
+			t.Errorf("запись %d: %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRecorderCSV(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewCSVRecorder(&buf)
+	want := recordSession(t, r)
+	if nil != r.Err() {
+		t.Fatal(r.Err())
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if nil != err {
+		t.Fatal(err)
+	}
+	header := []string{"time", "device", "kind", "name", "value", "unit", "error"}
+	if len(rows) != len(want)+1 || len(rows[0]) != len(header) {
+		t.Fatalf("строк %d, want %d:\n%q", len(rows), len(want)+1, rows)
+	}
+	for i, h := range header {
+		if h != rows[0][i] {
+			t.Errorf("заголовок %q, want %q", rows[0], header)
+			break
+		}
+	}
+	for i, row := range rows[1:] {
+		tm, err := time.Parse(time.RFC3339Nano, row[0])
+		if nil != err {
+			t.Fatal(err)
+		}
+		val, err := strconv.ParseFloat(row[4], 64)
+		if nil != err {
+			t.Fatal(err)
+		}
+		got := Record{Time: tm, Device: row[1], Kind: row[2], Name: row[3], Value: val, Unit: row[5], Err: row[6]}
+		if !sameRecord(&got, &want[i]) {
+			t.Errorf("строка %d: %q, want %+v", i+1, row, want[i])
+		}
+	}
+}
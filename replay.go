@@ -0,0 +1,282 @@
+package ipk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+var replayErrorEnd = newError(ErrNotConnected, `Записанный обмен закончился`, `Captured session is over`)
+var replayErrorMismatch = newError(ErrInvalidParam, `Обмен не совпадает с записанным`, `Exchange does not match capture`)
+var replayErrorClosed = newError(ErrNotConnected, `Транспорт воспроизведения закрыт`, `Replay transport is closed`)
+
+// Replay воспроизводит обмен с платами ФПС-3, записанный Capture, что позволяет
+// повторить сеанс работы с реальной стойкой без подключенного оборудования.
+// Каждая плата получает свой ReplayTransport, который отвечает на запросы
+// записанными данными в том же порядке, в каком они были получены от платы.
+// Запросы, не совпадающие с записью, завершаются ошибкой, первая из них доступна через Err.
+type Replay struct {
+	SkipDataCheck bool // не сравнивать данные, отправленные в плату, с записанными
+	Realtime      bool // выдерживать записанные интервалы между обменами
+
+	mutex      sync.Mutex
+	exchanges  map[uint16][]Exchange
+	transports map[uint16]*ReplayTransport
+	started    bool
+	start      time.Time
+	first      time.Duration
+}
+
+// NewReplay создаёт воспроизведение из списка обменов
+func NewReplay(exchanges []Exchange) *Replay {
+	rp := &Replay{exchanges: make(map[uint16][]Exchange), transports: make(map[uint16]*ReplayTransport)}
+	for _, e := range exchanges {
+		rp.exchanges[e.Product] = append(rp.exchanges[e.Product], e)
+	}
+	return rp
+}
+
+// LoadReplay читает запись Capture (JSON lines) и создаёт воспроизведение
+func LoadReplay(r io.Reader) (rp *Replay, err error) {
+	var exchanges []Exchange
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if 0 == len(text) {
+			continue
+		}
+		var e Exchange
+		if err = json.Unmarshal(text, &e); nil != err {
+			err = fmt.Errorf("LoadReplay(): строка %d: %w", line, err)
+			return
+		}
+		exchanges = append(exchanges, e)
+	}
+	if err = scanner.Err(); nil != err {
+		err = fmt.Errorf("LoadReplay():%w", err)
+		return
+	}
+	rp = NewReplay(exchanges)
+	return
+}
+
+// Products возвращает платы, обмен с которыми есть в записи
+func (rp *Replay) Products() (products []uint16) {
+	if nil == rp {
+		return
+	}
+	rp.mutex.Lock()
+	for product := range rp.exchanges {
+		products = append(products, product)
+	}
+	rp.mutex.Unlock()
+	sort.Slice(products, func(i, j int) bool { return products[i] < products[j] })
+	return
+}
+
+// Transport возвращает транспорт, воспроизводящий обмен с платой product.
+// Повторный вызов для той же платы возвращает тот же транспорт.
+func (rp *Replay) Transport(product uint16) *ReplayTransport {
+	if nil == rp {
+		return nil
+	}
+	rp.mutex.Lock()
+	defer rp.mutex.Unlock()
+	rt, ok := rp.transports[product]
+	if !ok {
+		rt = &ReplayTransport{replay: rp, product: product, exchanges: rp.exchanges[product]}
+		rp.transports[product] = rt
+	}
+	return rt
+}
+
+// Open соединяет платы ipk с транспортами воспроизведения для всех плат, которые есть в записи.
+// Возвращает true, если в записи есть хотя бы одна плата.
+func (rp *Replay) Open(ipk *IPK) (ok bool) {
+	if nil == rp || nil == ipk {
+		return
+	}
+	for _, product := range rp.Products() {
+		switch product {
+		case IDProductANL12bit, IDProductANL16bit:
+			if nil == ipk.AnalogDev {
+				ipk.AnalogDev = new(AnalogDevice)
+			}
+			ok = ipk.AnalogDev.OpenTransport(rp.Transport(product), product) || ok
+		case IDProductBIN:
+			if nil == ipk.BinDev {
+				ipk.BinDev = new(BinaryDevice)
+			}
+			ok = ipk.BinDev.OpenTransport(rp.Transport(product)) || ok
+		case IDProductFRQ:
+			if nil == ipk.FreqDev {
+				ipk.FreqDev = new(FreqDevice)
+			}
+			ok = ipk.FreqDev.OpenTransport(rp.Transport(product)) || ok
+		}
+	}
+	return
+}
+
+// Remaining возвращает количество ещё не воспроизведённых обменов со всеми платами
+func (rp *Replay) Remaining() (n int) {
+	for _, product := range rp.Products() {
+		n += rp.Transport(product).Remaining()
+	}
+	return
+}
+
+// Err возвращает первое расхождение с записью среди всех плат
+func (rp *Replay) Err() (err error) {
+	for _, product := range rp.Products() {
+		if err = rp.Transport(product).Err(); nil != err {
+			return
+		}
+	}
+	return
+}
+
+// wait выдерживает интервал до обмена, записанного в момент at (режим Realtime)
+func (rp *Replay) wait(at time.Duration) {
+	rp.mutex.Lock()
+	if !rp.Realtime {
+		rp.mutex.Unlock()
+		return
+	}
+	if !rp.started {
+		rp.started = true
+		rp.start = time.Now()
+		rp.first = at
+	}
+	delay := time.Until(rp.start.Add(at - rp.first))
+	rp.mutex.Unlock()
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// ReplayTransport транспорт, воспроизводящий записанный обмен с одной платой (см. Replay.Transport)
+type ReplayTransport struct {
+	replay  *Replay
+	product uint16
+
+	mutex     sync.Mutex
+	exchanges []Exchange
+	pos       int
+	err       error
+	closed    bool
+}
+
+// ControlIn возвращает данные, записанные для очередного запроса чтения
+func (rt *ReplayTransport) ControlIn(request byte, data []byte, timeout time.Duration) (n int, err error) {
+	e, err := rt.next(VendorRequestInput, request, data)
+	if nil != err {
+		err = fmt.Errorf("ReplayTransport.ControlIn():%w", err)
+		return
+	}
+	n = copy(data, e.Data)
+	if e.N < n {
+		n = e.N
+	}
+	err = errorFromExchange(&e)
+	return
+}
+
+// ControlOut проверяет, что отправляемые данные совпадают с записанными,
+// и возвращает записанный результат
+func (rt *ReplayTransport) ControlOut(request byte, data []byte, timeout time.Duration) (n int, err error) {
+	e, err := rt.next(VendorRequestOutput, request, data)
+	if nil != err {
+		err = fmt.Errorf("ReplayTransport.ControlOut():%w", err)
+		return
+	}
+	n = e.N
+	err = errorFromExchange(&e)
+	return
+}
+
+// next возвращает очередной записанный обмен, если он совпадает с запросом
+func (rt *ReplayTransport) next(direction, request byte, data []byte) (e Exchange, err error) {
+	if nil == rt {
+		err = errNoTransport
+		return
+	}
+	rt.mutex.Lock()
+	switch {
+	case rt.closed:
+		err = replayErrorClosed
+	case rt.pos >= len(rt.exchanges):
+		err = fmt.Errorf("%s, запрос 0x%02X:%w", ProductName(rt.product), request, replayErrorEnd)
+	default:
+		e = rt.exchanges[rt.pos]
+		switch {
+		case e.Direction != direction || e.Request != request:
+			err = fmt.Errorf("%s, обмен %d: запрос 0x%02X/0x%02X, записан 0x%02X/0x%02X:%w",
+				ProductName(rt.product), rt.pos, direction, request, e.Direction, e.Request, replayErrorMismatch)
+		case VendorRequestOutput == direction && !rt.replay.SkipDataCheck && !bytes.Equal(e.Data, data):
+			err = fmt.Errorf("%s, обмен %d: отправлено %X, записано %X:%w",
+				ProductName(rt.product), rt.pos, data, e.Data, replayErrorMismatch)
+		default:
+			rt.pos++
+		}
+	}
+	if nil != err && nil == rt.err && !rt.closed {
+		rt.err = err
+	}
+	rt.mutex.Unlock()
+
+	if nil == err {
+		rt.replay.wait(e.At)
+	}
+	return
+}
+
+// Remaining возвращает количество ещё не воспроизведённых обменов
+func (rt *ReplayTransport) Remaining() (n int) {
+	if nil == rt {
+		return
+	}
+	rt.mutex.Lock()
+	n = len(rt.exchanges) - rt.pos
+	rt.mutex.Unlock()
+	return
+}
+
+// Err возвращает первое расхождение с записью
+func (rt *ReplayTransport) Err() (err error) {
+	if nil == rt {
+		return
+	}
+	rt.mutex.Lock()
+	err = rt.err
+	rt.mutex.Unlock()
+	return
+}
+
+// Close закрывает транспорт, дальнейший обмен завершается ошибкой
+func (rt *ReplayTransport) Close() error {
+	if nil == rt {
+		return nil
+	}
+	rt.mutex.Lock()
+	rt.closed = true
+	rt.mutex.Unlock()
+	return nil
+}
+
+// Present показывает, что транспорт не закрыт
+func (rt *ReplayTransport) Present() (ok bool) {
+	if nil == rt {
+		return
+	}
+	rt.mutex.Lock()
+	ok = !rt.closed
+	rt.mutex.Unlock()
+	return
+}
@@ -0,0 +1,170 @@
+package ipk
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// RetryPolicy политика повторных попыток обмена с платой ФПС-3.
+// Обращение по USB иногда приводит к ошибке device not functioning,
+// поэтому каждая операция (например, чтение-изменение-запись значений ЦАП)
+// повторяется, пока не выполнится успешно или не закончатся попытки.
+// Не повторяется только команда перезагрузки с другого банка памяти (RestartToAnotherBank).
+type RetryPolicy struct {
+	MaxAttempts int                  // максимальное количество попыток, 0 - без ограничения (до истечения Timeout)
+	Timeout     time.Duration        // общее время на все попытки, если у ctx не задан срок; 0 - maxDelayUSB
+	Backoff     time.Duration        // пауза перед первой повторной попыткой, 0 - без паузы
+	MaxBackoff  time.Duration        // максимальная пауза между попытками, 0 - без ограничения
+	Multiplier  float64              // во сколько раз увеличивается пауза после каждой попытки (не меньше 1)
+	Retryable   func(err error) bool // можно ли повторить операцию после ошибки err, nil - DefaultRetryable
+}
+
+// DefaultRetryPolicy возвращает политику по умолчанию: попытки в течение 100 мс
+// с паузой от 1 до 10 мс между ними.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		Timeout:    maxDelayUSB,
+		Backoff:    time.Millisecond,
+		MaxBackoff: 10 * time.Millisecond,
+		Multiplier: 2,
+	}
+}
+
+// DefaultRetryable не повторяет операцию, если ошибка не может исчезнуть сама:
+// неверный параметр, неинициализированная структура, отсутствие соединения с платой,
+// а также отмена или истечение срока ctx.
+func DefaultRetryable(err error) bool {
+	switch {
+	case errors.Is(err, ErrInvalidParam),
+		errors.Is(err, ErrNotInitialized),
+		errors.Is(err, ErrNotConnected),
+		errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded):
+		return false
+	}
+	return true
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	if nil != p.Retryable {
+		return p.Retryable(err)
+	}
+	return DefaultRetryable(err)
+}
+
+// nextBackoff возвращает паузу перед следующей попыткой
+func (p *RetryPolicy) nextBackoff(backoff time.Duration) time.Duration {
+	if p.Multiplier > 1 {
+		backoff = time.Duration(float64(backoff) * p.Multiplier)
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	return backoff
+}
+
+// RetryStats счётчики обмена с платой (см. RetryStats у AnalogDevice, BinaryDevice, FreqDevice)
+type RetryStats struct {
+	Operations uint64 // количество операций
+	Retries    uint64 // количество повторных попыток
+	Failures   uint64 // количество операций, завершившихся ошибкой после всех попыток
+}
+
+type retryCounters struct {
+	operations atomic.Uint64
+	retries    atomic.Uint64
+	failures   atomic.Uint64
+}
+
+func (rc *retryCounters) get() RetryStats {
+	return RetryStats{
+		Operations: rc.operations.Load(),
+		Retries:    rc.retries.Load(),
+		Failures:   rc.failures.Load(),
+	}
+}
+
+func (rc *retryCounters) reset() {
+	rc.operations.Store(0)
+	rc.retries.Store(0)
+	rc.failures.Store(0)
+}
+
+// setRetryPolicy задаёт политику повторных попыток соединения
+func (c *usbConnection) setRetryPolicy(p RetryPolicy) {
+	c.policy.Store(&p)
+}
+
+// retryPolicy возвращает политику повторных попыток соединения
+func (c *usbConnection) retryPolicy() RetryPolicy {
+	if p := c.policy.Load(); nil != p {
+		return *p
+	}
+	return DefaultRetryPolicy()
+}
+
+// retry повторяет операцию op согласно политике соединения, пока она не выполнится успешно.
+// Попытки прекращаются при отмене ctx или по истечении его срока; если срок у ctx
+// не задан, то попытки делаются в течение RetryPolicy.Timeout.
+// При истечении срока возвращается последняя ошибка, относящаяся к виду ErrTimeout.
+func (c *usbConnection) retry(ctx context.Context, op func(ctx context.Context) error) (err error) {
+	p := c.retryPolicy()
+	if _, ok := ctx.Deadline(); !ok {
+		timeout := p.Timeout
+		if timeout <= 0 {
+			timeout = maxDelayUSB
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	c.stats.operations.Add(1)
+	backoff := p.Backoff
+	for attempt := 1; ; attempt++ {
+		lastErr := err
+		err = op(ctx)
+		if nil == err {
+			return
+		}
+		// попытка не дошла до платы из-за истечения срока: возвращаем ошибку предыдущей
+		if nil != lastErr && nil != ctx.Err() && errors.Is(err, ctx.Err()) {
+			err = lastErr
+		}
+		if nil != ctx.Err() || !p.retryable(err) || (p.MaxAttempts > 0 && attempt >= p.MaxAttempts) {
+			break
+		}
+		if !sleepCtx(ctx, backoff) {
+			break
+		}
+		backoff = p.nextBackoff(backoff)
+		c.stats.retries.Add(1)
+	}
+
+	switch ctx.Err() {
+	case nil:
+	case context.DeadlineExceeded:
+		err = wrapError(ErrTimeout, err)
+	default:
+		err = ctx.Err()
+	}
+	c.stats.failures.Add(1)
+	return
+}
+
+// sleepCtx ждёт d или отмены ctx. Возвращает false, если ctx отменён.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return nil == ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
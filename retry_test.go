@@ -0,0 +1,203 @@
+package ipk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errFlaky = fmt.Errorf("device not functioning:%w", ErrBadResponse)
+
+// flakyTransport завершает ошибкой err первые failures чтений данных ФЧС-3 (запрос 0xB0),
+// failures < 0 - все чтения. Запоминает время каждого чтения.
+type flakyTransport struct {
+	*FreqSimulator
+	mutex    sync.Mutex
+	failures int
+	err      error
+	calls    []time.Time
+}
+
+func (ft *flakyTransport) ControlIn(request byte, data []byte, timeout time.Duration) (int, error) {
+	if 0xB0 == request {
+		ft.mutex.Lock()
+		ft.calls = append(ft.calls, time.Now())
+		failed := ft.failures < 0 || len(ft.calls) <= ft.failures
+		ft.mutex.Unlock()
+		if failed {
+			return 0, ft.err
+		}
+	}
+	return ft.FreqSimulator.ControlIn(request, data, timeout)
+}
+
+func (ft *flakyTransport) attempts() int {
+	ft.mutex.Lock()
+	defer ft.mutex.Unlock()
+	return len(ft.calls)
+}
+
+func openFlaky(t *testing.T, failures int, err error, p RetryPolicy) (*FreqDevice, *flakyTransport) {
+	t.Helper()
+	ft := &flakyTransport{FreqSimulator: NewFreqSimulator(), failures: failures, err: err}
+	dev := new(FreqDevice)
+	if !dev.OpenTransport(ft) {
+		t.Fatal("OpenTransport() = false")
+	}
+	dev.SetRetryPolicy(p)
+	return dev, ft
+}
+
+func TestRetryAttempts(t *testing.T) {
+	invalid := fmt.Errorf("сбой:%w", ErrInvalidParam)
+	tests := []struct {
+		name      string
+		failures  int
+		err       error
+		policy    RetryPolicy
+		want      error // nil - операция выполнена
+		wantCalls int
+		wantStats RetryStats
+	}{
+		{"успех после сбоев", 2, errFlaky, RetryPolicy{MaxAttempts: 5}, nil, 3, RetryStats{1, 2, 0}},
+		{"MaxAttempts", -1, errFlaky, RetryPolicy{MaxAttempts: 3}, ErrBadResponse, 3, RetryStats{1, 2, 1}},
+		{"одна попытка", -1, errFlaky, RetryPolicy{MaxAttempts: 1}, ErrBadResponse, 1, RetryStats{1, 0, 1}},
+		{"неверный параметр не повторяется", -1, invalid, RetryPolicy{MaxAttempts: 5}, ErrInvalidParam, 1, RetryStats{1, 0, 1}},
+		{"отключение не повторяется", -1, fmt.Errorf("сбой:%w", ErrNotConnected), RetryPolicy{MaxAttempts: 5}, ErrNotConnected, 1, RetryStats{1, 0, 1}},
+		{"свой Retryable: не повторять", -1, errFlaky, RetryPolicy{MaxAttempts: 5, Retryable: func(err error) bool {
+			return !errors.Is(err, ErrBadResponse)
+		}}, ErrBadResponse, 1, RetryStats{1, 0, 1}},
+		{"свой Retryable: повторять неверный параметр", 2, invalid, RetryPolicy{MaxAttempts: 5, Retryable: func(err error) bool {
+			return true
+		}}, nil, 3, RetryStats{1, 2, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dev, ft := openFlaky(t, tt.failures, tt.err, tt.policy)
+			err := dev.UpdateFreqDataUSB()
+			if !errors.Is(err, tt.want) || (nil == tt.want) != (nil == err) {
+				t.Errorf("UpdateFreqDataUSB() = %v, want %v", err, tt.want)
+			}
+			if tt.wantCalls != ft.attempts() {
+				t.Errorf("попыток %d, want %d", ft.attempts(), tt.wantCalls)
+			}
+			if stats := dev.GetRetryStats(); tt.wantStats != stats {
+				t.Errorf("GetRetryStats() = %+v, want %+v", stats, tt.wantStats)
+			}
+			dev.ResetRetryStats()
+			if stats := dev.GetRetryStats(); (RetryStats{}) != stats {
+				t.Errorf("после ResetRetryStats() = %+v", stats)
+			}
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	const ms = time.Millisecond
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+		want   []time.Duration // паузы перед 2-й, 3-й и т.д. попытками
+	}{
+		{"рост до MaxBackoff", RetryPolicy{Backoff: ms, Multiplier: 2, MaxBackoff: 10 * ms}, []time.Duration{ms, 2 * ms, 4 * ms, 8 * ms, 10 * ms, 10 * ms}},
+		{"без MaxBackoff", RetryPolicy{Backoff: ms, Multiplier: 3}, []time.Duration{ms, 3 * ms, 9 * ms, 27 * ms}},
+		{"Multiplier меньше 1", RetryPolicy{Backoff: 2 * ms, Multiplier: 0.5}, []time.Duration{2 * ms, 2 * ms, 2 * ms}},
+		{"Backoff больше MaxBackoff", RetryPolicy{Backoff: 5 * ms, Multiplier: 2, MaxBackoff: 3 * ms}, []time.Duration{5 * ms, 3 * ms, 3 * ms}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []time.Duration
+			backoff := tt.policy.Backoff
+			for range tt.want {
+				got = append(got, backoff)
+				backoff = tt.policy.nextBackoff(backoff)
+			}
+			for i := range tt.want {
+				if tt.want[i] != got[i] {
+					t.Fatalf("паузы %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	// паузы между попытками обмена не короче заданных политикой
+	p := RetryPolicy{MaxAttempts: 5, Timeout: time.Second, Backoff: 2 * ms, Multiplier: 2, MaxBackoff: 5 * ms}
+	dev, ft := openFlaky(t, -1, errFlaky, p)
+	if err := dev.UpdateFreqDataUSB(); !errors.Is(err, ErrBadResponse) {
+		t.Fatalf("UpdateFreqDataUSB() = %v", err)
+	}
+	want := []time.Duration{2 * ms, 4 * ms, 5 * ms, 5 * ms}
+	if len(ft.calls) != len(want)+1 {
+		t.Fatalf("попыток %d, want %d", len(ft.calls), len(want)+1)
+	}
+	for i, w := range want {
+		if gap := ft.calls[i+1].Sub(ft.calls[i]); gap < w {
+			t.Errorf("пауза перед попыткой %d: %v, want не меньше %v", i+2, gap, w)
+		}
+	}
+}
+
+func TestRetryTimeout(t *testing.T) {
+	const timeout = 20 * time.Millisecond
+	dev, ft := openFlaky(t, -1, errFlaky, RetryPolicy{Timeout: timeout, Backoff: time.Millisecond})
+	start := time.Now()
+	err := dev.UpdateFreqDataUSB()
+	// ошибка относится к ErrTimeout и сохраняет последнюю ошибку обмена
+	if !errors.Is(err, ErrTimeout) || !errors.Is(err, ErrBadResponse) {
+		t.Errorf("UpdateFreqDataUSB() = %v, want %v и %v", err, ErrTimeout, ErrBadResponse)
+	}
+	if elapsed := time.Since(start); elapsed < timeout || elapsed > time.Second {
+		t.Errorf("попытки длились %v, want %v", elapsed, timeout)
+	}
+	if ft.attempts() < 2 {
+		t.Errorf("попыток %d", ft.attempts())
+	}
+	// последняя повторная попытка может не дойти до платы, если срок истёк сразу после паузы
+	if stats := dev.GetRetryStats(); 1 != stats.Failures ||
+		stats.Retries < uint64(ft.attempts()-1) || stats.Retries > uint64(ft.attempts()) {
+		t.Errorf("GetRetryStats() = %+v, попыток %d", stats, ft.attempts())
+	}
+
+	// срок ctx важнее RetryPolicy.Timeout
+	dev.SetRetryPolicy(RetryPolicy{Timeout: time.Minute, Backoff: time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start = time.Now()
+	if err := dev.UpdateFreqDataUSBCtx(ctx); !errors.Is(err, ErrTimeout) {
+		t.Errorf("UpdateFreqDataUSBCtx() = %v, want %v", err, ErrTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("попытки длились %v при сроке ctx %v", elapsed, timeout)
+	}
+}
+
+func TestRetryCancel(t *testing.T) {
+	dev, ft := openFlaky(t, -1, errFlaky, RetryPolicy{Timeout: time.Minute, Backoff: 5 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	start := time.Now()
+	err := dev.UpdateFreqDataUSBCtx(ctx)
+	if !errors.Is(err, context.Canceled) || errors.Is(err, ErrTimeout) {
+		t.Errorf("UpdateFreqDataUSBCtx() = %v, want %v", err, context.Canceled)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("попытки продолжались %v после отмены", elapsed)
+	}
+	// после отмены новых попыток нет
+	n := ft.attempts()
+	time.Sleep(20 * time.Millisecond)
+	if n != ft.attempts() {
+		t.Errorf("попыток после отмены: %d", ft.attempts()-n)
+	}
+
+	// отменённый заранее ctx - ни одной попытки
+	if err := dev.UpdateFreqDataUSBCtx(ctx); !errors.Is(err, context.Canceled) || n != ft.attempts() {
+		t.Errorf("UpdateFreqDataUSBCtx() = %v, попыток %d", err, ft.attempts()-n)
+	}
+}
@@ -0,0 +1,330 @@
+package ipk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var errUnknownTransfer = newError(ErrInvalidParam, `unknown deviceIoControl transfer`, `unknown deviceIoControl transfer`)
+var errNoTransport = newError(ErrNotConnected, `Transport == nil`, `Transport == nil`)
+
+// errUnknownRequest плата не знает запроса и отвечает на него STALL
+var errUnknownRequest = newError(ErrInvalidParam, `Неизвестный запрос`, `Unknown request`)
+
+// Transport - интерфейс обмена данными с платами ФПС-3.
+// Все платы управляются запросами производителя (vendor request) по нулевой
+// конечной точке, поэтому для работы с устройством достаточно двух операций:
+// чтения (VendorRequestInput) и записи (VendorRequestOutput).
+// Реализации: libusb (Linux), драйвер EZ-USB (Windows), симулятор, сетевой прокси и т.п.
+type Transport interface {
+	// ControlIn читает данные из устройства по запросу request.
+	// Возвращает количество прочитанных байт.
+	ControlIn(request byte, data []byte, timeout time.Duration) (n int, err error)
+	// ControlOut отправляет данные в устройство по запросу request.
+	// Возвращает количество отправленных байт.
+	ControlOut(request byte, data []byte, timeout time.Duration) (n int, err error)
+	// Close закрывает соединение с устройством.
+	Close() error
+}
+
+// presenceChecker может быть реализован транспортом, который умеет проверять,
+// подключено ли устройство физически (см. Active).
+type presenceChecker interface {
+	Present() bool
+}
+
+// lastOutput последняя команда, отправленная в плату, для восстановления после переподключения
+type lastOutput struct {
+	key     int
+	request byte
+	data    []byte
+}
+
+// usbConnection соединение с платой ФПС-3, общее для всех устройств.
+// Помимо транспорта хранит способ повторного открытия платы после отключения кабеля
+// и последние отправленные в плату команды (см. Supervisor).
+type usbConnection struct {
+	mutexUSB  sync.Mutex
+	mutexRMW  sync.Mutex // защищает чтение-изменение-запись данных платы от других горутин
+	transport Transport
+	detached  Transport                // транспорт, отключенный Supervisor, если его нельзя открыть заново
+	reopen    func() (Transport, bool) // повторное открытие платы, nil если плата открыта через OpenTransport
+	outputs   []lastOutput
+	lost      bool             // обмен завершился ошибкой "устройство отключено" (см. stale)
+	observer  transferObserver // наблюдатель за обменом (см. Recorder)
+	wrap      transportWrapper // обёртка транспорта (см. Capture), действует и после переподключения
+
+	policy atomic.Pointer[RetryPolicy] // политика повторных попыток, nil - DefaultRetryPolicy
+	stats  retryCounters
+
+	caps atomic.Pointer[Capabilities] // возможности платы, определённые при открытии
+}
+
+// attach устанавливает транспорт соединения.
+// reopen - функция повторного открытия платы; если nil, то после отключения
+// будет использован тот же самый транспорт.
+func (c *usbConnection) attach(t Transport, reopen func() (Transport, bool)) {
+	c.mutexUSB.Lock()
+	if nil != c.transport && unwrapTransport(c.transport) != t {
+		c.transport.Close()
+	}
+	if nil != c.wrap {
+		t = c.wrap(t)
+	}
+	c.transport = t
+	c.detached = nil
+	c.reopen = reopen
+	c.outputs = nil
+	c.lost = false
+	c.mutexUSB.Unlock()
+}
+
+// transferTimeout возвращает время ожидания одного обмена по USB:
+// оставшееся до срока ctx время, но не больше maxDelayUSB.
+func transferTimeout(ctx context.Context) time.Duration {
+	timeout := maxDelayUSB
+	if deadline, ok := ctx.Deadline(); ok {
+		if left := time.Until(deadline); left < timeout {
+			timeout = left
+		}
+		if timeout < time.Millisecond {
+			timeout = time.Millisecond
+		}
+	}
+	return timeout
+}
+
+// transferObserver получает каждый обмен с платой (см. Recorder).
+// data действительны только во время вызова.
+type transferObserver func(direction, request byte, data []byte, err error)
+
+// transfer выполняет потокобезопасный обмен данными с микроконтроллером.
+// Общая часть deviceIoControl для всех плат ФПС-3.
+func (c *usbConnection) transfer(ctx context.Context, direction, request byte, bytes []byte, length int) (err error) {
+	if length > len(bytes) {
+		length = len(bytes)
+	}
+	if err = ctx.Err(); nil != err {
+		return
+	}
+	c.mutexUSB.Lock()
+	observer := c.observer
+	switch {
+	case nil == c.transport:
+		err = fmt.Errorf("deviceIoControl():%w", errNoTransport)
+	case VendorRequestOutput == direction:
+		_, err = c.transport.ControlOut(request, bytes[:length], transferTimeout(ctx))
+	case VendorRequestInput == direction:
+		_, err = c.transport.ControlIn(request, bytes[:length], transferTimeout(ctx))
+	default:
+		err = errUnknownTransfer
+	}
+	// хэндл отключенного устройства недействителен, даже если оно уже подключено снова
+	if nil != c.transport && errors.Is(err, ErrNotConnected) {
+		c.lost = true
+	}
+	c.mutexUSB.Unlock()
+
+	if nil != observer {
+		observer(direction, request, bytes[:length], err)
+	}
+	return
+}
+
+// Transfer выполняет запрос производителя request к плате dev в обход остальных функций
+// библиотеки (например, для передачи обмена по сети, см. пакет ipkrpc).
+// Обмен выполняется под тем же мьютексом, что и все обращения к плате, и запоминается
+// для восстановления после переподключения, но без повторных попыток.
+func Transfer(ctx context.Context, dev Device, direction, request byte, data []byte) (err error) {
+	if 0 == len(data) {
+		err = fmt.Errorf("Transfer():%w", ErrInvalidParam)
+		return
+	}
+	if VendorRequestInput != direction && VendorRequestOutput != direction {
+		err = fmt.Errorf("Transfer():%w", errUnknownTransfer)
+		return
+	}
+	switch d := dev.(type) {
+	case *AnalogDevice:
+		err = d.deviceIoControl(ctx, direction, request, data, len(data))
+	case *BinaryDevice:
+		err = d.deviceIoControl(ctx, direction, request, data, len(data))
+	case *FreqDevice:
+		err = d.deviceIoControl(ctx, direction, request, data, len(data))
+	default:
+		err = fmt.Errorf("Transfer():%w", errNoTransport)
+	}
+	return
+}
+
+// observe устанавливает наблюдателя за обменом с платой, nil - убрать наблюдателя
+func (c *usbConnection) observe(observer transferObserver) {
+	c.mutexUSB.Lock()
+	c.observer = observer
+	c.mutexUSB.Unlock()
+}
+
+// transportWrapper оборачивает транспорт платы, например, для записи обмена (см. Capture)
+type transportWrapper func(t Transport) Transport
+
+// wrappedTransport реализуется транспортом-обёрткой, чтобы её можно было снять
+type wrappedTransport interface {
+	unwrap() Transport
+}
+
+// unwrapTransport возвращает исходный транспорт, снимая все обёртки
+func unwrapTransport(t Transport) Transport {
+	for {
+		w, ok := t.(wrappedTransport)
+		if !ok {
+			return t
+		}
+		t = w.unwrap()
+	}
+}
+
+// intercept оборачивает текущий транспорт соединения функцией wrap и запоминает её
+// для транспортов, открытых после переподключения. nil - снять обёртку.
+func (c *usbConnection) intercept(wrap transportWrapper) {
+	c.mutexUSB.Lock()
+	c.wrap = wrap
+	if nil != c.transport {
+		c.transport = unwrapTransport(c.transport)
+		if nil != wrap {
+			c.transport = wrap(c.transport)
+		}
+	}
+	if nil != c.detached {
+		c.detached = unwrapTransport(c.detached)
+		if nil != wrap {
+			c.detached = wrap(c.detached)
+		}
+	}
+	c.mutexUSB.Unlock()
+}
+
+// remember запоминает команду, отправленную в плату, под ключом key.
+// Более поздняя команда с тем же ключом заменяет предыдущую.
+func (c *usbConnection) remember(key int, request byte, data []byte) {
+	out := lastOutput{key: key, request: request, data: append([]byte(nil), data...)}
+	c.mutexUSB.Lock()
+	for i := range c.outputs {
+		if c.outputs[i].key == key {
+			c.outputs = append(c.outputs[:i], c.outputs[i+1:]...)
+			break
+		}
+	}
+	c.outputs = append(c.outputs, out)
+	c.mutexUSB.Unlock()
+}
+
+// connected показывает, установлено ли соединение
+func (c *usbConnection) connected() (ok bool) {
+	c.mutexUSB.Lock()
+	ok = nil != c.transport
+	c.mutexUSB.Unlock()
+	return
+}
+
+// present показывает, подключена ли плата физически
+func (c *usbConnection) present() (ok bool) {
+	c.mutexUSB.Lock()
+	t := c.transport
+	c.mutexUSB.Unlock()
+	return transportPresent(t)
+}
+
+// stale показывает, что соединение надо разорвать и открыть заново: плата отключена
+// или обмен с ней завершился ошибкой "устройство отключено" (например, кабель
+// переподключили между двумя проверками, и хэндл устройства больше недействителен).
+func (c *usbConnection) stale() bool {
+	c.mutexUSB.Lock()
+	lost := c.lost
+	c.mutexUSB.Unlock()
+	return lost || !c.present()
+}
+
+// close закрывает соединение. Повторно плата не открывается.
+func (c *usbConnection) close() {
+	c.mutexUSB.Lock()
+	if nil != c.transport {
+		c.transport.Close()
+	}
+	c.transport = nil
+	c.detached = nil
+	c.reopen = nil
+	c.outputs = nil
+	c.lost = false
+	c.mutexUSB.Unlock()
+}
+
+// detach разрывает соединение с отключенной платой, сохраняя возможность переподключения.
+func (c *usbConnection) detach() {
+	c.mutexUSB.Lock()
+	if nil != c.transport {
+		if nil != c.reopen {
+			c.transport.Close()
+		} else {
+			c.detached = c.transport
+		}
+	}
+	c.transport = nil
+	c.lost = false
+	c.mutexUSB.Unlock()
+}
+
+// canReconnect показывает, что соединение разорвано detach и может быть восстановлено
+func (c *usbConnection) canReconnect() (ok bool) {
+	c.mutexUSB.Lock()
+	ok = nil == c.transport && (nil != c.reopen || nil != c.detached)
+	c.mutexUSB.Unlock()
+	return
+}
+
+// reconnect пытается восстановить соединение после detach.
+// Если restore - true, то в плату повторно отправляются последние команды.
+func (c *usbConnection) reconnect(restore bool) (ok bool) {
+	c.mutexUSB.Lock()
+	defer c.mutexUSB.Unlock()
+
+	var t Transport
+	switch {
+	case nil != c.transport:
+		return true
+	case nil != c.reopen:
+		t, ok = c.reopen()
+		if ok && nil != c.wrap {
+			t = c.wrap(t)
+		}
+	case nil != c.detached:
+		t, ok = c.detached, transportPresent(c.detached)
+	}
+	if !ok {
+		return
+	}
+	c.transport = t
+	c.detached = nil
+
+	if restore {
+		for _, out := range c.outputs {
+			c.transport.ControlOut(out.request, out.data, maxDelayUSB)
+		}
+	}
+	return
+}
+
+// transportPresent показывает, подключено ли устройство, с которым работает транспорт t.
+// Если транспорт не умеет это проверять, считается, что устройство подключено.
+func transportPresent(t Transport) bool {
+	if nil == t {
+		return false
+	}
+	if pc, ok := t.(presenceChecker); ok {
+		return pc.Present()
+	}
+	return true
+}
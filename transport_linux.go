@@ -0,0 +1,192 @@
+package ipk
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gotmc/libusb"
+)
+
+// LibusbTransport реализует Transport поверх libusb.
+type LibusbTransport struct {
+	ctx     *libusb.Context // закрывается вместе с транспортом, если задан
+	handle  *libusb.DeviceHandle
+	product uint16
+	path    string // путь к плате (цепочка портов), если она открыта через OpenUSBTransportBy
+	bus     int    // номер шины открытого устройства
+	address int    // адрес открытого устройства на шине, 0 - неизвестен (см. Present)
+}
+
+// NewLibusbTransport создаёт транспорт для уже открытого хэндла устройства.
+// product - идентификатор продукта, используется для проверки подключения (см. Present).
+func NewLibusbTransport(handle *libusb.DeviceHandle, product uint16) *LibusbTransport {
+	return &LibusbTransport{handle: handle, product: product}
+}
+
+// OpenUSBTransport соединяет приложение с устройством по USB
+// и возвращает транспорт для работы с ним.
+func OpenUSBTransport(product uint16) (t Transport, ok bool) {
+	ctx, err := libusb.NewContext()
+	if err != nil {
+		return
+	}
+	device, handle, err := ctx.OpenDeviceWithVendorProduct(IDVendorElmeh, product)
+	if err != nil {
+		ctx.Close()
+		return
+	}
+	lt := &LibusbTransport{ctx: ctx, handle: handle, product: product}
+	lt.bus, _ = device.GetBusNumber()
+	lt.address, _ = device.GetDeviceAddress()
+	return lt, true
+}
+
+// OpenUSBTransportBy соединяет приложение с конкретной платой из списка Enumerate.
+// Плата ищется по пути (цепочке портов), а если задан серийный номер - ещё и по нему.
+func OpenUSBTransportBy(info DeviceInfo) (t Transport, ok bool) {
+	ctx, err := libusb.NewContext()
+	if err != nil {
+		return
+	}
+
+	list, err := ctx.GetDeviceList()
+	if err != nil {
+		ctx.Close()
+		return
+	}
+
+	for _, device := range list {
+		found, desc, isIPK := deviceInfo(device)
+		if !isIPK || found.ProductID != info.ProductID || ("" != info.Path && found.Path != info.Path) {
+			continue
+		}
+		handle, err := device.Open()
+		if err != nil {
+			continue
+		}
+		// серийный номер читается через тот же хэндл, с которым затем работает транспорт
+		if "" != info.Serial && deviceSerial(handle, desc) != info.Serial {
+			handle.Close()
+			continue
+		}
+		t = &LibusbTransport{ctx: ctx, handle: handle, product: info.ProductID, path: found.Path, bus: found.Bus, address: found.Address}
+		ok = true
+		return
+	}
+
+	ctx.Close()
+	return
+}
+
+// ControlIn читает данные из устройства по запросу request.
+func (t *LibusbTransport) ControlIn(request byte, data []byte, timeout time.Duration) (n int, err error) {
+	return t.control(VendorRequestInput, request, data, timeout)
+}
+
+// ControlOut отправляет данные в устройство по запросу request.
+func (t *LibusbTransport) ControlOut(request byte, data []byte, timeout time.Duration) (n int, err error) {
+	return t.control(VendorRequestOutput, request, data, timeout)
+}
+
+func (t *LibusbTransport) control(direction, request byte, data []byte, timeout time.Duration) (n int, err error) {
+	if nil == t || nil == t.handle {
+		err = fmt.Errorf("LibusbTransport:%w", errNoTransport)
+		return
+	}
+	if 0 == len(data) {
+		err = fmt.Errorf("LibusbTransport:%w", ErrInvalidParam)
+		return
+	}
+	switch direction {
+	case VendorRequestOutput:
+		n, err = t.handle.ControlTransfer(VendorRequestOutput, request, 0, 0, data, len(data), int(timeout.Milliseconds()))
+	case VendorRequestInput:
+		n, err = t.handle.ControlTransfer(VendorRequestInput, request, 0, 0, data, len(data), int(timeout.Milliseconds()))
+	default:
+		err = errUnknownTransfer
+	}
+	err = libusbError(err)
+	return
+}
+
+// коды ошибок libusb (libusb_error)
+const (
+	libusbErrorNoDevice = libusb.ErrorCode(-4)
+	libusbErrorTimeout  = libusb.ErrorCode(-7)
+	libusbErrorPipe     = libusb.ErrorCode(-9)
+)
+
+// libusbError относит ошибку libusb к одному из общих видов ошибок (ErrTimeout, ErrNotConnected).
+// STALL в ответ на запрос (LIBUSB_ERROR_PIPE) означает, что плата не знает запроса.
+func libusbError(err error) error {
+	code, ok := err.(libusb.ErrorCode)
+	if !ok {
+		return err
+	}
+	switch code {
+	case libusbErrorTimeout:
+		return wrapError(ErrTimeout, err)
+	case libusbErrorNoDevice:
+		return wrapError(ErrNotConnected, err)
+	case libusbErrorPipe:
+		return wrapError(errUnknownRequest, err)
+	}
+	return err
+}
+
+// Close закрывает хэндл устройства.
+func (t *LibusbTransport) Close() (err error) {
+	if nil == t || nil == t.handle {
+		return
+	}
+	err = t.handle.Close()
+	t.handle = nil
+	if nil != t.ctx {
+		t.ctx.Close()
+		t.ctx = nil
+	}
+	return
+}
+
+// Present показывает, подключено ли к компьютеру то самое устройство, которое открыто транспортом:
+// с тем же идентификатором продукта, на той же шине и с тем же адресом. При переподключении кабеля
+// устройство получает новый адрес, поэтому Present возвращает false, даже если плата вернулась
+// между двумя проверками: её старый хэндл уже недействителен.
+// Для транспорта, созданного NewLibusbTransport, адрес неизвестен и проверяется только наличие
+// устройства с тем же идентификатором продукта.
+func (t *LibusbTransport) Present() (ok bool) {
+	if nil == t {
+		return
+	}
+
+	ctx, err := libusb.NewContext()
+	if err != nil {
+		return
+	}
+	defer ctx.Close()
+
+	devices, err := ctx.GetDeviceList()
+	if err != nil {
+		return
+	}
+
+	for _, device := range devices {
+		usbDeviceDescriptor, err := device.GetDeviceDescriptor()
+		if err != nil {
+			continue
+		}
+
+		if usbDeviceDescriptor.VendorID == IDVendorElmeh && usbDeviceDescriptor.ProductID == t.product {
+			if 0 != t.address {
+				bus, _ := device.GetBusNumber()
+				address, _ := device.GetDeviceAddress()
+				if bus != t.bus || address != t.address {
+					continue
+				}
+			}
+			ok = true
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,108 @@
+package ipk
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// EZUSBTransport реализует Transport через драйвер EZ-USB (\\.\ezusb-N).
+type EZUSBTransport struct {
+	handle  windows.Handle
+	product uint16
+}
+
+// NewEZUSBTransport создаёт транспорт для уже открытого хэндла устройства.
+// product - идентификатор продукта, используется для проверки подключения (см. Present).
+func NewEZUSBTransport(handle windows.Handle, product uint16) *EZUSBTransport {
+	return &EZUSBTransport{handle: handle, product: product}
+}
+
+// OpenUSBTransport соединяет приложение с устройством по USB
+// и возвращает транспорт для работы с ним.
+func OpenUSBTransport(product uint16) (t Transport, ok bool) {
+	handle, ok := USBOpen(product)
+	if ok {
+		t = NewEZUSBTransport(handle, product)
+	}
+	return
+}
+
+// OpenUSBTransportBy соединяет приложение с конкретной платой из списка Enumerate.
+// Плата открывается по пути \\.\ezusb-N; если путь не задан - открывается первая подходящая плата.
+func OpenUSBTransportBy(info DeviceInfo) (t Transport, ok bool) {
+	if "" == info.Path {
+		return OpenUSBTransport(info.ProductID)
+	}
+	handle, ok := openEZUSB(info.Path)
+	if !ok {
+		return
+	}
+	vendorID, productID := GetVendorProduct(handle)
+	if IDVendorElmeh != vendorID || info.ProductID != productID {
+		windows.CloseHandle(handle)
+		ok = false
+		return
+	}
+	t = NewEZUSBTransport(handle, productID)
+	return
+}
+
+// ControlIn читает данные из устройства по запросу request.
+// Драйвер EZ-USB не позволяет задать время ожидания, поэтому timeout не используется.
+func (t *EZUSBTransport) ControlIn(request byte, data []byte, timeout time.Duration) (n int, err error) {
+	return t.control(MakeVendorOrClassRequestControlStruct(1, 2, 0, request), data)
+}
+
+// ControlOut отправляет данные в устройство по запросу request.
+// Драйвер EZ-USB не позволяет задать время ожидания, поэтому timeout не используется.
+func (t *EZUSBTransport) ControlOut(request byte, data []byte, timeout time.Duration) (n int, err error) {
+	return t.control(MakeVendorOrClassRequestControlStruct(0, 2, 0, request), data)
+}
+
+func (t *EZUSBTransport) control(vcrq []byte, data []byte) (n int, err error) {
+	if nil == t || windows.InvalidHandle == t.handle {
+		err = fmt.Errorf("EZUSBTransport:%w", errNoTransport)
+		return
+	}
+	if 0 == len(data) {
+		err = fmt.Errorf("EZUSBTransport:%w", ErrInvalidParam)
+		return
+	}
+	var bytesReturned uint32
+	err = windows.DeviceIoControl(t.handle, IoctlEZUSBVendorOrClassRequest(), &vcrq[0], uint32(len(vcrq)), &data[0], uint32(len(data)), &bytesReturned, nil)
+	n = int(bytesReturned)
+	err = ezusbError(err)
+	return
+}
+
+// ezusbError относит ошибку драйвера EZ-USB к одному из общих видов ошибок (ErrTimeout, ErrNotConnected)
+func ezusbError(err error) error {
+	switch err {
+	case windows.ERROR_SEM_TIMEOUT:
+		return wrapError(ErrTimeout, err)
+	case windows.ERROR_DEVICE_NOT_CONNECTED, windows.ERROR_INVALID_HANDLE, windows.ERROR_FILE_NOT_FOUND:
+		return wrapError(ErrNotConnected, err)
+	}
+	return err
+}
+
+// Close закрывает хэндл устройства.
+func (t *EZUSBTransport) Close() (err error) {
+	if nil == t || windows.InvalidHandle == t.handle {
+		return
+	}
+	err = windows.CloseHandle(t.handle)
+	t.handle = windows.InvalidHandle
+	return
+}
+
+// Present показывает, отвечает ли устройство на запрос дескриптора и совпадает ли идентификатор продукта.
+func (t *EZUSBTransport) Present() (ok bool) {
+	if nil == t || windows.InvalidHandle == t.handle {
+		return
+	}
+	vendorID, productID := GetVendorProduct(t.handle)
+	return IDVendorElmeh == vendorID && t.product == productID
+}
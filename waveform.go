@@ -0,0 +1,162 @@
+package ipk
+
+import (
+	"math"
+	"time"
+)
+
+// Waveform описывает изменение величины во времени (ток в мА, давление и т.д.).
+// Используется генератором Generator для вывода на каналы ЦАП ФАС-3.
+type Waveform interface {
+	// Value возвращает значение в момент t от начала формы.
+	// done - true, если форма закончилась и значение больше не меняется.
+	Value(t time.Duration) (val float64, done bool)
+}
+
+// Ramp линейное изменение от From до To за время Duration.
+// После окончания держится значение To.
+type Ramp struct {
+	From, To float64
+	Duration time.Duration
+}
+
+// Value реализует Waveform
+func (r Ramp) Value(t time.Duration) (val float64, done bool) {
+	if t >= r.Duration || r.Duration <= 0 {
+		return r.To, true
+	}
+	if t < 0 {
+		t = 0
+	}
+	val = r.From + (r.To-r.From)*float64(t)/float64(r.Duration)
+	return
+}
+
+// Sine синусоида Offset + Amplitude*sin(2π·t/Period + Phase).
+// Если Duration больше 0, форма заканчивается через Duration, иначе продолжается бесконечно.
+type Sine struct {
+	Offset, Amplitude float64
+	Period            time.Duration
+	Phase             float64 // начальная фаза в радианах
+	Duration          time.Duration
+}
+
+// Value реализует Waveform
+func (s Sine) Value(t time.Duration) (val float64, done bool) {
+	if s.Duration > 0 && t >= s.Duration {
+		t, done = s.Duration, true
+	}
+	if s.Period <= 0 {
+		return s.Offset, done
+	}
+	val = s.Offset + s.Amplitude*math.Sin(2*math.Pi*float64(t)/float64(s.Period)+s.Phase)
+	return
+}
+
+// Triangle треугольный сигнал: за первую половину периода значение растёт от Min до Max,
+// за вторую - падает обратно до Min.
+// Если Duration больше 0, форма заканчивается через Duration, иначе продолжается бесконечно.
+type Triangle struct {
+	Min, Max float64
+	Period   time.Duration
+	Duration time.Duration
+}
+
+// Value реализует Waveform
+func (tr Triangle) Value(t time.Duration) (val float64, done bool) {
+	if tr.Duration > 0 && t >= tr.Duration {
+		t, done = tr.Duration, true
+	}
+	if tr.Period <= 0 {
+		return tr.Min, done
+	}
+	phase := float64(t%tr.Period) / float64(tr.Period) // от 0 до 1
+	if phase < 0.5 {
+		val = tr.Min + (tr.Max-tr.Min)*phase*2
+	} else {
+		val = tr.Max - (tr.Max-tr.Min)*(phase-0.5)*2
+	}
+	return
+}
+
+// Step одна ступень последовательности Steps: значение Value держится в течение Hold.
+type Step struct {
+	Value float64
+	Hold  time.Duration
+}
+
+// Steps последовательность ступенек. Если Repeat - true, последовательность повторяется,
+// иначе после последней ступеньки держится её значение.
+type Steps struct {
+	Steps  []Step
+	Repeat bool
+}
+
+// Value реализует Waveform
+func (st Steps) Value(t time.Duration) (val float64, done bool) {
+	if 0 == len(st.Steps) {
+		return 0, true
+	}
+	var total time.Duration
+	for _, s := range st.Steps {
+		total += s.Hold
+	}
+	if total <= 0 {
+		return st.Steps[len(st.Steps)-1].Value, true
+	}
+	if st.Repeat {
+		t %= total
+	} else if t >= total {
+		return st.Steps[len(st.Steps)-1].Value, true
+	}
+	for _, s := range st.Steps {
+		if t < s.Hold {
+			return s.Value, false
+		}
+		t -= s.Hold
+	}
+	return st.Steps[len(st.Steps)-1].Value, false
+}
+
+// TablePoint точка таблицы Table: значение Value в момент At от начала формы.
+type TablePoint struct {
+	At    time.Duration
+	Value float64
+}
+
+// Table форма, заданная таблицей точек (время, значение), упорядоченных по времени.
+// Между точками значение изменяется линейно, если Interpolate - true,
+// иначе держится значение предыдущей точки. До первой точки выводится её значение,
+// после последней - значение последней точки. Если Repeat - true, таблица повторяется.
+type Table struct {
+	Points      []TablePoint
+	Interpolate bool
+	Repeat      bool
+}
+
+// Value реализует Waveform
+func (tb Table) Value(t time.Duration) (val float64, done bool) {
+	n := len(tb.Points)
+	if 0 == n {
+		return 0, true
+	}
+	last := tb.Points[n-1]
+	if tb.Repeat && last.At > 0 {
+		t %= last.At
+	} else if t >= last.At {
+		return last.Value, true
+	}
+	if t <= tb.Points[0].At {
+		return tb.Points[0].Value, false
+	}
+	for i := 1; i < n; i++ {
+		p0, p1 := tb.Points[i-1], tb.Points[i]
+		if t < p1.At {
+			if !tb.Interpolate || p1.At <= p0.At {
+				return p0.Value, false
+			}
+			return p0.Value + (p1.Value-p0.Value)*float64(t-p0.At)/float64(p1.At-p0.At), false
+		}
+	}
+	return last.Value, false
+}